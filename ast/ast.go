@@ -11,37 +11,29 @@ import (
 
 // The Node interface is implemented by all nodes.
 type Node interface {
-	// Start returns the start location of the first token that induced
+	// Start returns the start position of the first token that induced
 	// this node.
-	Start() token.Location
+	Start() token.Pos
 
-	// End returns the end location of the final token that induced
+	// End returns the end position of the final token that induced
 	// this node.
-	End() token.Location
+	End() token.Pos
 }
 
 // File is a node representing a Go source file.
 type File struct {
 	comments
-	startLoc, endLoc token.Location
+	startLoc, endLoc token.Pos
 	PackageName      Identifier
 	Imports          []ImportDecl
 	Declarations
 
 	// Syms is the symbol table for the scope of this source file.
-	syms *symtab
+	syms *Scope
 }
 
-func (n *File) Start() token.Location { return n.PackageName.Start() }
-func (n *File) End() token.Location {
-	if l := len(n.Declarations); l > 0 {
-		return n.Declarations[l-1].End()
-	}
-	if l := len(n.Imports); l > 0 {
-		return n.Imports[l-1].End()
-	}
-	return n.PackageName.End()
-}
+func (n *File) Start() token.Pos { return n.startLoc }
+func (n *File) End() token.Pos   { return n.endLoc }
 
 // A Statement is a node representing a statement.
 type Statement interface {
@@ -49,6 +41,12 @@ type Statement interface {
 	// Comments returns the comments appearing before this
 	// declaration without an intervening blank line.
 	Comments() []string
+
+	// check checks the statement, returning any errors. The syms
+	// argument is the symbol table for the innermost enclosing
+	// block, and sig is the signature of the innermost enclosing
+	// function or function literal.
+	check(syms *Scope, sig *Signature) error
 }
 
 // A Comments implements the Comments method of the Declaration
@@ -60,16 +58,19 @@ func (c comments) Comments() []string { return []string(c) }
 // A Select represents a select statement.
 type Select struct {
 	comments
-	startLoc, endLoc token.Location
+	startLoc, endLoc token.Pos
 	Cases            []CommCase
 }
 
-func (n *Select) Start() token.Location { return n.startLoc }
-func (n *Select) End() token.Location   { return n.endLoc }
+func (n *Select) Start() token.Pos { return n.startLoc }
+func (n *Select) End() token.Pos   { return n.endLoc }
 
 // A CommCase represents a single communication case in a select statement.
 // It is one of: a receive clause, a send clause, or a default clause.
 type CommCase struct {
+	// StartLoc is the location of the case or default keyword, and
+	// endLoc is the location of the token following the clause.
+	startLoc, endLoc token.Pos
 	// Receive is non-nil if this is a receive communication case.
 	Receive *RecvStmt
 	// Send is non-nil if this is a send communication case.
@@ -79,6 +80,9 @@ type CommCase struct {
 	Statements []Statement
 }
 
+func (n *CommCase) Start() token.Pos { return n.startLoc }
+func (n *CommCase) End() token.Pos   { return n.endLoc }
+
 // RecvStmt represents a receive statement in a communication clause
 // of a select statement.
 type RecvStmt struct {
@@ -95,13 +99,13 @@ type RecvStmt struct {
 	Right UnaryOp
 }
 
-func (n *RecvStmt) Start() token.Location { return n.Left[0].Start() }
-func (n *RecvStmt) End() token.Location   { return n.Right.End() }
+func (n *RecvStmt) Start() token.Pos { return n.Left[0].Start() }
+func (n *RecvStmt) End() token.Pos   { return n.Right.End() }
 
 // An ExprSwitch represents an expression switch statement.
 type ExprSwitch struct {
 	comments
-	startLoc, endLoc token.Location
+	startLoc, endLoc token.Pos
 	// Initialization is nil if there is no initialization for the switch.
 	Initialization Statement
 	// Expression is nil if there is no expression for the switch.
@@ -109,20 +113,26 @@ type ExprSwitch struct {
 	Cases      []ExprCase
 }
 
-func (n *ExprSwitch) Start() token.Location { return n.startLoc }
-func (n *ExprSwitch) End() token.Location   { return n.endLoc }
+func (n *ExprSwitch) Start() token.Pos { return n.startLoc }
+func (n *ExprSwitch) End() token.Pos   { return n.endLoc }
 
 // An ExprCase represents a case label for an expression switch statement.
 type ExprCase struct {
+	// StartLoc is the location of the case or default keyword, and
+	// endLoc is the location of the token following the clause.
+	startLoc, endLoc token.Pos
 	// The default case is represented by len(Expressions)==0.
 	Expressions []Expression
 	Statements  []Statement
 }
 
+func (n *ExprCase) Start() token.Pos { return n.startLoc }
+func (n *ExprCase) End() token.Pos   { return n.endLoc }
+
 // A TypeSwitch represents a type switch statement.
 type TypeSwitch struct {
 	comments
-	startLoc, endLoc token.Location
+	startLoc, endLoc token.Pos
 	// Initialization is nil if there is no initialization for the switch.
 	Initialization Statement
 	// Declaration is the identifier declared in a type switch with a
@@ -135,20 +145,26 @@ type TypeSwitch struct {
 	Cases      []TypeCase
 }
 
-func (n *TypeSwitch) Start() token.Location { return n.startLoc }
-func (n *TypeSwitch) End() token.Location   { return n.endLoc }
+func (n *TypeSwitch) Start() token.Pos { return n.startLoc }
+func (n *TypeSwitch) End() token.Pos   { return n.endLoc }
 
 // A TypeCase represents a case label in a type switch statement.
 type TypeCase struct {
+	// StartLoc is the location of the case or default keyword, and
+	// endLoc is the location of the token following the clause.
+	startLoc, endLoc token.Pos
 	// The default case is represented by len(Types)==0.
 	Types      []Type
 	Statements []Statement
 }
 
+func (n *TypeCase) Start() token.Pos { return n.startLoc }
+func (n *TypeCase) End() token.Pos   { return n.endLoc }
+
 // A ForStmt is a statement node representing a for loop.
 type ForStmt struct {
 	comments
-	startLoc token.Location
+	startLoc token.Pos
 
 	// Block is the body of the for loop.
 	Block BlockStmt
@@ -168,13 +184,13 @@ type ForStmt struct {
 	Post Statement
 }
 
-func (n *ForStmt) Start() token.Location { return n.startLoc }
-func (n *ForStmt) End() token.Location   { return n.Block.End() }
+func (n *ForStmt) Start() token.Pos { return n.startLoc }
+func (n *ForStmt) End() token.Pos   { return n.Block.End() }
 
 // An IfStmt is a statement node representing an if statement.
 type IfStmt struct {
 	comments
-	startLoc token.Location
+	startLoc token.Pos
 	// Statement is a simple statement evaluated before the condition.
 	Statement Statement
 	Condition Expression
@@ -185,9 +201,9 @@ type IfStmt struct {
 	Else Statement
 }
 
-func (n *IfStmt) Start() token.Location { return n.startLoc }
+func (n *IfStmt) Start() token.Pos { return n.startLoc }
 
-func (n *IfStmt) End() token.Location {
+func (n *IfStmt) End() token.Pos {
 	if n.Else != nil {
 		return n.Else.End()
 	}
@@ -197,85 +213,85 @@ func (n *IfStmt) End() token.Location {
 // A BlockStmt is a statement node representing block of statements.
 type BlockStmt struct {
 	comments
-	startLoc, endLoc token.Location
+	startLoc, endLoc token.Pos
 	Statements       []Statement
 }
 
-func (n *BlockStmt) Start() token.Location { return n.startLoc }
-func (n *BlockStmt) End() token.Location   { return n.endLoc }
+func (n *BlockStmt) Start() token.Pos { return n.startLoc }
+func (n *BlockStmt) End() token.Pos   { return n.endLoc }
 
 // A DeferStmt is a statement node representing a defer statement.
 type DeferStmt struct {
 	comments
-	startLoc   token.Location
+	startLoc   token.Pos
 	Expression Expression
 }
 
-func (n *DeferStmt) Start() token.Location { return n.startLoc }
-func (n *DeferStmt) End() token.Location   { return n.Expression.End() }
+func (n *DeferStmt) Start() token.Pos { return n.startLoc }
+func (n *DeferStmt) End() token.Pos   { return n.Expression.End() }
 
 // A GoStmt is a statement node representing a go statement.
 type GoStmt struct {
 	comments
-	startLoc   token.Location
+	startLoc   token.Pos
 	Expression Expression
 }
 
-func (n *GoStmt) Start() token.Location { return n.startLoc }
-func (n *GoStmt) End() token.Location   { return n.Expression.End() }
+func (n *GoStmt) Start() token.Pos { return n.startLoc }
+func (n *GoStmt) End() token.Pos   { return n.Expression.End() }
 
 // A ReturnStmt is a statement node representing a return.
 type ReturnStmt struct {
 	comments
-	startLoc, endLoc token.Location
+	startLoc, endLoc token.Pos
 	Expressions      []Expression
 }
 
-func (n *ReturnStmt) Start() token.Location { return n.startLoc }
-func (n *ReturnStmt) End() token.Location   { return n.endLoc }
+func (n *ReturnStmt) Start() token.Pos { return n.startLoc }
+func (n *ReturnStmt) End() token.Pos   { return n.endLoc }
 
 // A FallthroughStmt is a statement node representing a fallthrough.
 type FallthroughStmt struct {
 	comments
-	startLoc, endLoc token.Location
+	startLoc, endLoc token.Pos
 }
 
-func (n *FallthroughStmt) Start() token.Location { return n.startLoc }
-func (n *FallthroughStmt) End() token.Location   { return n.endLoc }
+func (n *FallthroughStmt) Start() token.Pos { return n.startLoc }
+func (n *FallthroughStmt) End() token.Pos   { return n.endLoc }
 
 // A ContinueStmt is a statement node representing a continue
 // statement with on optional label.
 type ContinueStmt struct {
 	comments
-	startLoc token.Location
+	startLoc token.Pos
 	// Label is nil if no label was specified.
 	Label *Identifier
 }
 
-func (n *ContinueStmt) Start() token.Location { return n.startLoc }
-func (n *ContinueStmt) End() token.Location   { return n.Label.End() }
+func (n *ContinueStmt) Start() token.Pos { return n.startLoc }
+func (n *ContinueStmt) End() token.Pos   { return n.Label.End() }
 
 // A BreakStmt is a statement node represent a break statement
 // with on optional label.
 type BreakStmt struct {
 	comments
-	startLoc token.Location
+	startLoc token.Pos
 	// Label is nil if no label was specified.
 	Label *Identifier
 }
 
-func (n *BreakStmt) Start() token.Location { return n.startLoc }
-func (n *BreakStmt) End() token.Location   { return n.Label.End() }
+func (n *BreakStmt) Start() token.Pos { return n.startLoc }
+func (n *BreakStmt) End() token.Pos   { return n.Label.End() }
 
 // A GotoStmt is a statement node representing a goto.
 type GotoStmt struct {
 	comments
-	startLoc token.Location
+	startLoc token.Pos
 	Label    Identifier
 }
 
-func (n *GotoStmt) Start() token.Location { return n.startLoc }
-func (n *GotoStmt) End() token.Location   { return n.Label.End() }
+func (n *GotoStmt) Start() token.Pos { return n.startLoc }
+func (n *GotoStmt) End() token.Pos   { return n.Label.End() }
 
 // A LabeledStmt is a statement node representing a statement
 // that is preceeded by a label.
@@ -285,8 +301,8 @@ type LabeledStmt struct {
 	Statement Statement
 }
 
-func (n *LabeledStmt) Start() token.Location { return n.Label.Start() }
-func (n *LabeledStmt) End() token.Location   { return n.Statement.End() }
+func (n *LabeledStmt) Start() token.Pos { return n.Label.Start() }
+func (n *LabeledStmt) End() token.Pos   { return n.Statement.End() }
 
 // A DeclarationStmt is a statement node representing a series of declarations.
 type DeclarationStmt struct {
@@ -302,8 +318,8 @@ type ShortVarDecl struct {
 	Right []Expression
 }
 
-func (n *ShortVarDecl) Start() token.Location { return n.Left[0].Start() }
-func (n *ShortVarDecl) End() token.Location   { return n.Right[len(n.Right)-1].End() }
+func (n *ShortVarDecl) Start() token.Pos { return n.Left[0].Start() }
+func (n *ShortVarDecl) End() token.Pos   { return n.Right[len(n.Right)-1].End() }
 
 // An Assignment is a statement node representing an assignment of
 // a sequence of expressions.
@@ -315,8 +331,8 @@ type Assignment struct {
 	Right []Expression
 }
 
-func (n *Assignment) Start() token.Location { return n.Left[0].Start() }
-func (n *Assignment) End() token.Location   { return n.Right[len(n.Right)-1].End() }
+func (n *Assignment) Start() token.Pos { return n.Left[0].Start() }
+func (n *Assignment) End() token.Pos   { return n.Right[len(n.Right)-1].End() }
 
 // An ExpressionStmt is a statement node representing an
 // expression evaluation
@@ -325,8 +341,19 @@ type ExpressionStmt struct {
 	Expression Expression
 }
 
-func (n *ExpressionStmt) Start() token.Location { return n.Expression.Start() }
-func (n *ExpressionStmt) End() token.Location   { return n.Expression.End() }
+func (n *ExpressionStmt) Start() token.Pos { return n.Expression.Start() }
+func (n *ExpressionStmt) End() token.Pos   { return n.Expression.End() }
+
+// A BadStmt is a statement node representing source that could not be
+// parsed as a statement. It spans the source skipped by the parser
+// when recovering from the syntax error.
+type BadStmt struct {
+	comments
+	startLoc, endLoc token.Pos
+}
+
+func (n *BadStmt) Start() token.Pos { return n.startLoc }
+func (n *BadStmt) End() token.Pos   { return n.endLoc }
 
 // An IncDecStmt is a statement node representing either an
 // increment or a decrement operation.
@@ -336,11 +363,11 @@ type IncDecStmt struct {
 	// Op is either token.PlusPlus or token.MinusMinus, representing
 	// either increment or decrement respectively.
 	Op    token.Token
-	opEnd token.Location
+	opEnd token.Pos
 }
 
-func (n *IncDecStmt) Start() token.Location { return n.Expression.Start() }
-func (n *IncDecStmt) End() token.Location   { return n.opEnd }
+func (n *IncDecStmt) Start() token.Pos { return n.Expression.Start() }
+func (n *IncDecStmt) End() token.Pos   { return n.opEnd }
 
 // A SendStmt is a statement node representing the sending of
 // an expression on a channel.
@@ -350,8 +377,8 @@ type SendStmt struct {
 	Expression Expression
 }
 
-func (n *SendStmt) Start() token.Location { return n.Channel.Start() }
-func (n *SendStmt) End() token.Location   { return n.Expression.End() }
+func (n *SendStmt) Start() token.Pos { return n.Channel.Start() }
+func (n *SendStmt) End() token.Pos   { return n.Expression.End() }
 
 // A Declaration is a node representing a declaration.
 type Declaration interface {
@@ -365,19 +392,30 @@ type Declaration interface {
 // A Declarations is node representing a non-empty list of declarations.
 type Declarations []Declaration
 
-func (n Declarations) Start() token.Location { return n[0].Start() }
-func (n Declarations) End() token.Location   { return n[len(n)-1].End() }
+func (n Declarations) Start() token.Pos { return n[0].Start() }
+func (n Declarations) End() token.Pos   { return n[len(n)-1].End() }
+
+// A BadDecl is a declaration node representing source that could not be
+// parsed as a declaration. It spans the source skipped by the parser
+// when recovering from the syntax error.
+type BadDecl struct {
+	comments
+	startLoc, endLoc token.Pos
+}
+
+func (n *BadDecl) Start() token.Pos { return n.startLoc }
+func (n *BadDecl) End() token.Pos   { return n.endLoc }
 
 // An ImportDecl is a declaration node representing the declaration of
 // a set of package imports.
 type ImportDecl struct {
 	comments
-	startLoc, endLoc token.Location
+	startLoc, endLoc token.Pos
 	Imports          []ImportSpec
 }
 
-func (n *ImportDecl) Start() token.Location { return n.startLoc }
-func (n *ImportDecl) End() token.Location   { return n.endLoc }
+func (n *ImportDecl) Start() token.Pos { return n.startLoc }
+func (n *ImportDecl) End() token.Pos   { return n.endLoc }
 
 // An ImportSpec represents the import of a single package.
 type ImportSpec struct {
@@ -385,8 +423,20 @@ type ImportSpec struct {
 	// If Identifier is nil, the import name is the last path element.
 	*Identifier
 	Path StringLiteral
+
+	// Pkg is the declaration of the imported package.
+	pkg *packageDecl
+}
+
+func (n *ImportSpec) Start() token.Pos {
+	if n.Identifier != nil {
+		return n.Identifier.Start()
+	}
+	return n.Path.Start()
 }
 
+func (n *ImportSpec) End() token.Pos { return n.Path.End() }
+
 // Name returns the name to which this import is bound.
 func (n *ImportSpec) Name() string {
 	if n.Identifier != nil {
@@ -398,37 +448,57 @@ func (n *ImportSpec) Name() string {
 // A MethodDecl is a declaration node representing a method declaration.
 type MethodDecl struct {
 	comments
-	startLoc     token.Location
+	startLoc     token.Pos
 	Receiver     Identifier
 	Pointer      bool
 	BaseTypeName Identifier
+	// ReceiverTypeParameters are the names given to the type parameters
+	// of a generic base type, or nil if the base type is not generic.
+	ReceiverTypeParameters []Identifier
 	Identifier
 	Signature
 	Body BlockStmt
 
 	// Syms is the file-level symbol table defining the scope in which this
 	// method was declared.
-	syms *symtab
+	syms *Scope
+
+	// Fsyms is the scope of the receiver, parameters, and results, and of
+	// the receiver type parameters. It is created when the signature is checked.
+	fsyms *Scope
+
+	// Recv is the declaration of the receiver. It is set by the Check pass.
+	recv *ParameterDecl
+
+	state checkState
 }
 
-func (n *MethodDecl) Start() token.Location { return n.startLoc }
-func (n *MethodDecl) End() token.Location   { return n.Body.End() }
+func (n *MethodDecl) Start() token.Pos { return n.startLoc }
+func (n *MethodDecl) End() token.Pos   { return n.Body.End() }
 
 // A FunctionDecl is a declaration node representing a function declaration.
 type FunctionDecl struct {
 	comments
-	startLoc token.Location
+	startLoc token.Pos
 	Identifier
+	// TypeParameters is nil if the function is not generic.
+	TypeParameters []TypeParameter
 	Signature
 	Body BlockStmt
 
 	// Syms is the file-level symbol table defining the scope in which this
 	// function was declared, or nil if this is not a package-level function.
-	syms *symtab
+	syms *Scope
+
+	// Fsyms is the scope of the type parameters, parameters, and results.
+	// It is created when the signature is checked.
+	fsyms *Scope
+
+	state checkState
 }
 
-func (n *FunctionDecl) Start() token.Location { return n.startLoc }
-func (n *FunctionDecl) End() token.Location   { return n.Body.End() }
+func (n *FunctionDecl) Start() token.Pos { return n.startLoc }
+func (n *FunctionDecl) End() token.Pos   { return n.Body.End() }
 
 // A ConstSpec is a declaration node representing the declaration of
 // a series of constants.
@@ -442,7 +512,7 @@ type ConstSpec struct {
 
 	// Syms is the file-level symbol table defining the scope in which these
 	// constants were declared, or nil if they are not package-level.
-	syms *symtab
+	syms *Scope
 
 	// Views is the set of views into this ConstSpec.
 	views []*constSpecView
@@ -450,8 +520,16 @@ type ConstSpec struct {
 	state checkState
 }
 
-func (n *ConstSpec) Start() token.Location { return n.Identifiers[0].Start() }
-func (n *ConstSpec) End() token.Location   { return n.Values[len(n.Values)-1].End() }
+func (n *ConstSpec) Start() token.Pos { return n.Identifiers[0].Start() }
+func (n *ConstSpec) End() token.Pos {
+	switch {
+	case len(n.Values) > 0:
+		return n.Values[len(n.Values)-1].End()
+	case n.Type != nil:
+		return n.Type.End()
+	}
+	return n.Identifiers[len(n.Identifiers)-1].End()
+}
 
 // A VarSpec is a declaration node representing the declaration of
 // a series of variables.
@@ -465,27 +543,71 @@ type VarSpec struct {
 
 	// Syms is the file-level symbol table defining the scope in which these
 	// variables were declared, or nil if they are not package-level.
-	syms *symtab
+	syms *Scope
+
+	// Views is the set of views into this VarSpec.
+	views []*varSpecView
+
+	// Types are the types of the values of a VarSpec with a single,
+	// multi-valued expression assigned to multiple identifiers.
+	types []Type
+
+	state checkState
 }
 
-func (n *VarSpec) Start() token.Location { return n.Identifiers[0].Start() }
-func (n *VarSpec) End() token.Location   { return n.Values[len(n.Values)-1].End() }
+func (n *VarSpec) Start() token.Pos { return n.Identifiers[0].Start() }
+
+func (n *VarSpec) End() token.Pos {
+	if len(n.Values) == 0 {
+		return n.Type.End()
+	}
+	return n.Values[len(n.Values)-1].End()
+}
 
 // A TypeSpec is a declaration node representing the declaration of
 // a single type.
 type TypeSpec struct {
 	comments
 	Identifier
-	Type Type
+	// TypeParameters is nil if the type is not generic.
+	TypeParameters []TypeParameter
+	// Alias is true if the TypeSpec declares an alias, type A = B,
+	// which denotes the type B rather than a new named type.
+	Alias bool
+	Type  Type
 
 	// Syms is the file-level symbol table defining the scope in which this
 	// type was declared, or nil if this is not a package-level type.
-	syms  *symtab
+	syms *Scope
+
+	// Methods are the methods declared with this type as their
+	// receiver's base type.
+	methods []*MethodDecl
+
 	state checkState
 }
 
-func (n *TypeSpec) Start() token.Location { return n.Identifier.Start() }
-func (n *TypeSpec) End() token.Location   { return n.Type.End() }
+func (n *TypeSpec) Start() token.Pos { return n.Identifier.Start() }
+func (n *TypeSpec) End() token.Pos   { return n.Type.End() }
+
+// A TypeParameter is a node representing the declaration of a single
+// type parameter of a generic function or type.
+type TypeParameter struct {
+	Identifier
+	// Constraint is the type constraint of the type parameter.
+	Constraint Type
+
+	// Iface is the interface of the constraint. If the constraint is not
+	// an interface, it is an implicit interface with the constraint as its
+	// only type element. It is set by the Check pass.
+	iface *InterfaceType
+}
+
+func (n *TypeParameter) Start() token.Pos { return n.Identifier.Start() }
+func (n *TypeParameter) End() token.Pos   { return n.Constraint.End() }
+
+// Comments returns nil, because type parameters have no comments.
+func (n *TypeParameter) Comments() []string { return nil }
 
 // The Type interface is implemented by nodes that represent types.
 type Type interface {
@@ -503,18 +625,18 @@ type Type interface {
 	//
 	// The path argument contains all type names along the path from
 	// the root of the type tree that occur without intervening references.
-	check(syms *symtab, iota int, path map[string]bool) (Type, error)
+	check(syms *Scope, iota int, path map[string]bool) (Type, error)
 }
 
 // A StructType is a type node representing a struct type.
 type StructType struct {
 	Fields               []FieldDecl
-	keywordLoc, closeLoc token.Location
+	keywordLoc, closeLoc token.Pos
 }
 
-func (n *StructType) Start() token.Location { return n.keywordLoc }
-func (n *StructType) End() token.Location   { return n.closeLoc }
-func (n *StructType) Loc() token.Location   { return n.Start() }
+func (n *StructType) Start() token.Pos { return n.keywordLoc }
+func (n *StructType) End() token.Pos   { return n.closeLoc }
+func (n *StructType) Loc() token.Pos   { return n.Start() }
 
 // A FieldDecl is a node representing a struct field declaration.
 type FieldDecl struct {
@@ -525,17 +647,21 @@ type FieldDecl struct {
 
 	// Pkg is the package in which the field was declared or nil
 	// if it was declared in the current package.
-	pkg *packageDecl
+	pkg *Package
+
+	// Embedded is the name of an embedded field.
+	// It is set by the Check pass.
+	embedded string
 }
 
-func (n *FieldDecl) Start() token.Location {
+func (n *FieldDecl) Start() token.Pos {
 	if n.Identifier != nil {
 		return n.Identifier.Start()
 	}
 	return n.Type.Start()
 }
 
-func (n *FieldDecl) End() token.Location {
+func (n *FieldDecl) End() token.Pos {
 	if n.Tag != nil {
 		return n.Tag.End()
 	}
@@ -547,17 +673,30 @@ type InterfaceType struct {
 	// Methods is a slice of the methods declarations of this interface.
 	// A method declaration is either a Method, giving the name and
 	// signature of a method, or a TypeName, naming an interface
-	// whose methods are included in this interface too.
+	// whose methods are included in this interface too. In a constraint
+	// interface, it may also be another Type or a Union, restricting the
+	// type set of the interface.
 	Methods []Node
 	// MethodSet is like Methods but with all TypeNames expanded and
 	// sorted by the methods' Identifier names.
-	methodSet            []*Method
-	keywordLoc, closeLoc token.Location
+	methodSet []*Method
+
+	// Restricted is true if the type set of the interface is restricted
+	// to the types denoted by terms. Otherwise it contains all types,
+	// and terms is nil.
+	restricted bool
+	terms      []Term
+
+	// Comparable is true if the type set of the interface contains only
+	// comparable types.
+	comparable bool
+
+	keywordLoc, closeLoc token.Pos
 }
 
-func (n *InterfaceType) Start() token.Location { return n.keywordLoc }
-func (n *InterfaceType) End() token.Location   { return n.closeLoc }
-func (n *InterfaceType) Loc() token.Location   { return n.Start() }
+func (n *InterfaceType) Start() token.Pos { return n.keywordLoc }
+func (n *InterfaceType) End() token.Pos   { return n.closeLoc }
+func (n *InterfaceType) Loc() token.Pos   { return n.Start() }
 
 // A Method is a node representing a method name and its signature.
 type Method struct {
@@ -566,29 +705,58 @@ type Method struct {
 
 	// Pkg is the package in which the method was declared or nil
 	// if it was declared in the current package.
-	pkg *packageDecl
+	pkg *Package
+}
+
+func (n *Method) Start() token.Pos { return n.Identifier.Start() }
+func (n *Method) End() token.Pos   { return n.Signature.End() }
+
+// A Union is a type node representing a union of terms in a type
+// constraint, for example, ~int | ~string. A single term with a tilde,
+// such as ~int, is also represented by a Union.
+type Union struct {
+	Terms []Term
+}
+
+func (n *Union) Start() token.Pos { return n.Terms[0].Start() }
+func (n *Union) End() token.Pos   { return n.Terms[len(n.Terms)-1].End() }
+func (n *Union) Loc() token.Pos   { return n.Start() }
+
+// A Term is a single term of a Union.
+type Term struct {
+	// Tilde is true if the term is prefixed by ~, denoting the set of
+	// all types with the underlying type Type.
+	Tilde    bool
+	tildeLoc token.Pos
+	Type     Type
+}
+
+func (n *Term) Start() token.Pos {
+	if n.Tilde {
+		return n.tildeLoc
+	}
+	return n.Type.Start()
 }
 
-func (n *Method) Start() token.Location { return n.Identifier.Start() }
-func (n *Method) End() token.Location   { return n.Signature.End() }
+func (n *Term) End() token.Pos { return n.Type.End() }
 
 // A FunctionType is a type node representing a function type.
 type FunctionType struct {
 	Signature
-	funcLoc token.Location
+	funcLoc token.Pos
 }
 
-func (n *FunctionType) Loc() token.Location { return n.funcLoc }
+func (n *FunctionType) Loc() token.Pos { return n.funcLoc }
 
 // A Signature is a node representing parameter and result declarations.
 type Signature struct {
 	Parameters []ParameterDecl
 	Results    []ParameterDecl
-	start, end token.Location
+	start, end token.Pos
 }
 
-func (n *Signature) Start() token.Location { return n.start }
-func (n *Signature) End() token.Location   { return n.end }
+func (n *Signature) Start() token.Pos { return n.start }
+func (n *Signature) End() token.Pos   { return n.end }
 
 // A ParameterDecl is a node representing the declaration of a single parameter.
 type ParameterDecl struct {
@@ -599,35 +767,39 @@ type ParameterDecl struct {
 	DotDotDot bool
 }
 
-func (n *ParameterDecl) Start() token.Location {
+func (n *ParameterDecl) Start() token.Pos {
 	if n.Identifier != nil {
 		return n.Identifier.Start()
 	}
 	return n.Type.Start()
 }
-func (n *ParameterDecl) End() token.Location { return n.Type.End() }
+func (n *ParameterDecl) End() token.Pos { return n.Type.End() }
+
+// Comments returns nil; parameters have no comments. Comments is
+// needed so that a ParameterDecl can be bound in a Scope.
+func (n *ParameterDecl) Comments() []string { return nil }
 
 // A ChannelType is a type node that represents a send, receive, or
 // a send and receive channel.
 type ChannelType struct {
 	Send, Receive bool
 	Element       Type
-	startLoc      token.Location
+	startLoc      token.Pos
 }
 
-func (n *ChannelType) Start() token.Location { return n.startLoc }
-func (n *ChannelType) End() token.Location   { return n.Element.End() }
-func (n *ChannelType) Loc() token.Location   { return n.Start() }
+func (n *ChannelType) Start() token.Pos { return n.startLoc }
+func (n *ChannelType) End() token.Pos   { return n.Element.End() }
+func (n *ChannelType) Loc() token.Pos   { return n.Start() }
 
 // An MapType is a type node that represents a map from types to types.
 type MapType struct {
 	Key, Value Type
-	mapLoc     token.Location
+	mapLoc     token.Pos
 }
 
-func (n *MapType) Start() token.Location { return n.mapLoc }
-func (n *MapType) End() token.Location   { return n.Value.End() }
-func (n *MapType) Loc() token.Location   { return n.Start() }
+func (n *MapType) Start() token.Pos { return n.mapLoc }
+func (n *MapType) End() token.Pos   { return n.Value.End() }
+func (n *MapType) Loc() token.Pos   { return n.Start() }
 
 // An ArrayType is a type node that represents an array of types.
 type ArrayType struct {
@@ -635,22 +807,22 @@ type ArrayType struct {
 	// with the size specified using [...]Type notation.
 	Size    Expression
 	Element Type
-	openLoc token.Location
+	openLoc token.Pos
 }
 
-func (n *ArrayType) Start() token.Location { return n.openLoc }
-func (n *ArrayType) End() token.Location   { return n.Element.End() }
-func (n *ArrayType) Loc() token.Location   { return n.Start() }
+func (n *ArrayType) Start() token.Pos { return n.openLoc }
+func (n *ArrayType) End() token.Pos   { return n.Element.End() }
+func (n *ArrayType) Loc() token.Pos   { return n.Start() }
 
 // A SliceType is a type node that represents a slice of types.
 type SliceType struct {
 	Element Type
-	openLoc token.Location
+	openLoc token.Pos
 }
 
-func (n *SliceType) Start() token.Location { return n.openLoc }
-func (n *SliceType) End() token.Location   { return n.Element.End() }
-func (n *SliceType) Loc() token.Location   { return n.Start() }
+func (n *SliceType) Start() token.Pos { return n.openLoc }
+func (n *SliceType) End() token.Pos   { return n.Element.End() }
+func (n *SliceType) Loc() token.Pos   { return n.Start() }
 
 // A Star is either a dereference expression or a type node that representing
 // a pointer to a type. After the Check pass, all Star nodes are Pointer types.
@@ -660,12 +832,12 @@ type Star struct {
 	// dereference expression, or the type being pointed to, in the case of
 	// a pointer type.
 	Target  Expression
-	starLoc token.Location
+	starLoc token.Pos
 }
 
-func (n *Star) Start() token.Location { return n.starLoc }
-func (n *Star) End() token.Location   { return n.Target.End() }
-func (n *Star) Loc() token.Location   { return n.starLoc }
+func (n *Star) Start() token.Pos { return n.starLoc }
+func (n *Star) End() token.Pos   { return n.Target.End() }
+func (n *Star) Loc() token.Pos   { return n.starLoc }
 
 // A TypeName is a type node representing a possibly-qualified type name.
 type TypeName struct {
@@ -674,13 +846,13 @@ type TypeName struct {
 	Identifier
 }
 
-func (n *TypeName) Start() token.Location {
+func (n *TypeName) Start() token.Pos {
 	if n.Package != nil {
 		return n.Package.Start()
 	}
 	return n.Identifier.Start()
 }
-func (n *TypeName) End() token.Location { return n.Identifier.End() }
+func (n *TypeName) End() token.Pos { return n.Identifier.End() }
 
 // The Expression interface is implemented by all nodes that are
 // also expressions.
@@ -689,7 +861,7 @@ type Expression interface {
 	// Loc returns a location that is indicative of this expression.
 	// For example, the location of the operator of a binary
 	// expression may be used.  Loc is used for error reporting.
-	Loc() token.Location
+	Loc() token.Pos
 	// Source returns a source-code-like representation of the expression.
 	// It is not guaranteed to be valid Go source; it strips information like
 	// function bodies, struct type field declarations, and interface type
@@ -704,7 +876,13 @@ type Expression interface {
 	// expressions that do not appear in ConstSpec nodes, it is -1.
 	// When iota is non-negative, it is an error for the expression
 	// not to reduce to a constant operand.
-	Check(syms *symtab, iota int) (Expression, error)
+	Check(syms *Scope, iota int) (Expression, error)
+}
+
+// A BadExpr is an expression node representing a missing or malformed
+// operand. It spans the token at which an operand was expected.
+type BadExpr struct {
+	span
 }
 
 // A FunctionLiteral is an expression node that represents a function literal.
@@ -713,26 +891,30 @@ type FunctionLiteral struct {
 	Body BlockStmt
 }
 
-func (n *FunctionLiteral) End() token.Location { return n.Body.End() }
+func (n *FunctionLiteral) End() token.Pos { return n.Body.End() }
 
 // A CompositeLiteral is an expression node that represents a
 // composite literal.
 type CompositeLiteral struct {
 	// LiteralType may be nil.
-	LiteralType       Type
-	Elements          []Element
-	openLoc, closeLoc token.Location
+	LiteralType Type
+	Elements    []Element
+	// Typ is the type of the literal. It differs from LiteralType for
+	// literals with elided types, nested within other composite
+	// literals. It is set by the Check pass.
+	typ               Type
+	openLoc, closeLoc token.Pos
 }
 
-func (n *CompositeLiteral) Start() token.Location {
-	if n.LiteralType != nil {
+func (n *CompositeLiteral) Start() token.Pos {
+	if n.LiteralType == nil {
 		return n.openLoc
 	}
 	return n.LiteralType.Start()
 }
 
-func (n *CompositeLiteral) Loc() token.Location { return n.Start() }
-func (n *CompositeLiteral) End() token.Location { return n.closeLoc }
+func (n *CompositeLiteral) Loc() token.Pos { return n.Start() }
+func (n *CompositeLiteral) End() token.Pos { return n.closeLoc }
 
 // An Element is a node representing the key-value mapping
 // of a single element of a composite literal.
@@ -741,61 +923,108 @@ type Element struct {
 	Key, Value Expression
 }
 
-func (n *Element) Start() token.Location {
+func (n *Element) Start() token.Pos {
 	if n.Key != nil {
 		return n.Key.Start()
 	}
 	return n.Value.Start()
 }
 
-func (n *Element) End() token.Location { return n.Value.End() }
+func (n *Element) End() token.Pos { return n.Value.End() }
 
 // An Index is an expression node that represents indexing into an
 // array or a slice.
 type Index struct {
 	Expression        Expression
 	Index             Expression
-	openLoc, closeLoc token.Location
+	typ               Type
+	openLoc, closeLoc token.Pos
 }
 
-func (n *Index) Start() token.Location { return n.Expression.Start() }
-func (n *Index) Loc() token.Location   { return n.openLoc }
-func (n *Index) End() token.Location   { return n.closeLoc }
+func (n *Index) Start() token.Pos { return n.Expression.Start() }
+func (n *Index) Loc() token.Pos   { return n.openLoc }
+func (n *Index) End() token.Pos   { return n.closeLoc }
+
+// An Instance is an expression node that represents the instantiation of
+// a generic function or type with a list of type arguments. An Instance
+// is also a type node if the instantiated generic is a type.
+//
+// An index expression with a single argument that is not a type literal,
+// for example, f[int], cannot be distinguished from an instantiation by
+// the parser, so it is represented as an Index.
+type Instance struct {
+	Expression    Expression
+	TypeArguments []Type
+
+	// Typ is the type of an instance of a generic function.
+	// It is set by the Check pass.
+	typ Type
+
+	// Decl is the declaration of an instantiated generic type.
+	// It is set by the Check pass, and it is nil for an instance
+	// of a generic function.
+	decl *TypeSpec
+
+	// Underlying is the underlying type of an instantiated generic type.
+	// It is computed by the Underlying method.
+	underlying Type
+
+	openLoc, closeLoc token.Pos
+}
+
+func (n *Instance) Start() token.Pos { return n.Expression.Start() }
+func (n *Instance) Loc() token.Pos   { return n.openLoc }
+func (n *Instance) End() token.Pos   { return n.closeLoc }
 
 // A Slice is an expression node that represents a slice of an array.
 type Slice struct {
 	Expression        Expression
 	Low, High, Max    Expression
-	openLoc, closeLoc token.Location
+	typ               Type
+	openLoc, closeLoc token.Pos
 }
 
-func (n *Slice) Start() token.Location { return n.Expression.Start() }
-func (n *Slice) Loc() token.Location   { return n.openLoc }
-func (n *Slice) End() token.Location   { return n.closeLoc }
+func (n *Slice) Start() token.Pos { return n.Expression.Start() }
+func (n *Slice) Loc() token.Pos   { return n.openLoc }
+func (n *Slice) End() token.Pos   { return n.closeLoc }
 
 // A TypeAssertion is an expression node representing a type assertion.
 type TypeAssertion struct {
 	Expression Expression
 	// If AssertedType == nil then this is a type switch guard.
 	AssertedType     Type
-	dotLoc, closeLoc token.Location
+	dotLoc, closeLoc token.Pos
 }
 
-func (n *TypeAssertion) Start() token.Location { return n.Expression.Start() }
-func (n *TypeAssertion) Loc() token.Location   { return n.dotLoc }
-func (n *TypeAssertion) End() token.Location   { return n.closeLoc }
+func (n *TypeAssertion) Start() token.Pos { return n.Expression.Start() }
+func (n *TypeAssertion) Loc() token.Pos   { return n.dotLoc }
+func (n *TypeAssertion) End() token.Pos   { return n.closeLoc }
 
 // Selector is a qualified identifier representing either a type name or
 // an selector expression.
 type Selector struct {
 	Parent Expression
 	*Identifier
-	dotLoc token.Location
+
+	// Typ is the type of the selected field or method.
+	// It is set by the Check pass.
+	typ Type
+
+	// Field is the selected field, or nil if a method is selected.
+	// It is set by the Check pass.
+	field *FieldDecl
+
+	// Indirect is whether a pointer is dereferenced in selecting the
+	// field or method, either of the Parent or of an embedded field.
+	// It is set by the Check pass.
+	indirect bool
+
+	dotLoc token.Pos
 }
 
-func (n *Selector) Start() token.Location { return n.Parent.Start() }
-func (n *Selector) Loc() token.Location   { return n.dotLoc }
-func (n *Selector) End() token.Location   { return n.Identifier.End() }
+func (n *Selector) Start() token.Pos { return n.Parent.Start() }
+func (n *Selector) Loc() token.Pos   { return n.dotLoc }
+func (n *Selector) End() token.Pos   { return n.Identifier.End() }
 
 // Call is a function call expression.
 // After parsing but before type checking, a Call can represent
@@ -804,36 +1033,41 @@ type Call struct {
 	Function  Expression
 	Arguments []Expression
 	// DotDotDot is true if the last argument ended with "...".
-	DotDotDot         bool
-	openLoc, closeLoc token.Location
+	DotDotDot bool
+	// Results are the types of the values of the call. There is more
+	// than one result for calls to multi-valued functions, and none for
+	// calls to functions without results. It is set by the Check pass.
+	results           []Type
+	openLoc, closeLoc token.Pos
 }
 
-func (c *Call) Start() token.Location { return c.Function.Start() }
-func (c *Call) Loc() token.Location   { return c.openLoc }
-func (c *Call) End() token.Location   { return c.closeLoc }
+func (c *Call) Start() token.Pos { return c.Function.Start() }
+func (c *Call) Loc() token.Pos   { return c.openLoc }
+func (c *Call) End() token.Pos   { return c.closeLoc }
 
 // BinaryOp is an expression node representing a binary operator.
 type BinaryOp struct {
 	Op          token.Token
-	opLoc       token.Location
+	opLoc       token.Pos
 	Left, Right Expression
+	typ         Type
 }
 
-func (b *BinaryOp) Start() token.Location { return b.Left.Start() }
-func (b *BinaryOp) Loc() token.Location   { return b.opLoc }
-func (b *BinaryOp) End() token.Location   { return b.Right.End() }
+func (b *BinaryOp) Start() token.Pos { return b.Left.Start() }
+func (b *BinaryOp) Loc() token.Pos   { return b.opLoc }
+func (b *BinaryOp) End() token.Pos   { return b.Right.End() }
 
 // UnaryOp is an expression node representing a unary operator.
 type UnaryOp struct {
 	Op      token.Token
 	Operand Expression
 	typ     Type
-	opLoc   token.Location
+	opLoc   token.Pos
 }
 
-func (u *UnaryOp) Start() token.Location { return u.opLoc }
-func (u *UnaryOp) Loc() token.Location   { return u.opLoc }
-func (u *UnaryOp) End() token.Location   { return u.Operand.End() }
+func (u *UnaryOp) Start() token.Pos { return u.opLoc }
+func (u *UnaryOp) Loc() token.Pos   { return u.opLoc }
+func (u *UnaryOp) End() token.Pos   { return u.Operand.End() }
 
 // An Identifier is an un-qualified identifier representing either a type
 // name or an identifier expression.
@@ -845,7 +1079,12 @@ type Identifier struct {
 
 // Exported returns whether the identifier is exported.
 func (n *Identifier) Exported() bool {
-	r, sz := utf8.DecodeRuneInString(n.Name)
+	return isExported(n.Name)
+}
+
+// IsExported returns whether an identifier with the given name is exported.
+func isExported(name string) bool {
+	r, sz := utf8.DecodeRuneInString(name)
 	if r == utf8.RuneError && sz == 1 {
 		return false
 	}
@@ -3,16 +3,32 @@ package ast
 import (
 	"fmt"
 	"math/big"
+	"sort"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/velour/stop/token"
 )
 
 // Check performs type checking and semantic analysis on the AST,
-// returning any errors that are encountered.
-func Check(files []*File) error {
+// returning any errors that are encountered, located in the FileSet
+// of the files. Imported packages are returned by imp. If info is
+// non-nil, the results of checking are recorded in it.
+func Check(fset *token.FileSet, files []*File, imp Importer, info *Info) error {
+	_, err := check(files, imp, info)
+	return locate(fset, err)
+}
+
+// Check checks the files of a package, returning the package Scope
+// and any errors that are encountered.
+func check(files []*File, imp Importer, info *Info) (*Scope, error) {
 	var errs errors
 
-	if _, err := pkgDecls(files); err != nil {
+	psyms, err := pkgDecls(files, info)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if err := importPkgs(files, imp); err != nil {
 		errs = append(errs, err)
 	}
 
@@ -34,501 +50,3737 @@ func Check(files []*File) error {
 		}
 	}
 
-	// BUG(eaburns): Check MethodDecl, FunctionDecl, and VarSpecs.
+	// Next check the package-level VarSpecs. The variables are checked
+	// lazily, in dependency order, as they are referenced. Functions are
+	// also checked lazily, but only their signatures.
+	for _, f := range files {
+		for _, d := range f.Declarations {
+			if d, ok := d.(*VarSpec); ok {
+				if err := d.Check(); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+	}
 
-	return errs.ErrorOrNil()
+	// Finally, check the bodies of all MethodDecls and FunctionDecls.
+	for _, f := range files {
+		for _, d := range f.Declarations {
+			switch d := d.(type) {
+			case *MethodDecl:
+				if err := d.Check(); err != nil {
+					errs = append(errs, err)
+				}
+			case *FunctionDecl:
+				if err := d.Check(); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+	}
+
+	info.recordTypes()
+	return psyms, errs.ErrorOrNil()
 }
 
-// Check checks the TypeSpec, returning any errors.
-func (n *TypeSpec) Check() error {
-	return n.check(map[string]bool{})
+// Check checks the MethodDecl, returning any errors.
+func (n *MethodDecl) Check() error {
+	if err := n.checkSignature(); err != nil {
+		return err
+	}
+	syms := n.fsyms
+	var errs errors
+	if err := syms.declare(&n.Receiver, n.recv); err != nil {
+		errs = append(errs, err)
+	}
+	if err := n.Signature.bind(syms); err != nil {
+		errs = append(errs, err)
+	}
+	if err := checkStmts(syms, &n.Signature, n.Body.Statements); err != nil {
+		errs = append(errs, err)
+	}
+	return errs.ErrorOrNil()
 }
 
-func (n *TypeSpec) check(path map[string]bool) error {
+// CheckSignature checks the receiver, parameter, and result types of the
+// method, returning any errors.
+//
+// BUG(eaburns): The receiver base type of a method cannot be an alias,
+// even an alias for a type declared in the current package.
+func (n *MethodDecl) checkSignature() error {
 	switch n.state {
-	case checking:
-		// We are currently checking this. It is not an invalid recursive type,
-		// since those are caught in TypeName.check using the path map.
+	case checking, checkedOK:
 		return nil
 	case checkedError:
 		return errors{}
-	case checkedOK:
-		return nil
 	}
-
-	var err error
 	n.state = checking
-	path[n.Name] = true
-	n.Type, err = n.Type.check(n.syms, -1, path)
-	path[n.Name] = false
-	if err != nil {
-		n.state = checkedError
-	} else {
-		n.state = checkedOK
-	}
-	return err
-}
-
-func (n *StructType) Check(*symtab, int) (Expression, error) {
-	panic("unimplemented")
-}
-
-func (n *StructType) check(syms *symtab, iota int, path map[string]bool) (Type, error) {
-	panic("unimplemented")
-}
-
-func (n *InterfaceType) Check(*symtab, int) (Expression, error) {
-	panic("unimplemented")
-}
-
-func (n *InterfaceType) check(syms *symtab, iota int, path map[string]bool) (Type, error) {
-	panic("unimplemented")
-}
-
-func (n *Signature) Check(*symtab, int) (Expression, error) {
-	panic("unimplemented")
-}
-
-func (n *Signature) check(syms *symtab, iota int, path map[string]bool) (Type, error) {
-	panic("unimplemented")
-}
-
-func (n *ChannelType) Check(syms *symtab, iota int) (Expression, error) {
-	return n.check(syms, iota, map[string]bool{})
-}
-
-func (n *ChannelType) check(syms *symtab, iota int, path map[string]bool) (Type, error) {
-	var err error
-	n.Element, err = n.Element.check(syms, iota, map[string]bool{})
-	if err != nil {
-		return nil, err
-	}
-	return n, nil
-}
-
-func (n *MapType) Check(syms *symtab, iota int) (Expression, error) {
-	return n.check(syms, iota, map[string]bool{})
-}
+	n.fsyms = makeScope(n.syms, n)
 
-func (n *MapType) check(syms *symtab, iota int, path map[string]bool) (Type, error) {
 	var errs errors
-
-	var err error
-	n.Key, err = n.Key.check(syms, iota, map[string]bool{})
-	if err != nil {
-		errs = append(errs, err)
-	} else {
-		switch n.Key.Underlying().(type) {
-		case *FunctionType, *MapType, *SliceType:
-			errs = append(errs, BadMapKey{n.Key})
+	var recv Type
+	d := n.syms.Find(n.BaseTypeName.Name)
+	n.syms.info.use(&n.BaseTypeName, d)
+	switch d := d.(type) {
+	case nil:
+		errs = append(errs, Undeclared{&n.BaseTypeName})
+	case *TypeSpec:
+		if err := d.check(map[string]bool{}); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		if d.Alias {
+			errs = append(errs, BadReceiver{n})
+			break
+		}
+		switch d.Type.Underlying().(type) {
+		case *Star, *InterfaceType:
+			errs = append(errs, BadReceiver{n})
+		default:
+			t := &TypeName{Identifier: n.BaseTypeName}
+			t.decl = d
+			recv = t
+			if len(d.TypeParameters) > 0 || len(n.ReceiverTypeParameters) > 0 {
+				var err error
+				if recv, err = n.bindReceiverTypeParameters(d, t); err != nil {
+					errs = append(errs, err)
+				}
+			}
 		}
+	default:
+		errs = append(errs, BadReceiver{n})
+	}
+	if recv != nil && n.Pointer {
+		recv = &Star{Target: recv, starLoc: n.BaseTypeName.Start()}
 	}
+	n.recv = &ParameterDecl{Identifier: &n.Receiver, Type: recv}
 
-	n.Value, err = n.Value.check(syms, iota, map[string]bool{})
-	if err != nil {
+	if err := n.Signature.checkTypes(n.fsyms); err != nil {
 		errs = append(errs, err)
 	}
 	if len(errs) > 0 {
-		return nil, errs
+		n.state = checkedError
+		return errs
 	}
-	return n, nil
+	n.state = checkedOK
+	return nil
 }
 
-func (n *ArrayType) Check(syms *symtab, iota int) (Expression, error) {
-	return n.check(syms, iota, map[string]bool{})
+// BindReceiverTypeParameters binds the receiver type parameters of a method
+// of the generic type d to the type parameters of d, returning the receiver
+// base type t instantiated with them. The receiver type parameters must
+// match the type parameters of d in number.
+func (n *MethodDecl) bindReceiverTypeParameters(d *TypeSpec, t *TypeName) (Type, error) {
+	if len(n.ReceiverTypeParameters) != len(d.TypeParameters) {
+		return nil, BadReceiver{n}
+	}
+	var errs errors
+	inst := &Instance{Expression: t, decl: d}
+	for i := range n.ReceiverTypeParameters {
+		id := &n.ReceiverTypeParameters[i]
+		tp := &d.TypeParameters[i]
+		if err := n.fsyms.declare(id, tp); err != nil {
+			errs = append(errs, err)
+		}
+		arg := &TypeName{Identifier: *id}
+		arg.decl = tp
+		inst.TypeArguments = append(inst.TypeArguments, arg)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return inst, nil
 }
 
-var intType = &TypeName{
-	Identifier: Identifier{
-		Name: "int",
-		decl: univScope.Find("int"),
-	},
+// Check checks the FunctionDecl, returning any errors.
+func (n *FunctionDecl) Check() error {
+	if err := n.checkSignature(); err != nil {
+		return err
+	}
+	syms := n.fsyms
+	var errs errors
+	if err := n.Signature.bind(syms); err != nil {
+		errs = append(errs, err)
+	}
+	if err := checkStmts(syms, &n.Signature, n.Body.Statements); err != nil {
+		errs = append(errs, err)
+	}
+	return errs.ErrorOrNil()
 }
 
-func (n *ArrayType) check(syms *symtab, iota int, path map[string]bool) (Type, error) {
+// CheckSignature checks the parameter and result types of the function,
+// returning any errors.
+func (n *FunctionDecl) checkSignature() error {
+	switch n.state {
+	case checking, checkedOK:
+		return nil
+	case checkedError:
+		return errors{}
+	}
+	n.state = checking
+	n.fsyms = makeScope(n.syms, n)
 	var errs errors
-	var err error
-	n.Size, err = n.Size.Check(syms, iota)
-	if err != nil {
+	if err := bindTypeParameters(n.fsyms, n.TypeParameters); err != nil {
 		errs = append(errs, err)
-	} else if !IsRepresentable(n.Size, intType) || Negative(n.Size) {
-		errs = append(errs, BadArraySize{n})
 	}
-	n.Element, err = n.Element.check(syms, iota, path)
-	if err != nil {
+	if err := n.Signature.checkTypes(n.fsyms); err != nil {
 		errs = append(errs, err)
 	}
 	if len(errs) > 0 {
-		return nil, errs
+		n.state = checkedError
+		return errs
 	}
-	return n, nil
+	n.state = checkedOK
+	return nil
 }
 
-func (n *SliceType) Check(syms *symtab, iota int) (Expression, error) {
-	return n.check(syms, iota, map[string]bool{})
-}
-
-func (n *SliceType) check(syms *symtab, iota int, _ map[string]bool) (Type, error) {
-	var err error
-	n.Element, err = n.Element.check(syms, iota, map[string]bool{})
-	if err != nil {
-		return nil, err
+// CheckTypes checks the types of the parameters and results of the signature.
+//
+// Parameters declared in a group, like a, b int, share a single Type node,
+// which is checked only once.
+func (n *Signature) checkTypes(syms *Scope) error {
+	var errs errors
+	for _, ps := range [][]ParameterDecl{n.Parameters, n.Results} {
+		var prev, t Type
+		var err error
+		for i := range ps {
+			if i == 0 || ps[i].Type != prev {
+				prev = ps[i].Type
+				t, err = checkType(syms, ps[i].Type)
+				if err == nil {
+					err = varType(t)
+				}
+				if err != nil {
+					errs = append(errs, err)
+				}
+			}
+			if err == nil {
+				ps[i].Type = t
+			}
+		}
 	}
-	return n, nil
+	return errs.ErrorOrNil()
 }
 
-func (n *Star) Check(syms *symtab, iota int) (Expression, error) {
-	var err error
-	n.Target, err = n.Target.Check(syms, iota)
-	if err != nil {
-		return nil, err
-	}
-	if _, ok := n.Target.(Type); ok {
-		// It was a pointer type. Nothing else to check.
-		return n, nil
-	}
-	u := &UnaryOp{
-		Op:      token.Star,
-		Operand: n.Target,
-		opLoc:   n.starLoc,
+// Bind binds all named parameters and results of the signature in syms.
+func (n *Signature) bind(syms *Scope) error {
+	var errs errors
+	for _, ps := range [][]ParameterDecl{n.Parameters, n.Results} {
+		for i := range ps {
+			if ps[i].Identifier == nil {
+				continue
+			}
+			if err := syms.declare(ps[i].Identifier, &ps[i]); err != nil {
+				errs = append(errs, err)
+			}
+		}
 	}
-	return u.Check(syms, iota)
+	return errs.ErrorOrNil()
 }
 
-func (n *Star) check(syms *symtab, iota int, _ map[string]bool) (Type, error) {
-	var err error
-	n.Target, err = n.Target.(Type).check(syms, iota, map[string]bool{})
-	if err != nil {
-		return nil, err
+// Check checks the VarSpec, returning any errors.
+func (n *VarSpec) Check() error {
+	var errs errors
+	for _, v := range n.views {
+		if err := v.Check(); err != nil {
+			errs = append(errs, err)
+		}
 	}
-	return n, nil
-}
-
-func (n *TypeName) Check(syms *symtab, iota int) (Expression, error) {
-	return n.check(syms, iota, map[string]bool{})
-
+	return errs.ErrorOrNil()
 }
 
-func (n *TypeName) check(syms *symtab, _ int, path map[string]bool) (Type, error) {
-	if path[n.Name] {
-		return nil, BadRecursiveType{n}
+// CheckSpec checks the parts of the VarSpec that are common to all of its views:
+// the type, the number of values, and, if a single value is assigned to
+// multiple identifiers, the value itself.
+func (n *VarSpec) checkSpec() error {
+	switch n.state {
+	case checking:
+		return InitializationLoop{n}
+	case checkedError:
+		return errors{}
+	case checkedOK:
+		return nil
 	}
+	n.state = checking
+
 	var errs errors
-	n.decl = syms.Find(n.Name)
-	if n.decl == nil {
-		errs = append(errs, Undeclared{&n.Identifier})
-	} else if ts, ok := n.decl.(*TypeSpec); ok {
-		if err := ts.check(path); err != nil {
+	if n.Type != nil {
+		if t, err := checkType(n.syms, n.Type); err != nil {
+			errs = append(errs, err)
+		} else if err := varType(t); err != nil {
 			errs = append(errs, err)
+		} else {
+			n.Type = t
 		}
 	}
-	if n.Package != nil {
-		n.Package.decl = syms.Find(n.Name)
-		if n.Package.decl == nil {
-			errs = append(errs, Undeclared{n.Package})
+	switch {
+	case len(n.Values) == 0 || len(n.Values) == len(n.Identifiers):
+		break
+	case len(n.Values) == 1:
+		v, err := checkExpr(n.syms, n.Values[0], -1)
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+		n.Values[0] = v
+		if n.types, err = valueTypes(n, v, len(n.Identifiers)); err != nil {
+			errs = append(errs, err)
 		}
+	default:
+		errs = append(errs, AssignCountMismatch{n})
 	}
 	if len(errs) > 0 {
-		return n, errs
+		n.state = checkedError
+		return errs
 	}
-	return n, nil
-}
-
-func (n *CompositeLiteral) Check(*symtab, int) (Expression, error) {
-	panic("unimplemented")
-}
-
-func (n *Index) Check(*symtab, int) (Expression, error) {
-	panic("unimplemented")
-}
-
-func (n *Slice) Check(*symtab, int) (Expression, error) {
-	panic("unimplemented")
-}
-
-func (n *TypeAssertion) Check(*symtab, int) (Expression, error) {
-	panic("unimplemented")
-}
-
-func (n *Selector) Check(*symtab, int) (Expression, error) {
-	panic("unimplemented")
-}
-
-func (n *Call) Check(*symtab, int) (Expression, error) {
-	panic("unimplemented")
-}
-
-func (n *BinaryOp) Check(*symtab, int) (Expression, error) {
-	panic("unimplemented")
+	n.state = checkedOK
+	return nil
 }
 
-func (n *UnaryOp) Check(syms *symtab, iota int) (Expression, error) {
-	var err error
-	n.Operand, err = n.Operand.Check(syms, iota)
-	if err != nil {
-		return nil, err
+// Check checks the variable of the view, returning any errors.
+func (n *varSpecView) Check() (err error) {
+	switch n.state {
+	case checking:
+		return InitializationLoop{n.VarSpec}
+	case checkedError:
+		return errors{}
+	case checkedOK:
+		return nil
 	}
-	n.typ = n.Operand.Type()
-
-	switch n.Op {
-	case token.Plus:
-		if !IsInteger(n.typ) && !IsComplex(n.typ) {
-			return nil, InvalidOperation{n, n.Op, n.Operand}
-		}
-		switch l := n.Operand.(type) {
-		case *IntegerLiteral, *FloatLiteral, *ComplexLiteral:
-			return l, nil
+	n.state = checking
+	defer func() {
+		if err != nil {
+			n.Type = nil
+			n.state = checkedError
+			return
 		}
+		n.state = checkedOK
+	}()
 
-	case token.Minus:
-		if !IsInteger(n.typ) && !IsComplex(n.typ) {
-			return nil, InvalidOperation{n, n.Op, n.Operand}
+	if err := n.VarSpec.checkSpec(); err != nil {
+		return err
+	}
+	switch t := n.VarSpec.Type; {
+	case len(n.Values) == 0:
+		n.Type = t
+
+	case len(n.types) > 0:
+		vt := n.types[n.Index]
+		if t != nil && !assignableTypes(vt, t) {
+			return assignError(n.Values[0], vt, t)
 		}
-		switch l := n.Operand.(type) {
-		case *IntegerLiteral:
-			l.Value.Neg(l.Value)
-			return valueOKOrError(l)
-
-		case *FloatLiteral:
-			l.Value.Neg(l.Value)
-			return valueOKOrError(l)
-
-		case *ComplexLiteral:
-			l.Real.Neg(l.Real)
-			l.Imaginary.Neg(l.Imaginary)
-			return valueOKOrError(l)
+		if t == nil {
+			t = defaultType(vt)
 		}
+		n.Type = t
 
-	case token.Bang:
-		if !IsBool(n.typ) {
-			return nil, InvalidOperation{n, n.Op, n.Operand}
+	default:
+		v, err := checkValue(n.syms, n.Values[n.Index])
+		if err != nil {
+			return err
 		}
-		if l, ok := n.Operand.(*BoolLiteral); ok {
-			l.Value = !l.Value
-			return l, nil
+		if t != nil {
+			v, err = assign(v, t)
+		} else {
+			v, err = inferType(v)
 		}
-
-	case token.Carrot:
-		if !IsInteger(n.typ) {
-			return nil, InvalidOperation{n, n.Op, n.Operand}
+		if err != nil {
+			return err
 		}
-		if l, ok := n.Operand.(*IntegerLiteral); ok {
-			l.Value.Not(l.Value)
-			return valueOKOrError(l)
+		n.Values[n.Index] = v
+		if t == nil {
+			t = defaultType(v.Type())
 		}
+		n.Type = t
+	}
+	return nil
+}
 
-	case token.And:
-		n.typ = &Star{Target: n.typ}
-		// BUG(eaburns): Verify that n.Operand is addressable.
-		panic("unimplemented")
-
-	case token.LessMinus:
-		ch, ok := n.typ.(*ChannelType)
-		if !ok || !ch.Receive {
-			return nil, InvalidOperation{n, n.Op, n.Operand}
-		}
-		n.typ = ch.Element
-
-	case token.Star:
-		p, ok := n.Operand.Type().(*Star)
-		if !ok {
-			return nil, InvalidOperation{n, n.Op, n.Operand}
-		}
-		n.typ = p.Target.(Type)
-
-	default:
-		panic("bad unary op: " + n.Op.String())
-	}
-
-	if iota >= 0 {
-		return nil, NotConstant{n.Operand}
-	}
-	return n, nil
-}
-
-// ValueOKOrError returns the literal expression if its value is representable
-// by its type, otherwise it returns an error.
-func valueOKOrError(l Expression) (Expression, error) {
-	if !IsRepresentable(l, l.Type()) {
-		return nil, Unrepresentable{l, l.Type()}
-	}
-	return l, nil
+// Check checks the TypeSpec, returning any errors.
+func (n *TypeSpec) Check() error {
+	return n.check(map[string]bool{})
 }
 
-func (n *ConstSpec) Check() error {
+func (n *TypeSpec) check(path map[string]bool) error {
 	switch n.state {
 	case checking:
-		panic("impossible, not recursive")
+		// We are currently checking this. It is not an invalid recursive type,
+		// since those are caught in TypeName.check using the path map.
+		return nil
 	case checkedError:
 		return errors{}
 	case checkedOK:
 		return nil
 	}
 
+	n.state = checking
+	syms := n.syms
 	var errs errors
-	if n.Type != nil {
-		if t, err := n.Type.Check(n.syms, -1); err != nil {
+	if len(n.TypeParameters) > 0 {
+		syms = makeScope(n.syms, n)
+		if err := bindTypeParameters(syms, n.TypeParameters); err != nil {
 			errs = append(errs, err)
-			n.Type = nil
-		} else {
-			n.Type = t.(Type)
 		}
 	}
-	if len(n.Identifiers) != len(n.Values) {
-		errs = append(errs, AssignCountMismatch{n})
+	path[n.Name] = true
+	t, err := n.Type.check(syms, -1, path)
+	path[n.Name] = false
+	switch {
+	case err != nil:
+		// The unchecked type is kept, because other specs in a cycle
+		// with this one may already have been checked using it.
+		errs = append(errs, err)
+	case n.Alias:
+		if tn := n.selfReference(t); tn != nil {
+			errs = append(errs, BadRecursiveType{tn})
+		}
 	}
 	if len(errs) > 0 {
 		n.state = checkedError
-	} else {
-		n.state = checkedOK
+		return errs
 	}
-	for _, v := range n.views {
-		if _, err := v.Check(); err != nil {
-			errs = append(errs, err)
+	n.Type = t
+	n.state = checkedOK
+	return nil
+}
+
+func (n *StructType) Check(syms *Scope, iota int) (Expression, error) {
+	return n.check(syms, iota, map[string]bool{})
+}
+
+func (n *StructType) check(syms *Scope, iota int, path map[string]bool) (Type, error) {
+	var errs errors
+	seen := make(map[string]*FieldDecl)
+	// Fields declared in a group, like x, y int, share a single
+	// Type node, which is checked only once.
+	var prev, t Type
+	var err error
+	for i := range n.Fields {
+		f := &n.Fields[i]
+		if f.Identifier == nil && f.embedded == "" {
+			// Checking may replace the type name of an embedded
+			// field, so its name is taken from the unchecked type.
+			f.embedded = embeddedName(f.Type)
+		}
+		if i == 0 || f.Type != prev {
+			prev = f.Type
+			t, err = f.Type.check(syms, iota, path)
+			if err == nil {
+				err = varType(t)
+			}
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err == nil {
+			f.Type = t
+			if f.Identifier == nil && !embeddable(t) {
+				errs = append(errs, BadEmbeddedField{f})
+			}
+		}
+		if f.Tag != nil {
+			tag, err := checkExpr(syms, f.Tag, iota)
+			if err != nil {
+				errs = append(errs, err)
+			} else {
+				f.Tag = tag.(*StringLiteral)
+			}
+		}
+		name := f.name()
+		if name == "_" {
+			continue
 		}
+		if g, ok := seen[name]; ok {
+			errs = append(errs, DuplicateField{First: g, Second: f})
+			continue
+		}
+		seen[name] = f
 	}
-	return errs.ErrorOrNil()
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return n, nil
 }
 
-func (n *constSpecView) Check() (v Expression, err error) {
-	defer func() {
-		if err != nil {
-			n.state = checkedError
-			return
+// Name returns the name of the field. The name of an embedded field is
+// the unqualified name of its type.
+func (n *FieldDecl) name() string {
+	switch {
+	case n.Identifier != nil:
+		return n.Name
+	case n.embedded != "":
+		return n.embedded
+	}
+	return embeddedName(n.Type)
+}
+
+// EmbeddedName returns the unqualified name of the type of an embedded
+// field, T, *T, T[A], or *T[A], or the empty string if the type is
+// none of these.
+func embeddedName(t Expression) string {
+	if s, ok := t.(*Star); ok {
+		t = s.Target
+	}
+	if inst, ok := t.(*Instance); ok {
+		t = inst.Expression
+	}
+	if tn, ok := t.(*TypeName); ok {
+		return tn.Name
+	}
+	return ""
+}
+
+// Field returns the field of the struct type with the given name, not
+// including promoted fields, or nil if there is none.
+func (n *StructType) field(name string) *FieldDecl {
+	for i := range n.Fields {
+		if n.Fields[i].name() == name {
+			return &n.Fields[i]
 		}
-		n.Value = v
-		n.state = checkedOK
-	}()
-	switch n.state {
-	case checking:
-		return nil, ConstantLoop{n}
-	case checkedError:
-		return nil, errors{}
-	case checkedOK:
-		return n.Value, nil
 	}
+	return nil
+}
 
-	if n.Index >= len(n.Values) {
-		// This constant has no expression, but the error will be
-		// reported when checking the ConstSpec instead of here.
-		return nil, errors{}
+// Embeddable returns whether the checked type of an embedded field is allowed:
+// a type name T, which is not a pointer type, or a pointer *T, where T is not
+// a pointer or interface type.
+func embeddable(t Type) bool {
+	s, ok := t.(*Star)
+	if !ok {
+		_, ptr := t.Underlying().(*Star)
+		return !ptr
 	}
-	v = n.Values[n.Index]
+	switch s.Target.(Type).Underlying().(type) {
+	case *Star, *InterfaceType:
+		return false
+	}
+	return true
+}
+
+func (n *InterfaceType) Check(syms *Scope, iota int) (Expression, error) {
+	return n.check(syms, iota, map[string]bool{})
+}
 
+// Check checks the interface type. The method set is built before the
+// method signatures are checked, because signatures may refer back to this
+// interface, and a type embedding it needs its methods.
+//
+// An embedded type that is not an interface, or a union, is a type element
+// that restricts the type set of the interface to the types of its terms.
+// The type set of an embedded interface is intersected with that of
+// this interface. An interface with a restricted type set, or that embeds
+// comparable, may only be used as a type constraint, which is reported
+// where it is used by varType.
+func (n *InterfaceType) check(syms *Scope, iota int, path map[string]bool) (Type, error) {
 	var errs errors
-	if err := n.ConstSpec.Check(); err != nil {
-		errs = append(errs, err)
+	var methods []*Method
+	explicit := make(map[*Method]bool)
+	n.methodSet = nil
+	n.restricted, n.terms, n.comparable = false, nil, false
+	for i, m := range n.Methods {
+		switch m := m.(type) {
+		case *Method:
+			methods = append(methods, m)
+			explicit[m] = true
+		case Type:
+			t, err := m.check(syms, iota, path)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			n.Methods[i] = t
+			iface, ok := t.Underlying().(*InterfaceType)
+			if !ok {
+				n.restrict(typeTerms(t))
+				continue
+			}
+			if iface.restricted {
+				n.restrict(iface.terms)
+			}
+			n.comparable = n.comparable || iface.comparable
+			methods = append(methods, iface.methodSet...)
+		default:
+			panic(fmt.Sprintf("bad interface method: %T", m))
+		}
 	}
-	// If the type is specified in the ConstSpec, then all views get that type.
-	// Otherwise, this will assign nil, and each view gets the type from its
-	// bound expression.
-	n.Type = n.ConstSpec.Type
 
-	n.state = checking
-	v, err = v.Check(n.syms, n.Iota)
-	switch {
-	case err != nil:
-		return nil, append(errs, err)
-	case !constOperand(v):
-		return nil, append(errs, NotConstant{v})
+	// Methods with the same name are allowed if at least one of them
+	// is embedded and their signatures are identical. Signatures are not
+	// checked yet, so identity is tested after they are.
+	seen := make(map[string]*Method)
+	var dups []DuplicateMethod
+	for _, m := range methods {
+		switch f, ok := seen[m.Name]; {
+		case !ok:
+			seen[m.Name] = m
+			n.methodSet = append(n.methodSet, m)
+		case explicit[f] && explicit[m]:
+			errs = append(errs, DuplicateMethod{First: f, Second: m})
+		default:
+			dups = append(dups, DuplicateMethod{First: f, Second: m})
+		}
 	}
+	sort.Sort(byName(n.methodSet))
 
-	switch _, vIsUntyped := v.Type().(Untyped); {
-	case n.Type != nil && vIsUntyped && !IsRepresentable(v, n.Type):
-		return nil, append(errs, Unrepresentable{v, n.Type})
-	case n.Type != nil && !IsAssignable(v, n.Type):
-		return nil, append(errs, BadAssign{v, n.Type})
-	case n.Type == nil:
-		n.Type = v.Type()
+	for _, m := range n.Methods {
+		if m, ok := m.(*Method); ok {
+			if err := m.checkTypes(syms); err != nil {
+				errs = append(errs, err)
+			}
+		}
 	}
-	if n.Type != nil {
-		// All Literals, which this must be after folding, have a SetType method.
-		v.(interface {
-			SetType(Type)
-		}).SetType(n.Type)
+	if len(errs) > 0 {
+		return nil, errs
 	}
-	return v, nil
+	for _, d := range dups {
+		if !d.First.identical(&d.Second.Signature) {
+			errs = append(errs, d)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return n, nil
 }
 
-// ConstOperand returns true if the expression is a constant operand.
-// A constant operand is the result of constant folding on a truly
-// constant expression.
-func constOperand(e Expression) bool {
-	switch e.(type) {
-	case *IntegerLiteral, *FloatLiteral, *ComplexLiteral, *StringLiteral, *BoolLiteral:
-		return true
+// ByName implements sort.Interface, sorting methods by their names.
+type byName []*Method
+
+func (ms byName) Len() int           { return len(ms) }
+func (ms byName) Swap(i, j int)      { ms[i], ms[j] = ms[j], ms[i] }
+func (ms byName) Less(i, j int) bool { return ms[i].Name < ms[j].Name }
+
+func (n *FunctionType) Check(syms *Scope, iota int) (Expression, error) {
+	return n.check(syms, iota, map[string]bool{})
+}
+
+func (n *FunctionType) check(syms *Scope, _ int, _ map[string]bool) (Type, error) {
+	if err := n.Signature.checkTypes(syms); err != nil {
+		return nil, err
 	}
-	return false
+	return n, nil
 }
 
-func (n *Identifier) Check(syms *symtab, iota int) (Expression, error) {
-	n.decl = syms.Find(n.Name)
-	if n.decl == nil {
-		return nil, Undeclared{n}
+func (n *ChannelType) Check(syms *Scope, iota int) (Expression, error) {
+	return n.check(syms, iota, map[string]bool{})
+}
+
+func (n *ChannelType) check(syms *Scope, iota int, path map[string]bool) (Type, error) {
+	t, err := n.Element.check(syms, iota, map[string]bool{})
+	if err == nil {
+		err = varType(t)
 	}
-	switch d := n.decl.(type) {
-	case *predeclaredConst:
-		switch n.Name {
-		case "iota":
-			v := big.NewInt(int64(iota))
-			return (&IntegerLiteral{Value: v, span: n.span}).Check(syms, iota)
-		case "true":
-			return (&BoolLiteral{Value: true, span: n.span}).Check(syms, iota)
-		case "false":
-			return (&BoolLiteral{Value: false, span: n.span}).Check(syms, iota)
-		case "nil":
-			return (&NilLiteral{span: n.span}).Check(syms, iota)
-		default:
-			panic("unknown predeclared constant: " + n.Name)
-		}
+	if err != nil {
+		return nil, err
+	}
+	n.Element = t
+	return n, nil
+}
 
-	case *constSpecView:
-		return d.Check()
+func (n *MapType) Check(syms *Scope, iota int) (Expression, error) {
+	return n.check(syms, iota, map[string]bool{})
+}
 
-	case *predeclaredType:
-		return (&TypeName{Identifier: *n}).Check(syms, iota)
-	case *TypeSpec:
-		return (&TypeName{Identifier: *n}).Check(syms, iota)
+func (n *MapType) check(syms *Scope, iota int, path map[string]bool) (Type, error) {
+	var errs errors
 
-	default:
-		panic(fmt.Sprintf("unimplemented identifier type: %T", d))
+	k, err := n.Key.check(syms, iota, map[string]bool{})
+	if err == nil {
+		err = varType(k)
+	}
+	if err != nil {
+		errs = append(errs, err)
+	} else {
+		n.Key = k
+		switch n.Key.Underlying().(type) {
+		case *FunctionType, *MapType, *SliceType:
+			errs = append(errs, BadMapKey{n.Key})
+		}
 	}
-}
 
-func (n *IntegerLiteral) Check(*symtab, int) (Expression, error) {
-	if n.Rune {
-		n.typ = Untyped(RuneConst)
+	v, err := n.Value.check(syms, iota, map[string]bool{})
+	if err == nil {
+		err = varType(v)
+	}
+	if err != nil {
+		errs = append(errs, err)
 	} else {
-		n.typ = Untyped(IntegerConst)
+		n.Value = v
+	}
+	if len(errs) > 0 {
+		return nil, errs
 	}
 	return n, nil
 }
 
-func (n *FloatLiteral) Check(*symtab, int) (Expression, error) {
-	n.typ = Untyped(FloatConst)
-	return n, nil
+func (n *ArrayType) Check(syms *Scope, iota int) (Expression, error) {
+	return n.check(syms, iota, map[string]bool{})
 }
 
-func (n *ComplexLiteral) Check(*symtab, int) (Expression, error) {
-	n.typ = Untyped(ComplexConst)
-	return n, nil
+// Named types for some of the predeclared types.
+var (
+	boolType       = univTypeName("bool")
+	byteType       = univTypeName("byte")
+	runeType       = univTypeName("rune")
+	intType        = univTypeName("int")
+	float32Type    = univTypeName("float32")
+	float64Type    = univTypeName("float64")
+	complex64Type  = univTypeName("complex64")
+	complex128Type = univTypeName("complex128")
+	stringType     = univTypeName("string")
+)
+
+// UnivTypeName returns a TypeName for a predeclared type.
+func univTypeName(n string) *TypeName {
+	return &TypeName{
+		Identifier: Identifier{
+			Name: n,
+			decl: Universe.Find(n),
+		},
+	}
 }
 
-func (n *StringLiteral) Check(*symtab, int) (Expression, error) {
-	n.typ = Untyped(StringConst)
+func (n *ArrayType) check(syms *Scope, iota int, path map[string]bool) (Type, error) {
+	var errs errors
+	var err error
+	if n.Size == nil {
+		// The [...]Type notation is only allowed in composite literals,
+		// which check the element type themselves.
+		errs = append(errs, BadArraySize{n})
+	} else if n.Size, err = checkExpr(syms, n.Size, iota); err != nil {
+		errs = append(errs, err)
+	} else if !IsRepresentable(n.Size, intType) || Negative(n.Size) {
+		errs = append(errs, BadArraySize{n})
+	} else if _, ok := n.Size.(*IntegerLiteral); !ok {
+		// Array sizes are always IntegerLiterals after checking.
+		s := span{start: n.Size.Start(), end: n.Size.End()}
+		n.Size = &IntegerLiteral{Value: intValue(n.Size), typ: n.Size.Type(), span: s}
+	}
+	t, err := n.Element.check(syms, iota, path)
+	if err == nil {
+		err = varType(t)
+	}
+	if err != nil {
+		errs = append(errs, err)
+	} else {
+		n.Element = t
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
 	return n, nil
 }
 
-func (n *BoolLiteral) Check(*symtab, int) (Expression, error) {
-	n.typ = Untyped(BoolConst)
-	return n, nil
+func (n *SliceType) Check(syms *Scope, iota int) (Expression, error) {
+	return n.check(syms, iota, map[string]bool{})
 }
 
-func (n *NilLiteral) Check(*symtab, int) (Expression, error) {
-	n.typ = Untyped(NilConst)
+func (n *SliceType) check(syms *Scope, iota int, _ map[string]bool) (Type, error) {
+	t, err := n.Element.check(syms, iota, map[string]bool{})
+	if err == nil {
+		err = varType(t)
+	}
+	if err != nil {
+		return nil, err
+	}
+	n.Element = t
 	return n, nil
 }
 
-func (n Untyped) Check(*symtab, int) (Expression, error) { return n, nil }
-
-func (n Untyped) check(*symtab, int, map[string]bool) (Type, error) { return n, nil }
+func (n *Star) Check(syms *Scope, iota int) (Expression, error) {
+	x, err := checkExpr(syms, n.Target, iota)
+	if err != nil {
+		return nil, err
+	}
+	n.Target = x
+	if isType(n.Target) {
+		// It was a pointer type. Nothing else to check.
+		return n, nil
+	}
+	u := &UnaryOp{
+		Op:      token.Star,
+		Operand: n.Target,
+		opLoc:   n.starLoc,
+	}
+	return u.Check(syms, iota)
+}
+
+func (n *Star) check(syms *Scope, iota int, _ map[string]bool) (Type, error) {
+	t, err := n.Target.(Type).check(syms, iota, map[string]bool{})
+	if err == nil {
+		err = varType(t)
+	}
+	if err != nil {
+		return nil, err
+	}
+	n.Target = t
+	return n, nil
+}
+
+func (n *Union) Check(syms *Scope, iota int) (Expression, error) {
+	return n.check(syms, iota, map[string]bool{})
+}
+
+func (n *Union) check(syms *Scope, iota int, path map[string]bool) (Type, error) {
+	var errs errors
+	for i := range n.Terms {
+		term := &n.Terms[i]
+		t, err := term.Type.check(syms, iota, map[string]bool{})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		term.Type = t
+		if term.Tilde && !t.Underlying().Identical(t) {
+			errs = append(errs, BadTerm{term})
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return n, nil
+}
+
+// Check checks an instance in an expression, which may be either an
+// instance of a generic type or of a generic function. An instance of
+// a generic function may omit trailing type arguments, which are then
+// inferred if the instance is called.
+func (n *Instance) Check(syms *Scope, iota int) (Expression, error) {
+	d, ok := genericDecl(syms, n.Expression).(*FunctionDecl)
+	if !ok {
+		return n.check(syms, iota, map[string]bool{})
+	}
+	x, err := checkExpr(syms, n.Expression, iota)
+	if err != nil {
+		return nil, err
+	}
+	n.Expression = x
+	var errs errors
+	for i := range n.TypeArguments {
+		t, err := checkType(syms, n.TypeArguments[i])
+		if err == nil {
+			err = varType(t)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		n.TypeArguments[i] = t
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	switch tps := d.TypeParameters; {
+	case len(n.TypeArguments) > len(tps):
+		return nil, TypeArgCountMismatch{n}
+	case len(n.TypeArguments) < len(tps):
+		return n, nil
+	}
+	if err := satisfy(n, d.TypeParameters, n.TypeArguments); err != nil {
+		return nil, err
+	}
+	m := bindings(d.TypeParameters, n.TypeArguments)
+	n.typ = subst(&FunctionType{Signature: d.Signature}, m)
+	if iota >= 0 {
+		return nil, NotConstant{n}
+	}
+	return n, nil
+}
+
+// Check checks an instance of a generic type.
+func (n *Instance) check(syms *Scope, iota int, path map[string]bool) (Type, error) {
+	tn, ok := typeExpr(n.Expression).(*TypeName)
+	if !ok {
+		return nil, NotType{n.Expression}
+	}
+	n.Expression = tn
+	if err := tn.checkName(syms, path); err != nil {
+		return nil, err
+	}
+	d, ok := tn.decl.(*TypeSpec)
+	if !ok || len(d.TypeParameters) == 0 {
+		return nil, NotGeneric{tn}
+	}
+	var errs errors
+	for i, a := range n.TypeArguments {
+		t, err := a.check(syms, iota, map[string]bool{})
+		if err == nil {
+			err = varType(t)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		n.TypeArguments[i] = t
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if len(n.TypeArguments) != len(d.TypeParameters) {
+		return nil, TypeArgCountMismatch{n}
+	}
+	// The declaration is set before checking the constraints, because
+	// a type argument may refer back to this instance by name, as in
+	// type T L[*T], and its method set needs this instance's underlying type.
+	n.decl = d
+	if err := satisfy(n, d.TypeParameters, n.TypeArguments); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (n *TypeName) Check(syms *Scope, iota int) (Expression, error) {
+	return n.check(syms, iota, map[string]bool{})
+
+}
+
+// The name of an alias is replaced by the type that it denotes, unless
+// the alias is still being checked, in which case the name is kept.
+// TypeSpec.check reports such a kept name in the type of the alias itself
+// as an invalid recursive type.
+func (n *TypeName) check(syms *Scope, _ int, path map[string]bool) (Type, error) {
+	if err := n.checkName(syms, path); err != nil {
+		return n, err
+	}
+	d, ok := n.decl.(*TypeSpec)
+	switch {
+	case !ok:
+		return n, nil
+	case len(d.TypeParameters) > 0:
+		return n, NotInstantiated{n}
+	case d.Alias && d.state == checkedOK:
+		return d.Type, nil
+	}
+	return n, nil
+}
+
+// SelfReference returns a name of the type spec in the checked type t,
+// or nil if there is none. The types of other declarations are not searched,
+// so a reference back to the type spec through a defined type is not found.
+//
+// An alias cannot refer to itself. A reference to the alias from its own
+// type keeps its name, since it is still being checked, but a reference
+// through another alias is replaced by that alias's type, so in
+// type A = []B; type B = []A, the type of A becomes [][]A.
+func (n *TypeSpec) selfReference(t Type) *TypeName {
+	switch t := t.(type) {
+	case *TypeName:
+		if t.decl == Declaration(n) {
+			return t
+		}
+	case *Star:
+		return n.selfReference(t.Target.(Type))
+	case *SliceType:
+		return n.selfReference(t.Element)
+	case *ArrayType:
+		return n.selfReference(t.Element)
+	case *MapType:
+		if tn := n.selfReference(t.Key); tn != nil {
+			return tn
+		}
+		return n.selfReference(t.Value)
+	case *ChannelType:
+		return n.selfReference(t.Element)
+	case *FunctionType:
+		return n.signatureSelfReference(&t.Signature)
+	case *StructType:
+		for _, f := range t.Fields {
+			if tn := n.selfReference(f.Type); tn != nil {
+				return tn
+			}
+		}
+	case *InterfaceType:
+		for _, m := range t.Methods {
+			switch m := m.(type) {
+			case *Method:
+				if tn := n.signatureSelfReference(&m.Signature); tn != nil {
+					return tn
+				}
+			case Type:
+				if tn := n.selfReference(m); tn != nil {
+					return tn
+				}
+			}
+		}
+	case *Union:
+		for _, term := range t.Terms {
+			if tn := n.selfReference(term.Type); tn != nil {
+				return tn
+			}
+		}
+	case *Instance:
+		for _, a := range t.TypeArguments {
+			if tn := n.selfReference(a); tn != nil {
+				return tn
+			}
+		}
+	}
+	return nil
+}
+
+// SignatureSelfReference returns a name of the type spec in the parameter
+// or result types of the checked signature, or nil if there is none.
+func (n *TypeSpec) signatureSelfReference(s *Signature) *TypeName {
+	for _, ps := range [][]ParameterDecl{s.Parameters, s.Results} {
+		for _, p := range ps {
+			if tn := n.selfReference(p.Type); tn != nil {
+				return tn
+			}
+		}
+	}
+	return nil
+}
+
+// CheckName resolves and checks the declaration of the type name. Unlike
+// check, it allows the name of a generic type, which the caller instantiates.
+func (n *TypeName) checkName(syms *Scope, path map[string]bool) error {
+	if n.Package == nil && path[n.Name] {
+		return BadRecursiveType{n}
+	}
+	var errs errors
+	if n.Package == nil {
+		n.decl = syms.Find(n.Name)
+	} else {
+		switch p := syms.Find(n.Package.Name).(type) {
+		case nil:
+			errs = append(errs, Undeclared{n.Package})
+		case *packageDecl:
+			n.Package.decl = p
+			syms.info.use(n.Package, p)
+			if p.Package == nil {
+				// The import failed. It has already been reported.
+				return errors{}
+			}
+			n.decl = p.Lookup(n.Name)
+		default:
+			return NotPackage{n.Package}
+		}
+	}
+	syms.info.use(&n.Identifier, n.decl)
+	switch d := n.decl.(type) {
+	case nil:
+		errs = append(errs, Undeclared{&n.Identifier})
+	case predeclaredType, *TypeParameter:
+		break
+	case *TypeSpec:
+		if err := d.check(path); err != nil {
+			errs = append(errs, err)
+		}
+	default:
+		errs = append(errs, NotType{n})
+	}
+	return errs.ErrorOrNil()
+}
+
+func (n *FunctionLiteral) Check(syms *Scope, _ int) (Expression, error) {
+	if err := n.Signature.checkTypes(syms); err != nil {
+		return nil, err
+	}
+	fsyms := makeScope(syms, n)
+	var errs errors
+	if err := n.Signature.bind(fsyms); err != nil {
+		errs = append(errs, err)
+	}
+	if err := checkStmts(fsyms, &n.Signature, n.Body.Statements); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return n, nil
+}
+
+func (n *CompositeLiteral) Check(syms *Scope, _ int) (Expression, error) {
+	var t Type
+	var err error
+	if a, ok := n.LiteralType.(*ArrayType); ok && a.Size == nil {
+		// The size of a [...]Type array is set by checkElements.
+		a.Element, err = checkType(syms, a.Element)
+		t = a
+	} else {
+		t, err = checkType(syms, n.LiteralType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	n.LiteralType = t
+	if err := n.checkElements(syms, t); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// CheckElements checks the elements of a composite literal of the given type.
+func (n *CompositeLiteral) checkElements(syms *Scope, t Type) error {
+	n.typ = t
+	var errs errors
+	switch u := coreType(t).(type) {
+	case *ArrayType, *SliceType:
+		var elm Type
+		size := int64(-1)
+		if a, ok := u.(*ArrayType); ok {
+			elm = a.Element
+			if a.Size != nil {
+				size = a.Size.(*IntegerLiteral).Value.Int64()
+			}
+		} else {
+			elm = u.(*SliceType).Element
+		}
+		var i, max int64
+		seen := make(map[int64]bool)
+		for j := range n.Elements {
+			e := &n.Elements[j]
+			if e.Key != nil {
+				k, err := checkValue(syms, e.Key)
+				if err != nil {
+					errs = append(errs, err)
+				} else if l, ok := k.(*IntegerLiteral); !ok || !IsRepresentable(l, intType) || Negative(l) {
+					errs = append(errs, BadIndex{k})
+				} else {
+					e.Key = k
+					i = l.Value.Int64()
+				}
+			}
+			if seen[i] || (size >= 0 && i >= size) {
+				errs = append(errs, BadIndex{e.Value})
+			}
+			seen[i] = true
+			if err := checkElement(syms, e, elm); err != nil {
+				errs = append(errs, err)
+			}
+			i++
+			if i > max {
+				max = i
+			}
+		}
+		if a, ok := u.(*ArrayType); ok && a.Size == nil {
+			a.Size = &IntegerLiteral{Value: big.NewInt(max), typ: Untyped(IntegerConst)}
+		}
+
+	case *MapType:
+		for j := range n.Elements {
+			e := &n.Elements[j]
+			if e.Key == nil {
+				errs = append(errs, BadCompositeLiteral{n})
+				continue
+			}
+			if k, err := checkValue(syms, e.Key); err != nil {
+				errs = append(errs, err)
+			} else if k, err = assign(k, u.Key); err != nil {
+				errs = append(errs, err)
+			} else {
+				e.Key = k
+			}
+			if err := checkElement(syms, e, u.Value); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+	case *StructType:
+		errs = n.checkFields(syms, u)
+
+	default:
+		errs = append(errs, BadCompositeLiteral{n})
+	}
+	return errs.ErrorOrNil()
+}
+
+// CheckFields checks the elements of a struct composite literal. Either every
+// element is keyed by the name of a field, or no element is keyed and there
+// is an element for each field, in order.
+func (n *CompositeLiteral) checkFields(syms *Scope, t *StructType) errors {
+	var errs errors
+	if len(n.Elements) > 0 && n.Elements[0].Key == nil {
+		if len(n.Elements) != len(t.Fields) {
+			return errors{BadCompositeLiteral{n}}
+		}
+		for j := range n.Elements {
+			e := &n.Elements[j]
+			if e.Key != nil {
+				errs = append(errs, BadCompositeLiteral{n})
+				continue
+			}
+			if err := checkElement(syms, e, t.Fields[j].Type); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errs
+	}
+	seen := make(map[string]bool)
+	for j := range n.Elements {
+		e := &n.Elements[j]
+		if e.Key == nil {
+			errs = append(errs, BadCompositeLiteral{n})
+			continue
+		}
+		id, ok := e.Key.(*Identifier)
+		var f *FieldDecl
+		if ok {
+			f = t.field(id.Name)
+		}
+		if f == nil {
+			errs = append(errs, UnknownField{e.Key})
+			continue
+		}
+		if seen[id.Name] {
+			errs = append(errs, DuplicateFieldName{id})
+			continue
+		}
+		seen[id.Name] = true
+		if err := checkElement(syms, e, f.Type); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// CheckElement checks the value of an element of a composite literal with the
+// given element type. If the value is a composite literal with an elided type,
+// then its type is the element type.
+func checkElement(syms *Scope, e *Element, t Type) error {
+	if c, ok := e.Value.(*CompositeLiteral); ok && c.LiteralType == nil {
+		if p, ok := t.(*Star); ok {
+			u := &UnaryOp{Op: token.And, Operand: c, typ: p, opLoc: c.Start()}
+			e.Value = u
+			return c.checkElements(syms, p.Target.(Type))
+		}
+		return c.checkElements(syms, t)
+	}
+	v, err := checkValue(syms, e.Value)
+	if err != nil {
+		return err
+	}
+	if v, err = assign(v, t); err != nil {
+		return err
+	}
+	e.Value = v
+	return nil
+}
+
+func (n *Index) Check(syms *Scope, iota int) (Expression, error) {
+	if genericDecl(syms, n.Expression) != nil {
+		// An instance with a single type argument.
+		t := typeExpr(n.Index)
+		if t == nil {
+			return nil, NotType{n.Index}
+		}
+		inst := &Instance{
+			Expression:    n.Expression,
+			TypeArguments: []Type{t},
+			openLoc:       n.openLoc,
+			closeLoc:      n.closeLoc,
+		}
+		return checkExpr(syms, inst, iota)
+	}
+	x, err := checkValue(syms, n.Expression)
+	if err != nil {
+		return nil, err
+	}
+	n.Expression = x
+	i, err := checkValue(syms, n.Index)
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := coreType(x.Type()).(type) {
+	case *MapType:
+		if i, err = assign(i, t.Key); err != nil {
+			return nil, err
+		}
+		n.typ = t.Value
+	default:
+		elm, size := elementType(x.Type())
+		if elm == nil {
+			return nil, InvalidOperation{n, token.OpenBracket, x}
+		}
+		if i, err = checkIndex(i, size); err != nil {
+			return nil, err
+		}
+		n.typ = elm
+	}
+	n.Index = i
+	if iota >= 0 {
+		return nil, NotConstant{n}
+	}
+	return n, nil
+}
+
+// ElementType returns the type of the elements of an indexable type and the
+// length for array types. The length is -1 for non-array types. If the type is
+// not a string, array, pointer to array, or slice type, then the element
+// type is nil.
+//
+// A type parameter is indexable if all of the types in its type set are
+// indexable and have identical element types. Its length is that of the
+// shortest array in its type set.
+func elementType(t Type) (Type, int64) {
+	if typeParam(t) != nil {
+		var elm Type
+		size := int64(-1)
+		for _, t := range typeSet(t) {
+			e, n := elementType(t)
+			if e == nil || elm != nil && !elm.Identical(e) {
+				return nil, -1
+			}
+			elm = e
+			if n >= 0 && (size < 0 || n < size) {
+				size = n
+			}
+		}
+		return elm, size
+	}
+	if IsString(t) {
+		return byteType, -1
+	}
+	switch u := t.Underlying().(type) {
+	case *Star:
+		if a, ok := u.Target.(Type).Underlying().(*ArrayType); ok {
+			return a.Element, a.Size.(*IntegerLiteral).Value.Int64()
+		}
+	case *ArrayType:
+		return u.Element, u.Size.(*IntegerLiteral).Value.Int64()
+	case *SliceType:
+		return u.Element, -1
+	}
+	return nil, -1
+}
+
+// CheckIndex checks that a checked index expression is an integer that
+// is in range for a sequence of the given length. A length of -1 means that
+// the length is unknown. If the index is an untyped constant, then it is
+// converted to an int.
+func checkIndex(i Expression, length int64) (Expression, error) {
+	if !allTypes(i.Type(), IsInteger) {
+		if _, ok := i.Type().(Untyped); !ok || !IsRepresentable(i, intType) {
+			return nil, BadIndex{i}
+		}
+	}
+	if _, ok := i.Type().(Untyped); ok {
+		var err error
+		if i, err = assign(i, intType); err != nil {
+			return nil, err
+		}
+	}
+	if l, ok := i.(*IntegerLiteral); ok && (Negative(l) || length >= 0 && l.Value.Cmp(big.NewInt(length)) >= 0) {
+		return nil, BadIndex{i}
+	}
+	return i, nil
+}
+
+func (n *Slice) Check(syms *Scope, iota int) (Expression, error) {
+	x, err := checkValue(syms, n.Expression)
+	if err != nil {
+		return nil, err
+	}
+	n.Expression = x
+
+	elm, size := elementType(x.Type())
+	switch t := coreType(x.Type()).(type) {
+	case *ArrayType:
+		if !addressable(x) {
+			return nil, InvalidOperation{n, token.OpenBracket, x}
+		}
+		n.typ = &SliceType{Element: t.Element}
+	case *Star:
+		n.typ = &SliceType{Element: elm}
+	default:
+		// A type parameter without a core type may still be sliced
+		// if its type set contains only strings and byte slices.
+		if elm == nil || t == nil && !allTypes(x.Type(), isByteString) {
+			return nil, InvalidOperation{n, token.OpenBracket, x}
+		}
+		if n.Max != nil && !allTypes(x.Type(), func(t Type) bool { return !IsString(t) }) {
+			return nil, InvalidOperation{n, token.OpenBracket, x}
+		}
+		n.typ = defaultType(x.Type())
+	}
+	if size >= 0 {
+		// Slice indices may be equal to the length.
+		size++
+	}
+
+	var errs errors
+	for _, i := range []*Expression{&n.Low, &n.High, &n.Max} {
+		if *i == nil {
+			continue
+		}
+		v, err := checkValue(syms, *i)
+		if err == nil {
+			v, err = checkIndex(v, size)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		*i = v
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if iota >= 0 {
+		return nil, NotConstant{n}
+	}
+	return n, nil
+}
+
+func (n *TypeAssertion) Check(syms *Scope, iota int) (Expression, error) {
+	x, err := checkValue(syms, n.Expression)
+	if err != nil {
+		return nil, err
+	}
+	n.Expression = x
+	if _, ok := coreType(x.Type()).(*InterfaceType); !ok {
+		return nil, NotInterface{x}
+	}
+	if n.AssertedType == nil {
+		// A type switch guard outside of a type switch.
+		return nil, NotExpression{n}
+	}
+	if n.AssertedType, err = checkType(syms, n.AssertedType); err != nil {
+		return nil, err
+	}
+	if iota >= 0 {
+		return nil, NotConstant{n}
+	}
+	return n, nil
+}
+
+func (n *Selector) Check(syms *Scope, iota int) (Expression, error) {
+	if id, ok := n.Parent.(*Identifier); ok {
+		if p, ok := syms.Find(id.Name).(*packageDecl); ok {
+			id.decl = p
+			syms.info.use(id, p)
+			if p.Package == nil {
+				// The import failed. It has already been reported.
+				return nil, errors{}
+			}
+			d := p.Lookup(n.Name)
+			if d == nil {
+				return nil, Undeclared{n.Identifier}
+			}
+			syms.info.use(n.Identifier, d)
+			return n.Identifier.Check(p.syms, iota)
+		}
+	}
+	x, err := checkExpr(syms, n.Parent, -1)
+	if err != nil {
+		return nil, err
+	}
+	if isType(x) {
+		return n.checkMethodExpr(syms, iota, x.(Type))
+	}
+	if x, err = singleValue(x); err != nil {
+		return nil, err
+	}
+	n.Parent = x
+	sel, ambiguous := lookup(x.Type(), n.Name)
+	switch {
+	case sel == nil:
+		return nil, UnknownSelector{n, ambiguous}
+	case sel.field != nil:
+		n.typ, n.field = sel.field.Type, sel.field
+	case sel.sig == nil:
+		// The method's declaration has errors. They are reported
+		// when the method is checked.
+		return nil, errors{}
+	default:
+		// A method with a pointer receiver is called with the address
+		// of an addressable value.
+		if sel.method != nil && sel.method.Pointer && !sel.indirect && !addressable(x) {
+			return nil, PointerMethod{n}
+		}
+		syms.info.use(n.Identifier, sel.method)
+		n.typ = &FunctionType{Signature: *sel.sig}
+	}
+	n.indirect = sel.indirect
+	if iota >= 0 {
+		return nil, NotConstant{n}
+	}
+	return n, nil
+}
+
+// CheckMethodExpr checks a selector of a method of the type t, which is
+// a function with the receiver as its first parameter.
+func (n *Selector) checkMethodExpr(syms *Scope, iota int, t Type) (Expression, error) {
+	n.Parent = t
+	sel, ambiguous := lookup(t, n.Name)
+	switch {
+	case sel == nil || sel.field != nil:
+		return nil, UnknownSelector{n, ambiguous}
+	case sel.sig == nil:
+		return nil, errors{}
+	case sel.method != nil && sel.method.Pointer && !sel.indirect:
+		return nil, PointerMethod{n}
+	}
+	syms.info.use(n.Identifier, sel.method)
+	sig := *sel.sig
+	sig.Parameters = append([]ParameterDecl{{Type: t}}, sig.Parameters...)
+	n.typ = &FunctionType{Signature: sig}
+	n.indirect = sel.indirect
+	if iota >= 0 {
+		return nil, NotConstant{n}
+	}
+	return n, nil
+}
+
+func (n *Call) Check(syms *Scope, iota int) (Expression, error) {
+	if id, ok := n.Function.(*Identifier); ok {
+		if d, ok := syms.Find(id.Name).(*predeclaredFunc); ok {
+			id.decl = d
+			syms.info.use(id, d)
+			return n.checkBuiltin(syms, iota)
+		}
+	}
+	f, err := checkExpr(syms, n.Function, iota)
+	if err != nil {
+		return nil, err
+	}
+	n.Function = f
+	if isType(f) {
+		return n.checkConversion(syms, iota, f.(Type))
+	}
+	// The arguments of a call to a generic function are checked
+	// when inferring its type arguments.
+	checked := false
+	if d := genericFunc(f); d != nil {
+		if f, err = n.infer(syms, d, f); err != nil {
+			return nil, err
+		}
+		n.Function = f
+		checked = true
+	}
+	if f, err = singleValue(f); err != nil {
+		return nil, err
+	}
+	ft, ok := coreType(f.Type()).(*FunctionType)
+	if !ok {
+		return nil, NotFunction{f}
+	}
+
+	var types []Type
+	params := ft.Parameters
+	variadic := len(params) > 0 && params[len(params)-1].DotDotDot
+	if len(n.Arguments) == 1 && !n.DotDotDot && (len(params) > 1 || variadic) {
+		// The argument may be a multi-valued call.
+		x := n.Arguments[0]
+		if !checked {
+			if x, err = checkExpr(syms, x, -1); err != nil {
+				return nil, err
+			}
+		}
+		n.Arguments[0] = x
+		if c, ok := x.(*Call); ok && len(c.results) != 1 {
+			types = c.results
+		}
+	}
+
+	var errs errors
+	switch {
+	case types != nil:
+		if len(types) < len(params)-1 || !variadic && len(types) != len(params) {
+			return nil, ArgCountMismatch{n}
+		}
+		for i, t := range types {
+			pt := paramType(params, i)
+			if !assignableTypes(t, pt) {
+				errs = append(errs, assignError(n.Arguments[0], t, pt))
+			}
+		}
+
+	default:
+		switch {
+		case n.DotDotDot && (!variadic || len(n.Arguments) != len(params)):
+			return nil, ArgCountMismatch{n}
+		case len(n.Arguments) < len(params)-1 || !variadic && len(n.Arguments) != len(params):
+			return nil, ArgCountMismatch{n}
+		}
+		for i := range n.Arguments {
+			var x Expression
+			var err error
+			if checked {
+				x, err = singleValue(n.Arguments[i])
+			} else {
+				x, err = checkValue(syms, n.Arguments[i])
+			}
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			pt := paramType(params, i)
+			if n.DotDotDot && i == len(n.Arguments)-1 {
+				pt = &SliceType{Element: pt}
+			}
+			if x, err = assign(x, pt); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			n.Arguments[i] = x
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	n.results = nil
+	for _, r := range ft.Results {
+		n.results = append(n.results, r.Type)
+	}
+	if iota >= 0 {
+		return nil, NotConstant{n}
+	}
+	return n, nil
+}
+
+// ParamType returns the type of the parameter to which the ith argument
+// is assigned. If the parameters are variadic, then all arguments at or
+// beyond the final parameter get its element type.
+func paramType(params []ParameterDecl, i int) Type {
+	if i >= len(params) {
+		i = len(params) - 1
+	}
+	return params[i].Type
+}
+
+// CheckConversion checks a call that is a conversion to the type t.
+func (n *Call) checkConversion(syms *Scope, iota int, t Type) (Expression, error) {
+	if len(n.Arguments) != 1 || n.DotDotDot {
+		return nil, ArgCountMismatch{n}
+	}
+	x, err := checkExpr(syms, n.Arguments[0], iota)
+	if err != nil {
+		return nil, err
+	}
+	if x, err = singleValue(x); err != nil {
+		return nil, err
+	}
+
+	if constOperand(x) {
+		s := span{start: n.Start(), end: n.End()}
+		switch {
+		case IsRepresentable(x, t):
+			v := copyConst(x, s)
+			v.(interface {
+				SetType(Type)
+			}).SetType(t)
+			return v, nil
+		case IsInteger(x.Type()) && IsString(t):
+			l := x.(*IntegerLiteral)
+			r := unicode.ReplacementChar
+			if l.Value.IsInt64() && utf8.ValidRune(rune(l.Value.Int64())) {
+				r = rune(l.Value.Int64())
+			}
+			return &StringLiteral{Value: string(r), typ: t, span: s}, nil
+		case numeric(x.Type()) && numeric(t):
+			return nil, Unrepresentable{x, t}
+		}
+	}
+	if !convertible(x, t) {
+		return nil, BadConversion{x, t}
+	}
+	if l, ok := x.(*NilLiteral); ok {
+		l.SetType(t)
+	} else if x, err = inferType(x); err != nil {
+		return nil, err
+	}
+	n.Arguments[0] = x
+	n.results = []Type{t}
+	if iota >= 0 {
+		return nil, NotConstant{n}
+	}
+	return n, nil
+}
+
+// Numeric returns whether the type is an integer, floating point, or complex type.
+func numeric(t Type) bool { return IsInteger(t) || IsComplex(t) }
+
+// IsComplexNumber returns whether the type is a complex type. Unlike
+// IsComplex, it returns false for floating point types.
+func isComplexNumber(t Type) bool {
+	switch u := t.Underlying().(type) {
+	case Untyped:
+		return u == Untyped(ComplexConst)
+	case *TypeName:
+		return u.decl == Complex64 || u.decl == Complex128
+	}
+	return false
+}
+
+// IsByteOrRuneSlice returns whether the type is a slice of bytes or of runes.
+func isByteOrRuneSlice(t Type) bool {
+	s, ok := t.Underlying().(*SliceType)
+	if !ok {
+		return false
+	}
+	e, ok := s.Element.Underlying().(*TypeName)
+	return ok && (e.decl == Uint8 || e.decl == Int32)
+}
+
+// IsByteString returns whether the type is a string type or a slice of bytes.
+func isByteString(t Type) bool {
+	s, ok := t.Underlying().(*SliceType)
+	return IsString(t) || ok && s.Element.Underlying().Identical(byteType)
+}
+
+// Convertible returns whether a checked, single-valued expression is
+// convertible to the type t. If either type is a type parameter, each
+// type in its type set must be convertible.
+func convertible(x Expression, t Type) bool {
+	if IsAssignable(x, t) {
+		return true
+	}
+	return allTypes(x.Type(), func(xt Type) bool {
+		return allTypes(t, func(t Type) bool { return convertibleTypes(xt, t) })
+	})
+}
+
+// ConvertibleTypes returns whether a value of type xt is convertible to
+// the type t.
+func convertibleTypes(xt, t Type) bool {
+	xp, xIsPtr := xt.Underlying().(*Star)
+	tp, tIsPtr := t.Underlying().(*Star)
+	switch {
+	case assignableTypes(xt, t):
+		return true
+	case xt.Underlying().Identical(t.Underlying()):
+		return true
+	case xIsPtr && tIsPtr && xp.Target.(Type).Underlying().Identical(tp.Target.(Type).Underlying()):
+		return true
+	case numeric(xt) && numeric(t):
+		return isComplexNumber(xt) == isComplexNumber(t)
+	case IsString(t) && (IsInteger(xt) || isByteOrRuneSlice(xt)):
+		return true
+	case IsString(xt) && isByteOrRuneSlice(t):
+		return true
+	}
+	return false
+}
+
+// CheckBuiltin checks a call to a predeclared function.
+func (n *Call) checkBuiltin(syms *Scope, iota int) (Expression, error) {
+	name := n.Function.(*Identifier).Name
+
+	// The first argument of make and new is a type.
+	var t Type
+	args := n.Arguments
+	if (name == "make" || name == "new") && len(args) > 0 {
+		x, err := checkExpr(syms, args[0], -1)
+		if err != nil {
+			return nil, err
+		}
+		if !isType(x) {
+			return nil, NotType{x}
+		}
+		t = x.(Type)
+		args[0] = t
+		args = args[1:]
+	}
+
+	var errs errors
+	for i := range args {
+		x, err := checkExpr(syms, args[i], iota)
+		if err == nil {
+			x, err = singleValue(x)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		args[i] = x
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if n.DotDotDot && name != "append" {
+		return nil, ArgCountMismatch{n}
+	}
+
+	nargs := map[string][2]int{
+		"append":  {1, -1},
+		"cap":     {1, 1},
+		"close":   {1, 1},
+		"complex": {2, 2},
+		"copy":    {2, 2},
+		"delete":  {2, 2},
+		"imag":    {1, 1},
+		"len":     {1, 1},
+		"make":    {1, 3},
+		"new":     {1, 1},
+		"panic":   {1, 1},
+		"print":   {0, -1},
+		"println": {0, -1},
+		"real":    {1, 1},
+		"recover": {0, 0},
+	}[name]
+	if len(n.Arguments) < nargs[0] || nargs[1] >= 0 && len(n.Arguments) > nargs[1] {
+		return nil, ArgCountMismatch{n}
+	}
+
+	s := span{start: n.Start(), end: n.End()}
+	var err error
+	switch name {
+	case "len", "cap":
+		x := args[0]
+		if l, ok := x.(*StringLiteral); ok && name == "len" {
+			return &IntegerLiteral{Value: big.NewInt(int64(len(l.Value))), typ: intType, span: s}, nil
+		}
+		if !allTypes(x.Type(), func(t Type) bool { return hasLength(name, t) }) {
+			return nil, BadArgument{x}
+		}
+		if _, size := elementType(x.Type()); size >= 0 && typeParam(x.Type()) == nil {
+			if _, ok := x.(*Identifier); ok {
+				// The length of an array variable is constant.
+				return &IntegerLiteral{Value: big.NewInt(size), typ: intType, span: s}, nil
+			}
+		}
+		if args[0], err = inferType(x); err != nil {
+			return nil, err
+		}
+		n.results = []Type{intType}
+
+	case "append":
+		sl, ok := coreType(args[0].Type()).(*SliceType)
+		if !ok {
+			return nil, BadArgument{args[0]}
+		}
+		for i := 1; i < len(args); i++ {
+			t := sl.Element
+			if n.DotDotDot {
+				if i > 1 {
+					return nil, ArgCountMismatch{n}
+				}
+				t = args[0].Type()
+				if IsString(args[i].Type()) && IsInteger(sl.Element) {
+					t = stringType
+				}
+			}
+			if args[i], err = assign(args[i], t); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		n.results = []Type{args[0].Type()}
+
+	case "copy":
+		dst, ok := coreType(args[0].Type()).(*SliceType)
+		if !ok {
+			return nil, BadArgument{args[0]}
+		}
+		src, ok := coreType(args[1].Type()).(*SliceType)
+		switch {
+		case ok && dst.Element.Identical(src.Element):
+			break
+		case allTypes(args[1].Type(), IsString) && dst.Element.Underlying().Identical(byteType):
+			args[1], err = inferType(args[1])
+		default:
+			return nil, BadArgument{args[1]}
+		}
+		n.results = []Type{intType}
+
+	case "delete":
+		m, ok := coreType(args[0].Type()).(*MapType)
+		if !ok {
+			return nil, BadArgument{args[0]}
+		}
+		args[1], err = assign(args[1], m.Key)
+
+	case "make":
+		switch coreType(t).(type) {
+		case *SliceType:
+			if len(args) == 0 || len(args) > 2 {
+				return nil, ArgCountMismatch{n}
+			}
+		case *MapType, *ChannelType:
+			if len(args) > 1 {
+				return nil, ArgCountMismatch{n}
+			}
+		default:
+			return nil, BadArgument{t}
+		}
+		for i := range args {
+			if args[i], err = checkIndex(args[i], -1); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		n.results = []Type{t}
+
+	case "new":
+		n.results = []Type{&Star{Target: t, starLoc: t.Start()}}
+
+	case "panic", "print", "println":
+		for i := range args {
+			if _, ok := args[i].(*NilLiteral); ok && name == "panic" {
+				continue
+			}
+			if args[i], err = inferType(args[i]); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+	case "close":
+		if ch, ok := coreType(args[0].Type()).(*ChannelType); !ok || !ch.Send {
+			return nil, BadArgument{args[0]}
+		}
+
+	case "complex":
+		return n.checkComplex(iota, s)
+
+	case "real", "imag":
+		x := args[0]
+		if constOperand(x) && numeric(x.Type()) {
+			c := complexValue(x)
+			t := Type(Untyped(FloatConst))
+			if _, ok := c.typ.(Untyped); !ok {
+				if !isComplexNumber(c.typ) {
+					return nil, BadArgument{x}
+				}
+				t = floatType(c.typ)
+			}
+			v := c.Real
+			if name == "imag" {
+				v = c.Imaginary
+			}
+			return &FloatLiteral{Value: v, typ: t, span: s}, nil
+		}
+		if !isComplexNumber(x.Type()) {
+			return nil, BadArgument{x}
+		}
+		n.results = []Type{floatType(x.Type())}
+
+	case "recover":
+		n.results = []Type{&InterfaceType{}}
+	}
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if iota >= 0 {
+		return nil, NotConstant{n}
+	}
+	return n, nil
+}
+
+// HasLength returns whether the predeclared function len, or cap if name
+// is "cap", accepts values of the type.
+func hasLength(name string, t Type) bool {
+	switch t.Underlying().(type) {
+	case *MapType:
+		return name != "cap"
+	case *ChannelType, *SliceType:
+		return true
+	}
+	_, size := elementType(t)
+	return size >= 0 || IsString(t) && name != "cap"
+}
+
+// FloatType returns the floating point type of the components of a complex type.
+func floatType(t Type) Type {
+	if t.Underlying().(*TypeName).decl == Complex64 {
+		return float32Type
+	}
+	return float64Type
+}
+
+// CheckComplex checks a call to the predeclared complex function.
+func (n *Call) checkComplex(iota int, s span) (Expression, error) {
+	r, i := n.Arguments[0], n.Arguments[1]
+	if !IsComplex(r.Type()) || isComplexNumber(r.Type()) {
+		return nil, BadArgument{r}
+	}
+	if !IsComplex(i.Type()) || isComplexNumber(i.Type()) {
+		return nil, BadArgument{i}
+	}
+	_, rUntyped := r.Type().(Untyped)
+	_, iUntyped := i.Type().(Untyped)
+	var err error
+	switch {
+	case rUntyped && iUntyped:
+		if constOperand(r) && constOperand(i) {
+			return &ComplexLiteral{
+				Real:      complexValue(r).Real,
+				Imaginary: complexValue(i).Real,
+				typ:       Untyped(ComplexConst),
+				span:      s,
+			}, nil
+		}
+		if r, err = assign(r, float64Type); err == nil {
+			i, err = assign(i, float64Type)
+		}
+	case rUntyped:
+		r, err = assign(r, i.Type())
+	case iUntyped:
+		i, err = assign(i, r.Type())
+	case !r.Type().Identical(i.Type()):
+		err = BadArgument{i}
+	}
+	if err != nil {
+		return nil, err
+	}
+	n.Arguments[0], n.Arguments[1] = r, i
+	t := complex128Type
+	if r.Type().Underlying().(*TypeName).decl == Float32 {
+		t = complex64Type
+	}
+	if constOperand(r) && constOperand(i) {
+		return &ComplexLiteral{
+			Real:      complexValue(r).Real,
+			Imaginary: complexValue(i).Real,
+			typ:       t,
+			span:      s,
+		}, nil
+	}
+	n.results = []Type{t}
+	if iota >= 0 {
+		return nil, NotConstant{n}
+	}
+	return n, nil
+}
+
+// ComplexValue returns a new ComplexLiteral with the value and type of a
+// numeric constant operand.
+func complexValue(x Expression) *ComplexLiteral {
+	c := &ComplexLiteral{Real: new(big.Rat), Imaginary: new(big.Rat), typ: x.Type()}
+	switch l := x.(type) {
+	case *IntegerLiteral:
+		c.Real.SetInt(l.Value)
+	case *FloatLiteral:
+		c.Real.Set(l.Value)
+	case *ComplexLiteral:
+		c.Real.Set(l.Real)
+		c.Imaginary.Set(l.Imaginary)
+	default:
+		panic(fmt.Sprintf("not a numeric constant: %T", x))
+	}
+	return c
+}
+
+func (n *BinaryOp) Check(syms *Scope, iota int) (Expression, error) {
+	var errs errors
+	l, err := checkExpr(syms, n.Left, iota)
+	if err == nil {
+		l, err = singleValue(l)
+	}
+	if err != nil {
+		errs = append(errs, err)
+	}
+	r, err := checkExpr(syms, n.Right, iota)
+	if err == nil {
+		r, err = singleValue(r)
+	}
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if iota < 0 {
+		// In a ConstSpec, the same expression is checked once for each
+		// identifier with a different value for iota, so it is only
+		// modified outside of ConstSpecs.
+		n.Left, n.Right = l, r
+	}
+
+	var x Expression
+	switch n.Op {
+	case token.LessLess, token.GreaterGreater:
+		x, err = n.checkShift(l, r)
+	case token.EqualEqual, token.BangEqual, token.Less, token.LessEqual, token.Greater, token.GreaterEqual:
+		x, err = n.checkComparison(l, r)
+	default:
+		x, err = n.checkArith(l, r)
+	}
+	switch {
+	case err != nil:
+		return nil, err
+	case x != n:
+		return x, nil
+	case iota >= 0:
+		return nil, NotConstant{n}
+	}
+	return n, nil
+}
+
+// CheckArith checks an arithmetic, logical, or string concatenation
+// operation on checked operands. If both operands are constant, the
+// folded constant is returned.
+func (n *BinaryOp) checkArith(l, r Expression) (Expression, error) {
+	l, r, t, err := n.unify(l, r, false)
+	if err != nil {
+		return nil, err
+	}
+	var ok func(Type) bool
+	switch n.Op {
+	case token.Plus:
+		ok = func(t Type) bool { return numeric(t) || IsString(t) }
+	case token.Minus, token.Star, token.Divide:
+		ok = numeric
+	case token.Percent, token.And, token.Or, token.Carrot, token.AndCarrot:
+		ok = IsInteger
+	case token.AndAnd, token.OrOr:
+		ok = IsBool
+	default:
+		panic("bad binary op: " + n.Op.String())
+	}
+	if !allTypes(t, ok) {
+		return nil, InvalidOperation{n, n.Op, l}
+	}
+	if (n.Op == token.Divide || n.Op == token.Percent) && constOperand(r) && isZero(r) {
+		return nil, DivisionByZero{n}
+	}
+	if !constOperand(l) || !constOperand(r) {
+		n.typ = t
+		return n, nil
+	}
+
+	s := span{start: n.Start(), end: n.End()}
+	switch {
+	case IsString(t):
+		v := l.(*StringLiteral).Value + r.(*StringLiteral).Value
+		return &StringLiteral{Value: v, typ: t, span: s}, nil
+
+	case IsBool(t):
+		a, b := l.(*BoolLiteral).Value, r.(*BoolLiteral).Value
+		v := a && b
+		if n.Op == token.OrOr {
+			v = a || b
+		}
+		return &BoolLiteral{Value: v, typ: t, span: s}, nil
+
+	case IsInteger(t):
+		a, b := intValue(l), intValue(r)
+		v := new(big.Int)
+		switch n.Op {
+		case token.Plus:
+			v.Add(a, b)
+		case token.Minus:
+			v.Sub(a, b)
+		case token.Star:
+			v.Mul(a, b)
+		case token.Divide:
+			v.Quo(a, b)
+		case token.Percent:
+			v.Rem(a, b)
+		case token.And:
+			v.And(a, b)
+		case token.Or:
+			v.Or(a, b)
+		case token.Carrot:
+			v.Xor(a, b)
+		case token.AndCarrot:
+			v.AndNot(a, b)
+		}
+		return valueOKOrError(&IntegerLiteral{Value: v, Rune: t == Untyped(RuneConst), typ: t, span: s})
+
+	case isComplexNumber(t):
+		a, b := complexValue(l), complexValue(r)
+		re, im := new(big.Rat), new(big.Rat)
+		switch n.Op {
+		case token.Plus:
+			re.Add(a.Real, b.Real)
+			im.Add(a.Imaginary, b.Imaginary)
+		case token.Minus:
+			re.Sub(a.Real, b.Real)
+			im.Sub(a.Imaginary, b.Imaginary)
+		case token.Star:
+			re.Sub(new(big.Rat).Mul(a.Real, b.Real), new(big.Rat).Mul(a.Imaginary, b.Imaginary))
+			im.Add(new(big.Rat).Mul(a.Real, b.Imaginary), new(big.Rat).Mul(a.Imaginary, b.Real))
+		case token.Divide:
+			d := new(big.Rat).Add(new(big.Rat).Mul(b.Real, b.Real), new(big.Rat).Mul(b.Imaginary, b.Imaginary))
+			re.Add(new(big.Rat).Mul(a.Real, b.Real), new(big.Rat).Mul(a.Imaginary, b.Imaginary))
+			im.Sub(new(big.Rat).Mul(a.Imaginary, b.Real), new(big.Rat).Mul(a.Real, b.Imaginary))
+			re.Quo(re, d)
+			im.Quo(im, d)
+		}
+		return valueOKOrError(&ComplexLiteral{Real: re, Imaginary: im, typ: t, span: s})
+
+	default:
+		a, b := complexValue(l).Real, complexValue(r).Real
+		v := new(big.Rat)
+		switch n.Op {
+		case token.Plus:
+			v.Add(a, b)
+		case token.Minus:
+			v.Sub(a, b)
+		case token.Star:
+			v.Mul(a, b)
+		case token.Divide:
+			v.Quo(a, b)
+		}
+		return valueOKOrError(&FloatLiteral{Value: v, typ: t, span: s})
+	}
+}
+
+// CheckComparison checks a comparison operation on checked operands.
+// If both operands are constant, the folded constant is returned.
+func (n *BinaryOp) checkComparison(l, r Expression) (Expression, error) {
+	l, r, t, err := n.unify(l, r, true)
+	if err != nil {
+		return nil, err
+	}
+	switch n.Op {
+	case token.EqualEqual, token.BangEqual:
+		_, lNil := l.(*NilLiteral)
+		_, rNil := r.(*NilLiteral)
+		if !lNil && !rNil && !comparable(t) {
+			return nil, InvalidOperation{n, n.Op, l}
+		}
+	default:
+		if !allTypes(t, ordered) {
+			return nil, InvalidOperation{n, n.Op, l}
+		}
+	}
+	if !constOperand(l) || !constOperand(r) {
+		n.typ = Untyped(BoolConst)
+		return n, nil
+	}
+
+	var c int
+	switch {
+	case IsString(t):
+		a, b := l.(*StringLiteral).Value, r.(*StringLiteral).Value
+		switch {
+		case a < b:
+			c = -1
+		case a > b:
+			c = 1
+		}
+	case IsBool(t):
+		if l.(*BoolLiteral).Value != r.(*BoolLiteral).Value {
+			c = 1
+		}
+	default:
+		a, b := complexValue(l), complexValue(r)
+		c = a.Real.Cmp(b.Real)
+		if c == 0 {
+			c = a.Imaginary.Cmp(b.Imaginary)
+		}
+	}
+	var v bool
+	switch n.Op {
+	case token.EqualEqual:
+		v = c == 0
+	case token.BangEqual:
+		v = c != 0
+	case token.Less:
+		v = c < 0
+	case token.LessEqual:
+		v = c <= 0
+	case token.Greater:
+		v = c > 0
+	case token.GreaterEqual:
+		v = c >= 0
+	}
+	s := span{start: n.Start(), end: n.End()}
+	return &BoolLiteral{Value: v, typ: Untyped(BoolConst), span: s}, nil
+}
+
+// Ordered returns whether values of the type can be compared with <.
+func ordered(t Type) bool {
+	return IsInteger(t) || IsComplex(t) && !isComplexNumber(t) || IsString(t)
+}
+
+// MaxShift is the maximum shift count of a constant shift expression.
+const maxShift = 1 << 12
+
+// CheckShift checks a shift operation on checked operands. If both operands
+// are constant, the folded constant is returned.
+func (n *BinaryOp) checkShift(l, r Expression) (Expression, error) {
+	_, rUntyped := r.Type().(Untyped)
+	switch {
+	case rUntyped && !IsRepresentable(r, Untyped(IntegerConst)):
+		return nil, InvalidOperation{n, n.Op, r}
+	case !rUntyped && !allTypes(r.Type(), IsInteger):
+		return nil, InvalidOperation{n, n.Op, r}
+	case constOperand(r) && Negative(r):
+		return nil, InvalidOperation{n, n.Op, r}
+	}
+
+	t := l.Type()
+	if _, ok := t.(Untyped); ok && constOperand(l) {
+		if !IsRepresentable(l, Untyped(IntegerConst)) {
+			return nil, InvalidOperation{n, n.Op, l}
+		}
+		if t != Untyped(RuneConst) {
+			t = Untyped(IntegerConst)
+		}
+		if !constOperand(r) {
+			// BUG(eaburns): The left operand of a non-constant shift
+			// should be converted to the type that it would have if the
+			// shift were replaced by the left operand alone, not to its
+			// default type.
+			var err error
+			if l, err = assign(l, defaultType(t)); err != nil {
+				return nil, err
+			}
+			t = l.Type()
+		}
+	} else if !allTypes(t, IsInteger) {
+		return nil, InvalidOperation{n, n.Op, l}
+	}
+	if !constOperand(l) || !constOperand(r) {
+		n.typ = t
+		return n, nil
+	}
+
+	s := span{start: n.Start(), end: n.End()}
+	c := intValue(r)
+	if c.Cmp(big.NewInt(maxShift)) > 0 {
+		return nil, Unrepresentable{n, t}
+	}
+	v := new(big.Int)
+	if n.Op == token.LessLess {
+		v.Lsh(intValue(l), uint(c.Uint64()))
+	} else {
+		v.Rsh(intValue(l), uint(c.Uint64()))
+	}
+	return valueOKOrError(&IntegerLiteral{Value: v, Rune: t == Untyped(RuneConst), typ: t, span: s})
+}
+
+// Unify returns the operands of a binary operation converted to a common type,
+// along with the type. If one operand is untyped, it is converted to the type
+// of the other. If both are untyped constants, the type is the untyped kind
+// that appears later in the list: integer, rune, floating-point, complex.
+// If both are typed, the types must be identical or, for comparisons,
+// one must be assignable to the other.
+func (n *BinaryOp) unify(l, r Expression, comparison bool) (Expression, Expression, Type, error) {
+	lt, rt := l.Type(), r.Type()
+	lu, lUntyped := lt.(Untyped)
+	ru, rUntyped := rt.(Untyped)
+	switch {
+	case lUntyped && rUntyped:
+		if lu == ru && lu != Untyped(NilConst) {
+			return l, r, lt, nil
+		}
+		lr, lNum := numericRank[lu]
+		rr, rNum := numericRank[ru]
+		if !lNum || !rNum {
+			return nil, nil, nil, InvalidOperation{n, n.Op, r}
+		}
+		if lr > rr {
+			return l, r, lt, nil
+		}
+		return l, r, rt, nil
+
+	case lUntyped:
+		l, err := n.convert(l, rt)
+		return l, r, rt, err
+
+	case rUntyped:
+		r, err := n.convert(r, lt)
+		return l, r, lt, err
+
+	case lt.Identical(rt):
+		return l, r, lt, nil
+
+	case comparison && assignableTypes(rt, lt):
+		return l, r, lt, nil
+
+	case comparison && assignableTypes(lt, rt):
+		return l, r, rt, nil
+	}
+	return nil, nil, nil, InvalidOperation{n, n.Op, r}
+}
+
+// NumericRank gives the rank of the untyped numeric constant kinds. The kind
+// of the result of a binary operation on two untyped numeric constants is the
+// kind with the greatest rank.
+var numericRank = map[Untyped]int{
+	Untyped(IntegerConst): 0,
+	Untyped(RuneConst):    1,
+	Untyped(FloatConst):   2,
+	Untyped(ComplexConst): 3,
+}
+
+// Convert returns an untyped operand of the binary operation converted to the
+// type of the other operand.
+func (n *BinaryOp) convert(x Expression, t Type) (Expression, error) {
+	if IsAssignable(x, t) {
+		return assign(x, t)
+	}
+	if constOperand(x) && numeric(x.Type()) && allTypes(t, numeric) {
+		return nil, Unrepresentable{x, t}
+	}
+	return nil, InvalidOperation{n, n.Op, x}
+}
+
+// Comparable returns whether values of the type can be compared with ==.
+func comparable(t Type) bool {
+	if tp := typeParam(t); tp != nil {
+		if tp.iface.comparable {
+			return true
+		}
+		for _, term := range tp.iface.terms {
+			if !comparable(term.Type) {
+				return false
+			}
+		}
+		return tp.iface.restricted
+	}
+	switch t.Underlying().(type) {
+	case *SliceType, *MapType, *FunctionType:
+		return false
+	}
+	return t != Untyped(NilConst)
+}
+
+// IsZero returns whether a numeric constant operand is zero.
+func isZero(x Expression) bool {
+	if !numeric(x.Type()) {
+		return false
+	}
+	c := complexValue(x)
+	return c.Real.Sign() == 0 && c.Imaginary.Sign() == 0
+}
+
+// IntValue returns the value of a constant operand that is representable
+// as an integer.
+func intValue(x Expression) *big.Int {
+	if l, ok := x.(*IntegerLiteral); ok {
+		return l.Value
+	}
+	return complexValue(x).Real.Num()
+}
+
+func (n *UnaryOp) Check(syms *Scope, iota int) (Expression, error) {
+	x, err := checkExpr(syms, n.Operand, iota)
+	if err == nil {
+		x, err = singleValue(x)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if iota < 0 {
+		// See the comment in BinaryOp.Check.
+		n.Operand = x
+	}
+	t := x.Type()
+	s := span{start: n.Start(), end: n.End()}
+
+	switch n.Op {
+	case token.Plus:
+		if !allTypes(t, numeric) {
+			return nil, InvalidOperation{n, n.Op, x}
+		}
+		if constOperand(x) {
+			return copyConst(x, s), nil
+		}
+
+	case token.Minus:
+		if !allTypes(t, numeric) {
+			return nil, InvalidOperation{n, n.Op, x}
+		}
+		if constOperand(x) {
+			switch l := copyConst(x, s).(type) {
+			case *IntegerLiteral:
+				l.Value.Neg(l.Value)
+				return valueOKOrError(l)
+			case *FloatLiteral:
+				l.Value.Neg(l.Value)
+				return valueOKOrError(l)
+			case *ComplexLiteral:
+				l.Real.Neg(l.Real)
+				l.Imaginary.Neg(l.Imaginary)
+				return valueOKOrError(l)
+			}
+		}
+
+	case token.Bang:
+		if !allTypes(t, IsBool) {
+			return nil, InvalidOperation{n, n.Op, x}
+		}
+		if constOperand(x) {
+			l := copyConst(x, s).(*BoolLiteral)
+			l.Value = !l.Value
+			return l, nil
+		}
+
+	case token.Carrot:
+		if !allTypes(t, IsInteger) {
+			return nil, InvalidOperation{n, n.Op, x}
+		}
+		if constOperand(x) {
+			l, _ := x.(*IntegerLiteral)
+			v := new(big.Int).Not(intValue(x))
+			if tn, ok := t.Underlying().(*TypeName); ok && bounds[tn.decl.(predeclaredType)].min.Sign() == 0 {
+				// For unsigned types, ^x is x XOR the all-ones mask.
+				v.Xor(intValue(x), bounds[tn.decl.(predeclaredType)].max)
+			}
+			return valueOKOrError(&IntegerLiteral{Value: v, Rune: l != nil && l.Rune, typ: t, span: s})
+		}
+
+	case token.And:
+		if _, ok := x.(*CompositeLiteral); !ok && !addressable(x) {
+			return nil, InvalidOperation{n, n.Op, x}
+		}
+		t = &Star{Target: t, starLoc: n.opLoc}
+
+	case token.LessMinus:
+		ch, ok := coreType(t).(*ChannelType)
+		if !ok || !ch.Receive {
+			return nil, InvalidOperation{n, n.Op, x}
+		}
+		t = ch.Element
+
+	case token.Star:
+		p, ok := coreType(t).(*Star)
+		if !ok {
+			return nil, InvalidOperation{n, n.Op, x}
+		}
+		t = p.Target.(Type)
+
+	default:
+		panic("bad unary op: " + n.Op.String())
+	}
+
+	if iota >= 0 {
+		return nil, NotConstant{x}
+	}
+	n.typ = t
+	return n, nil
+}
+
+// ValueOKOrError returns the literal expression if its value is representable
+// by its type, otherwise it returns an error.
+func valueOKOrError(l Expression) (Expression, error) {
+	if !IsRepresentable(l, l.Type()) {
+		return nil, Unrepresentable{l, l.Type()}
+	}
+	return l, nil
+}
+
+func (n *ConstSpec) Check() error {
+	switch n.state {
+	case checking:
+		panic("impossible, not recursive")
+	case checkedError:
+		return errors{}
+	case checkedOK:
+		return nil
+	}
+
+	var errs errors
+	if n.Type != nil {
+		if t, err := checkExpr(n.syms, n.Type, -1); err != nil {
+			errs = append(errs, err)
+			n.Type = nil
+		} else {
+			n.Type = t.(Type)
+		}
+	}
+	if len(n.Identifiers) != len(n.Values) {
+		errs = append(errs, AssignCountMismatch{n})
+	}
+	if len(errs) > 0 {
+		n.state = checkedError
+	} else {
+		n.state = checkedOK
+	}
+	for _, v := range n.views {
+		if _, err := v.Check(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+func (n *constSpecView) Check() (v Expression, err error) {
+	defer func() {
+		if err != nil {
+			n.state = checkedError
+			return
+		}
+		n.Value = v
+		n.state = checkedOK
+	}()
+	switch n.state {
+	case checking:
+		return nil, ConstantLoop{n}
+	case checkedError:
+		return nil, errors{}
+	case checkedOK:
+		return n.Value, nil
+	}
+
+	if n.Index >= len(n.Values) {
+		// This constant has no expression, but the error will be
+		// reported when checking the ConstSpec instead of here.
+		return nil, errors{}
+	}
+	v = n.Values[n.Index]
+
+	var errs errors
+	if err := n.ConstSpec.Check(); err != nil {
+		errs = append(errs, err)
+	}
+	// If the type is specified in the ConstSpec, then all views get that type.
+	// Otherwise, this will assign nil, and each view gets the type from its
+	// bound expression.
+	n.Type = n.ConstSpec.Type
+
+	n.state = checking
+	v, err = checkExpr(n.syms, v, n.Iota)
+	switch {
+	case err != nil:
+		return nil, append(errs, err)
+	case !constOperand(v):
+		return nil, append(errs, NotConstant{v})
+	}
+
+	switch _, vIsUntyped := v.Type().(Untyped); {
+	case n.Type != nil && vIsUntyped && !IsRepresentable(v, n.Type):
+		return nil, append(errs, Unrepresentable{v, n.Type})
+	case n.Type != nil && !IsAssignable(v, n.Type):
+		return nil, append(errs, BadAssign{v, n.Type})
+	case n.Type == nil:
+		n.Type = v.Type()
+	}
+	if n.Type != nil {
+		// All Literals, which this must be after folding, have a SetType method.
+		v.(interface {
+			SetType(Type)
+		}).SetType(n.Type)
+	}
+	return v, nil
+}
+
+// ConstOperand returns true if the expression is a constant operand.
+// A constant operand is the result of constant folding on a truly
+// constant expression.
+func constOperand(e Expression) bool {
+	switch e.(type) {
+	case *IntegerLiteral, *FloatLiteral, *ComplexLiteral, *StringLiteral, *BoolLiteral:
+		return true
+	}
+	return false
+}
+
+func (n *Identifier) Check(syms *Scope, iota int) (Expression, error) {
+	n.decl = syms.Find(n.Name)
+	if n.decl == nil {
+		return nil, Undeclared{n}
+	}
+	syms.info.use(n, n.decl)
+	switch d := n.decl.(type) {
+	case *predeclaredConst:
+		switch n.Name {
+		case "iota":
+			if iota < 0 {
+				return nil, Undeclared{n}
+			}
+			v := big.NewInt(int64(iota))
+			return (&IntegerLiteral{Value: v, span: n.span}).Check(syms, iota)
+		case "true":
+			return (&BoolLiteral{Value: true, span: n.span}).Check(syms, iota)
+		case "false":
+			return (&BoolLiteral{Value: false, span: n.span}).Check(syms, iota)
+		case "nil":
+			return (&NilLiteral{span: n.span}).Check(syms, iota)
+		default:
+			panic("unknown predeclared constant: " + n.Name)
+		}
+
+	case *constSpecView:
+		v, err := d.Check()
+		if err != nil {
+			return nil, err
+		}
+		// The value is copied, because folding may modify it.
+		return copyConst(v, n.span), nil
+
+	case predeclaredType, *TypeSpec, *TypeParameter:
+		return (&TypeName{Identifier: *n}).Check(syms, iota)
+
+	case *varSpecView:
+		if err := d.Check(); err != nil {
+			return nil, err
+		}
+		return n, nil
+
+	case *shortVarDeclView, *recvStmtView, *typeSwitchView:
+		if n.Type() == nil {
+			return nil, errors{}
+		}
+		return n, nil
+
+	case *ParameterDecl:
+		return n, nil
+
+	case *FunctionDecl:
+		if err := d.checkSignature(); err != nil {
+			return nil, err
+		}
+		return n, nil
+
+	case *packageDecl, *predeclaredFunc:
+		return nil, NotExpression{n}
+
+	default:
+		panic(fmt.Sprintf("unimplemented identifier type: %T", d))
+	}
+}
+
+// CopyConst returns a copy of a constant operand with the given span.
+func copyConst(v Expression, s span) Expression {
+	switch l := v.(type) {
+	case *IntegerLiteral:
+		c := *l
+		c.Value = new(big.Int).Set(l.Value)
+		c.span = s
+		return &c
+	case *FloatLiteral:
+		c := *l
+		c.Value = new(big.Rat).Set(l.Value)
+		c.span = s
+		return &c
+	case *ComplexLiteral:
+		c := *l
+		c.Real = new(big.Rat).Set(l.Real)
+		c.Imaginary = new(big.Rat).Set(l.Imaginary)
+		c.span = s
+		return &c
+	case *StringLiteral:
+		c := *l
+		c.span = s
+		return &c
+	case *BoolLiteral:
+		c := *l
+		c.span = s
+		return &c
+	default:
+		panic(fmt.Sprintf("not a constant operand: %T", v))
+	}
+}
+
+func (n *IntegerLiteral) Check(*Scope, int) (Expression, error) {
+	if n.Rune {
+		n.typ = Untyped(RuneConst)
+	} else {
+		n.typ = Untyped(IntegerConst)
+	}
+	return n, nil
+}
+
+func (n *FloatLiteral) Check(*Scope, int) (Expression, error) {
+	n.typ = Untyped(FloatConst)
+	return n, nil
+}
+
+func (n *ComplexLiteral) Check(*Scope, int) (Expression, error) {
+	n.typ = Untyped(ComplexConst)
+	return n, nil
+}
+
+func (n *StringLiteral) Check(*Scope, int) (Expression, error) {
+	n.typ = Untyped(StringConst)
+	return n, nil
+}
+
+func (n *BoolLiteral) Check(*Scope, int) (Expression, error) {
+	n.typ = Untyped(BoolConst)
+	return n, nil
+}
+
+func (n *NilLiteral) Check(*Scope, int) (Expression, error) {
+	n.typ = Untyped(NilConst)
+	return n, nil
+}
+
+// Check returns an errors, because the syntax error for a BadExpr
+// is reported by the parser.
+func (n *BadExpr) Check(*Scope, int) (Expression, error) { return nil, errors{} }
+
+func (n Untyped) Check(*Scope, int) (Expression, error) { return n, nil }
+
+func (n Untyped) check(*Scope, int, map[string]bool) (Type, error) { return n, nil }
+
+// IsType returns whether the expression is a type. A FunctionLiteral
+// embeds a FunctionType, but it is not a type.
+func isType(e Expression) bool {
+	switch e := e.(type) {
+	case *FunctionLiteral:
+		return false
+	case *Instance:
+		// An instance of a generic function is not a type.
+		return e.decl != nil
+	}
+	_, ok := e.(Type)
+	return ok
+}
+
+// CheckType checks an expression that must be a type, returning the
+// replacement type and any errors.
+func checkType(syms *Scope, t Type) (Type, error) {
+	x, err := checkExpr(syms, t, -1)
+	if err != nil {
+		return nil, err
+	}
+	if !isType(x) {
+		return nil, NotType{x}
+	}
+	return x.(Type), nil
+}
+
+// VarType returns an error if the checked type is an interface that may only
+// be used as a type constraint, because its type set is restricted or it
+// embeds comparable. Such an interface may not be the type of a variable,
+// nor the element of a composite type, nor a type argument. A declared
+// type that is still being checked is not reported, since its underlying
+// type is not yet known.
+func varType(t Type) error {
+	var d *TypeSpec
+	switch t := t.(type) {
+	case *TypeName:
+		d, _ = t.decl.(*TypeSpec)
+	case *Instance:
+		d = t.decl
+	}
+	if d != nil && d.state != checkedOK {
+		return nil
+	}
+	iface, ok := t.Underlying().(*InterfaceType)
+	if ok && typeParam(t) == nil && (iface.restricted || iface.comparable) {
+		return ConstraintInterface{t}
+	}
+	return nil
+}
+
+// CheckValue checks an expression that must be a single value, returning
+// the replacement expression and any errors.
+func checkValue(syms *Scope, x Expression) (Expression, error) {
+	x, err := checkExpr(syms, x, -1)
+	if err != nil {
+		return nil, err
+	}
+	return singleValue(x)
+}
+
+// SingleValue returns an error if a checked expression is not a single value:
+// if it is a type or a call that does not have exactly one result.
+func singleValue(x Expression) (Expression, error) {
+	if isType(x) {
+		return nil, NotExpression{x}
+	}
+	if genericFunc(x) != nil {
+		return nil, NotInstantiated{x}
+	}
+	if c, ok := x.(*Call); ok && len(c.results) != 1 {
+		return nil, NotSingleValue{x}
+	}
+	return x, nil
+}
+
+// Assign returns an error if a checked, single-valued expression is not
+// assignable to the type t. If the expression is untyped, its type
+// is set to t.
+func assign(x Expression, t Type) (Expression, error) {
+	xt := x.Type()
+	if !IsAssignable(x, t) {
+		if _, ok := xt.(Untyped); ok && constOperand(x) && numeric(xt) && numeric(t) {
+			return nil, Unrepresentable{x, t}
+		}
+		return nil, assignError(x, xt, t)
+	}
+	if _, ok := xt.(Untyped); !ok {
+		return x, nil
+	}
+	if _, ok := t.Underlying().(*InterfaceType); ok {
+		// An untyped constant assigned to an interface
+		// is first converted to its default type.
+		t = defaultType(xt)
+	}
+	if l, ok := x.(interface {
+		SetType(Type)
+	}); ok {
+		l.SetType(t)
+	}
+	return x, nil
+}
+
+// AssignError returns the error for a value of type xt that is not assignable
+// to the type t. If t is an interface type, the error reports the methods that
+// are missing from the method set of xt.
+func assignError(x Expression, xt, t Type) error {
+	if iface, ok := t.Underlying().(*InterfaceType); ok {
+		missing, wrong := missingMethods(defaultType(xt), iface)
+		if len(missing) > 0 || len(wrong) > 0 {
+			return NotImplemented{x, t, missing, wrong}
+		}
+	}
+	return BadAssign{x, t}
+}
+
+// InferType returns the expression converted to the default type if it is
+// an untyped constant. It returns an error if the expression is nil, which has
+// no default type.
+func inferType(x Expression) (Expression, error) {
+	if _, ok := x.(*NilLiteral); ok {
+		return nil, UntypedNil{x}
+	}
+	xt, ok := x.Type().(Untyped)
+	if !ok {
+		return x, nil
+	}
+	return assign(x, defaultType(xt))
+}
+
+// ValueTypes returns the types of the values of a checked expression that
+// is assigned to n variables. If n is 2, then the expression may be a comma-ok
+// expression: a map index, a type assertion, or a receive operation. The
+// declaration d is the declaration or statement reported for a count mismatch.
+func valueTypes(d Declaration, x Expression, n int) ([]Type, error) {
+	if c, ok := x.(*Call); ok && len(c.results) != 1 {
+		if len(c.results) != n {
+			return nil, AssignCountMismatch{d}
+		}
+		return c.results, nil
+	}
+	if isType(x) {
+		return nil, NotExpression{x}
+	}
+	switch {
+	case n == 1:
+		return []Type{x.Type()}, nil
+	case n == 2 && commaOK(x):
+		return []Type{x.Type(), Untyped(BoolConst)}, nil
+	}
+	return nil, AssignCountMismatch{d}
+}
+
+// CommaOK returns whether the checked expression may be used in a
+// comma-ok assignment.
+func commaOK(x Expression) bool {
+	switch x := x.(type) {
+	case *Index:
+		return isMapIndex(x)
+	case *TypeAssertion:
+		return true
+	case *UnaryOp:
+		return x.Op == token.LessMinus
+	}
+	return false
+}
+
+// IsMapIndex returns whether the checked expression is a map index expression.
+func isMapIndex(x Expression) bool {
+	i, ok := x.(*Index)
+	if !ok {
+		return false
+	}
+	_, ok = coreType(i.Expression.Type()).(*MapType)
+	return ok
+}
+
+// Addressable returns whether the checked expression is addressable: a variable,
+// a pointer indirection, a slice index operation, or an array index
+// operation of an addressable array.
+func addressable(x Expression) bool {
+	switch x := x.(type) {
+	case *Identifier:
+		return isVariable(x.decl)
+	case *UnaryOp:
+		return x.Op == token.Star
+	case *Index:
+		switch coreType(x.Expression.Type()).(type) {
+		case *SliceType, *Star:
+			return true
+		case *ArrayType:
+			return addressable(x.Expression)
+		}
+	case *Selector:
+		return x.field != nil && (x.indirect || addressable(x.Parent))
+	}
+	return false
+}
+
+// IsBlank returns whether the expression is the blank identifier.
+func isBlank(x Expression) bool {
+	id, ok := x.(*Identifier)
+	return ok && id.Name == "_"
+}
+
+// CheckStmts checks a sequence of statements in the scope of syms.
+// The signature is that of the innermost enclosing function.
+func checkStmts(syms *Scope, sig *Signature, stmts []Statement) error {
+	var errs errors
+	for _, s := range stmts {
+		if s == nil {
+			// An empty statement.
+			continue
+		}
+		if err := s.check(syms, sig); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// CheckSimpleStmt checks a simple statement, which may be nil.
+func checkSimpleStmt(syms *Scope, sig *Signature, s Statement) error {
+	if s == nil {
+		return nil
+	}
+	return s.check(syms, sig)
+}
+
+// CheckCondition checks an expression that must be a boolean.
+func checkCondition(syms *Scope, x Expression) (Expression, error) {
+	x, err := checkValue(syms, x)
+	if err != nil {
+		return nil, err
+	}
+	if !IsBool(x.Type()) {
+		return nil, BadCondition{x}
+	}
+	return x, nil
+}
+
+func (n *Select) check(syms *Scope, sig *Signature) error {
+	var errs errors
+	for i := range n.Cases {
+		c := &n.Cases[i]
+		csyms := makeScope(syms, c)
+		var err error
+		switch {
+		case c.Receive != nil:
+			err = c.Receive.check(csyms, sig)
+		case c.Send != nil:
+			err = c.Send.check(csyms, sig)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if err := checkStmts(csyms, sig, c.Statements); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+func (n *RecvStmt) check(syms *Scope, sig *Signature) error {
+	var errs errors
+	var types []Type
+	if x, err := checkExpr(syms, &n.Right, -1); err != nil {
+		errs = append(errs, err)
+	} else if types, err = valueTypes(n, x, len(n.Left)); err != nil {
+		errs = append(errs, err)
+	}
+
+	if n.Op == token.ColonEqual {
+		for i := range n.Left {
+			id := n.Left[i].(*Identifier)
+			v := &recvStmtView{RecvStmt: n, Index: i}
+			if types != nil {
+				v.Type = defaultType(types[i])
+			}
+			id.decl = v
+			if err := syms.declare(id, v); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errs.ErrorOrNil()
+	}
+
+	for i := range n.Left {
+		var t Type
+		if types != nil {
+			t = types[i]
+		}
+		if err := assignType(syms, &n.Left[i], t, &n.Right); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// AssignType checks an expression on the left-hand side of an assignment to
+// which a value of type t is assigned. The expression x is the value being
+// assigned, which is used for error reporting. If t is nil, then only the
+// left-hand side is checked.
+func assignType(syms *Scope, l *Expression, t Type, x Expression) error {
+	if isBlank(*l) {
+		return nil
+	}
+	v, err := checkLHS(syms, *l)
+	if err != nil {
+		return err
+	}
+	*l = v
+	if t != nil && !assignableTypes(t, v.Type()) {
+		return assignError(x, t, v.Type())
+	}
+	return nil
+}
+
+// CheckLHS checks an expression on the left-hand side of an assignment,
+// which must be addressable or a map index expression.
+func checkLHS(syms *Scope, l Expression) (Expression, error) {
+	l, err := checkValue(syms, l)
+	if err != nil {
+		return nil, err
+	}
+	if !addressable(l) && !isMapIndex(l) {
+		return nil, Unassignable{l}
+	}
+	return l, nil
+}
+
+func (n *ExprSwitch) check(syms *Scope, sig *Signature) error {
+	var errs errors
+	ssyms := makeScope(syms, n)
+	if err := checkSimpleStmt(ssyms, sig, n.Initialization); err != nil {
+		errs = append(errs, err)
+	}
+	var t Type
+	if n.Expression != nil {
+		x, err := checkValue(ssyms, n.Expression)
+		if err == nil {
+			x, err = inferType(x)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			n.Expression = x
+			t = x.Type()
+		}
+	}
+	for i := range n.Cases {
+		c := &n.Cases[i]
+		for j := range c.Expressions {
+			x, err := checkValue(ssyms, c.Expressions[j])
+			switch {
+			case err != nil:
+				errs = append(errs, err)
+				continue
+			case n.Expression == nil:
+				if !IsBool(x.Type()) {
+					errs = append(errs, BadCondition{x})
+					continue
+				}
+			case t != nil:
+				if !IsAssignable(x, t) && !assignableTypes(t, x.Type()) {
+					errs = append(errs, InvalidOperation{x, token.EqualEqual, n.Expression})
+					continue
+				}
+				if _, ok := x.Type().(Untyped); ok {
+					if x, err = assign(x, t); err != nil {
+						errs = append(errs, err)
+						continue
+					}
+				}
+			}
+			c.Expressions[j] = x
+		}
+		if err := checkStmts(makeScope(ssyms, c), sig, c.Statements); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+func (n *TypeSwitch) check(syms *Scope, sig *Signature) error {
+	var errs errors
+	ssyms := makeScope(syms, n)
+	if err := checkSimpleStmt(ssyms, sig, n.Initialization); err != nil {
+		errs = append(errs, err)
+	}
+	var t Type
+	if x, err := checkValue(ssyms, n.Expression); err != nil {
+		errs = append(errs, err)
+	} else if _, ok := coreType(x.Type()).(*InterfaceType); !ok {
+		errs = append(errs, NotInterface{x})
+	} else {
+		n.Expression = x
+		t = x.Type()
+	}
+
+	for i := range n.Cases {
+		c := &n.Cases[i]
+		typesOK := true
+		for j := range c.Types {
+			if tn, ok := c.Types[j].(*TypeName); ok && tn.Package == nil {
+				if _, ok := ssyms.Find(tn.Name).(*predeclaredConst); ok && tn.Name == "nil" {
+					// The nil case is left as a TypeName.
+					tn.decl = ssyms.Find(tn.Name)
+					ssyms.info.use(&tn.Identifier, tn.decl)
+					continue
+				}
+			}
+			ct, err := checkType(ssyms, c.Types[j])
+			if err != nil {
+				errs = append(errs, err)
+				typesOK = false
+				continue
+			}
+			c.Types[j] = ct
+		}
+
+		csyms := makeScope(ssyms, c)
+		if n.Declaration != nil {
+			v := &typeSwitchView{TypeSwitch: n, Type: t}
+			if typesOK && len(c.Types) == 1 && isType(c.Types[0]) {
+				if tn, ok := c.Types[0].(*TypeName); !ok || tn.Name != "nil" || !isNilCase(tn) {
+					v.Type = c.Types[0]
+				}
+			}
+			if err := csyms.Bind(n.Declaration.Name, v); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err := checkStmts(csyms, sig, c.Statements); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// IsNilCase returns whether the TypeName in a type switch case is the
+// predeclared identifier nil.
+func isNilCase(tn *TypeName) bool {
+	_, ok := tn.decl.(*predeclaredConst)
+	return ok
+}
+
+func (n *ForStmt) check(syms *Scope, sig *Signature) error {
+	var errs errors
+	fsyms := makeScope(syms, n)
+	switch r := n.Range.(type) {
+	case *ShortVarDecl:
+		if err := r.checkRange(fsyms); err != nil {
+			errs = append(errs, err)
+		}
+	case *Assignment:
+		if err := r.checkRange(fsyms); err != nil {
+			errs = append(errs, err)
+		}
+	case nil:
+		if err := checkSimpleStmt(fsyms, sig, n.Initialization); err != nil {
+			errs = append(errs, err)
+		}
+		if n.Condition != nil {
+			if x, err := checkCondition(fsyms, n.Condition); err != nil {
+				errs = append(errs, err)
+			} else {
+				n.Condition = x
+			}
+		}
+		if err := checkSimpleStmt(fsyms, sig, n.Post); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := n.Block.check(fsyms, sig); err != nil {
+		errs = append(errs, err)
+	}
+	return errs.ErrorOrNil()
+}
+
+// RangeTypes returns the types of the iteration values of a range clause
+// over the checked expression x.
+func rangeTypes(x Expression) ([]Type, error) {
+	t := coreType(x.Type())
+	if t == nil {
+		return nil, InvalidOperation{x, token.Range, x}
+	}
+	if IsString(t) {
+		return []Type{intType, runeType}, nil
+	}
+	if elm, _ := elementType(t); elm != nil {
+		return []Type{intType, elm}, nil
+	}
+	switch t := t.(type) {
+	case *MapType:
+		return []Type{t.Key, t.Value}, nil
+	case *ChannelType:
+		if t.Receive {
+			return []Type{t.Element}, nil
+		}
+	}
+	return nil, InvalidOperation{x, token.Range, x}
+}
+
+// CheckRange checks a ShortVarDecl that is the range clause of a for loop.
+func (n *ShortVarDecl) checkRange(syms *Scope) error {
+	var errs errors
+	var types []Type
+	x, err := checkValue(syms, n.Right[0])
+	if err == nil {
+		x, err = inferType(x)
+	}
+	if err == nil {
+		n.Right[0] = x
+		types, err = rangeTypes(x)
+	}
+	if err != nil {
+		errs = append(errs, err)
+	} else if len(n.Left) > len(types) {
+		errs = append(errs, AssignCountMismatch{n})
+		types = nil
+	}
+	for i := range n.Left {
+		v := &shortVarDeclView{ShortVarDecl: n, Index: i}
+		if types != nil {
+			v.Type = types[i]
+		}
+		n.Left[i].decl = v
+		if err := syms.declare(&n.Left[i], v); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// CheckRange checks an Assignment that is the range clause of a for loop.
+func (n *Assignment) checkRange(syms *Scope) error {
+	var errs errors
+	var types []Type
+	x, err := checkValue(syms, n.Right[0])
+	if err == nil {
+		x, err = inferType(x)
+	}
+	if err == nil {
+		n.Right[0] = x
+		types, err = rangeTypes(x)
+	}
+	if err != nil {
+		errs = append(errs, err)
+	} else if len(n.Left) > len(types) {
+		errs = append(errs, AssignCountMismatch{n})
+		types = nil
+	}
+	for i := range n.Left {
+		var t Type
+		if types != nil {
+			t = types[i]
+		}
+		if err := assignType(syms, &n.Left[i], t, x); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+func (n *IfStmt) check(syms *Scope, sig *Signature) error {
+	var errs errors
+	isyms := makeScope(syms, n)
+	if err := checkSimpleStmt(isyms, sig, n.Statement); err != nil {
+		errs = append(errs, err)
+	}
+	if x, err := checkCondition(isyms, n.Condition); err != nil {
+		errs = append(errs, err)
+	} else {
+		n.Condition = x
+	}
+	if err := n.Block.check(isyms, sig); err != nil {
+		errs = append(errs, err)
+	}
+	if n.Else != nil {
+		if err := n.Else.check(isyms, sig); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+func (n *BlockStmt) check(syms *Scope, sig *Signature) error {
+	return checkStmts(makeScope(syms, n), sig, n.Statements)
+}
+
+func (n *DeferStmt) check(syms *Scope, _ *Signature) error {
+	x, err := checkCallStmt(syms, n.Expression)
+	if err != nil {
+		return err
+	}
+	n.Expression = x
+	return nil
+}
+
+func (n *GoStmt) check(syms *Scope, _ *Signature) error {
+	x, err := checkCallStmt(syms, n.Expression)
+	if err != nil {
+		return err
+	}
+	n.Expression = x
+	return nil
+}
+
+// StmtBuiltins are the predeclared functions that may be called in
+// statement context.
+var stmtBuiltins = map[string]bool{
+	"close":   true,
+	"copy":    true,
+	"delete":  true,
+	"panic":   true,
+	"print":   true,
+	"println": true,
+	"recover": true,
+}
+
+// CheckCallStmt checks the expression of a go or defer statement, which
+// must be a function call.
+func checkCallStmt(syms *Scope, x Expression) (Expression, error) {
+	if _, ok := x.(*Call); !ok {
+		return nil, NotCall{x}
+	}
+	x, err := checkExpr(syms, x, -1)
+	if err != nil {
+		return nil, err
+	}
+	if !isStmtCall(x) {
+		return nil, NotCall{x}
+	}
+	return x, nil
+}
+
+// IsStmtCall returns whether the checked expression is a call that may be
+// used in statement context: a call that is neither a conversion nor a call
+// to a predeclared function that is not in stmtBuiltins.
+func isStmtCall(x Expression) bool {
+	c, ok := x.(*Call)
+	if !ok || isType(c.Function) {
+		return false
+	}
+	if id, ok := c.Function.(*Identifier); ok {
+		if _, ok := id.decl.(*predeclaredFunc); ok {
+			return stmtBuiltins[id.Name]
+		}
+	}
+	return true
+}
+
+func (n *ReturnStmt) check(syms *Scope, sig *Signature) error {
+	res := sig.Results
+	switch {
+	case len(n.Expressions) == 0:
+		if len(res) > 0 && res[0].Identifier == nil {
+			return AssignCountMismatch{n}
+		}
+		return nil
+
+	case len(res) == 0:
+		return AssignCountMismatch{n}
+
+	case len(n.Expressions) == len(res):
+		var errs errors
+		for i := range n.Expressions {
+			x, err := checkValue(syms, n.Expressions[i])
+			if err == nil {
+				x, err = assign(x, res[i].Type)
+			}
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			n.Expressions[i] = x
+		}
+		return errs.ErrorOrNil()
+
+	case len(n.Expressions) == 1:
+		x, err := checkExpr(syms, n.Expressions[0], -1)
+		if err != nil {
+			return err
+		}
+		n.Expressions[0] = x
+		types, err := valueTypes(n, x, len(res))
+		if err != nil {
+			return err
+		}
+		var errs errors
+		for i, t := range types {
+			if !assignableTypes(t, res[i].Type) {
+				errs = append(errs, assignError(x, t, res[i].Type))
+			}
+		}
+		return errs.ErrorOrNil()
+	}
+	return AssignCountMismatch{n}
+}
+
+// BUG(eaburns): Check that labels of break, continue, and goto statements are
+// declared, and that break, continue, and fallthrough statements are within
+// a statement to which they apply.
+
+func (n *FallthroughStmt) check(*Scope, *Signature) error { return nil }
+
+func (n *ContinueStmt) check(*Scope, *Signature) error { return nil }
+
+func (n *BreakStmt) check(*Scope, *Signature) error { return nil }
+
+func (n *GotoStmt) check(*Scope, *Signature) error { return nil }
+
+func (n *LabeledStmt) check(syms *Scope, sig *Signature) error {
+	return checkSimpleStmt(syms, sig, n.Statement)
+}
+
+func (n *DeclarationStmt) check(syms *Scope, _ *Signature) error {
+	var errs errors
+	for _, d := range n.Declarations {
+		switch d := d.(type) {
+		case *TypeSpec:
+			// The scope of a type begins at its identifier,
+			// so it is bound before it is checked.
+			d.syms = syms
+			if err := syms.declare(&d.Identifier, d); err != nil {
+				errs = append(errs, err)
+			}
+			if err := d.Check(); err != nil {
+				errs = append(errs, err)
+			}
+
+		case *ConstSpec:
+			d.syms = syms
+			for i := range d.Identifiers {
+				d.views = append(d.views, &constSpecView{Index: i, ConstSpec: d})
+			}
+			if err := d.Check(); err != nil {
+				errs = append(errs, err)
+			}
+			for i, v := range d.views {
+				d.Identifiers[i].decl = v
+				if err := syms.declare(&d.Identifiers[i], v); err != nil {
+					errs = append(errs, err)
+				}
+			}
+
+		case *VarSpec:
+			d.syms = syms
+			for i := range d.Identifiers {
+				d.views = append(d.views, &varSpecView{Index: i, VarSpec: d})
+			}
+			if err := d.Check(); err != nil {
+				errs = append(errs, err)
+			}
+			for i, v := range d.views {
+				d.Identifiers[i].decl = v
+				if err := syms.declare(&d.Identifiers[i], v); err != nil {
+					errs = append(errs, err)
+				}
+			}
+
+		default:
+			panic(fmt.Sprintf("bad declaration statement: %T", d))
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+func (n *ShortVarDecl) check(syms *Scope, _ *Signature) error {
+	var errs errors
+	var types []Type
+	switch {
+	case len(n.Left) == len(n.Right):
+		for i := range n.Right {
+			x, err := checkValue(syms, n.Right[i])
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if d, ok := syms.Decls[n.Left[i].Name]; ok && isVariable(d) {
+				// Redeclared variables are simply assigned.
+				n.Left[i].decl = d
+				if t := n.Left[i].Type(); t != nil {
+					x, err = assign(x, t)
+				} else {
+					// The variable's error is already reported.
+					x, err = inferType(x)
+				}
+			} else {
+				x, err = inferType(x)
+			}
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			n.Right[i] = x
+		}
+		if len(errs) == 0 {
+			for _, x := range n.Right {
+				types = append(types, defaultType(x.Type()))
+			}
+		}
+
+	case len(n.Right) == 1:
+		x, err := checkExpr(syms, n.Right[0], -1)
+		if err == nil {
+			n.Right[0] = x
+			types, err = valueTypes(n, x, len(n.Left))
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for i, t := range types {
+			types[i] = defaultType(t)
+			id := &n.Left[i]
+			if d, ok := syms.Decls[id.Name]; ok && isVariable(d) {
+				id.decl = d
+				if id.Type() != nil && !assignableTypes(t, id.Type()) {
+					errs = append(errs, assignError(x, t, id.Type()))
+				}
+			}
+		}
+
+	default:
+		errs = append(errs, AssignCountMismatch{n})
+	}
+
+	newVars := false
+	for i := range n.Left {
+		id := &n.Left[i]
+		if d, ok := syms.Decls[id.Name]; ok && isVariable(d) {
+			id.decl = d
+			continue
+		}
+		v := &shortVarDeclView{ShortVarDecl: n, Index: i}
+		if types != nil {
+			v.Type = types[i]
+		}
+		id.decl = v
+		if err := syms.declare(id, v); err != nil {
+			errs = append(errs, err)
+		}
+		if id.Name != "_" {
+			newVars = true
+		}
+	}
+	if !newVars {
+		errs = append(errs, NoNewVariables{n})
+	}
+	return errs.ErrorOrNil()
+}
+
+// AssignBinaryOps maps each assignment operator to its binary operator.
+var assignBinaryOps = map[token.Token]token.Token{
+	token.PlusEqual:           token.Plus,
+	token.MinusEqual:          token.Minus,
+	token.StarEqual:           token.Star,
+	token.DivideEqual:         token.Divide,
+	token.PercentEqual:        token.Percent,
+	token.AndEqual:            token.And,
+	token.OrEqual:             token.Or,
+	token.CarrotEqual:         token.Carrot,
+	token.LessLessEqual:       token.LessLess,
+	token.GreaterGreaterEqual: token.GreaterGreater,
+	token.AndCarrotEqual:      token.AndCarrot,
+}
+
+func (n *Assignment) check(syms *Scope, _ *Signature) error {
+	if n.Op != token.Equal {
+		if len(n.Left) != 1 || len(n.Right) != 1 {
+			return AssignCountMismatch{n}
+		}
+		l, err := checkLHS(syms, n.Left[0])
+		if err != nil {
+			return err
+		}
+		n.Left[0] = l
+		b := &BinaryOp{Op: assignBinaryOps[n.Op], opLoc: l.End(), Left: l, Right: n.Right[0]}
+		x, err := checkExpr(syms, b, -1)
+		if err == nil {
+			x, err = assign(x, l.Type())
+		}
+		if err != nil {
+			return err
+		}
+		n.Right[0] = x
+		return nil
+	}
+
+	var errs errors
+	switch {
+	case len(n.Left) == len(n.Right):
+		for i := range n.Left {
+			x, err := checkValue(syms, n.Right[i])
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if isBlank(n.Left[i]) {
+				x, err = inferType(x)
+			} else if l, err1 := checkLHS(syms, n.Left[i]); err1 != nil {
+				err = err1
+			} else {
+				n.Left[i] = l
+				x, err = assign(x, l.Type())
+			}
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			n.Right[i] = x
+		}
+
+	case len(n.Right) == 1:
+		var types []Type
+		x, err := checkExpr(syms, n.Right[0], -1)
+		if err == nil {
+			n.Right[0] = x
+			types, err = valueTypes(n, x, len(n.Left))
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for i := range n.Left {
+			var t Type
+			if types != nil {
+				t = types[i]
+			}
+			if err := assignType(syms, &n.Left[i], t, n.Right[0]); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+	default:
+		errs = append(errs, AssignCountMismatch{n})
+	}
+	return errs.ErrorOrNil()
+}
+
+// Check returns an errors, because the syntax error for a BadStmt
+// is reported by the parser.
+func (n *BadStmt) check(*Scope, *Signature) error { return errors{} }
+
+func (n *ExpressionStmt) check(syms *Scope, _ *Signature) error {
+	x, err := checkExpr(syms, n.Expression, -1)
+	if err != nil {
+		return err
+	}
+	n.Expression = x
+	if u, ok := x.(*UnaryOp); ok && u.Op == token.LessMinus {
+		return nil
+	}
+	if !isStmtCall(x) {
+		return Unused{x}
+	}
+	return nil
+}
+
+func (n *IncDecStmt) check(syms *Scope, _ *Signature) error {
+	x, err := checkLHS(syms, n.Expression)
+	if err != nil {
+		return err
+	}
+	if !allTypes(x.Type(), numeric) {
+		return InvalidOperation{x, n.Op, x}
+	}
+	n.Expression = x
+	return nil
+}
+
+func (n *SendStmt) check(syms *Scope, _ *Signature) error {
+	ch, err := checkValue(syms, n.Channel)
+	if err != nil {
+		return err
+	}
+	n.Channel = ch
+	t, ok := coreType(ch.Type()).(*ChannelType)
+	if !ok || !t.Send {
+		return InvalidOperation{ch, token.LessMinus, ch}
+	}
+	x, err := checkValue(syms, n.Expression)
+	if err == nil {
+		x, err = assign(x, t.Element)
+	}
+	if err != nil {
+		return err
+	}
+	n.Expression = x
+	return nil
+}
@@ -15,48 +15,33 @@ import (
 )
 
 var (
-	boolType = typ("bool")
-	runeType = typ("rune")
-	// intType is declared in check.go.
-	int8Type       = typ("int8")
-	int16Type      = typ("int16")
-	int32Type      = typ("int32")
-	int64Type      = typ("int64")
-	uintType       = typ("uint")
-	byteType       = typ("byte")
-	uint8Type      = typ("uint8")
-	uint16Type     = typ("uint16")
-	uint32Type     = typ("uint32")
-	uint64Type     = typ("uint64")
-	complex64Type  = typ("complex64")
-	complex128Type = typ("complex128")
-	float32Type    = typ("float32")
-	float64Type    = typ("float64")
-	stringType     = typ("string")
+	// boolType, byteType, runeType, intType, float32Type, float64Type,
+	// complex64Type, complex128Type, and stringType are declared in check.go.
+	int8Type   = typ("int8")
+	int16Type  = typ("int16")
+	int32Type  = typ("int32")
+	int64Type  = typ("int64")
+	uintType   = typ("uint")
+	uint8Type  = typ("uint8")
+	uint16Type = typ("uint16")
+	uint32Type = typ("uint32")
+	uint64Type = typ("uint64")
 
 	t1Ident = typ("T1")
 	t1Diff  = typ("T1")
 )
 
 func init() {
-	boolType.Identifier.decl = univScope.Decls["bool"]
-	runeType.Identifier.decl = univScope.Decls["rune"]
-	int8Type.Identifier.decl = univScope.Decls["int8"]
-	int16Type.Identifier.decl = univScope.Decls["int16"]
-	int32Type.Identifier.decl = univScope.Decls["int32"]
-	int64Type.Identifier.decl = univScope.Decls["int64"]
-	uintType.Identifier.decl = univScope.Decls["uint"]
-	byteType.Identifier.decl = univScope.Decls["byte"]
-	uint8Type.Identifier.decl = univScope.Decls["uint8"]
-	uint16Type.Identifier.decl = univScope.Decls["uint16"]
-	uint32Type.Identifier.decl = univScope.Decls["uint32"]
-	uint64Type.Identifier.decl = univScope.Decls["uint64"]
-	int32Type.Identifier.decl = univScope.Decls["int32"]
-	complex64Type.Identifier.decl = univScope.Decls["complex64"]
-	complex128Type.Identifier.decl = univScope.Decls["complex128"]
-	float32Type.Identifier.decl = univScope.Decls["float32"]
-	float64Type.Identifier.decl = univScope.Decls["float64"]
-	stringType.Identifier.decl = univScope.Decls["string"]
+	int8Type.Identifier.decl = Universe.Decls["int8"]
+	int16Type.Identifier.decl = Universe.Decls["int16"]
+	int32Type.Identifier.decl = Universe.Decls["int32"]
+	int64Type.Identifier.decl = Universe.Decls["int64"]
+	uintType.Identifier.decl = Universe.Decls["uint"]
+	uint8Type.Identifier.decl = Universe.Decls["uint8"]
+	uint16Type.Identifier.decl = Universe.Decls["uint16"]
+	uint32Type.Identifier.decl = Universe.Decls["uint32"]
+	uint64Type.Identifier.decl = Universe.Decls["uint64"]
+	int32Type.Identifier.decl = Universe.Decls["int32"]
 
 	t0.Identifier.decl = &TypeSpec{Identifier: *id("T0"), Type: intType}
 	t1.Identifier.decl = &TypeSpec{Identifier: *id("T1"), Type: t0}
@@ -103,12 +88,88 @@ func TestCheckTypes(t *testing.T) {
 		{`package a; const α int = +1.0`, intType},
 		{`package a; const α int = ^1`, intType},
 		{`package a; const α int = ^1`, intType},
+
+		// BinaryOps
+		{`package a; const α = 1 + 1`, Untyped(IntegerConst)},
+		{`package a; const α = 1 + 'a'`, Untyped(RuneConst)},
+		{`package a; const α = 'a' + 1.0`, Untyped(FloatConst)},
+		{`package a; const α = 1.0 + 1i`, Untyped(ComplexConst)},
+		{`package a; const α = "a" + "b"`, Untyped(StringConst)},
+		{`package a; const α = 1 < 2`, Untyped(BoolConst)},
+		{`package a; const α = 1.0 << 2`, Untyped(IntegerConst)},
+		{`package a; const α = 'a' << 2`, Untyped(RuneConst)},
+		{`package a; const a int8 = 1; const α = a + 1`, int8Type},
+		{`package a; const a int8 = 1; const α = 1 + a`, int8Type},
+		{`package a; const a int8 = 1; const α = a << 2`, int8Type},
+		{`package a; const a int8 = 1; const α = a == 1`, Untyped(BoolConst)},
+		{`package a; var a int8; var α = a + 1`, int8Type},
+		{`package a; var a, b int; var α = a < b`, boolType},
+		{`package a; var a uint; var α = 1 << a`, intType},
+
+		// Vars
+		{`package a; var α = 1`, intType},
+		{`package a; var α = 1.0`, float64Type},
+		{`package a; var α = 1.0i`, complex128Type},
+		{`package a; var α = 'a'`, runeType},
+		{`package a; var α = "Hello, World!"`, stringType},
+		{`package a; var α = true`, boolType},
+		{`package a; var α int8 = 1`, int8Type},
+		{`package a; var α int8`, int8Type},
+		{`package a; var α, b = 1, "Hello, World!"`, intType},
+		{`package a; var a, α = 1, "Hello, World!"`, stringType},
+		{`package a; var α = a; var a = 5.0`, float64Type},
+		{`package a; const c int8 = 1; var α = c`, int8Type},
+		{`package a; var α = int8(1)`, int8Type},
+		{`package a; var α = len("abc")`, intType},
+		{`package a; var α = f(); func f() uint8 { return 0 }`, uint8Type},
+		{`package a; var a, α = f(); func f() (int, string) { return 0, "" }`, stringType},
+		{`package a; var α = []int{1, 2, 3}`, &SliceType{Element: intType}},
+		{`package a; var α = new(int)`, &Star{Target: intType}},
+		{`package a; var s = []string{}; var α = s[0]`, stringType},
+		{`package a; var s = "abc"; var α = s[0]`, byteType},
+		{`package a; var m = map[string]int8{}; var α, ok = m[""]`, int8Type},
+		{`package a; var m = map[string]int8{}; var v, α = m[""]`, boolType},
+
+		// Generics
+		{`package a; func F[T any](x T) T { return x }; var α = F(int8(1))`, int8Type},
+		{`package a; func F[T any](x T) T { return x }; var α = F(1)`, intType},
+		{`package a; func F[T any](x T) T { return x }; var α = F[uint8](1)`, uint8Type},
+		{`package a; func F[T any](x ...T) T { return x[0] }; var α = F("a", "b")`, stringType},
+		{`package a; func F[T any](x []T) T { return x[0] }; var α = F([]string{})`, stringType},
+		{`package a; func F[T any](x map[string]*T) T { return *x[""] }; var α = F(map[string]*int8{})`, int8Type},
+		{
+			`package a; func F[M ~map[K]V, K comparable, V any](m M) []K { return nil }; var α = F(map[string]int{})`,
+			&SliceType{Element: stringType},
+		},
+
+		// Selectors
+		{`package a; type S struct { x int8 }; var s S; var α = s.x`, int8Type},
+		{`package a; type S struct { x int8 }; var s *S; var α = s.x`, int8Type},
+		{`package a; type S struct { T }; type T struct { x int8 }; var s S; var α = s.x`, int8Type},
+		{`package a; type S struct { *T }; type T struct { x int8 }; var s S; var α = s.x`, int8Type},
+		{`package a; type S struct { x string; T }; type T struct { x int8 }; var s S; var α = s.x`, stringType},
+		{`package a; type T int; func (_ T) M() int8 { return 0 }; var t T; var α = t.M()`, int8Type},
+		{`package a; type T int; func (t *T) M() int8 { return 0 }; var t T; var α = t.M()`, int8Type},
+		{`package a; type T int; func (_ T) M() int8 { return 0 }; var α = T.M(0)`, int8Type},
+		{`package a; type T int; func (t *T) M() int8 { return 0 }; var α = (*T).M(nil)`, int8Type},
+		{`package a; type S struct { T }; type T int; func (_ T) M() int8 { return 0 }; var s S; var α = s.M()`, int8Type},
+		{`package a; type I interface { M() int8 }; var i I; var α = i.M()`, int8Type},
+		{`package a; type S struct { I }; type I interface { M() int8 }; var s S; var α = s.M()`, int8Type},
+		{`package a; type L[T any] struct { x T }; var l L[int8]; var α = l.x`, int8Type},
+		{`package a; type L[T any] struct { x T }; func (l L[T]) M() T { return l.x }; var l L[int8]; var α = l.M()`, int8Type},
+
+		// Aliases
+		{`package a; type A = int8; var α A`, int8Type},
+		{`package a; type A = B; type B = []int; var α A`, &SliceType{Element: intType}},
+		{`package a; type A = []int; var α = A{1}`, &SliceType{Element: intType}},
+		{`package a; var α any`, &InterfaceType{}},
 	}
 	for _, test := range tests {
-		l := token.NewLexer("", test.src)
+		fset := token.NewFileSet()
+		l := token.NewLexer(fset, "", test.src)
 		p := NewParser(l)
 		f := parseFile(p)
-		if err := Check([]*File{f}); err != nil {
+		if err := Check(fset, []*File{f}, nil, nil); err != nil {
 			t.Errorf("Check(%v), unexpected error: %v", test.src, err)
 			continue
 		}
@@ -192,6 +253,10 @@ func TestCheckErrors(t *testing.T) {
 			[]string{`package a; const a, b = 1`},
 			[]reflect.Type{reflect.TypeOf(AssignCountMismatch{})},
 		},
+		{
+			[]string{"package a\nconst A\n"},
+			[]reflect.Type{reflect.TypeOf(AssignCountMismatch{})},
+		},
 		{
 			[]string{`package a; const nilConst = nil`},
 			[]reflect.Type{reflect.TypeOf(NotConstant{})},
@@ -273,280 +338,1410 @@ func TestCheckErrors(t *testing.T) {
 				reflect.TypeOf(Undeclared{}),
 			},
 		},
-
-		// UnaryOps
-		{[]string{`package a; const a float64 = +256`}, []reflect.Type{}},
-		{[]string{`package a; const a float64 = -256`}, []reflect.Type{}},
-		{[]string{`package a; const a float64 = ^256`}, []reflect.Type{}},
-		{[]string{`package a; const a float64 = 1; const b = -a`}, []reflect.Type{}},
-		{[]string{`package a; const a complex128 = 1; const b = -a`}, []reflect.Type{}},
-		{[]string{`package a; const t bool = true; const f = !t`}, []reflect.Type{}},
+
+		// UnaryOps
+		{[]string{`package a; const a float64 = +256`}, []reflect.Type{}},
+		{[]string{`package a; const a float64 = -256`}, []reflect.Type{}},
+		{[]string{`package a; const a float64 = ^256`}, []reflect.Type{}},
+		{[]string{`package a; const a float64 = 1; const b = -a`}, []reflect.Type{}},
+		{[]string{`package a; const a complex128 = 1; const b = -a`}, []reflect.Type{}},
+		{[]string{`package a; const t bool = true; const f = !t`}, []reflect.Type{}},
+		{
+			[]string{`package a; const a uint = -undeclared`},
+			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+		},
+		{
+			[]string{`package a; const a uint = +"hello"`},
+			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
+		},
+		{
+			[]string{`package a; const a uint = -"hello"`},
+			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
+		},
+		{
+			[]string{`package a; const a uint = !0`},
+			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
+		},
+		{
+			[]string{`package a; const a uint = ^1.0`},
+			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
+		},
+		{
+			[]string{`package a; const a uint = ^1.1`},
+			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
+		},
+		{
+			[]string{`package a; const a uint = -1`},
+			[]reflect.Type{reflect.TypeOf(Unrepresentable{})},
+		},
+		{
+			[]string{`package a; const a, b uint = 1, -a`},
+			[]reflect.Type{reflect.TypeOf(Unrepresentable{})},
+		},
+		{
+			[]string{`package a; const a uint = -1.0i`},
+			[]reflect.Type{reflect.TypeOf(Unrepresentable{})},
+		},
+		{
+			[]string{`package a; const a uint8 = +256`},
+			[]reflect.Type{reflect.TypeOf(Unrepresentable{})},
+		},
+		{
+			[]string{
+				`package a
+				const a string = "hello"
+				const b int = -a`,
+			},
+			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
+		},
+		{
+			[]string{
+				`package a
+				const a float64 = 1.1
+				const b complex128 = 1.1i
+				const c, d int = ^a, ^b`,
+			},
+			[]reflect.Type{
+				reflect.TypeOf(InvalidOperation{}),
+				reflect.TypeOf(InvalidOperation{}),
+			},
+		},
+		{
+			[]string{`package a; const i int = 1; const f = !i`},
+			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
+		},
+		{
+			[]string{`package a; const c = nil`},
+			[]reflect.Type{reflect.TypeOf(NotConstant{})},
+		},
+
+		// Types
+		{[]string{`package a; type T int`}, []reflect.Type{}},
+		{
+			[]string{`package a; type T undeclared`},
+			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+		},
+		{[]string{`package a; type T int`}, []reflect.Type{}},
+		{
+			[]string{`package a; type T undeclared0.undeclared1`},
+			[]reflect.Type{
+				reflect.TypeOf(Undeclared{}),
+				reflect.TypeOf(Undeclared{}),
+			},
+		},
+		{
+			[]string{`package a; type T [5]int`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type T [1.0]int`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type T ["hello"]int`},
+			[]reflect.Type{reflect.TypeOf(BadArraySize{})},
+		},
+		{
+			// Too big for 64 bits, surely too big for an int.
+			[]string{`package a; type T [18446744073709551616]int`},
+			[]reflect.Type{reflect.TypeOf(BadArraySize{})},
+		},
+		{
+			[]string{`package a; type T [-1]int`},
+			[]reflect.Type{reflect.TypeOf(BadArraySize{})},
+		},
+		{
+			[]string{`package a; type T [1.1]int`},
+			[]reflect.Type{reflect.TypeOf(BadArraySize{})},
+		},
+		{
+			[]string{`package a; type T [^1]int`},
+			[]reflect.Type{reflect.TypeOf(BadArraySize{})},
+		},
+		{
+			[]string{`package a; const c = 5; type T [c]int`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; const c = 5; type T [-1]undeclared`},
+			[]reflect.Type{
+				reflect.TypeOf(BadArraySize{}),
+				reflect.TypeOf(Undeclared{}),
+			},
+		},
+		{
+			// Don't report both BadArraySize and Undeclared.
+			[]string{`package a; const c = 5; type T [undeclared]undeclared`},
+			[]reflect.Type{
+				reflect.TypeOf(Undeclared{}),
+				reflect.TypeOf(Undeclared{}),
+			},
+		},
+		{
+			[]string{`package a; type T [undeclared]int`},
+			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+		},
+		{
+			[]string{`package a; type T []int`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type T []undeclared`},
+			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+		},
+		{
+			[]string{`package a; type T [][3][]int`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type T *int`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type T ***[5]int`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type T *undeclared`},
+			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+		},
+		{
+			[]string{`package a; type T map[string]int`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type T map[string]map[string]int`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type T map[map[string]int]undeclared`},
+			[]reflect.Type{
+				reflect.TypeOf(BadMapKey{}),
+				reflect.TypeOf(Undeclared{}),
+			},
+		},
+		{
+			// Don't report both BadMapKey and Undeclared.
+			[]string{`package a; type T map[map[undeclared]int]undeclared`},
+			[]reflect.Type{
+				reflect.TypeOf(Undeclared{}),
+				reflect.TypeOf(Undeclared{}),
+			},
+		},
+		{
+			[]string{`package a; type T map[undefined]int`},
+			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+		},
+		{
+			[]string{`package a; type T map[string]undefined`},
+			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+		},
+		{
+			[]string{`package a; type T map[map[string]int]int`},
+			[]reflect.Type{reflect.TypeOf(BadMapKey{})},
+		},
+		{
+			[]string{`
+				package a
+				type (
+					T chan int
+					U chan <- int
+					V <-chan int
+				)`,
+			},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type T chan undeclared`},
+			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+		},
+
+		// Recursive Types
+		{
+			[]string{`package a; type T T`},
+			[]reflect.Type{reflect.TypeOf(BadRecursiveType{})},
+		},
+		{
+			[]string{`package a; type T U; type U T`},
+			[]reflect.Type{reflect.TypeOf(BadRecursiveType{})},
+		},
+		{
+			[]string{`package a; type T U; type U V; type V T`},
+			[]reflect.Type{reflect.TypeOf(BadRecursiveType{})},
+		},
+		{
+			[]string{`package a; type T [5]T`},
+			[]reflect.Type{reflect.TypeOf(BadRecursiveType{})},
+		},
+		{
+			[]string{`package a; type T [5][6][7]T`},
+			[]reflect.Type{reflect.TypeOf(BadRecursiveType{})},
+		},
+		{
+			[]string{`package a; type T U; type U V; type V [1]T`},
+			[]reflect.Type{reflect.TypeOf(BadRecursiveType{})},
+		},
+		{
+			[]string{`package a; type T []T`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type T [][5]T`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type T U; type U V; type V []T`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type T *T`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type T U; type U V; type V *T`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type T map[T]int`},
+			[]reflect.Type{reflect.TypeOf(BadMapKey{})},
+		},
+		{
+			[]string{`package a; type T map[string]T`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type T chan T`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type T struct { t T }`},
+			[]reflect.Type{reflect.TypeOf(BadRecursiveType{})},
+		},
+		{
+			[]string{`package a; type T struct { U }; type U struct { T }`},
+			[]reflect.Type{reflect.TypeOf(BadRecursiveType{})},
+		},
+		{
+			[]string{`package a; type T struct { t *T }`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type T interface { T }`},
+			[]reflect.Type{reflect.TypeOf(BadRecursiveType{})},
+		},
+		{
+			[]string{`package a; type T interface { U }; type U interface { T }`},
+			[]reflect.Type{reflect.TypeOf(BadRecursiveType{})},
+		},
+		{
+			[]string{`package a; type T interface { M() T }`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type T func(T) T`},
+			[]reflect.Type{},
+		},
+
+		// Struct types
+		{
+			[]string{`package a; type T struct { a, b int; c string "tag"; _, _ float64 }`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type T struct { U; *V; W "tag" }; type U int; type V struct{}; type W struct{}`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type T struct { int; *string }`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type T struct { a int; a string }`},
+			[]reflect.Type{reflect.TypeOf(DuplicateField{})},
+		},
+		{
+			[]string{`package a; type T struct { U; U int }; type U int`},
+			[]reflect.Type{reflect.TypeOf(DuplicateField{})},
+		},
+		{
+			[]string{`package a; type T struct { U; *U }; type U int`},
+			[]reflect.Type{reflect.TypeOf(DuplicateField{})},
+		},
+		{
+			[]string{`package a; type T struct { P }; type P *int`},
+			[]reflect.Type{reflect.TypeOf(BadEmbeddedField{})},
+		},
+		{
+			[]string{`package a; type T struct { *P }; type P *int`},
+			[]reflect.Type{reflect.TypeOf(BadEmbeddedField{})},
+		},
+		{
+			[]string{`package a; type T struct { *I }; type I interface{}`},
+			[]reflect.Type{reflect.TypeOf(BadEmbeddedField{})},
+		},
+		{
+			[]string{`package a; type T struct { a undeclared }`},
+			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+		},
+		{
+			[]string{`package a; type C int; type B struct{ *C[int] }`},
+			[]reflect.Type{reflect.TypeOf(NotGeneric{})},
+		},
+		{
+			[]string{`package a; type T struct{ x int }; type A = T; type S struct{ *A }; var s S; var x = s.A.x`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; var t struct { a, b int }`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type E struct{ x undeclared; next *L }; type L E; func (l L) M(){}`},
+			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+		},
+		{
+			[]string{`package a; type E struct{ x undeclared; next *L }; type L E; var v L; var w = v.next`},
+			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+		},
+		{
+			[]string{`package a; type E struct{ x undeclared; next *L }; type L E; type I interface{ M() }; var v L; var w I = v`},
+			[]reflect.Type{reflect.TypeOf(Undeclared{}), reflect.TypeOf(NotImplemented{})},
+		},
+		{
+			[]string{`package a; type E struct{ next, prev *undeclared }`},
+			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+		},
+		{
+			[]string{`package a; type E struct{ next, prev **undeclared }`},
+			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+		},
+		{
+			[]string{`package a; func f(a, b **undeclared) {}`},
+			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+		},
+		{
+			[]string{`package a; func f() (a, b []undeclared) { return nil, nil }`},
+			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+		},
+
+		// Interface types
+		{
+			[]string{`package a; type T interface { M(int) string; N(...int) (a, b int) }`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type T interface { U; V }; type U interface { M() }; type V interface { N() }`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type T interface { U; V }; type U interface { M() }; type V interface { M() }`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type T interface { U; M() }; type U interface { M() }`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type T interface { M(); M() }`},
+			[]reflect.Type{reflect.TypeOf(DuplicateMethod{})},
+		},
+		{
+			[]string{`package a; type T interface { U; M(int) }; type U interface { M() }`},
+			[]reflect.Type{reflect.TypeOf(DuplicateMethod{})},
+		},
+		{
+			[]string{`package a; type T interface { U; V }; type U interface { M() }; type V interface { M() int }`},
+			[]reflect.Type{reflect.TypeOf(DuplicateMethod{})},
+		},
+		{
+			// A non-interface type restricts the type set.
+			[]string{`package a; type T interface { U }; type U int`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type T interface { M(undeclared) }`},
+			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+		},
+		{
+			[]string{`package a; type T interface { M() U }; type U interface { T; N() }`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; var i interface { M() }`},
+			[]reflect.Type{},
+		},
+
+		// Generics
+		{
+			[]string{`package a; type List[T any] struct{ next *List[T]; v T }; var l List[int]`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type List[T any] []T; type T List[*T]; type U struct{ l List[U] }`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a
+				type Number interface{ ~int | ~float64 }
+				type MyInt int
+				func Sum[T Number](xs []T) T { var s T; return s }
+				var x MyInt = Sum([]MyInt{})`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; func Eq[T comparable](a, b T) bool { return a == b }; var b = Eq(1, 2)`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; func Eq[T any](a, b T) bool { return a == b }`},
+			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
+		},
+		{
+			[]string{`package a
+				type Number interface{ ~int | ~float64 }
+				func Sum[T Number](xs []T) T { var s T; return s }
+				var x = Sum([]string{})`},
+			[]reflect.Type{reflect.TypeOf(Unsatisfied{})},
+		},
+		{
+			[]string{`package a; type Set[T comparable] map[T]bool; var s Set[[]int]`},
+			[]reflect.Type{reflect.TypeOf(Unsatisfied{})},
+		},
+		{
+			[]string{`package a; type Set[T interface{ int | string }] map[T]bool; var s Set[int8]`},
+			[]reflect.Type{reflect.TypeOf(Unsatisfied{})},
+		},
+		{
+			[]string{`package a; type List[T any] struct{}; var l List`},
+			[]reflect.Type{reflect.TypeOf(NotInstantiated{})},
+		},
+		{
+			[]string{`package a; func F[T any]() {}; var f = F`},
+			[]reflect.Type{reflect.TypeOf(NotInstantiated{})},
+		},
+		{
+			[]string{`package a; func F[T any]() T { var x T; return x }; var x = F()`},
+			[]reflect.Type{reflect.TypeOf(CannotInfer{})},
+		},
+		{
+			[]string{`package a; type List[T any] struct{}; var l List[int, string]`},
+			[]reflect.Type{reflect.TypeOf(TypeArgCountMismatch{})},
+		},
+		{
+			[]string{`package a; func F[T any]() {}; func g() { F[int, string]() }`},
+			[]reflect.Type{reflect.TypeOf(TypeArgCountMismatch{})},
+		},
+		{
+			[]string{`package a; type T int; var x T[int]`},
+			[]reflect.Type{reflect.TypeOf(NotGeneric{})},
+		},
+		{
+			[]string{`package a; func F[T any](x T) { var y int = x }`},
+			[]reflect.Type{reflect.TypeOf(BadAssign{})},
+		},
+		{
+			[]string{`package a; func F[T any](x T) { var y interface{} = x; x = y }`},
+			[]reflect.Type{reflect.TypeOf(BadAssign{})},
+		},
+		{
+			[]string{`package a; func F[T any](x T) { x = nil }`},
+			[]reflect.Type{reflect.TypeOf(BadAssign{})},
+		},
+		{
+			[]string{`package a; func F[T ~int | ~int8](x T) { var y T = 1; x = y }`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; func F[T ~int | ~int8](x T) { x = 1000 }`},
+			[]reflect.Type{reflect.TypeOf(BadAssign{})},
+		},
+		{
+			[]string{`package a
+				type Stringer interface{ String() string }
+				type S int
+				func (s S) String() string { return "" }
+				func F[T Stringer](x T) { var s Stringer = x; _ = s }
+				func g() { F(S(0)) }`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a
+				type Stringer interface{ String() string }
+				func F[T Stringer](x T) {}
+				func g() { F(1) }`},
+			[]reflect.Type{reflect.TypeOf(Unsatisfied{})},
+		},
+		{
+			[]string{`package a
+				type Box[T any] []T
+				func (b Box[U]) Get() U { return b[0] }
+				type Getter interface{ Get() int }
+				var g Getter = Box[int]{}`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a
+				type Box[T any] []T
+				func (b *Box[T]) Get() T { return (*b)[0] }
+				type Getter interface{ Get() int }
+				var g Getter = &Box[string]{}`},
+			[]reflect.Type{reflect.TypeOf(NotImplemented{})},
+		},
+		{
+			[]string{`package a; type Box[T any] struct{}; func (b Box) M() {}`},
+			[]reflect.Type{reflect.TypeOf(BadReceiver{})},
+		},
+		{
+			[]string{`package a; type MyInt int; func F[T ~MyInt]() {}`},
+			[]reflect.Type{reflect.TypeOf(BadTerm{})},
+		},
+		{
+			[]string{`package a; func F[S ~[]E, E any](s S) E { var e E; return e }; type Ints []int; var x int = F(Ints{})`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; func F[K comparable, V any](k K, v V) {}; func g() { F[string]("", 1) }`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; func F[K comparable, V any](k K, v V) {}; func g() { F[string](1, 1) }`},
+			[]reflect.Type{reflect.TypeOf(BadAssign{})},
+		},
+		{
+			[]string{`package a; type Map[K comparable, V any] map[K]V; var m = Map[string, int]{"a": 1}`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type Map[K comparable, V any] map[K]V; var m = Map[string, int]{1: 1}`},
+			[]reflect.Type{reflect.TypeOf(BadAssign{})},
+		},
+
+		// Operations on the values of type parameters
+		{
+			[]string{`package a
+				func Sum[T ~int | ~float64](xs []T) T {
+					var s T
+					for _, x := range xs {
+						s += x
+					}
+					return s
+				}
+				func Less[T ~int | ~string](a, b T) bool { return a < b }
+				func Neg[T ~int | ~float64](a T) T { return -a }
+				func Inc[T ~int | ~float64](a T) T { a++; return a + 1 }
+				func Shift[T ~int | ~uint](a T) T { return a << 1 &^ a }
+				func Len[T ~string | ~[]byte](a T) int { return len(a) }
+				func First[S ~[]E, E any](s S) E { return s[0] }
+				func Tail[S ~[]E, E any](s S) S { return append(s[1:], s[0]) }
+				func Get[M ~map[K]V, K comparable, V any](m M, k K) V { return m[k] }
+				func Recv[C ~chan E, E any](c C) E { return <-c }
+				func Deref[P ~*E, E any](p P) E { return *p }
+				func Float[T ~int | ~int8](a T) float64 { return float64(a) }
+				func Keys[M ~map[K]V, K comparable, V any](m M) []K {
+					var ks []K
+					for k := range m {
+						ks = append(ks, k)
+					}
+					return ks
+				}`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a
+				type List[T any] struct{ val T }
+				type I interface{ M() int }
+				func Val(l List[int]) int { return l.val }
+				func M[T I](t T) int { return t.M() }`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; func F[T any](a, b T) T { return a + b }`},
+			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
+		},
+		{
+			[]string{`package a; func F[T ~int | ~string](a T) T { return -a }`},
+			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
+		},
+		{
+			[]string{`package a; func F[T ~int | ~bool](a, b T) bool { return a < b }`},
+			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
+		},
+		{
+			[]string{`package a; func F[T ~int8](a T) T { return a + 1000 }`},
+			[]reflect.Type{reflect.TypeOf(Unrepresentable{})},
+		},
+		{
+			[]string{`package a; func F[T any](a T) int { return len(a) }`},
+			[]reflect.Type{reflect.TypeOf(BadArgument{})},
+		},
+		{
+			[]string{`package a; func F[T ~string | ~[]byte](a T) int { return cap(a) }`},
+			[]reflect.Type{reflect.TypeOf(BadArgument{})},
+		},
+		{
+			[]string{`package a; func F[T ~[]int | ~string](a T) int { return a[0] }`},
+			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
+		},
+		{
+			[]string{`package a; func F[T ~[]int | ~[]string](a T) { for range a {} }`},
+			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
+		},
+		{
+			[]string{`package a; func F[T any](a T) int { return a.(int) }`},
+			[]reflect.Type{reflect.TypeOf(NotInterface{})},
+		},
+		{
+			[]string{`package a; func F[T ~int | ~string](a T) float64 { return float64(a) }`},
+			[]reflect.Type{reflect.TypeOf(BadConversion{})},
+		},
+
+		// Interfaces that may only be used as constraints
+		{
+			[]string{`package a
+				type Number interface{ ~int | ~float64 }
+				type Ordered interface{ Number | ~string }
+				type Key interface{ comparable; String() string }
+				func Max[T Ordered](a, b T) T { return a }
+				func F[K Key, N interface{ Number }]() {}`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type Number interface{ ~int | ~float64 }; var n Number`},
+			[]reflect.Type{reflect.TypeOf(ConstraintInterface{})},
+		},
+		{
+			[]string{`package a; var c comparable`},
+			[]reflect.Type{reflect.TypeOf(ConstraintInterface{})},
+		},
+		{
+			[]string{`package a; type Number interface{ ~int | ~float64 }; func f(n Number) {}`},
+			[]reflect.Type{reflect.TypeOf(ConstraintInterface{})},
+		},
+		{
+			[]string{`package a; type S struct{ n interface{ int } }`},
+			[]reflect.Type{reflect.TypeOf(ConstraintInterface{})},
+		},
+		{
+			[]string{`package a; type Number interface{ ~int | ~float64 }; var ns []Number; var m map[Number]*Number`},
+			[]reflect.Type{
+				reflect.TypeOf(ConstraintInterface{}),
+				reflect.TypeOf(ConstraintInterface{}),
+				reflect.TypeOf(ConstraintInterface{}),
+			},
+		},
+		{
+			[]string{`package a; type Number interface{ ~int | ~float64 }; type L[T any] []T; var l L[Number]`},
+			[]reflect.Type{reflect.TypeOf(ConstraintInterface{})},
+		},
+
+		// Aliases
+		{
+			[]string{`package a; type A = int; var x int = 1; var y A = x`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type T int; type A = T; var x T; var y A = x; var z int = y`},
+			[]reflect.Type{reflect.TypeOf(BadAssign{})},
+		},
+		{
+			[]string{`package a; type A = []int; var x []int; var y A = x`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; var x any = 1; var y interface{} = x; var z any = y`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type T struct{ next *A }; type A = T; var x *T; var y *A = x`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type A = B; type B = A`},
+			[]reflect.Type{reflect.TypeOf(BadRecursiveType{})},
+		},
+		{
+			[]string{`package a; type A = []A`},
+			[]reflect.Type{reflect.TypeOf(BadRecursiveType{})},
+		},
+		{
+			[]string{`package a; type A = struct{ next *A }`},
+			[]reflect.Type{reflect.TypeOf(BadRecursiveType{})},
+		},
+		{
+			[]string{`package a; type A = func(A) map[string]A`},
+			[]reflect.Type{reflect.TypeOf(BadRecursiveType{})},
+		},
+		{
+			[]string{`package a; type A = interface{ M() A }`},
+			[]reflect.Type{reflect.TypeOf(BadRecursiveType{})},
+		},
+		{
+			[]string{`package a; type List[T any] []T; type A = List[A]`},
+			[]reflect.Type{reflect.TypeOf(BadRecursiveType{})},
+		},
+		{
+			[]string{`package a; type A = []B; type B = *A`},
+			[]reflect.Type{reflect.TypeOf(BadRecursiveType{})},
+		},
+		{
+			[]string{`package a; type A = T; type T struct{ next *A }; var x *T; var y *A = x`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type A = []T; type T []A`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type List[T any] []T; type A = List[int]; var x List[int]; var y A = x`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type List[T any] []T; type A = List`},
+			[]reflect.Type{reflect.TypeOf(NotInstantiated{})},
+		},
+		{
+			[]string{`package a; type T int; type A = T; func (a A) M() {}`},
+			[]reflect.Type{reflect.TypeOf(BadReceiver{})},
+		},
+		{
+			[]string{`package a; type A = undeclared`},
+			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+		},
+
+		// Function types
+		{
+			[]string{`package a; type T func(a, b int, c ...string) (int, error)`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type T func(int, ...string) (x, y float64)`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type T func(undeclared) undeclared`},
+			[]reflect.Type{
+				reflect.TypeOf(Undeclared{}),
+				reflect.TypeOf(Undeclared{}),
+			},
+		},
+
+		// BinaryOps
+		{[]string{`package a; const a = 1 + 2*3 - 4/5`}, []reflect.Type{}},
+		{[]string{`package a; var a, b int; var c = a + b*2`}, []reflect.Type{}},
+		{[]string{`package a; var a [2.0]int; var b [2]int = a`}, []reflect.Type{}},
+		{[]string{`package a; func f() { x := 1; x += 2; x <<= 1 }`}, []reflect.Type{}},
+		{[]string{`package a; var p *int; var b = p == nil`}, []reflect.Type{}},
+		{
+			[]string{`package a; const a = 1 / 0`},
+			[]reflect.Type{reflect.TypeOf(DivisionByZero{})},
+		},
+		{
+			[]string{`package a; const a = 1.0 / 0.0`},
+			[]reflect.Type{reflect.TypeOf(DivisionByZero{})},
+		},
+		{
+			[]string{`package a; var a int; var b = a % 0`},
+			[]reflect.Type{reflect.TypeOf(DivisionByZero{})},
+		},
+		{
+			[]string{`package a; const a int8 = 100 + 100`},
+			[]reflect.Type{reflect.TypeOf(Unrepresentable{})},
+		},
+		{
+			[]string{`package a; const a int8 = 100; const b = a * 2`},
+			[]reflect.Type{reflect.TypeOf(Unrepresentable{})},
+		},
+		{
+			[]string{`package a; const a uint8 = 1 << 8`},
+			[]reflect.Type{reflect.TypeOf(Unrepresentable{})},
+		},
+		{
+			[]string{`package a; var a int8; var b = a + 1000`},
+			[]reflect.Type{reflect.TypeOf(Unrepresentable{})},
+		},
+		{
+			[]string{`package a; const a = 1 + "Hello, World!"`},
+			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
+		},
+		{
+			[]string{`package a; const a int = 1; const b int8 = 2; const c = a + b`},
+			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
+		},
+		{
+			[]string{`package a; var a int; var b int8; var c = a == b`},
+			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
+		},
+		{
+			[]string{`package a; const a = "a" - "b"`},
+			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
+		},
+		{
+			[]string{`package a; const a = 1.5 % 1`},
+			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
+		},
+		{
+			[]string{`package a; const a = 1 && true`},
+			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
+		},
+		{
+			[]string{`package a; const a = 1i < 2i`},
+			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
+		},
+		{
+			[]string{`package a; const a = 1 << -1`},
+			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
+		},
+		{
+			[]string{`package a; const a = 1.5 << 1`},
+			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
+		},
+		{
+			[]string{`package a; var a, b []int; var c = a == b`},
+			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
+		},
+		{
+			[]string{`package a; var a int; const b = a + 1`},
+			[]reflect.Type{reflect.TypeOf(NotConstant{})},
+		},
+
+		// Vars
+		{[]string{`package a; var a int`}, []reflect.Type{}},
+		{[]string{`package a; var a, b int = 1, 2`}, []reflect.Type{}},
+		{[]string{`package a; var a, b = f(); func f() (int, string) { return 0, "" }`}, []reflect.Type{}},
+		{[]string{`package a; var a = b; var b = 1`}, []reflect.Type{}},
+		{
+			[]string{`package a; var a = undeclared`},
+			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+		},
+		{
+			[]string{`package a; var a = iota`},
+			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+		},
+		{
+			[]string{`package a; var a int = "Hello, World!"`},
+			[]reflect.Type{reflect.TypeOf(BadAssign{})},
+		},
+		{
+			[]string{`package a; var a int8 = 1000`},
+			[]reflect.Type{reflect.TypeOf(Unrepresentable{})},
+		},
+		{
+			[]string{`package a; var a, b, c = 1, 2`},
+			[]reflect.Type{reflect.TypeOf(AssignCountMismatch{})},
+		},
+		{
+			[]string{`package a; var a, b = 1`},
+			[]reflect.Type{reflect.TypeOf(AssignCountMismatch{})},
+		},
+		{
+			[]string{`package a; var a = b; var b = a`},
+			[]reflect.Type{reflect.TypeOf(InitializationLoop{})},
+		},
+		{
+			[]string{`package a; var a = nil`},
+			[]reflect.Type{reflect.TypeOf(UntypedNil{})},
+		},
+		{
+			[]string{`package a; var a int; var b a`},
+			[]reflect.Type{reflect.TypeOf(NotType{})},
+		},
+		{
+			[]string{`package a; var a = int`},
+			[]reflect.Type{reflect.TypeOf(NotExpression{})},
+		},
+		{
+			[]string{`package a; var a int = f(); func f() (int, int) { return 0, 0 }`},
+			[]reflect.Type{reflect.TypeOf(NotSingleValue{})},
+		},
+		{
+			[]string{`package a; var a = int8(1000)`},
+			[]reflect.Type{reflect.TypeOf(Unrepresentable{})},
+		},
+		{
+			[]string{`package a; var a = string(1.5)`},
+			[]reflect.Type{reflect.TypeOf(BadConversion{})},
+		},
+		{
+			[]string{`package a; var a [3]int; var b = a[5]`},
+			[]reflect.Type{reflect.TypeOf(BadIndex{})},
+		},
+		{
+			[]string{`package a; var a = [2]int{1, 2, 3}`},
+			[]reflect.Type{reflect.TypeOf(BadIndex{})},
+		},
+		{
+			[]string{`package a; var a = [...]int{1, 2, 3}; var b [3]int = a`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; var a = map[string]int{1: 2}`},
+			[]reflect.Type{reflect.TypeOf(BadAssign{})},
+		},
+		{
+			[]string{`package a; var a = int{1}`},
+			[]reflect.Type{reflect.TypeOf(BadCompositeLiteral{})},
+		},
+		{
+			[]string{`package a; var f = func(x int) int { return x }`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; var f = func() { undeclared() }`},
+			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+		},
+		{
+			[]string{`package a; type T int; func (t T) M() {}; var a = M`},
+			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+		},
+
+		// Functions and methods
+		{[]string{`package a; func f(x int) int { y := x; return y }`}, []reflect.Type{}},
+		{[]string{`package a; func f() (x int) { return }`}, []reflect.Type{}},
+		{[]string{`package a; type T []int; func (t T) Len() int { return len(t) }`}, []reflect.Type{}},
+		{[]string{`package a; type T int; func (t *T) Set() { *t = 5 }`}, []reflect.Type{}},
+		{[]string{`package a; func f() (int, string) { return 1, "" }; func g() (int, string) { return f() }`}, []reflect.Type{}},
+		{[]string{`package a; func f(xs ...int) { f(); f(1, 2, 3); f(xs...) }`}, []reflect.Type{}},
+		{
+			[]string{`package a; func f(x, x int) {}`},
+			[]reflect.Type{reflect.TypeOf(&Redeclaration{})},
+		},
+		{
+			[]string{`package a; func f(x int) { var x int }`},
+			[]reflect.Type{reflect.TypeOf(&Redeclaration{})},
+		},
+		{
+			[]string{`package a; func f(x undeclared) {}`},
+			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+		},
+		{
+			[]string{`package a; var v int; func (x v) M() {}`},
+			[]reflect.Type{reflect.TypeOf(BadReceiver{})},
+		},
+		{
+			[]string{`package a; type P *int; func (p P) M() {}`},
+			[]reflect.Type{reflect.TypeOf(BadReceiver{})},
+		},
+		{
+			[]string{`package a; func f() int { return }`},
+			[]reflect.Type{reflect.TypeOf(AssignCountMismatch{})},
+		},
+		{
+			[]string{`package a; func f() { return 1 }`},
+			[]reflect.Type{reflect.TypeOf(AssignCountMismatch{})},
+		},
+		{
+			[]string{`package a; func f() int { return "Hello, World!" }`},
+			[]reflect.Type{reflect.TypeOf(BadAssign{})},
+		},
+		{
+			[]string{`package a; func f(int) {}; func g() { f() }`},
+			[]reflect.Type{reflect.TypeOf(ArgCountMismatch{})},
+		},
+		{
+			[]string{`package a; func f(int) {}; func g() { f("Hello, World!") }`},
+			[]reflect.Type{reflect.TypeOf(BadAssign{})},
+		},
+		{
+			[]string{`package a; var a int; func f() { a() }`},
+			[]reflect.Type{reflect.TypeOf(NotFunction{})},
+		},
+		{
+			[]string{`package a; type T int; func (t T) M() {}; func (t *T) M() {}`},
+			[]reflect.Type{reflect.TypeOf(&Redeclaration{})},
+		},
+
+		// Interfaces
+		{[]string{`package a; var i interface{} = 1`}, []reflect.Type{}},
+		{[]string{`package a; var i interface{} = nil`}, []reflect.Type{}},
+		{[]string{`package a; var i interface{}; var j interface{} = i`}, []reflect.Type{}},
+		{[]string{`package a; var i interface{} = 1 < 2`}, []reflect.Type{}},
+		{[]string{`package a; var err error = nil`}, []reflect.Type{}},
+		{
+			[]string{`package a; type E int; func (e E) Error() string { return "" }; var e E; var err error = e`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type I interface{ M() }; type T int; func (t T) M() {}; var i I = T(0)`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type I interface{ M() }; type T int; func (t T) M() {}; var t T; var i I = &t`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type I interface{ M() }; type T int; func (t *T) M() {}; var t T; var i I = &t`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{
+				`package a; type I interface{ M(int) string }; var i I = T(0)`,
+				`package a; func (t T) M(x int) string { return "" }; type T int`,
+			},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type I interface{ M(); N() }; type J interface{ M() }; var i I; var j J = i`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type I interface{ M() }; type T int; func (t T) M() {}; func f(I) {}; func g() { f(T(0)) }`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type I interface{ M() }; type T int; func (t T) M() {}; func f() I { return T(0) }`},
+			[]reflect.Type{},
+		},
+		{
+			// Promoted from an embedded field.
+			[]string{`package a; type I interface{ M() }; type T int; func (t T) M() {}; type S struct{ T }; var s S; var i I = s`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type I interface{ M() }; type T int; func (t *T) M() {}; type S struct{ T }; var s S; var i I = &s`},
+			[]reflect.Type{},
+		},
+		{
+			[]string{`package a; type I interface{ M() }; type T int; func (t *T) M() {}; type S struct{ *T }; var s S; var i I = s`},
+			[]reflect.Type{},
+		},
 		{
-			[]string{`package a; const a uint = -undeclared`},
-			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+			[]string{`package a; type I interface{ M() }; type S struct{ I }; var s S; var i I = s`},
+			[]reflect.Type{},
 		},
 		{
-			[]string{`package a; const a uint = +"hello"`},
-			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
+			[]string{`package a; type I interface{ M() }; type S struct{ *S; I }; var s S; var i I = s`},
+			[]reflect.Type{},
 		},
 		{
-			[]string{`package a; const a uint = -"hello"`},
-			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
+			// T.M is shallower than U.V.M.
+			[]string{`
+				package a
+				type I interface{ M() }
+				type T int
+				func (t T) M() {}
+				type U struct{ V }
+				type V int
+				func (v V) M() {}
+				type S struct{ T; U }
+				var s S
+				var i I = s`,
+			},
+			[]reflect.Type{},
 		},
 		{
-			[]string{`package a; const a uint = !0`},
-			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
+			[]string{`package a; type I interface{ M() }; var i I = 1`},
+			[]reflect.Type{reflect.TypeOf(NotImplemented{})},
 		},
 		{
-			[]string{`package a; const a uint = ^1.0`},
-			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
+			[]string{`package a; var err error = 1`},
+			[]reflect.Type{reflect.TypeOf(NotImplemented{})},
 		},
 		{
-			[]string{`package a; const a uint = ^1.1`},
-			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
+			[]string{`package a; type I interface{ M() }; type T int; var i I = T(0)`},
+			[]reflect.Type{reflect.TypeOf(NotImplemented{})},
 		},
 		{
-			[]string{`package a; const a uint = -1`},
-			[]reflect.Type{reflect.TypeOf(Unrepresentable{})},
+			[]string{`package a; type I interface{ M() }; type T int; func (t T) M(int) {}; var i I = T(0)`},
+			[]reflect.Type{reflect.TypeOf(NotImplemented{})},
 		},
 		{
-			[]string{`package a; const a, b uint = 1, -a`},
-			[]reflect.Type{reflect.TypeOf(Unrepresentable{})},
+			[]string{`package a; type I interface{ M() }; type T int; func (t *T) M() {}; var i I = T(0)`},
+			[]reflect.Type{reflect.TypeOf(NotImplemented{})},
 		},
 		{
-			[]string{`package a; const a uint = -1.0i`},
-			[]reflect.Type{reflect.TypeOf(Unrepresentable{})},
+			[]string{`package a; type I interface{ M() }; type T int; func (t T) M() {}; var t T; var p = &t; var i I = &p`},
+			[]reflect.Type{reflect.TypeOf(NotImplemented{})},
 		},
 		{
-			[]string{`package a; const a uint8 = +256`},
-			[]reflect.Type{reflect.TypeOf(Unrepresentable{})},
+			[]string{`package a; type I interface{ M(); N() }; type J interface{ M() }; var j J; var i I = j`},
+			[]reflect.Type{reflect.TypeOf(NotImplemented{})},
 		},
 		{
-			[]string{
-				`package a
-				const a string = "hello"
-				const b int = -a`,
-			},
-			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
+			[]string{`package a; type I interface{ M() }; var i I; var p *I = &i; var j I = p`},
+			[]reflect.Type{reflect.TypeOf(NotImplemented{})},
 		},
 		{
-			[]string{
-				`package a
-				const a float64 = 1.1
-				const b complex128 = 1.1i
-				const c, d int = ^a, ^b`,
-			},
-			[]reflect.Type{
-				reflect.TypeOf(InvalidOperation{}),
-				reflect.TypeOf(InvalidOperation{}),
+			[]string{`package a; type I interface{ M() }; type T int; func (t *T) M() {}; type S struct{ T }; var s S; var i I = s`},
+			[]reflect.Type{reflect.TypeOf(NotImplemented{})},
+		},
+		{
+			[]string{`package a; type I interface{ M() }; type T struct{ M int }; var t T; var i I = t`},
+			[]reflect.Type{reflect.TypeOf(NotImplemented{})},
+		},
+		{
+			// Ambiguous selector.
+			[]string{`
+				package a
+				type I interface{ M() }
+				type T int
+				func (t T) M() {}
+				type U int
+				func (u U) M() {}
+				type S struct{ T; U }
+				var s S
+				var i I = s`,
 			},
+			[]reflect.Type{reflect.TypeOf(NotImplemented{})},
 		},
 		{
-			[]string{`package a; const i int = 1; const f = !i`},
-			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
+			[]string{`package a; type I interface{ M() }; type T int; func f(I) {}; func g() { f(T(0)) }`},
+			[]reflect.Type{reflect.TypeOf(NotImplemented{})},
 		},
 		{
-			[]string{`package a; const c = nil`},
-			[]reflect.Type{reflect.TypeOf(NotConstant{})},
+			[]string{`package a; type I interface{ M() }; type T int; func f() I { return T(0) }`},
+			[]reflect.Type{reflect.TypeOf(NotImplemented{})},
 		},
 
-		// Types
-		{[]string{`package a; type T int`}, []reflect.Type{}},
+		// Statements
+		{
+			[]string{`package a
+				func f() {
+					const c = 5
+					type T int
+					var x T = c
+					_ = x
+				}`,
+			},
+			[]reflect.Type{},
+		},
 		{
-			[]string{`package a; type T undeclared`},
-			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+			[]string{`package a
+				func f(s []string, m map[int]string) {
+					for i, v := range s {
+						var x int = i
+						var y string = v
+						_, _ = x, y
+					}
+					for k := range m {
+						var x int = k
+						_ = x
+					}
+					for i := 0; true; i++ {
+					}
+				}`,
+			},
+			[]reflect.Type{},
 		},
-		{[]string{`package a; type T int`}, []reflect.Type{}},
 		{
-			[]string{`package a; type T undeclared0.undeclared1`},
-			[]reflect.Type{
-				reflect.TypeOf(Undeclared{}),
-				reflect.TypeOf(Undeclared{}),
+			[]string{`package a
+				func f(c chan int) {
+					select {
+					case x, ok := <-c:
+						_, _ = x, ok
+					case c <- 1:
+					default:
+					}
+				}`,
 			},
+			[]reflect.Type{},
 		},
 		{
-			[]string{`package a; type T [5]int`},
+			[]string{`package a
+				func f(x int) {
+					switch y := x; y {
+					case 1, 2:
+					default:
+					}
+				}`,
+			},
 			[]reflect.Type{},
 		},
 		{
-			[]string{`package a; type T [1.0]int`},
+			[]string{`package a
+				func f() {
+					s := make([]int, 5)
+					s = append(s, 1, 2)
+					m := map[string]int{"a": 1}
+					delete(m, "a")
+					p := new(int)
+					*p = len(s)
+					if b := true; b {
+						panic("b")
+					}
+				}`,
+			},
 			[]reflect.Type{},
 		},
 		{
-			[]string{`package a; type T ["hello"]int`},
-			[]reflect.Type{reflect.TypeOf(BadArraySize{})},
+			[]string{`package a; func f() { a := 1; a, b := 2, 3; _ = b }`},
+			[]reflect.Type{},
 		},
 		{
-			// Too big for 64 bits, surely too big for an int.
-			[]string{`package a; type T [18446744073709551616]int`},
-			[]reflect.Type{reflect.TypeOf(BadArraySize{})},
+			[]string{`package a; func f() { x = 1 }`},
+			[]reflect.Type{reflect.TypeOf(Undeclared{})},
 		},
 		{
-			[]string{`package a; type T [-1]int`},
-			[]reflect.Type{reflect.TypeOf(BadArraySize{})},
+			[]string{`package a; func f() { { x := 1; _ = x }; x = 2 }`},
+			[]reflect.Type{reflect.TypeOf(Undeclared{})},
 		},
 		{
-			[]string{`package a; type T [1.1]int`},
-			[]reflect.Type{reflect.TypeOf(BadArraySize{})},
+			[]string{`package a; func f() { a := 1; a := 2 }`},
+			[]reflect.Type{reflect.TypeOf(NoNewVariables{})},
 		},
 		{
-			[]string{`package a; type T [^1]int`},
-			[]reflect.Type{reflect.TypeOf(BadArraySize{})},
+			[]string{`package a; func f() { var x int; x = "Hello, World!" }`},
+			[]reflect.Type{reflect.TypeOf(BadAssign{})},
 		},
 		{
-			[]string{`package a; const c = 5; type T [c]int`},
-			[]reflect.Type{},
+			[]string{`package a; func f() { var x int8; x = 1000 }`},
+			[]reflect.Type{reflect.TypeOf(Unrepresentable{})},
 		},
 		{
-			[]string{`package a; const c = 5; type T [-1]undeclared`},
-			[]reflect.Type{
-				reflect.TypeOf(BadArraySize{}),
-				reflect.TypeOf(Undeclared{}),
-			},
+			[]string{`package a; const c = 1; func f() { c = 2 }`},
+			[]reflect.Type{reflect.TypeOf(Unassignable{})},
 		},
 		{
-			// Don't report both BadArraySize and Undeclared.
-			[]string{`package a; const c = 5; type T [undeclared]undeclared`},
-			[]reflect.Type{
-				reflect.TypeOf(Undeclared{}),
-				reflect.TypeOf(Undeclared{}),
-			},
+			[]string{`package a; func f() { a, b := 1 }`},
+			[]reflect.Type{reflect.TypeOf(AssignCountMismatch{})},
 		},
 		{
-			[]string{`package a; type T [undeclared]int`},
-			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+			[]string{`package a; func f() { 5 }`},
+			[]reflect.Type{reflect.TypeOf(Unused{})},
 		},
 		{
-			[]string{`package a; type T []int`},
-			[]reflect.Type{},
+			[]string{`package a; func f() { s := ""; len(s) }`},
+			[]reflect.Type{reflect.TypeOf(Unused{})},
 		},
 		{
-			[]string{`package a; type T []undeclared`},
-			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+			[]string{`package a; func f() { go 5 }`},
+			[]reflect.Type{reflect.TypeOf(NotCall{})},
 		},
 		{
-			[]string{`package a; type T [][3][]int`},
-			[]reflect.Type{},
+			[]string{`package a; func f() { defer int(5) }`},
+			[]reflect.Type{reflect.TypeOf(NotCall{})},
 		},
 		{
-			[]string{`package a; type T *int`},
-			[]reflect.Type{},
+			[]string{`package a; func f() { if 1 {} }`},
+			[]reflect.Type{reflect.TypeOf(BadCondition{})},
 		},
 		{
-			[]string{`package a; type T ***[5]int`},
-			[]reflect.Type{},
+			[]string{`package a; func f() { for "Hello, World!" {} }`},
+			[]reflect.Type{reflect.TypeOf(BadCondition{})},
 		},
 		{
-			[]string{`package a; type T *undeclared`},
-			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+			[]string{`package a; func f() { switch { case 1: } }`},
+			[]reflect.Type{reflect.TypeOf(BadCondition{})},
 		},
 		{
-			[]string{`package a; type T map[string]int`},
-			[]reflect.Type{},
+			[]string{`package a; func f(x int) { switch x { case "Hello, World!": } }`},
+			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
 		},
 		{
-			[]string{`package a; type T map[string]map[string]int`},
-			[]reflect.Type{},
+			[]string{`package a; func f(b bool) { for range b {} }`},
+			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
 		},
 		{
-			[]string{`package a; type T map[map[string]int]undeclared`},
-			[]reflect.Type{
-				reflect.TypeOf(BadMapKey{}),
-				reflect.TypeOf(Undeclared{}),
-			},
+			[]string{`package a; func f() { x := "Hello, World!"; x++ }`},
+			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
 		},
 		{
-			// Don't report both BadMapKey and Undeclared.
-			[]string{`package a; type T map[map[undeclared]int]undeclared`},
-			[]reflect.Type{
-				reflect.TypeOf(Undeclared{}),
-				reflect.TypeOf(Undeclared{}),
-			},
+			[]string{`package a; func f(c <-chan int) { c <- 1 }`},
+			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
 		},
 		{
-			[]string{`package a; type T map[undefined]int`},
-			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+			[]string{`package a; func f() { x := 1; p := &x; q := &5 }`},
+			[]reflect.Type{reflect.TypeOf(InvalidOperation{})},
 		},
+
+		// Selectors
 		{
-			[]string{`package a; type T map[string]undefined`},
-			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+			[]string{`package a; type S struct { x int }; func f(s S) int { return s.x }`},
+			[]reflect.Type{},
 		},
 		{
-			[]string{`package a; type T map[map[string]int]int`},
-			[]reflect.Type{reflect.TypeOf(BadMapKey{})},
+			[]string{`package a; type T int; func (_ T) M() {}; func f() { var t T; t.M() }`},
+			[]reflect.Type{},
 		},
 		{
-			[]string{`
-				package a
-				type (
-					T chan int
-					U chan <- int
-					V <-chan int
-				)`,
-			},
+			[]string{`package a; type S struct { x int }; func f(s S, p *S) { s.x = 1; p.x++ }`},
 			[]reflect.Type{},
 		},
 		{
-			[]string{`package a; type T chan undeclared`},
-			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+			[]string{`package a; type S struct { x int }; func f() S { f().x = 1; return S{} }`},
+			[]reflect.Type{reflect.TypeOf(Unassignable{})},
 		},
-
-		// Recursive Types
 		{
-			[]string{`package a; type T T`},
-			[]reflect.Type{reflect.TypeOf(BadRecursiveType{})},
+			[]string{`package a; type S struct { x int }; var s S; var y = s.y`},
+			[]reflect.Type{reflect.TypeOf(UnknownSelector{})},
 		},
 		{
-			[]string{`package a; type T U; type U T`},
-			[]reflect.Type{reflect.TypeOf(BadRecursiveType{})},
+			[]string{`package a
+				type A struct { x int }
+				type B struct { x int }
+				type S struct { A; B }
+				var s S
+				var y = s.x`},
+			[]reflect.Type{reflect.TypeOf(UnknownSelector{})},
 		},
 		{
-			[]string{`package a; type T U; type U V; type V T`},
-			[]reflect.Type{reflect.TypeOf(BadRecursiveType{})},
+			[]string{`package a; type S struct { x int }; var y = S.x`},
+			[]reflect.Type{reflect.TypeOf(UnknownSelector{})},
 		},
 		{
-			[]string{`package a; type T [5]T`},
-			[]reflect.Type{reflect.TypeOf(BadRecursiveType{})},
+			[]string{`package a; type I interface { M() }; var p *I; var y = p.M`},
+			[]reflect.Type{reflect.TypeOf(UnknownSelector{})},
 		},
 		{
-			[]string{`package a; type T [5][6][7]T`},
-			[]reflect.Type{reflect.TypeOf(BadRecursiveType{})},
+			[]string{`package a; type T int; func (t *T) M() {}; func f() { T(1).M() }`},
+			[]reflect.Type{reflect.TypeOf(PointerMethod{})},
 		},
 		{
-			[]string{`package a; type T U; type U V; type V [1]T`},
-			[]reflect.Type{reflect.TypeOf(BadRecursiveType{})},
+			[]string{`package a; type T int; func (t *T) M() {}; var m = T.M`},
+			[]reflect.Type{reflect.TypeOf(PointerMethod{})},
 		},
 		{
-			[]string{`package a; type T []T`},
-			[]reflect.Type{},
+			[]string{`package a; type T int; func (_ T) M() int { return 0 }; const c = T(0).M()`},
+			[]reflect.Type{reflect.TypeOf(NotConstant{})},
 		},
+
+		// Struct composite literals
 		{
-			[]string{`package a; type T [][5]T`},
+			[]string{`package a; type S struct { x, y int; z *S }; var v = S{x: 1}; var w = S{1, 2, &v}; var e = S{}`},
 			[]reflect.Type{},
 		},
 		{
-			[]string{`package a; type T U; type U V; type V []T`},
+			[]string{`package a; type S struct { T }; type T struct{}; var v = S{T: T{}}`},
 			[]reflect.Type{},
 		},
 		{
-			[]string{`package a; type T *T`},
+			[]string{`package a; type S struct { x int; y *S }; var v = []S{{x: 1}, {2, &S{}}}`},
 			[]reflect.Type{},
 		},
 		{
-			[]string{`package a; type T U; type U V; type V *T`},
-			[]reflect.Type{},
+			[]string{`package a; type S struct { x, y int }; var v = S{1}`},
+			[]reflect.Type{reflect.TypeOf(BadCompositeLiteral{})},
 		},
 		{
-			[]string{`package a; type T map[T]int`},
-			[]reflect.Type{reflect.TypeOf(BadMapKey{})},
+			[]string{`package a; type S struct { x, y int }; var v = S{x: 1, 2}`},
+			[]reflect.Type{reflect.TypeOf(BadCompositeLiteral{})},
 		},
 		{
-			[]string{`package a; type T map[string]T`},
-			[]reflect.Type{},
+			[]string{`package a; type S struct { x, y int }; var v = S{z: 1}`},
+			[]reflect.Type{reflect.TypeOf(UnknownField{})},
 		},
 		{
-			[]string{`package a; type T chan T`},
-			[]reflect.Type{},
+			[]string{`package a; type S struct { x, y int }; var v = S{x: 1, x: 2}`},
+			[]reflect.Type{reflect.TypeOf(DuplicateFieldName{})},
+		},
+		{
+			[]string{`package a; type S struct { x, y int }; var v = S{x: ""}`},
+			[]reflect.Type{reflect.TypeOf(BadAssign{})},
+		},
+		{
+			[]string{`package a; func A(){}; func f(x interface{}){ switch t := x.(type) { case A: _ = t.A } }`},
+			[]reflect.Type{reflect.TypeOf(NotType{}), reflect.TypeOf(UnknownSelector{})},
+		},
+		{
+			[]string{`package a; func f() { v := undeclared; v, w := 0, 1; _, _ = v, w }`},
+			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+		},
+		{
+			[]string{`package a; func f() { v := undeclared; v, w := 0, ""; _, _ = v, w }`},
+			[]reflect.Type{reflect.TypeOf(Undeclared{})},
+		},
+		{
+			[]string{`package a; func g() (int, int) { return 0, 0 }; func f() { v := undeclared; v, w := g(); _, _ = v, w }`},
+			[]reflect.Type{reflect.TypeOf(Undeclared{})},
 		},
 	}
 	for _, test := range tests {
@@ -555,17 +1750,18 @@ func TestCheckErrors(t *testing.T) {
 			want[e]++
 		}
 
+		fset := token.NewFileSet()
 		var files []*File
 		for _, src := range test.src {
-			l := token.NewLexer("", src)
+			l := token.NewLexer(fset, "", src)
 			p := NewParser(l)
 			files = append(files, parseFile(p))
 		}
 
 		var got []reflect.Type
-		if err := Check(files); err != nil {
+		if err := Check(fset, files, nil, nil); err != nil {
 			for _, e := range err.(errors).All() {
-				t := reflect.TypeOf(e)
+				t := reflect.TypeOf(e.(*LocatedError).Err)
 				want[t]--
 				if want[t] == 0 {
 					delete(want, t)
@@ -579,6 +1775,52 @@ func TestCheckErrors(t *testing.T) {
 	}
 }
 
+func TestNotImplementedMethods(t *testing.T) {
+	tests := []struct {
+		src            string
+		missing, wrong []string
+	}{
+		{
+			`package a
+			type I interface{ A(); B(); C() int; D() }
+			type T int
+			func (t T) C() string { return "" }
+			func (t *T) D() {}
+			var i I = T(0)`,
+			[]string{"A", "B", "D"},
+			[]string{"C"},
+		},
+	}
+	for _, test := range tests {
+		fset := token.NewFileSet()
+		l := token.NewLexer(fset, "", test.src)
+		p := NewParser(l)
+		f := parseFile(p)
+		err := Check(fset, []*File{f}, nil, nil)
+		if err == nil {
+			t.Errorf("Check(%v), expected error", test.src)
+			continue
+		}
+		es := err.(errors).All()
+		ni, ok := es[0].(*LocatedError).Err.(NotImplemented)
+		if len(es) != 1 || !ok {
+			t.Errorf("Check(%v)=%v, want a single NotImplemented", test.src, err)
+			continue
+		}
+		var missing, wrong []string
+		for _, m := range ni.Missing {
+			missing = append(missing, m.Name)
+		}
+		for _, m := range ni.Wrong {
+			wrong = append(wrong, m.Name)
+		}
+		if !reflect.DeepEqual(missing, test.missing) || !reflect.DeepEqual(wrong, test.wrong) {
+			t.Errorf("Check(%v) missing=%v, wrong=%v, want missing=%v, wrong=%v",
+				test.src, missing, wrong, test.missing, test.wrong)
+		}
+	}
+}
+
 func TestConstFolding(t *testing.T) {
 	runeNeg97 := intLit("-97")
 	runeNeg97.Rune = true
@@ -617,12 +1859,52 @@ func TestConstFolding(t *testing.T) {
 		{`package a; const α = !false`, &BoolLiteral{Value: true}},
 		{`package a; const α = !!false`, &BoolLiteral{Value: false}},
 		{`package a; const f, α = false, !f`, &BoolLiteral{Value: true}},
+		{`package a; const ( a = -iota; α )`, intLit("-1")},
+		{`package a; const ( a = 1 << iota; b; α )`, intLit("4")},
+		{`package a; const α uint8 = ^uint8(0)`, intLit("255")},
+
+		// BinaryOps
+		{`package a; const α = 1 + 2`, intLit("3")},
+		{`package a; const α = 1 - 2`, intLit("-1")},
+		{`package a; const α = 3 * 4`, intLit("12")},
+		{`package a; const α = 7 / 2`, intLit("3")},
+		{`package a; const α = -7 / 2`, intLit("-3")},
+		{`package a; const α = 7 % 2`, intLit("1")},
+		{`package a; const α = -7 % 2`, intLit("-1")},
+		{`package a; const α = 7.0 / 2`, floatLit("3.5")},
+		{`package a; const α = 7 / 2.0`, floatLit("3.5")},
+		{`package a; const α = 'a' + 1`, runeLit('b')},
+		{`package a; const α = 1 + 2i`, &ComplexLiteral{Real: big.NewRat(1, 1), Imaginary: big.NewRat(2, 1)}},
+		{`package a; const α = (1 + 2i) * (3 + 4i)`, &ComplexLiteral{Real: big.NewRat(-5, 1), Imaginary: big.NewRat(10, 1)}},
+		{`package a; const α = (1 + 2i) / (3 + 4i)`, &ComplexLiteral{Real: big.NewRat(11, 25), Imaginary: big.NewRat(2, 25)}},
+		{`package a; const α = 6 & 3`, intLit("2")},
+		{`package a; const α = 6 | 3`, intLit("7")},
+		{`package a; const α = 6 ^ 3`, intLit("5")},
+		{`package a; const α = 6 &^ 3`, intLit("4")},
+		{`package a; const α = 1 << 3`, intLit("8")},
+		{`package a; const α = 1.0 << 3`, intLit("8")},
+		{`package a; const α = 16 >> 3`, intLit("2")},
+		{`package a; const α = -16 >> 3`, intLit("-2")},
+		{`package a; const α = 1 << 100 >> 98`, intLit("4")},
+		{`package a; const α = "Hello, " + "World!"`, strLit("Hello, World!")},
+		{`package a; const α = true && false`, &BoolLiteral{Value: false}},
+		{`package a; const α = true || false`, &BoolLiteral{Value: true}},
+		{`package a; const α = 1 == 1`, &BoolLiteral{Value: true}},
+		{`package a; const α = 1 != 1.0`, &BoolLiteral{Value: false}},
+		{`package a; const α = 1 < 2`, &BoolLiteral{Value: true}},
+		{`package a; const α = 2.5 <= 2`, &BoolLiteral{Value: false}},
+		{`package a; const α = "a" > "b"`, &BoolLiteral{Value: false}},
+		{`package a; const α = "a" >= "a"`, &BoolLiteral{Value: true}},
+		{`package a; const α = 1i == 1i`, &BoolLiteral{Value: true}},
+		{`package a; const α = true == false`, &BoolLiteral{Value: false}},
+		{`package a; const b int8 = 5; const α = b * 2 + 1`, intLit("11")},
 	}
 	for _, test := range tests {
-		l := token.NewLexer("", test.src)
+		fset := token.NewFileSet()
+		l := token.NewLexer(fset, "", test.src)
 		p := NewParser(l)
 		f := parseFile(p)
-		if err := Check([]*File{f}); err != nil {
+		if err := Check(fset, []*File{f}, nil, nil); err != nil {
 			t.Errorf("Check(%v), unexpected error: %v", test.src, err)
 			continue
 		}
@@ -861,7 +2143,7 @@ func TestIsRepresentable(t *testing.T) {
 
 func TestTypeIdentical(t *testing.T) {
 	// We don't care about the contents of these, just that &pkgA != &pkgB.
-	var pkgA, pkgB packageDecl
+	var pkgA, pkgB Package
 
 	tests := []struct {
 		u, v  Type
@@ -1580,6 +2862,39 @@ func TestTypeUnderlying(t *testing.T) {
 	}
 }
 
+func TestInterfaceMethodSet(t *testing.T) {
+	tests := []struct {
+		src   string
+		names []string
+	}{
+		{`package a; type α interface{}`, []string{}},
+		{`package a; type α interface{ M() }`, []string{"M"}},
+		{`package a; type α interface{ C(); A(); B() }`, []string{"A", "B", "C"}},
+		{`package a; type α interface{ B(); U }; type U interface{ C(); A() }`, []string{"A", "B", "C"}},
+		{`package a; type α interface{ U; V }; type U interface{ A() }; type V interface{ A() }`, []string{"A"}},
+		{`package a; type α interface{ U; B() }; type U interface{ V; C() }; type V interface{ A() }`, []string{"A", "B", "C"}},
+		{`package a; type α interface{ M() U }; type U interface{ α; N() }`, []string{"M"}},
+	}
+	for _, test := range tests {
+		fset := token.NewFileSet()
+		l := token.NewLexer(fset, "", test.src)
+		p := NewParser(l)
+		f := parseFile(p)
+		if err := Check(fset, []*File{f}, nil, nil); err != nil {
+			t.Errorf("Check(%v), unexpected error: %v", test.src, err)
+			continue
+		}
+		iface := f.syms.Find("α").(*TypeSpec).Type.(*InterfaceType)
+		names := []string{}
+		for _, m := range iface.methodSet {
+			names = append(names, m.Name)
+		}
+		if !reflect.DeepEqual(names, test.names) {
+			t.Errorf("Check(%v): α's method set is %v, want %v", test.src, names, test.names)
+		}
+	}
+}
+
 func TestPkgDecls(t *testing.T) {
 	tests := []struct {
 		src []string
@@ -1659,24 +2974,31 @@ func TestPkgDecls(t *testing.T) {
 			},
 			ids: []string{"a", "B", "c", "d"},
 		},
+
+		// Methods are not bound in the package scope.
 		{
 			src: []string{
 				`package a
 			func (z int) a(){}
 			func (z float64) B() int { return 0 }`,
 			},
-			ids: []string{"a", "B"},
 		},
 		{
 			src: []string{
 				`package a
+			type T0 int
 			func (z T0) a(){}
-			func (z T1) B() int { return 0 }`,
+			func (z T0) B() int { return 0 }`,
 				`package a
-			func (z T2) c(e int){}
-			func (z T3) d() (f int) { return 0 }`,
+			func (z T1) a(e int){}
+			func (z *T1) B() (f int) { return 0 }
+			type T1 int`,
 			},
-			ids: []string{"a", "B", "c", "d"},
+			ids: []string{"T0", "T1"},
+		},
+		{
+			src: []string{`package a; type T int; func (t T) _(){}; func (t *T) _(){}`},
+			ids: []string{"T"},
 		},
 
 		// Redeclaration errors.
@@ -1699,6 +3021,17 @@ func TestPkgDecls(t *testing.T) {
 			},
 			err: "a redeclared",
 		},
+		{
+			src: []string{`package a; type T int; func (t T) a(){}; func (t *T) a(){}`},
+			err: "a redeclared",
+		},
+		{
+			src: []string{
+				`package a; type T int; func (t T) a(){}`,
+				`package a; func (t T) a(){}`,
+			},
+			err: "a redeclared",
+		},
 		{
 			src: []string{`
 				package a
@@ -1758,7 +3091,7 @@ func TestPkgDecls(t *testing.T) {
 		}
 		var ids []string
 		seen := make(map[Declaration]bool)
-		s, err := pkgDecls(files)
+		s, err := pkgDecls(files, nil)
 		if test.err != "" {
 			if err == nil {
 				t.Errorf("pkgDecls(%v), err=nil, want matching %s", test.src, test.err)
@@ -1790,7 +3123,7 @@ func TestPkgDecls(t *testing.T) {
 func parseSrcFiles(t *testing.T, srcFiles []string) []*File {
 	var files []*File
 	for _, src := range srcFiles {
-		p := NewParser(token.NewLexer("", src))
+		p := NewParser(token.NewLexer(token.NewFileSet(), "", src))
 		file, err := Parse(p)
 		if err == nil {
 			files = append(files, file)
@@ -1813,8 +3146,8 @@ func TestScopeFind(t *testing.T) {
 		var len = 1	// Shadows the predeclared len function.
 		func f() int { return 0 }
 	`
-	p := NewParser(token.NewLexer("", src))
-	s, err := pkgDecls([]*File{parseFile(p)})
+	p := NewParser(token.NewLexer(token.NewFileSet(), "", src))
+	s, err := pkgDecls([]*File{parseFile(p)}, nil)
 	if err != nil {
 		panic(err)
 	}
@@ -1840,7 +3173,7 @@ func TestScopeFind(t *testing.T) {
 
 	// Our testing source file shadowed len, but let's check that it's
 	// still findable in the universal scope.
-	d := univScope.Find("len")
+	d := Universe.Find("len")
 	typ := reflect.TypeOf(d)
 	lenType := reflect.TypeOf(&predeclaredFunc{})
 	if d == nil || typ != lenType {
@@ -1849,10 +3182,30 @@ func TestScopeFind(t *testing.T) {
 
 	// Rune and byte are aliases for int32 and uint8 respectively;
 	// the have the same declaration.
-	if univScope.Find("rune") != univScope.Find("int32") {
+	if Universe.Find("rune") != Universe.Find("int32") {
 		t.Errorf("rune is not an alias for int32")
 	}
-	if univScope.Find("byte") != univScope.Find("uint8") {
+	if Universe.Find("byte") != Universe.Find("uint8") {
 		t.Errorf("byte is not an alias for uint8")
 	}
 }
+
+func TestCheckBadNodes(t *testing.T) {
+	// The syntax errors of Bad nodes are reported by the parser,
+	// so checking them reports no additional errors.
+	src := `package a
+		var x = )
+		func f() int {
+			y := ]
+			if 1 == { }
+			return 1
+		}`
+	fset := token.NewFileSet()
+	f, err := Parse(NewParser(token.NewLexer(fset, "", src)))
+	if err == nil {
+		t.Fatalf("Parse(%s), expected syntax errors", src)
+	}
+	if err := Check(fset, []*File{f}, nil, nil); err != nil {
+		t.Errorf("Check(%s)=%v, want nil", src, err)
+	}
+}
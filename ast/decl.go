@@ -1,18 +1,22 @@
 package ast
 
 import (
+	"sort"
+
 	"github.com/velour/stop/token"
 )
 
 var (
-	// UnivScope is the universal symtab, containing all predeclared identifiers.
-	univScope = symtab{
+	// Universe is the outermost scope, containing all predeclared identifiers.
+	Universe = &Scope{
 		Decls: map[string]Declaration{
 			// Predeclared types.
+			"any":        anyAlias,
 			"bool":       Bool,
 			"byte":       Uint8,
 			"complex64":  Complex64,
 			"complex128": Complex128,
+			"comparable": Comparable,
 			"error":      Error,
 			"float32":    Float32,
 			"float64":    Float64,
@@ -81,55 +85,149 @@ const (
 	Uint32
 	Uint64
 	Uintptr
+	Comparable
 )
 
-func (predeclaredType) Comments() []string    { return nil }
-func (predeclaredType) Start() token.Location { return token.Location{} }
-func (predeclaredType) End() token.Location   { return token.Location{} }
+// AnyAlias is the declaration of the predeclared type any,
+// an alias for interface{}.
+var anyAlias = &TypeSpec{
+	Identifier: Identifier{Name: "any"},
+	Alias:      true,
+	Type:       anyInterface,
+	state:      checkedOK,
+}
+
+func (predeclaredType) Comments() []string { return nil }
+func (predeclaredType) Start() token.Pos   { return token.NoPos }
+func (predeclaredType) End() token.Pos     { return token.NoPos }
 
 // A predeclaredConst is a declaration node representing a predeclared constant.
 type predeclaredConst struct{}
 
-func (*predeclaredConst) Comments() []string    { return nil }
-func (*predeclaredConst) Start() token.Location { return token.Location{} }
-func (*predeclaredConst) End() token.Location   { return token.Location{} }
+func (*predeclaredConst) Comments() []string { return nil }
+func (*predeclaredConst) Start() token.Pos   { return token.NoPos }
+func (*predeclaredConst) End() token.Pos     { return token.NoPos }
 
 // A predeclaredFunc is a declaration node representing a predeclared function.
 type predeclaredFunc struct{}
 
-func (*predeclaredFunc) Comments() []string    { return nil }
-func (*predeclaredFunc) Start() token.Location { return token.Location{} }
-func (*predeclaredFunc) End() token.Location   { return token.Location{} }
+func (*predeclaredFunc) Comments() []string { return nil }
+func (*predeclaredFunc) Start() token.Pos   { return token.NoPos }
+func (*predeclaredFunc) End() token.Pos     { return token.NoPos }
+
+// A Scope is the main element of the symbol table. It contains a mapping
+// from all identifiers declared in a given scope to their declaration.
+// The declarations are unique. Each identifier declared in a VarSpec or
+// a ConstSpec is mapped to a unique view of the declaring spec.
+type Scope struct {
+	// Parent is the enclosing scope, or nil for the Universe scope.
+	Parent *Scope
 
-// A symtab is the main element of the symbol table. It contains a mapping
-// from all identifiers in a given scope to their declaration. The declarations
-// are unique.  Each identifier declared in a VarSpec or a ConstSpec is
-// mapped to a unique view of the declaring spec.
-type symtab struct {
-	Up    *symtab
+	// Children are the scopes nested directly within this scope,
+	// in the order that they were created. The package scopes
+	// are not children of the Universe scope.
+	Children []*Scope
+
+	// Decls maps the names declared in this scope to their declarations.
 	Decls map[string]Declaration
+
+	// Start and end are the source range of the scope. They are both
+	// NoPos for the Universe and package scopes, which have no
+	// source range.
+	start, end token.Pos
+
+	// Info records the results of checking. It is inherited from Parent.
+	info *Info
+}
+
+// MakeScope returns a new Scope nested in up. If n is non-nil, it is
+// the node that introduces the scope: its range is the range of the scope,
+// and it is recorded in the Info of the scope.
+func makeScope(up *Scope, n Node) *Scope {
+	s := &Scope{Parent: up, Decls: make(map[string]Declaration)}
+	if up != nil {
+		s.info = up.info
+		if up != Universe {
+			up.Children = append(up.Children, s)
+		}
+	}
+	if n != nil {
+		s.start, s.end = n.Start(), n.End()
+		s.info.scope(n, s)
+	}
+	return s
 }
 
-// MakeSymtab returns a new symbol table.
-func makeSymtab(up *symtab) *symtab {
-	return &symtab{Up: up, Decls: make(map[string]Declaration)}
+// Start returns the start location of the scope's source range.
+func (s *Scope) Start() token.Pos { return s.start }
+
+// End returns the end location of the scope's source range.
+func (s *Scope) End() token.Pos { return s.end }
+
+// Lookup returns the declaration bound to the given identifier in this scope,
+// or nil if the identifier is not declared in this scope. Unlike Find,
+// the enclosing scopes are not searched.
+func (s *Scope) Lookup(n string) Declaration {
+	return s.Decls[n]
 }
 
-// Find returns the declaration bound to the given identifier, or nil if the identifier
+// Find returns the declaration bound to the given identifier in this scope
+// or the innermost enclosing scope that declares it, or nil if the identifier
 // is not found.
-func (s *symtab) Find(n string) Declaration {
+func (s *Scope) Find(n string) Declaration {
 	if s == nil {
 		return nil
 	}
 	if d, ok := s.Decls[n]; ok {
 		return d
 	}
-	return s.Up.Find(n)
+	return s.Parent.Find(n)
+}
+
+// Names returns the sorted names declared in this scope.
+func (s *Scope) Names() []string {
+	names := make([]string, 0, len(s.Decls))
+	for n := range s.Decls {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Contains returns whether the position is within the source range of
+// the scope. Scopes without a source range contain all positions.
+func (s *Scope) Contains(p token.Pos) bool {
+	if !s.start.IsValid() {
+		return true
+	}
+	return s.start <= p && p <= s.end
+}
+
+// Innermost returns the innermost scope, either this scope or one nested
+// within it, that contains the position, or nil if the position is not
+// contained by this scope. The names visible at the position are those
+// declared in the returned scope and its parents.
+//
+// Only the Children of a scope are searched, so the package scopes are
+// not found from the Universe scope.
+func (s *Scope) Innermost(p token.Pos) *Scope {
+	if !s.Contains(p) {
+		return nil
+	}
+	for _, c := range s.Children {
+		if !c.start.IsValid() {
+			continue
+		}
+		if in := c.Innermost(p); in != nil {
+			return in
+		}
+	}
+	return s
 }
 
 // Bind binds a name to its declaration, returning an error if the name is already
-// bound in this symtab. The blank identifier is not bound.
-func (s *symtab) Bind(n string, decl Declaration) error {
+// bound in this scope. The blank identifier is not bound.
+func (s *Scope) Bind(n string, decl Declaration) error {
 	if n == "_" {
 		return nil
 	}
@@ -171,23 +269,117 @@ type constSpecView struct {
 type varSpecView struct {
 	Index int
 	*VarSpec
+	// If the type of the var spec is not specified, then each identifier gets
+	// its own type, based on the type of its expression. Type is set by the
+	// Check pass.
+	Type Type
+
+	state checkState
+}
+
+// A shortVarDeclView is a view of a ShortVarDecl that focuses on a single
+// identifier at a given index. Only identifiers that are newly declared by
+// the ShortVarDecl have views; the others are simply assigned.
+type shortVarDeclView struct {
+	*ShortVarDecl
+	Index int
+	// Type is the type of the variable. It is nil if the type could not be
+	// determined because of an error that has already been reported.
+	Type Type
+}
+
+func (n *shortVarDeclView) Start() token.Pos { return n.Left[n.Index].Start() }
+func (n *shortVarDeclView) End() token.Pos   { return n.Left[n.Index].End() }
+
+// A recvStmtView is a view of a RecvStmt in a communication case of a select
+// statement that focuses on a single identifier at a given index. Views are only
+// created for RecvStmts with Op==token.ColonEqual.
+type recvStmtView struct {
+	*RecvStmt
+	Index int
+	// Type is the type of the variable. It is nil if the type could not be
+	// determined because of an error that has already been reported.
+	Type Type
+}
+
+func (n *recvStmtView) Start() token.Pos { return n.Left[n.Index].Start() }
+func (n *recvStmtView) End() token.Pos   { return n.Left[n.Index].End() }
+
+// A typeSwitchView is a view of the variable declared by a TypeSwitch within
+// a single case clause. Each clause has a different view, because the type of
+// the variable differs from clause to clause.
+type typeSwitchView struct {
+	*TypeSwitch
+	// Type is the type of the variable within the clause. It is nil if the
+	// type could not be determined because of an error that has already
+	// been reported.
+	Type Type
+}
+
+func (n *typeSwitchView) Start() token.Pos { return n.Declaration.Start() }
+func (n *typeSwitchView) End() token.Pos   { return n.Declaration.End() }
+
+// IsVariable returns whether the declaration declares a variable.
+func isVariable(d Declaration) bool {
+	switch d.(type) {
+	case *varSpecView, *shortVarDeclView, *recvStmtView, *typeSwitchView, *ParameterDecl:
+		return true
+	}
+	return false
+}
+
+// DeclType returns the type of the constant, variable, parameter,
+// function, or method declared by a checked declaration, such as one
+// in the Defs or Uses of an Info. An untyped constant has its default
+// type. DeclType returns nil for other declarations, like types and
+// imports, and for declarations whose types are not known because
+// of an error.
+func DeclType(d Declaration) Type {
+	var t Type
+	switch d := d.(type) {
+	case *constSpecView:
+		t = d.Type
+	case *varSpecView:
+		t = d.Type
+	case *shortVarDeclView:
+		t = d.Type
+	case *recvStmtView:
+		t = d.Type
+	case *typeSwitchView:
+		t = d.Type
+	case *ParameterDecl:
+		t = d.Type
+		if d.DotDotDot {
+			t = &SliceType{Element: d.Type}
+		}
+	case *FunctionDecl:
+		t = &FunctionType{Signature: d.Signature}
+	case *MethodDecl:
+		t = &FunctionType{Signature: d.Signature}
+	}
+	return recordedType(t)
 }
 
-// A packageDecl is a a package import declaration. It contains a mapping for all
-// exported symbols in the package.
+// A packageDecl is a a package import declaration.
 type packageDecl struct {
-	syms *symtab
+	// Package is the imported package. It is nil until the package
+	// is imported, and it remains nil if the package could not be
+	// imported.
+	*Package
 	*ImportDecl
 }
 
-// PkgDecls returns a symtab, mapping from package-scoped identifiers
+// PkgDecls returns the package Scope, mapping from package-scoped identifiers
 // to their corresponding declarations. Each identifier is mapped to a
 // unique declaration. Each identifier declared in a VarSpec or a
 // ConstSpec is mapped to a unique view of the declaring spec.
-// Any errors that are encountered are also returned, but the symtab is always
-// valid, even in the face of errors.
-func pkgDecls(files []*File) (*symtab, error) {
-	psyms := makeSymtab(&univScope)
+// Any errors that are encountered are also returned, but the Scope is always
+// valid, even in the face of errors. The definitions are recorded in info,
+// which may be nil.
+func pkgDecls(files []*File, info *Info) (*Scope, error) {
+	psyms := makeScope(Universe, nil)
+	psyms.info = info
+	var methods []*MethodDecl
 	var errs errors
 	for _, f := range files {
 		var err error
@@ -198,61 +390,87 @@ func pkgDecls(files []*File) (*symtab, error) {
 			switch d := d.(type) {
 			case *MethodDecl:
 				d.syms = f.syms
-				if err := psyms.Bind(d.Identifier.Name, d); err != nil {
-					errs = append(errs, err)
-				}
+				methods = append(methods, d)
 			case *FunctionDecl:
 				d.syms = f.syms
-				if err := psyms.Bind(d.Identifier.Name, d); err != nil {
+				if err := psyms.declare(&d.Identifier, d); err != nil {
 					errs = append(errs, err)
 				}
 			case *TypeSpec:
 				d.syms = f.syms
-				if err := psyms.Bind(d.Identifier.Name, d); err != nil {
+				if err := psyms.declare(&d.Identifier, d); err != nil {
 					errs = append(errs, err)
 				}
 			case *ConstSpec:
 				d.syms = f.syms
 				for i := range d.Identifiers {
-					n := d.Identifiers[i].Name
 					v := &constSpecView{Index: i, ConstSpec: d}
 					d.views = append(d.views, v)
-					if err := psyms.Bind(n, v); err != nil {
+					if err := psyms.declare(&d.Identifiers[i], v); err != nil {
 						errs = append(errs, err)
 					}
 				}
 			case *VarSpec:
 				d.syms = f.syms
 				for i := range d.Identifiers {
-					n := d.Identifiers[i].Name
 					v := &varSpecView{Index: i, VarSpec: d}
-					if err := psyms.Bind(n, v); err != nil {
+					d.views = append(d.views, v)
+					if err := psyms.declare(&d.Identifiers[i], v); err != nil {
 						errs = append(errs, err)
 					}
 				}
+			case *BadDecl:
+				// The syntax error is reported by the parser.
+				errs = append(errs, errors{})
 			default:
 				panic("invalid top-level declaration")
 			}
 		}
 	}
+	for _, m := range methods {
+		if err := bindMethod(psyms, m); err != nil {
+			errs = append(errs, err)
+		}
+	}
 	return psyms, errs.ErrorOrNil()
 }
 
-// FileDecls returns the symtab, mapping file-scoped identifiers to their
+// BindMethod adds a method to the methods of its receiver's base type.
+// Methods are not bound in the package scope. If the base type is not a
+// package-level type, the error is reported when the method is checked.
+func bindMethod(psyms *Scope, m *MethodDecl) error {
+	psyms.info.def(&m.Identifier, m)
+	d, ok := psyms.Decls[m.BaseTypeName.Name].(*TypeSpec)
+	if !ok || m.Name == "_" {
+		return nil
+	}
+	for _, p := range d.methods {
+		if p.Name == m.Name {
+			return &Redeclaration{Name: m.Name, First: p, Second: m}
+		}
+	}
+	d.methods = append(d.methods, m)
+	return nil
+}
+
+// FileDecls returns the file Scope, mapping file-scoped identifiers to their
 // correpsonding declarations. Any errors that are encountered are also
-// returned, but the symtab is always valid, even in the face of errors.
-func fileDecls(psyms *symtab, file *File) (*symtab, error) {
-	syms := makeSymtab(psyms)
+// returned, but the Scope is always valid, even in the face of errors.
+func fileDecls(psyms *Scope, file *File) (*Scope, error) {
+	syms := makeScope(psyms, file)
 	var errs errors
-	for i, d := range file.Imports {
-		for _, im := range d.Imports {
-			// BUG(eaburns): Should actually read the package imports.
-			// BUG(eaburns): Should deal with dot imports
-			p := &packageDecl{
-				syms:       makeSymtab(&univScope),
-				ImportDecl: &file.Imports[i],
+	for i := range file.Imports {
+		d := &file.Imports[i]
+		for j := range d.Imports {
+			im := &d.Imports[j]
+			im.pkg = &packageDecl{ImportDecl: d}
+			if im.Dot {
+				// The declarations of dot imports are bound
+				// when the package is imported.
+				continue
 			}
-			if err := syms.Bind(im.Name(), p); err != nil {
+			syms.info.def(im.Identifier, im.pkg)
+			if err := syms.Bind(im.Name(), im.pkg); err != nil {
 				errs = append(errs, err)
 			}
 		}
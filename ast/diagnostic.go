@@ -0,0 +1,214 @@
+package ast
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/velour/stop/token"
+)
+
+// A Renderer writes errors as diagnostics that show the lines of source
+// to which they refer. Each diagnostic is the error message followed by
+// the line containing the start of the error with its span underlined,
+// and by any secondary locations of the error, such as the original
+// declaration of a redeclared identifier, each with its line underlined.
+//
+// For example:
+//
+//	a.go:3:4: x redeclared, originally declared at a.go:2:4
+//		var x = 2
+//		    ^~~~~
+//	a.go:2:4: note: originally declared here
+//		var x = 1
+//		    ^~~~~
+type Renderer struct {
+	// Sources maps the path of each file to its source text.
+	// Source lines are not shown for files that are not in Sources.
+	Sources map[string]string
+
+	// Color is whether the diagnostics are colored with ANSI
+	// escape sequences.
+	Color bool
+}
+
+// ANSI escape sequences used by a Renderer with Color.
+const (
+	ansiBold  = "\x1b[1m"
+	ansiRed   = "\x1b[31m"
+	ansiCyan  = "\x1b[36m"
+	ansiReset = "\x1b[0m"
+)
+
+// A note is a secondary location of an error.
+type note struct {
+	start, end token.Pos
+	msg        string
+}
+
+// Render writes a diagnostic for each of the errors of err, gathered
+// recursively by calling All on any nested errors. Each *LocatedError,
+// such as those returned by Parse and Check, is located in its FileSet.
+// Other errors are written as their message alone. Nothing is written
+// for nil errors.
+func (r *Renderer) Render(w io.Writer, err error) error {
+	es := []error{err}
+	if all, ok := err.(interface {
+		All() []error
+	}); ok {
+		es = all.All()
+	}
+	for _, e := range es {
+		if e == nil {
+			continue
+		}
+		if err := r.render(w, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Render writes the diagnostic of a single error.
+func (r *Renderer) render(w io.Writer, err error) error {
+	var fset *token.FileSet
+	var start, end token.Pos
+	var notes []note
+	if e, ok := err.(*LocatedError); ok {
+		fset = e.Fset
+		start, end, notes = errorSpans(e.Err)
+	}
+	msg := err.Error()
+	if r.Color {
+		msg = ansiBold + msg + ansiReset
+	}
+	if _, err := io.WriteString(w, msg+"\n"); err != nil {
+		return err
+	}
+	if err := r.snippet(w, fset, start, end, ansiRed); err != nil {
+		return err
+	}
+	for _, n := range notes {
+		if !n.start.IsValid() {
+			continue
+		}
+		msg := fset.Location(n.start).String() + ": note: " + n.msg
+		if r.Color {
+			msg = ansiBold + msg + ansiReset
+		}
+		if _, err := io.WriteString(w, msg+"\n"); err != nil {
+			return err
+		}
+		if err := r.snippet(w, fset, n.start, n.end, ansiCyan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Snippet writes the source line containing start, underlined
+// from start to end, or to the end of the line if end is on a later line.
+// Nothing is written if the line is not available.
+func (r *Renderer) snippet(w io.Writer, fset *token.FileSet, start, end token.Pos, color string) error {
+	if !start.IsValid() {
+		return nil
+	}
+	s := fset.Location(start)
+	src, ok := r.Sources[s.Path]
+	if !ok {
+		return nil
+	}
+	line, ok := sourceLine(src, s.Line)
+	if !ok {
+		return nil
+	}
+	runes := []rune(line)
+	col := s.Column()
+	if col > len(runes) {
+		return nil
+	}
+	n := 1
+	if end.IsValid() {
+		e := fset.Location(end)
+		switch {
+		case e.Line > s.Line:
+			n = len(runes) - col
+		case e.Rune > s.Rune:
+			n = e.Rune - s.Rune
+		}
+		if col+n > len(runes) {
+			n = len(runes) - col
+		}
+		if n < 1 {
+			n = 1
+		}
+	}
+
+	// Tabs are kept in the indentation of the underline,
+	// so that it lines up with the source line.
+	var indent []rune
+	for _, c := range runes[:col] {
+		if c == '\t' {
+			indent = append(indent, '\t')
+		} else {
+			indent = append(indent, ' ')
+		}
+	}
+	mark := "^" + strings.Repeat("~", n-1)
+	if r.Color {
+		mark = color + mark + ansiReset
+	}
+	_, err := fmt.Fprintf(w, "\t%s\n\t%s%s\n", line, string(indent), mark)
+	return err
+}
+
+// SourceLine returns the text of a line of the source, without its newline,
+// where the first line is line 1.
+func sourceLine(src string, n int) (string, bool) {
+	for i := 1; i < n; i++ {
+		j := strings.IndexByte(src, '\n')
+		if j < 0 {
+			return "", false
+		}
+		src = src[j+1:]
+	}
+	if j := strings.IndexByte(src, '\n'); j >= 0 {
+		src = src[:j]
+	}
+	return strings.TrimSuffix(src, "\r"), true
+}
+
+// ErrorSpans returns the span of an error and its secondary locations.
+// The span is NoPos for an error without a location.
+func errorSpans(err error) (start, end token.Pos, notes []note) {
+	switch e := err.(type) {
+	case *SyntaxError:
+		return e.Start, e.End, nil
+	case *Redeclaration:
+		n := note{start: e.First.Start(), end: e.First.End(), msg: "originally declared here"}
+		return e.Second.Start(), e.Second.End(), []note{n}
+	case DuplicateField:
+		n := note{start: e.First.Start(), end: e.First.End(), msg: "originally declared here"}
+		return e.Second.Start(), e.Second.End(), []note{n}
+	case DuplicateMethod:
+		n := note{start: e.First.Start(), end: e.First.End(), msg: "originally declared here"}
+		return e.Second.Start(), e.Second.End(), []note{n}
+	case Unrepresentable:
+		return e.Expression.Loc(), e.Expression.End(), nil
+	case BadAssign:
+		return e.Expression.Start(), e.Expression.End(), nil
+	case NotImplemented:
+		return e.Expression.Start(), e.Expression.End(), nil
+	case BadConversion:
+		return e.Expression.Start(), e.Expression.End(), nil
+	case BadMapKey:
+		return e.Type.Start(), e.Type.End(), nil
+	case BadReceiver:
+		return e.BaseTypeName.Start(), e.BaseTypeName.End(), nil
+	case DivisionByZero:
+		return e.Loc(), e.End(), nil
+	case Node:
+		return e.Start(), e.End(), nil
+	}
+	return token.NoPos, token.NoPos, nil
+}
@@ -0,0 +1,109 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/velour/stop/token"
+)
+
+func TestRenderer(t *testing.T) {
+	tests := []struct {
+		src   string
+		color bool
+		want  string
+	}{
+		{
+			src: "package a\nvar x = 1\nvar x = 2",
+			want: "a.go:3:4: x redeclared, originally declared at a.go:2:4\n" +
+				"\tvar x = 2\n" +
+				"\t    ^~~~~\n" +
+				"a.go:2:4: note: originally declared here\n" +
+				"\tvar x = 1\n" +
+				"\t    ^~~~~\n",
+		},
+		{
+			src: "package a\nfunc f() {\n\tvar s string = 1 + 2\n}",
+			want: "a.go:3:16: bad assignment\n" +
+				"\t\tvar s string = 1 + 2\n" +
+				"\t\t               ^~~~~\n",
+		},
+		{
+			src: "package a\nvar x = \"a\" - \"b\"",
+			want: "a.go:2:8: invalid operation: \"a\" - \"b\"\n" +
+				"\tvar x = \"a\" - \"b\"\n" +
+				"\t        ^~~~~~~~~\n",
+		},
+		{
+			src: "package a\nvar y = -true",
+			want: "a.go:2:8: invalid operation: -true\n" +
+				"\tvar y = -true\n" +
+				"\t        ^~~~~\n",
+		},
+		{
+			src: "package a\nvar y = zzz",
+			want: "a.go:2:8: undeclared identifier zzz\n" +
+				"\tvar y = zzz\n" +
+				"\t        ^~~\n",
+		},
+		{
+			src: "package a\nvar x = \"abc\\z\"",
+			want: "a.go:2:8: invalid escape sequence [\\\"abc\\\\z\\\"]\n" +
+				"\tvar x = \"abc\\z\"\n" +
+				"\t        ^~~~~~~\n",
+		},
+		{
+			src:   "package a\nvar y = zzz",
+			color: true,
+			want: "\x1b[1ma.go:2:8: undeclared identifier zzz\x1b[0m\n" +
+				"\tvar y = zzz\n" +
+				"\t        \x1b[31m^~~\x1b[0m\n",
+		},
+	}
+	for _, test := range tests {
+		fset := token.NewFileSet()
+		p := NewParser(token.NewLexer(fset, "a.go", test.src))
+		f, err := Parse(p)
+		if err == nil {
+			err = Check(fset, []*File{f}, nil, nil)
+		}
+		if err == nil {
+			t.Errorf("%q: expected an error", test.src)
+			continue
+		}
+		r := Renderer{Sources: map[string]string{"a.go": test.src}, Color: test.color}
+		var b bytes.Buffer
+		if err := r.Render(&b, err); err != nil {
+			t.Errorf("%q: Render failed: %s", test.src, err)
+		}
+		if got := b.String(); got != test.want {
+			t.Errorf("%q: got\n%s\nwanted\n%s", test.src, got, test.want)
+		}
+	}
+}
+
+func TestRendererWithoutSource(t *testing.T) {
+	src := "package a\nvar y = zzz"
+	fset := token.NewFileSet()
+	f := parseFile(NewParser(token.NewLexer(fset, "a.go", src)))
+	err := errs(Check(fset, []*File{f}, nil, nil), fmt.Errorf("other"))
+	var b bytes.Buffer
+	if err := new(Renderer).Render(&b, err); err != nil {
+		t.Fatalf("Render failed: %s", err)
+	}
+	want := "a.go:2:8: undeclared identifier zzz\nother\n"
+	if got := b.String(); got != want {
+		t.Errorf("got\n%s\nwanted\n%s", got, want)
+	}
+}
+
+func TestRendererNil(t *testing.T) {
+	var b bytes.Buffer
+	if err := new(Renderer).Render(&b, nil); err != nil {
+		t.Fatalf("Render failed: %s", err)
+	}
+	if got := b.String(); got != "" {
+		t.Errorf("got %q, wanted nothing", got)
+	}
+}
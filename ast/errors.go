@@ -3,6 +3,7 @@ package ast
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/velour/stop/token"
 )
@@ -48,6 +49,55 @@ func (es errors) All() []error {
 	return all
 }
 
+// A LocatedError is an error returned by Parse or Check along with
+// the FileSet of the source files, in which the Pos values of the error
+// are located. The message of a LocatedError begins with the location
+// of the error, if any, followed by the message of Err.
+type LocatedError struct {
+	// Err is the error, such as a *SyntaxError or an Undeclared.
+	Err  error
+	Fset *token.FileSet
+}
+
+func (e *LocatedError) Error() string {
+	msg := e.Err.Error()
+	if l, ok := e.Err.(interface {
+		locatedError(*token.FileSet) string
+	}); ok {
+		msg = l.locatedError(e.Fset)
+	}
+	start, _, _ := errorSpans(e.Err)
+	if !start.IsValid() {
+		return msg
+	}
+	return fmt.Sprintf("%s: %s", e.Fset.Location(start), msg)
+}
+
+// Unwrap returns Err.
+func (e *LocatedError) Unwrap() error { return e.Err }
+
+// Locate returns the errors of err, gathered recursively by calling All
+// on any nested errors, each as a *LocatedError in the FileSet.
+// It returns nil if there are no errors, including if err only holds
+// errors that were already reported, like the syntax errors of Bad nodes.
+func locate(fset *token.FileSet, err error) error {
+	if err == nil {
+		return nil
+	}
+	es := []error{err}
+	if all, ok := err.(errors); ok {
+		es = all.All()
+	}
+	var located errors
+	for _, e := range es {
+		if _, ok := e.(*LocatedError); !ok {
+			e = &LocatedError{Err: e, Fset: fset}
+		}
+		located = append(located, e)
+	}
+	return located.ErrorOrNil()
+}
+
 // A SyntaxError is an error that describes a parse failure: something
 // unexpected in the syntax of the Go source code.
 type SyntaxError struct {
@@ -57,17 +107,26 @@ type SyntaxError struct {
 	Got fmt.Stringer
 	// Text is the text of the unexpected token.
 	Text string
+	// Reason describes why the token is malformed if Got is
+	// token.Error. It may be empty if the reason is unknown.
+	Reason string
 	// Start and End give the location of the error.
-	Start, End token.Location
-	// Stack is a human-readable trace of the stack showing the
-	// cause of the syntax error.
-	Stack string
+	Start, End token.Pos
+	// Productions is the chain of grammar productions that were
+	// being parsed when the error occurred, outermost first, such as
+	// parseFile, parseTopLevelDecl, parseFunctionOrMethodDecl, ....
+	// It is only recorded if the Parser's TraceErrors field is true.
+	Productions []string
 }
 
 func (e *SyntaxError) Error() string {
 	if e.Got == token.Error {
+		reason := e.Reason
+		if reason == "" {
+			reason = "unexpected rune in input"
+		}
 		text := strconv.QuoteToASCII(e.Text)
-		return fmt.Sprintf("%s: unexpected rune in input [%s]", e.Start, text[1:len(text)-1])
+		return fmt.Sprintf("%s [%s]", reason, text[1:len(text)-1])
 	}
 	switch e.Got {
 	case token.Semicolon:
@@ -75,27 +134,7 @@ func (e *SyntaxError) Error() string {
 	case token.EOF:
 		e.Text = "EOF"
 	}
-	return fmt.Sprintf("%s: expected %s, got %s", e.Start, e.Wanted, e.Text)
-}
-
-// A MalformedLiteral is an error that describes a literal for which the
-// value was malformed.  For example, an integer literal for which the
-// text is not parsable as an integer.
-//
-// BUG(eaburns): The parser should not have to deal with this class
-// of error.  The lexer should return an Error token if it scans a literal
-// that is lexicographically incorrect.
-type MalformedLiteral struct {
-	// The name for the literal type.
-	Type string
-	// The malformed text.
-	Text string
-	// Start and End give the location of the error.
-	Start, End token.Location
-}
-
-func (e *MalformedLiteral) Error() string {
-	return fmt.Sprintf("%s: malformed %s: [%s]", e.Start, e.Type, e.Text)
+	return fmt.Sprintf("expected %s, got %s", e.Wanted, e.Text)
 }
 
 // Redeclaration is an error that denotes multiple definitions of the same
@@ -106,28 +145,32 @@ type Redeclaration struct {
 }
 
 func (e *Redeclaration) Error() string {
-	return fmt.Sprintf("%s: %s redeclared, originally declared at %s", e.Second.Start(), e.Name, e.First.Start())
+	return fmt.Sprintf("%s redeclared", e.Name)
+}
+
+func (e *Redeclaration) locatedError(fset *token.FileSet) string {
+	return fmt.Sprintf("%s, originally declared at %s", e, fset.Location(e.First.Start()))
 }
 
 // An Undeclared is an error returned for undeclared identifiers.
 type Undeclared struct{ *Identifier }
 
 func (e Undeclared) Error() string {
-	return fmt.Sprintf("%s: undeclared identifier %s", e.Start(), e.Name)
+	return fmt.Sprintf("undeclared identifier %s", e.Name)
 }
 
 // A ConstantLoop is an error returned when there is a cycle in a constant definition.
 type ConstantLoop struct{ *constSpecView }
 
 func (e ConstantLoop) Error() string {
-	return fmt.Sprintf("%s: constant definition loop", e.Start())
+	return "constant definition loop"
 }
 
 // A NotConstant is an error returned when a constant initializer is not constant.
 type NotConstant struct{ Expression }
 
 func (e NotConstant) Error() string {
-	return fmt.Sprintf("%s: const initializer is not constant", e.Start())
+	return "const initializer is not constant"
 }
 
 // A Unrepresentable is an error returned when a constant operand has a value
@@ -138,7 +181,7 @@ type Unrepresentable struct {
 }
 
 func (e Unrepresentable) Error() string {
-	return fmt.Sprintf("%s: const is not representable by its type", e.Expression.Loc())
+	return "const is not representable by its type"
 }
 
 // A BadAssign is an error returned when an expression is not assignable
@@ -149,7 +192,33 @@ type BadAssign struct {
 }
 
 func (e BadAssign) Error() string {
-	return fmt.Sprintf("%s: bad assignment", e.Expression.Start())
+	return "bad assignment"
+}
+
+// A NotImplemented is an error returned when a value is assigned to an
+// interface type, but the method set of the value's type does not implement
+// the interface.
+type NotImplemented struct {
+	Expression
+	Interface Type
+	// Missing are the methods of the interface that are not in
+	// the method set of the value's type.
+	Missing []*Method
+	// Wrong are the methods of the interface for which the value's type
+	// has a method of the same name, but with a different signature.
+	Wrong []*Method
+}
+
+func (e NotImplemented) Error() string {
+	var ms []string
+	for _, m := range e.Missing {
+		ms = append(ms, "missing method "+m.Name)
+	}
+	for _, m := range e.Wrong {
+		ms = append(ms, "wrong signature for method "+m.Name)
+	}
+	return fmt.Sprintf("%s does not implement %s (%s)",
+		e.Expression.Source(), e.Interface.Source(), strings.Join(ms, ", "))
 }
 
 // A AssignCountMismatch is an error returned when a variable or contstant
@@ -160,7 +229,7 @@ type AssignCountMismatch struct {
 }
 
 func (e AssignCountMismatch) Error() string {
-	return fmt.Sprintf("%s: assignment count mismatch", e.Start())
+	return "assignment count mismatch"
 }
 
 // An InvalidOperation is an error returned when an operation is not
@@ -172,7 +241,13 @@ type InvalidOperation struct {
 }
 
 func (e InvalidOperation) Error() string {
-	return fmt.Sprintf("%s: invalid operation: %s %s", e.Start(), e.Op, e.Operand.Source())
+	switch n := e.Expression.(type) {
+	case *BinaryOp:
+		return fmt.Sprintf("invalid operation: %s %s %s", n.Left.Source(), e.Op, n.Right.Source())
+	case *UnaryOp:
+		return fmt.Sprintf("invalid operation: %s%s", e.Op, e.Operand.Source())
+	}
+	return fmt.Sprintf("invalid operation: %s %s", e.Op, e.Operand.Source())
 }
 
 // A BadRecursiveType is an error returned when a type is self-referential,
@@ -182,7 +257,7 @@ type BadRecursiveType struct {
 }
 
 func (e BadRecursiveType) Error() string {
-	return fmt.Sprintf("%s: bad recursive type", e.Loc())
+	return "bad recursive type"
 }
 
 // A BadArraySize is an error returned when an array size is either not
@@ -192,7 +267,7 @@ type BadArraySize struct {
 }
 
 func (e BadArraySize) Error() string {
-	return fmt.Sprintf("%s: bad array size", e.Loc())
+	return "bad array size"
 }
 
 // A BadMapKey is an error returned when a map type's key value is a map,
@@ -202,5 +277,339 @@ type BadMapKey struct {
 }
 
 func (e BadMapKey) Error() string {
-	return fmt.Sprintf("%s: map key type does not support ==", e.Type.Loc())
+	return "map key type does not support =="
+}
+
+// An InitializationLoop is an error returned when there is a cycle in the
+// initialization of a variable.
+type InitializationLoop struct{ *VarSpec }
+
+func (e InitializationLoop) Error() string {
+	return "initialization loop"
+}
+
+// A NotType is an error returned when an expression that is not a type
+// is used where a type is required.
+type NotType struct{ Expression }
+
+func (e NotType) Error() string {
+	return fmt.Sprintf("%s is not a type", e.Source())
+}
+
+// A NotExpression is an error returned when a type, a package name, or a
+// built-in function is used where a value is required.
+type NotExpression struct{ Expression }
+
+func (e NotExpression) Error() string {
+	return fmt.Sprintf("%s is not an expression", e.Source())
+}
+
+// A NotSingleValue is an error returned when a call to a function with
+// either no results or multiple results is used where a single value is
+// required.
+type NotSingleValue struct{ Expression }
+
+func (e NotSingleValue) Error() string {
+	return fmt.Sprintf("%s is not a single value", e.Source())
+}
+
+// An UntypedNil is an error returned when the predeclared identifier nil
+// is used in a context that requires a type, for example, when it is used
+// to initialize a variable declared without a type.
+type UntypedNil struct{ Expression }
+
+func (e UntypedNil) Error() string {
+	return "use of untyped nil"
+}
+
+// An Unassignable is an error returned when the left-hand side of an
+// assignment is neither addressable, a map index expression, nor the
+// blank identifier.
+type Unassignable struct{ Expression }
+
+func (e Unassignable) Error() string {
+	return fmt.Sprintf("cannot assign to %s", e.Source())
+}
+
+// A NoNewVariables is an error returned when a short variable declaration
+// does not declare any new, non-blank variables.
+type NoNewVariables struct{ *ShortVarDecl }
+
+func (e NoNewVariables) Error() string {
+	return "no new variables on left side of :="
+}
+
+// A NotFunction is an error returned when calling an expression that is not
+// a function.
+type NotFunction struct{ Expression }
+
+func (e NotFunction) Error() string {
+	return fmt.Sprintf("cannot call non-function %s", e.Source())
+}
+
+// An ArgCountMismatch is an error returned when a call has the wrong
+// number of arguments.
+type ArgCountMismatch struct{ *Call }
+
+func (e ArgCountMismatch) Error() string {
+	return fmt.Sprintf("wrong number of arguments in call to %s", e.Function.Source())
+}
+
+// A BadConversion is an error returned when an expression cannot be
+// converted to a type.
+type BadConversion struct {
+	Expression
+	Type
+}
+
+func (e BadConversion) Error() string {
+	return fmt.Sprintf("cannot convert %s to type %s", e.Expression.Source(), e.Type.Source())
+}
+
+// A BadCondition is an error returned when the condition of an if or for
+// statement, or the case of a switch statement with no expression, is not
+// a boolean.
+type BadCondition struct{ Expression }
+
+func (e BadCondition) Error() string {
+	return fmt.Sprintf("non-bool %s used as condition", e.Source())
+}
+
+// A NotInterface is an error returned when a type assertion or a type switch
+// is applied to an expression that is not of interface type.
+type NotInterface struct{ Expression }
+
+func (e NotInterface) Error() string {
+	return fmt.Sprintf("%s is not an interface", e.Source())
+}
+
+// A NotCall is an error returned when the expression of a go or defer
+// statement is not a function call.
+type NotCall struct{ Expression }
+
+func (e NotCall) Error() string {
+	return fmt.Sprintf("%s must be a function call", e.Source())
+}
+
+// An Unused is an error returned when the value of an expression statement
+// is not used. The only expressions that may be used as statements are
+// function calls and receive operations.
+type Unused struct{ Expression }
+
+func (e Unused) Error() string {
+	return fmt.Sprintf("%s evaluated but not used", e.Source())
+}
+
+// A BadReceiver is an error returned when the receiver base type of a
+// method is not a type declared in the current package.
+type BadReceiver struct{ *MethodDecl }
+
+func (e BadReceiver) Error() string {
+	return fmt.Sprintf("invalid receiver type %s", e.BaseTypeName.Name)
+}
+
+// An UnknownSelector is an error returned when a selector does not denote
+// a field or method of the type of its operand, either because there is no
+// field or method with its name, or because the name is ambiguous.
+type UnknownSelector struct {
+	*Selector
+	// Ambiguous is whether there is more than one field or method
+	// with the name at the shallowest depth of embedding.
+	Ambiguous bool
+}
+
+func (e UnknownSelector) Error() string {
+	if e.Ambiguous {
+		return fmt.Sprintf("ambiguous selector %s", e.Source())
+	}
+	return fmt.Sprintf("%s undefined (no field or method %s)", e.Source(), e.Name)
+}
+
+// A PointerMethod is an error returned when a method with a pointer receiver
+// is selected from a value that is neither a pointer nor addressable.
+type PointerMethod struct{ *Selector }
+
+func (e PointerMethod) Error() string {
+	return fmt.Sprintf("cannot call pointer method %s on %s", e.Name, e.Parent.Source())
+}
+
+// A BadCompositeLiteral is an error returned when the type of a composite
+// literal is not a struct, array, slice, or map type.
+type BadCompositeLiteral struct{ *CompositeLiteral }
+
+func (e BadCompositeLiteral) Error() string {
+	return "invalid type for composite literal"
+}
+
+// An UnknownField is an error returned when the key of an element of a
+// struct composite literal is not the name of a field of the struct.
+type UnknownField struct{ Expression }
+
+func (e UnknownField) Error() string {
+	return fmt.Sprintf("unknown field %s in struct literal", e.Source())
+}
+
+// A DuplicateFieldName is an error returned when a struct composite literal
+// has more than one element for the same field.
+type DuplicateFieldName struct{ *Identifier }
+
+func (e DuplicateFieldName) Error() string {
+	return fmt.Sprintf("duplicate field name %s in struct literal", e.Name)
+}
+
+// A BadIndex is an error returned when an index is not an integer, or is a
+// constant that is negative or out of range.
+type BadIndex struct{ Expression }
+
+func (e BadIndex) Error() string {
+	return fmt.Sprintf("invalid index %s", e.Source())
+}
+
+// A BadArgument is an error returned when an argument to a predeclared
+// function has an invalid type.
+type BadArgument struct{ Expression }
+
+func (e BadArgument) Error() string {
+	return fmt.Sprintf("invalid argument %s", e.Source())
+}
+
+// A DivisionByZero is an error returned when the divisor of a division or
+// remainder operation is the constant zero.
+type DivisionByZero struct{ *BinaryOp }
+
+func (e DivisionByZero) Error() string {
+	return "division by zero"
+}
+
+// A DuplicateField is an error returned when a struct type has multiple
+// fields with the same name.
+type DuplicateField struct {
+	First, Second *FieldDecl
+}
+
+func (e DuplicateField) Error() string {
+	return fmt.Sprintf("duplicate field %s", e.Second.name())
+}
+
+func (e DuplicateField) locatedError(fset *token.FileSet) string {
+	return fmt.Sprintf("%s, originally declared at %s", e, fset.Location(e.First.Start()))
+}
+
+// A BadEmbeddedField is an error returned when the type of an embedded field
+// is a pointer type, or a pointer to a pointer or interface type.
+type BadEmbeddedField struct{ *FieldDecl }
+
+func (e BadEmbeddedField) Error() string {
+	return "bad embedded field type"
+}
+
+// A DuplicateMethod is an error returned when an interface type has multiple
+// methods with the same name, either declared explicitly or with
+// different signatures from embedded interfaces.
+type DuplicateMethod struct {
+	First, Second *Method
+}
+
+func (e DuplicateMethod) Error() string {
+	return fmt.Sprintf("duplicate method %s", e.Second.Name)
+}
+
+func (e DuplicateMethod) locatedError(fset *token.FileSet) string {
+	return fmt.Sprintf("%s, originally declared at %s", e, fset.Location(e.First.Start()))
+}
+
+// A BadImport is an error returned when an imported package cannot be read.
+type BadImport struct {
+	*ImportSpec
+	Err error
+}
+
+func (e BadImport) Error() string {
+	return fmt.Sprintf("cannot import %s: %s", e.Path.Value, e.Err)
+}
+
+// An ImportCycle is an error returned when a package imports itself,
+// either directly or indirectly.
+type ImportCycle struct {
+	*ImportSpec
+	// Cycle is the import paths along the cycle. The first and last
+	// paths are the same.
+	Cycle []string
+}
+
+func (e ImportCycle) Error() string {
+	return fmt.Sprintf("import cycle: %s", strings.Join(e.Cycle, " -> "))
+}
+
+// A NotPackage is an error returned when the qualifier of a qualified
+// identifier does not name an imported package.
+type NotPackage struct{ *Identifier }
+
+func (e NotPackage) Error() string {
+	return fmt.Sprintf("%s is not a package", e.Name)
+}
+
+// A NotGeneric is an error returned when type arguments are given for
+// a type or function that is not generic.
+type NotGeneric struct{ Expression }
+
+func (e NotGeneric) Error() string {
+	return fmt.Sprintf("%s is not a generic type or function", e.Source())
+}
+
+// A NotInstantiated is an error returned when a generic type or function
+// is used without instantiation.
+type NotInstantiated struct{ Expression }
+
+func (e NotInstantiated) Error() string {
+	return fmt.Sprintf("cannot use generic %s without instantiation", e.Source())
+}
+
+// A TypeArgCountMismatch is an error returned when an instantiation has
+// more type arguments than there are type parameters, or, for a generic
+// type, fewer.
+type TypeArgCountMismatch struct{ *Instance }
+
+func (e TypeArgCountMismatch) Error() string {
+	return fmt.Sprintf("wrong number of type arguments for %s", e.Expression.Source())
+}
+
+// An Unsatisfied is an error returned when a type argument does not satisfy
+// the constraint of its type parameter.
+type Unsatisfied struct {
+	// Node is the instantiation, either an Instance or a Call.
+	Node
+	Argument  Type
+	Parameter *TypeParameter
+}
+
+func (e Unsatisfied) Error() string {
+	return fmt.Sprintf("%s does not satisfy %s", e.Argument.Source(), e.Parameter.Constraint.Source())
+}
+
+// A CannotInfer is an error returned when the type argument of a type
+// parameter of a generic function cannot be inferred from a call.
+type CannotInfer struct {
+	*Call
+	Parameter *TypeParameter
+}
+
+func (e CannotInfer) Error() string {
+	return fmt.Sprintf("cannot infer %s", e.Parameter.Name)
+}
+
+// A BadTerm is an error returned when the type of a term ~T in a type
+// constraint is not its own underlying type.
+type BadTerm struct{ *Term }
+
+func (e BadTerm) Error() string {
+	return fmt.Sprintf("invalid use of ~ with %s", e.Type.Source())
+}
+
+// A ConstraintInterface is an error returned when an interface that may only
+// be used as a type constraint is used as the type of a value.
+type ConstraintInterface struct{ Type }
+
+func (e ConstraintInterface) Error() string {
+	return fmt.Sprintf("cannot use %s outside of a type constraint", e.Source())
 }
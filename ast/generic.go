@@ -0,0 +1,580 @@
+package ast
+
+// TypeParam returns the declaration of the type parameter named by t,
+// or nil if t does not name a type parameter.
+func typeParam(t Type) *TypeParameter {
+	if n, ok := t.(*TypeName); ok {
+		if tp, ok := n.decl.(*TypeParameter); ok {
+			return tp
+		}
+	}
+	return nil
+}
+
+// BindTypeParameters binds type parameters in syms and checks their
+// constraints. All of the type parameters are bound before any constraint
+// is checked, because a constraint may refer to the type parameters.
+func bindTypeParameters(syms *Scope, tps []TypeParameter) error {
+	var errs errors
+	for i := range tps {
+		tps[i].iface = &InterfaceType{}
+		if err := syms.declare(&tps[i].Identifier, &tps[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for i := range tps {
+		if err := tps[i].check(syms); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// Check checks the constraint of the type parameter, setting its interface.
+func (n *TypeParameter) check(syms *Scope) error {
+	t, err := checkType(syms, n.Constraint)
+	if err != nil {
+		return err
+	}
+	n.Constraint = t
+	if iface, ok := t.Underlying().(*InterfaceType); ok {
+		n.iface = iface
+		return nil
+	}
+	n.iface = &InterfaceType{Methods: []Node{t}}
+	n.iface.restrict(typeTerms(t))
+	return nil
+}
+
+// TypeTerms returns the terms of a checked type element of an interface:
+// the terms of a Union, or a single term for any other type.
+func typeTerms(t Type) []Term {
+	if u, ok := t.(*Union); ok {
+		return u.Terms
+	}
+	return []Term{{Type: t}}
+}
+
+// Restrict intersects the type set of the interface with the set of types
+// denoted by the terms.
+func (n *InterfaceType) restrict(terms []Term) {
+	if !n.restricted {
+		n.restricted = true
+		n.terms = append([]Term{}, terms...)
+		return
+	}
+	var is []Term
+	for _, a := range n.terms {
+		for _, b := range terms {
+			switch {
+			case termSubset(a, b):
+				is = append(is, a)
+			case termSubset(b, a):
+				is = append(is, b)
+			}
+		}
+	}
+	n.terms = is
+}
+
+// TermSubset returns whether the set of types denoted by the term a
+// is a subset of the set of types denoted by the term b.
+func termSubset(a, b Term) bool {
+	if b.Tilde {
+		return a.Type.Underlying().Identical(b.Type.Underlying())
+	}
+	return !a.Tilde && a.Type.Identical(b.Type)
+}
+
+// InTypeSet returns whether the type t is in the type set of the interface.
+// A type parameter is in the type set if all types in its own type set are.
+func inTypeSet(t Type, iface *InterfaceType) bool {
+	if !iface.restricted {
+		return true
+	}
+	if tp := typeParam(t); tp != nil {
+		if !tp.iface.restricted {
+			return false
+		}
+		for _, a := range tp.iface.terms {
+			if !inTerms(a, iface.terms) {
+				return false
+			}
+		}
+		return true
+	}
+	return inTerms(Term{Type: t}, iface.terms)
+}
+
+func inTerms(a Term, terms []Term) bool {
+	for _, b := range terms {
+		if termSubset(a, b) {
+			return true
+		}
+	}
+	return false
+}
+
+// TypeSet returns the types of the type set of t. The type set of a type
+// parameter is the types of the terms of its constraint; it is nil if the
+// constraint does not restrict the type set to specific types. The type set
+// of any other type contains only the type itself.
+func typeSet(t Type) []Type {
+	tp := typeParam(t)
+	if tp == nil {
+		return []Type{t}
+	}
+	if !tp.iface.restricted {
+		return nil
+	}
+	ts := make([]Type, len(tp.iface.terms))
+	for i, term := range tp.iface.terms {
+		ts[i] = term.Type
+	}
+	return ts
+}
+
+// AllTypes returns whether f returns true for every type in the type set
+// of t. It returns false if the type set is empty or unrestricted, so an
+// operation is permitted on the value of a type parameter only if it is
+// permitted on the values of every type in the type set.
+func allTypes(t Type, f func(Type) bool) bool {
+	ts := typeSet(t)
+	if len(ts) == 0 {
+		return false
+	}
+	for _, t := range ts {
+		if !f(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// CoreType returns the core type of t. The core type of a type parameter
+// is the underlying type shared by all of the types in its type set, and
+// that of any other type is its underlying type. CoreType returns nil if
+// the types of the type set do not share an underlying type.
+func coreType(t Type) Type {
+	if typeParam(t) == nil {
+		return t.Underlying()
+	}
+	var core Type
+	for _, t := range typeSet(t) {
+		switch u := t.Underlying(); {
+		case core == nil:
+			core = u
+		case !core.Identical(u):
+			return nil
+		}
+	}
+	return core
+}
+
+// Satisfies returns whether the type t satisfies the constraint interface:
+// whether t is in its type set and implements its methods.
+func satisfies(t Type, iface *InterfaceType) bool {
+	if iface.comparable && !comparable(t) {
+		return false
+	}
+	return inTypeSet(t, iface) && Implements(t, iface)
+}
+
+// Satisfy returns an error for each type argument of the instantiation n
+// that does not satisfy the constraint of its type parameter. Constraints may
+// refer to the type parameters, so the type arguments are substituted into
+// them first.
+func satisfy(n Node, tps []TypeParameter, args []Type) error {
+	m := bindings(tps, args)
+	var errs errors
+	for i := range tps {
+		iface := subst(tps[i].iface, m).(*InterfaceType)
+		if !satisfies(args[i], iface) {
+			errs = append(errs, Unsatisfied{Node: n, Argument: args[i], Parameter: &tps[i]})
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// Bindings returns a map from each type parameter to its type argument.
+func bindings(tps []TypeParameter, args []Type) map[*TypeParameter]Type {
+	m := make(map[*TypeParameter]Type, len(tps))
+	for i := range tps {
+		m[&tps[i]] = args[i]
+	}
+	return m
+}
+
+// Bindings returns the map from the type parameters of the generic type
+// of the instance to its type arguments.
+func (t *Instance) bindings() map[*TypeParameter]Type {
+	return bindings(t.decl.TypeParameters, t.TypeArguments)
+}
+
+// Subst returns the type t with each type parameter in m replaced by its
+// type argument. Types are copied, not modified in place.
+func subst(t Type, m map[*TypeParameter]Type) Type {
+	switch t := t.(type) {
+	case *TypeName:
+		if tp, ok := t.decl.(*TypeParameter); ok && m[tp] != nil {
+			return m[tp]
+		}
+		return t
+	case *Star:
+		c := *t
+		c.Target = subst(t.Target.(Type), m)
+		return &c
+	case *SliceType:
+		c := *t
+		c.Element = subst(t.Element, m)
+		return &c
+	case *ArrayType:
+		c := *t
+		c.Element = subst(t.Element, m)
+		return &c
+	case *MapType:
+		c := *t
+		c.Key = subst(t.Key, m)
+		c.Value = subst(t.Value, m)
+		return &c
+	case *ChannelType:
+		c := *t
+		c.Element = subst(t.Element, m)
+		return &c
+	case *FunctionType:
+		c := *t
+		c.Signature = substSignature(&t.Signature, m)
+		return &c
+	case *StructType:
+		c := *t
+		c.Fields = make([]FieldDecl, len(t.Fields))
+		for i, f := range t.Fields {
+			f.Type = subst(f.Type, m)
+			c.Fields[i] = f
+		}
+		return &c
+	case *InterfaceType:
+		c := *t
+		c.Methods = nil
+		c.methodSet = nil
+		for _, m0 := range t.methodSet {
+			m1 := *m0
+			m1.Signature = substSignature(&m0.Signature, m)
+			c.Methods = append(c.Methods, &m1)
+			c.methodSet = append(c.methodSet, &m1)
+		}
+		c.terms = substTerms(t.terms, m)
+		return &c
+	case *Union:
+		c := *t
+		c.Terms = substTerms(t.Terms, m)
+		return &c
+	case *Instance:
+		c := *t
+		c.underlying = nil
+		c.TypeArguments = make([]Type, len(t.TypeArguments))
+		for i, a := range t.TypeArguments {
+			c.TypeArguments[i] = subst(a, m)
+		}
+		return &c
+	}
+	return t
+}
+
+func substTerms(terms []Term, m map[*TypeParameter]Type) []Term {
+	if terms == nil {
+		return nil
+	}
+	ts := make([]Term, len(terms))
+	for i, t := range terms {
+		t.Type = subst(t.Type, m)
+		ts[i] = t
+	}
+	return ts
+}
+
+// SubstSignature returns a copy of the signature with each type parameter
+// in m replaced by its type argument.
+func substSignature(s *Signature, m map[*TypeParameter]Type) Signature {
+	c := *s
+	c.Parameters = substParameters(s.Parameters, m)
+	c.Results = substParameters(s.Results, m)
+	return c
+}
+
+func substParameters(ps []ParameterDecl, m map[*TypeParameter]Type) []ParameterDecl {
+	if ps == nil {
+		return nil
+	}
+	qs := make([]ParameterDecl, len(ps))
+	for i, p := range ps {
+		p.Type = subst(p.Type, m)
+		qs[i] = p
+	}
+	return qs
+}
+
+// GenericDecl returns the declaration of the generic type or function named
+// by an unchecked expression, or nil if it does not name a generic type or
+// function.
+func genericDecl(syms *Scope, x Expression) Declaration {
+	var d Declaration
+	switch x := x.(type) {
+	case *Identifier:
+		d = syms.Find(x.Name)
+	case *TypeName:
+		if x.Package == nil {
+			d = syms.Find(x.Name)
+		} else {
+			d = pkgLookup(syms, x.Package, x.Name)
+		}
+	case *Selector:
+		if id, ok := x.Parent.(*Identifier); ok {
+			d = pkgLookup(syms, id, x.Name)
+		}
+	}
+	switch d := d.(type) {
+	case *TypeSpec:
+		if len(d.TypeParameters) > 0 {
+			return d
+		}
+	case *FunctionDecl:
+		if len(d.TypeParameters) > 0 {
+			return d
+		}
+	}
+	return nil
+}
+
+// PkgLookup returns the declaration of an exported identifier of the
+// package named by pkg, or nil if pkg does not name an imported package
+// or the package has no such identifier.
+func pkgLookup(syms *Scope, pkg *Identifier, name string) Declaration {
+	if p, ok := syms.Find(pkg.Name).(*packageDecl); ok && p.Package != nil {
+		return p.Lookup(name)
+	}
+	return nil
+}
+
+// GenericFunc returns the declaration of the generic function of a checked
+// expression that refers to a generic function without instantiating all
+// of its type parameters, or nil if the expression does not.
+func genericFunc(x Expression) *FunctionDecl {
+	switch x := x.(type) {
+	case *Identifier:
+		if d, ok := x.decl.(*FunctionDecl); ok && len(d.TypeParameters) > 0 {
+			return d
+		}
+	case *Instance:
+		if x.typ == nil && x.decl == nil {
+			return genericFunc(x.Expression)
+		}
+	}
+	return nil
+}
+
+// Infer returns the instance of the generic function d that is called by n.
+// The function expression f either names d or is a partial instantiation
+// of it. The type arguments that are not given explicitly are inferred from
+// the types of the arguments of the call, which are checked.
+//
+// Typed arguments are unified with their parameter types first. Then, any
+// type parameter that remains unbound and is the type of a parameter with
+// an untyped constant argument is bound to the constant's default type.
+// After each step, type arguments are also inferred from the constraints
+// of type parameters that have a single term in their type set.
+func (n *Call) infer(syms *Scope, d *FunctionDecl, f Expression) (*Instance, error) {
+	inst, ok := f.(*Instance)
+	if !ok {
+		inst = &Instance{Expression: f}
+	}
+	tps := d.TypeParameters
+	m := make(map[*TypeParameter]Type, len(tps))
+	for i := range tps {
+		m[&tps[i]] = nil
+		if i < len(inst.TypeArguments) {
+			m[&tps[i]] = inst.TypeArguments[i]
+		}
+	}
+
+	ts, err := n.checkArgs(syms)
+	if err != nil {
+		return nil, err
+	}
+	params := d.Parameters
+	variadic := len(params) > 0 && params[len(params)-1].DotDotDot
+	for _, untyped := range []bool{false, true} {
+		for i, t := range ts {
+			if i >= len(params) && !variadic {
+				break
+			}
+			pt := paramType(params, i)
+			if n.DotDotDot && i == len(ts)-1 {
+				pt = &SliceType{Element: pt}
+			}
+			_, isUntyped := t.(Untyped)
+			switch {
+			case isUntyped != untyped:
+				continue
+			case !untyped:
+				unify(pt, t, m)
+			case t != Untyped(NilConst):
+				if tp := typeParam(pt); tp != nil {
+					if b, ok := m[tp]; ok && b == nil {
+						m[tp] = defaultType(t)
+					}
+				}
+			}
+		}
+		inferCore(tps, m)
+	}
+
+	var args []Type
+	for i := range tps {
+		t := m[&tps[i]]
+		if t == nil {
+			return nil, CannotInfer{Call: n, Parameter: &tps[i]}
+		}
+		args = append(args, t)
+	}
+	if err := satisfy(n, tps, args); err != nil {
+		return nil, err
+	}
+	inst.TypeArguments = args
+	inst.typ = subst(&FunctionType{Signature: d.Signature}, m)
+	return inst, nil
+}
+
+// CheckArgs checks the arguments of a call, returning their types.
+// A single argument that is a call with other than one result is expanded
+// to the types of its results.
+func (n *Call) checkArgs(syms *Scope) ([]Type, error) {
+	if len(n.Arguments) == 1 && !n.DotDotDot {
+		x, err := checkExpr(syms, n.Arguments[0], -1)
+		if err != nil {
+			return nil, err
+		}
+		n.Arguments[0] = x
+		if c, ok := x.(*Call); ok && len(c.results) != 1 {
+			return c.results, nil
+		}
+		if x, err = singleValue(x); err != nil {
+			return nil, err
+		}
+		return []Type{x.Type()}, nil
+	}
+	var ts []Type
+	var errs errors
+	for i := range n.Arguments {
+		x, err := checkValue(syms, n.Arguments[i])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		n.Arguments[i] = x
+		ts = append(ts, x.Type())
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return ts, nil
+}
+
+// Unify unifies a parameter type p with an argument type a, binding the
+// unbound type parameters in m that appear in p. Type parameters that are
+// not keys of m are not inferred. It returns false if the types do not
+// match. A type name a is unified with a type literal p by its underlying
+// type.
+func unify(p, a Type, m map[*TypeParameter]Type) bool {
+	if tp := typeParam(p); tp != nil {
+		if b, ok := m[tp]; ok {
+			if b == nil {
+				m[tp] = a
+				return true
+			}
+			return b.Identical(a)
+		}
+	}
+	switch p.(type) {
+	case *TypeName, *Instance:
+		break
+	default:
+		if _, ok := a.(*TypeName); ok && typeParam(a) == nil {
+			a = a.Underlying()
+		} else if _, ok := a.(*Instance); ok {
+			a = a.Underlying()
+		}
+	}
+	switch p := p.(type) {
+	case *Star:
+		q, ok := a.(*Star)
+		return ok && unify(p.Target.(Type), q.Target.(Type), m)
+	case *SliceType:
+		q, ok := a.(*SliceType)
+		return ok && unify(p.Element, q.Element, m)
+	case *ArrayType:
+		q, ok := a.(*ArrayType)
+		return ok && unify(p.Element, q.Element, m)
+	case *MapType:
+		q, ok := a.(*MapType)
+		return ok && unify(p.Key, q.Key, m) && unify(p.Value, q.Value, m)
+	case *ChannelType:
+		q, ok := a.(*ChannelType)
+		return ok && unify(p.Element, q.Element, m)
+	case *FunctionType:
+		q, ok := a.(*FunctionType)
+		return ok && unifyParameters(p.Parameters, q.Parameters, m) &&
+			unifyParameters(p.Results, q.Results, m)
+	case *StructType:
+		q, ok := a.(*StructType)
+		if !ok || len(p.Fields) != len(q.Fields) {
+			return false
+		}
+		for i := range p.Fields {
+			if !unify(p.Fields[i].Type, q.Fields[i].Type, m) {
+				return false
+			}
+		}
+	case *Instance:
+		q, ok := a.(*Instance)
+		if !ok || p.decl != q.decl || len(p.TypeArguments) != len(q.TypeArguments) {
+			return false
+		}
+		for i := range p.TypeArguments {
+			if !unify(p.TypeArguments[i], q.TypeArguments[i], m) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func unifyParameters(ps, qs []ParameterDecl, m map[*TypeParameter]Type) bool {
+	if len(ps) != len(qs) {
+		return false
+	}
+	for i := range ps {
+		if !unify(ps[i].Type, qs[i].Type, m) {
+			return false
+		}
+	}
+	return true
+}
+
+// InferCore infers type arguments from the constraints of the bound type
+// parameters that have a single term in their type set, by unifying the
+// term with the type argument.
+func inferCore(tps []TypeParameter, m map[*TypeParameter]Type) {
+	for i := range tps {
+		tp := &tps[i]
+		t := m[tp]
+		if t == nil || !tp.iface.restricted || len(tp.iface.terms) != 1 {
+			continue
+		}
+		term := tp.iface.terms[0]
+		if term.Tilde {
+			t = t.Underlying()
+		}
+		unify(term.Type, t, m)
+	}
+}
@@ -9,56 +9,87 @@ import (
 )
 
 // Parse returns the root of an abstract syntax tree for the Go language
-// or an error if one is encountered.
+// and any syntax errors that are encountered. The parser recovers from
+// syntax errors at statement and declaration boundaries, so up to the
+// parser's MaxErrors errors are returned, in the order that they were
+// encountered. The root is always non-nil. Source that could not be
+// parsed is represented in the tree by BadDecl, BadStmt, and BadExpr
+// nodes.
 func Parse(p *Parser) (root *File, err error) {
+	root = parseFile(p)
+	return root, locate(p.lex.FileSet(), p.errs.ErrorOrNil())
+}
+
+// ParseFile always returns a *File. If the limit on the number of syntax
+// errors is reached, the remainder of the file is represented by a BadDecl.
+func parseFile(p *Parser) (s *File) {
+	if p.tracing() {
+		defer p.enter("parseFile").leave()
+	}
+	s = &File{comments: p.comments(), startLoc: p.start()}
+	start := p.start()
 	defer func() {
 		r := recover()
 		if r == nil {
 			return
 		}
-		switch e := r.(type) {
-		case *SyntaxError:
-			err = e
-		case *MalformedLiteral:
-			err = e
-		default:
+		if _, ok := r.(bailout); !ok {
 			panic(r)
 		}
-
+		for p.tok != token.EOF {
+			p.next()
+		}
+		s.Declarations = append(s.Declarations, &BadDecl{startLoc: start, endLoc: p.prevEnd})
+		s.endLoc = p.start()
 	}()
-	return parseFile(p), nil
-}
 
-func parseFile(p *Parser) *File {
-	p.expect(token.Package)
-	s := &File{comments: p.comments(), startLoc: p.start()}
-	p.next()
-	s.PackageName = *parseIdentifier(p)
-	p.expect(token.Semicolon)
-	p.next()
+	if !p.try(func() {
+		p.expect(token.Package)
+		p.next()
+		s.PackageName = *parseIdentifier(p)
+		p.expect(token.Semicolon)
+		p.next()
+	}, syncPackage) {
+		s.Declarations = append(s.Declarations, &BadDecl{startLoc: start, endLoc: p.prevEnd})
+	}
 
 	for p.tok == token.Import {
-		s.Imports = append(s.Imports, *parseImportDecl(p))
-		if p.tok == token.EOF {
-			break
+		start = p.start()
+		var d *ImportDecl
+		if !p.try(func() {
+			d = parseImportDecl(p)
+			if p.tok != token.EOF {
+				p.expect(token.Semicolon)
+				p.next()
+			}
+		}, syncDecl) {
+			s.Declarations = append(s.Declarations, &BadDecl{startLoc: start, endLoc: p.prevEnd})
+			continue
 		}
-		p.expect(token.Semicolon)
-		p.next()
+		s.Imports = append(s.Imports, *d)
 	}
 	for p.tok != token.EOF {
-		decls := parseTopLevelDecl(p)
-		s.Declarations = append(s.Declarations, decls...)
-		if p.tok == token.EOF {
-			break
+		start = p.start()
+		var decls Declarations
+		if !p.try(func() {
+			decls = parseTopLevelDecl(p)
+			if p.tok != token.EOF {
+				p.expect(token.Semicolon)
+				p.next()
+			}
+		}, syncDecl) {
+			decls = Declarations{&BadDecl{comments: p.comments(), startLoc: start, endLoc: p.prevEnd}}
 		}
-		p.expect(token.Semicolon)
-		p.next()
+		s.Declarations = append(s.Declarations, decls...)
 	}
 	s.endLoc = p.start()
 	return s
 }
 
 func parseStatement(p *Parser) Statement {
+	if p.tracing() {
+		defer p.enter("parseStatement").leave()
+	}
 	switch p.tok {
 	case token.Type, token.Const, token.Var:
 		return &DeclarationStmt{
@@ -112,6 +143,9 @@ func parseStatement(p *Parser) Statement {
 }
 
 func parseSelect(p *Parser) Statement {
+	if p.tracing() {
+		defer p.enter("parseSelect").leave()
+	}
 	p.expect(token.Select)
 	s := &Select{
 		comments: p.comments(),
@@ -130,7 +164,10 @@ func parseSelect(p *Parser) Statement {
 }
 
 func parseCommCase(p *Parser) CommCase {
-	var c CommCase
+	if p.tracing() {
+		defer p.enter("parseCommCase").leave()
+	}
+	c := CommCase{startLoc: p.start()}
 	if p.tok == token.Default {
 		p.next()
 	} else {
@@ -149,10 +186,14 @@ func parseCommCase(p *Parser) CommCase {
 	p.expect(token.Colon)
 	p.next()
 	c.Statements = parseCaseStatements(p)
+	c.endLoc = p.start()
 	return c
 }
 
 func parseSendOrRecvStmt(p *Parser) Statement {
+	if p.tracing() {
+		defer p.enter("parseSendOrRecvStmt").leave()
+	}
 	cmnts := p.comments()
 	expr := parseExpr(p)
 	switch p.tok {
@@ -176,6 +217,9 @@ func parseSendOrRecvStmt(p *Parser) Statement {
 }
 
 func parseRecvStmtTail(p *Parser, cmnts comments, left []Expression) Statement {
+	if p.tracing() {
+		defer p.enter("parseRecvStmtTail").leave()
+	}
 	ids := true
 	for _, e := range left {
 		if _, ok := e.(*Identifier); !ok {
@@ -200,6 +244,9 @@ func parseRecvStmtTail(p *Parser, cmnts comments, left []Expression) Statement {
 }
 
 func parseSwitch(p *Parser) Statement {
+	if p.tracing() {
+		defer p.enter("parseSwitch").leave()
+	}
 	p.expect(token.Switch)
 	loc := p.start()
 	cmnts := p.comments()
@@ -269,8 +316,11 @@ func guardStatement(stmt Statement) (Expression, *Identifier) {
 	return nil, nil
 }
 
-func parseExprSwitchBlock(p *Parser, loc token.Location, cmnts comments,
+func parseExprSwitchBlock(p *Parser, loc token.Pos, cmnts comments,
 	init Statement, expr Expression) *ExprSwitch {
+	if p.tracing() {
+		defer p.enter("parseExprSwitchBlock").leave()
+	}
 	p.expect(token.OpenBrace)
 	p.next()
 
@@ -290,9 +340,13 @@ func parseExprSwitchBlock(p *Parser, loc token.Location, cmnts comments,
 }
 
 func parseExprCase(p *Parser) (c ExprCase) {
+	if p.tracing() {
+		defer p.enter("parseExprCase").leave()
+	}
 	if p.tok != token.Default && p.tok != token.Case {
 		panic(p.err(token.Default, token.Case))
 	}
+	c.startLoc = p.start()
 	def := p.tok == token.Default
 	p.next()
 	if !def {
@@ -301,11 +355,15 @@ func parseExprCase(p *Parser) (c ExprCase) {
 	p.expect(token.Colon)
 	p.next()
 	c.Statements = parseCaseStatements(p)
+	c.endLoc = p.start()
 	return c
 }
 
-func parseTypeSwitchBlock(p *Parser, loc token.Location, cmnts comments,
+func parseTypeSwitchBlock(p *Parser, loc token.Pos, cmnts comments,
 	init Statement, expr Expression, id *Identifier) *TypeSwitch {
+	if p.tracing() {
+		defer p.enter("parseTypeSwitchBlock").leave()
+	}
 	p.expect(token.OpenBrace)
 	p.next()
 
@@ -326,9 +384,13 @@ func parseTypeSwitchBlock(p *Parser, loc token.Location, cmnts comments,
 }
 
 func parseTypeCase(p *Parser) (c TypeCase) {
+	if p.tracing() {
+		defer p.enter("parseTypeCase").leave()
+	}
 	if p.tok != token.Default && p.tok != token.Case {
 		panic(p.err(token.Default, token.Case))
 	}
+	c.startLoc = p.start()
 	def := p.tok == token.Default
 	p.next()
 	if !def {
@@ -337,10 +399,14 @@ func parseTypeCase(p *Parser) (c TypeCase) {
 	p.expect(token.Colon)
 	p.next()
 	c.Statements = parseCaseStatements(p)
+	c.endLoc = p.start()
 	return c
 }
 
 func parseTypeList(p *Parser) []Type {
+	if p.tracing() {
+		defer p.enter("parseTypeList").leave()
+	}
 	types := []Type{parseType(p)}
 	for p.tok == token.Comma {
 		p.next()
@@ -350,18 +416,38 @@ func parseTypeList(p *Parser) []Type {
 }
 
 func parseCaseStatements(p *Parser) []Statement {
+	if p.tracing() {
+		defer p.enter("parseCaseStatements").leave()
+	}
 	var stmts []Statement
-	for p.tok != token.Case && p.tok != token.Default && p.tok != token.CloseBrace {
-		stmts = append(stmts, parseStatement(p))
-		if p.tok == token.Case || p.tok == token.Default || p.tok == token.CloseBrace {
-			break
-		}
-		p.expect(token.Semicolon)
-		p.next()
+	for !endsStmtList(p.tok) {
+		stmts = append(stmts, parseStatementOrBad(p, endsStmtList))
 	}
 	return stmts
 }
 
+// ParseStatementOrBad returns the next statement and consumes its
+// terminating semicolon, unless the statement is followed by a token
+// for which end returns true. If there is a syntax error, the parser
+// is resynchronized, and a BadStmt is returned.
+func parseStatementOrBad(p *Parser, end func(token.Token) bool) Statement {
+	if p.tracing() {
+		defer p.enter("parseStatementOrBad").leave()
+	}
+	var stmt Statement
+	c, start := p.comments(), p.start()
+	if !p.try(func() {
+		stmt = parseStatement(p)
+		if !end(p.tok) {
+			p.expect(token.Semicolon)
+			p.next()
+		}
+	}, syncStmt) {
+		return &BadStmt{comments: c, startLoc: start, endLoc: p.prevEnd}
+	}
+	return stmt
+}
+
 // A rangeClause is either an Assignment or a ShortVarDecl statement
 // repressenting a range clause in a range-style for loop.
 type rangeClause struct {
@@ -369,6 +455,9 @@ type rangeClause struct {
 }
 
 func parseFor(p *Parser) Statement {
+	if p.tracing() {
+		defer p.enter("parseFor").leave()
+	}
 	f := &ForStmt{comments: p.comments(), startLoc: p.start()}
 	p.expect(token.For)
 	p.next()
@@ -419,6 +508,9 @@ func parseFor(p *Parser) Statement {
 }
 
 func parseIf(p *Parser) Statement {
+	if p.tracing() {
+		defer p.enter("parseIf").leave()
+	}
 	ifst := &IfStmt{comments: p.comments(), startLoc: p.start()}
 	p.expect(token.If)
 	p.next()
@@ -453,20 +545,17 @@ func parseIf(p *Parser) Statement {
 }
 
 func parseBlock(p *Parser) *BlockStmt {
+	if p.tracing() {
+		defer p.enter("parseBlock").leave()
+	}
 	p.expect(token.OpenBrace)
 	c, s := p.comments(), p.start()
 	p.next()
 	var stmts []Statement
-	for p.tok != token.CloseBrace {
-		stmt := parseStatement(p)
-		if stmt != nil {
+	for p.tok != token.CloseBrace && p.tok != token.EOF {
+		if stmt := parseStatementOrBad(p, endsBlock); stmt != nil {
 			stmts = append(stmts, stmt)
 		}
-		if p.tok == token.CloseBrace {
-			break
-		}
-		p.expect(token.Semicolon)
-		p.next()
 	}
 	p.expect(token.CloseBrace)
 	e := p.end()
@@ -480,6 +569,9 @@ func parseBlock(p *Parser) *BlockStmt {
 }
 
 func parseGo(p *Parser) Statement {
+	if p.tracing() {
+		defer p.enter("parseGo").leave()
+	}
 	p.expect(token.Go)
 	c, s := p.comments(), p.start()
 	p.next()
@@ -491,6 +583,9 @@ func parseGo(p *Parser) Statement {
 }
 
 func parseDefer(p *Parser) Statement {
+	if p.tracing() {
+		defer p.enter("parseDefer").leave()
+	}
 	p.expect(token.Defer)
 	c, s := p.comments(), p.start()
 	p.next()
@@ -502,6 +597,9 @@ func parseDefer(p *Parser) Statement {
 }
 
 func parseReturn(p *Parser) Statement {
+	if p.tracing() {
+		defer p.enter("parseReturn").leave()
+	}
 	p.expect(token.Return)
 	c, s, e := p.comments(), p.start(), p.end()
 	p.next()
@@ -519,6 +617,9 @@ func parseReturn(p *Parser) Statement {
 }
 
 func parseGoto(p *Parser) Statement {
+	if p.tracing() {
+		defer p.enter("parseGoto").leave()
+	}
 	p.expect(token.Goto)
 	c, s := p.comments(), p.start()
 	p.next()
@@ -530,6 +631,9 @@ func parseGoto(p *Parser) Statement {
 }
 
 func parseContinue(p *Parser) Statement {
+	if p.tracing() {
+		defer p.enter("parseContinue").leave()
+	}
 	p.expect(token.Continue)
 	c, s := p.comments(), p.start()
 	p.next()
@@ -545,6 +649,9 @@ func parseContinue(p *Parser) Statement {
 }
 
 func parseBreak(p *Parser) Statement {
+	if p.tracing() {
+		defer p.enter("parseBreak").leave()
+	}
 	p.expect(token.Break)
 	c, s := p.comments(), p.start()
 	p.next()
@@ -614,6 +721,9 @@ const (
 )
 
 func parseSimpleStmt(p *Parser, opts options) (st Statement) {
+	if p.tracing() {
+		defer p.enter("parseSimpleStmt").leave()
+	}
 	cmnts := p.comments()
 	if !expressionFirst[p.tok] {
 		// Empty statement
@@ -692,6 +802,9 @@ func parseSimpleStmt(p *Parser, opts options) (st Statement) {
 // allowRange is true, then a rangeClause is returned if the range
 // keyword appears after the := operator.
 func parseShortVarDeclTail(p *Parser, cmnts comments, ids []Identifier, opts options) Statement {
+	if p.tracing() {
+		defer p.enter("parseShortVarDeclTail").leave()
+	}
 	p.expect(token.ColonEqual)
 	p.next()
 	if opts == rangeOK && p.tok == token.Range {
@@ -719,6 +832,9 @@ func parseShortVarDeclTail(p *Parser, cmnts comments, ids []Identifier, opts opt
 // operator.  If allowRange is true, then a rangeClause is returned
 // if the range keyword appears after the assignment operator.
 func parseAssignmentTail(p *Parser, cmnts comments, exprs []Expression, rangeOK bool) Statement {
+	if p.tracing() {
+		defer p.enter("parseAssignmentTail").leave()
+	}
 	op := expectAssign(p)
 	p.next()
 	if rangeOK && op == token.Equal && p.tok == token.Range {
@@ -739,6 +855,9 @@ func parseAssignmentTail(p *Parser, cmnts comments, exprs []Expression, rangeOK
 }
 
 func parseImportDecl(p *Parser) *ImportDecl {
+	if p.tracing() {
+		defer p.enter("parseImportDecl").leave()
+	}
 	p.expect(token.Import)
 	d := &ImportDecl{comments: p.comments(), startLoc: p.start()}
 	p.next()
@@ -765,6 +884,9 @@ func parseImportDecl(p *Parser) *ImportDecl {
 }
 
 func parseImportSpec(p *Parser) ImportSpec {
+	if p.tracing() {
+		defer p.enter("parseImportSpec").leave()
+	}
 	var s ImportSpec
 	if p.tok == token.Dot {
 		p.next()
@@ -780,6 +902,9 @@ func parseImportSpec(p *Parser) ImportSpec {
 }
 
 func parseTopLevelDecl(p *Parser) Declarations {
+	if p.tracing() {
+		defer p.enter("parseTopLevelDecl").leave()
+	}
 	if p.tok == token.Func {
 		return Declarations{parseFunctionOrMethodDecl(p)}
 	}
@@ -787,6 +912,9 @@ func parseTopLevelDecl(p *Parser) Declarations {
 }
 
 func parseFunctionOrMethodDecl(p *Parser) Declaration {
+	if p.tracing() {
+		defer p.enter("parseFunctionOrMethodDecl").leave()
+	}
 	p.expect(token.Func)
 	cmnts := p.comments()
 	l := p.start()
@@ -800,6 +928,12 @@ func parseFunctionOrMethodDecl(p *Parser) Declaration {
 			m.Pointer = true
 		}
 		m.BaseTypeName = *parseIdentifier(p)
+		if p.tok == token.OpenBracket {
+			p.next()
+			m.ReceiverTypeParameters = parseIdentifierList(p)
+			p.expect(token.CloseBracket)
+			p.next()
+		}
 		p.expect(token.CloseParen)
 		p.next()
 		m.Identifier = *parseIdentifier(p)
@@ -807,16 +941,24 @@ func parseFunctionOrMethodDecl(p *Parser) Declaration {
 		m.Body = *parseBlock(p)
 		return m
 	}
-	return &FunctionDecl{
+	f := &FunctionDecl{
 		comments:   cmnts,
 		startLoc:   l,
 		Identifier: *parseIdentifier(p),
-		Signature:  parseSignature(p),
-		Body:       *parseBlock(p),
 	}
+	if p.tok == token.OpenBracket {
+		p.next()
+		f.TypeParameters = parseTypeParameters(p, parseIdentifier(p))
+	}
+	f.Signature = parseSignature(p)
+	f.Body = *parseBlock(p)
+	return f
 }
 
 func parseDeclarations(p *Parser) Declarations {
+	if p.tracing() {
+		defer p.enter("parseDeclarations").leave()
+	}
 	switch p.tok {
 	case token.Type:
 		return parseTypeDecl(p)
@@ -829,6 +971,9 @@ func parseDeclarations(p *Parser) Declarations {
 }
 
 func parseVarDecl(p *Parser) Declarations {
+	if p.tracing() {
+		defer p.enter("parseVarDecl").leave()
+	}
 	var decls Declarations
 	p.expect(token.Var)
 	cmnts := p.comments()
@@ -854,6 +999,9 @@ func parseVarDecl(p *Parser) Declarations {
 }
 
 func parseVarSpec(p *Parser) *VarSpec {
+	if p.tracing() {
+		defer p.enter("parseVarSpec").leave()
+	}
 	vs := &VarSpec{
 		comments:    p.comments(),
 		Identifiers: parseIdentifierList(p),
@@ -871,6 +1019,9 @@ func parseVarSpec(p *Parser) *VarSpec {
 }
 
 func parseConstDecl(p *Parser) Declarations {
+	if p.tracing() {
+		defer p.enter("parseConstDecl").leave()
+	}
 	var decls Declarations
 	p.expect(token.Const)
 	cmnts := p.comments()
@@ -904,6 +1055,9 @@ func parseConstDecl(p *Parser) Declarations {
 }
 
 func parseConstSpec(p *Parser, typ Type, vals []Expression, i int) *ConstSpec {
+	if p.tracing() {
+		defer p.enter("parseConstSpec").leave()
+	}
 	cs := &ConstSpec{
 		comments:    p.comments(),
 		Identifiers: parseIdentifierList(p),
@@ -932,6 +1086,9 @@ func parseConstSpec(p *Parser, typ Type, vals []Expression, i int) *ConstSpec {
 }
 
 func parseIdentifierList(p *Parser) []Identifier {
+	if p.tracing() {
+		defer p.enter("parseIdentifierList").leave()
+	}
 	var ids []Identifier
 	for {
 		ids = append(ids, *parseIdentifier(p))
@@ -944,6 +1101,9 @@ func parseIdentifierList(p *Parser) []Identifier {
 }
 
 func parseTypeDecl(p *Parser) Declarations {
+	if p.tracing() {
+		defer p.enter("parseTypeDecl").leave()
+	}
 	var decls Declarations
 	p.expect(token.Type)
 	cmnts := p.comments()
@@ -969,12 +1129,257 @@ func parseTypeDecl(p *Parser) Declarations {
 	return decls
 }
 
+// The open bracket following the name of a TypeSpec begins either
+// a type parameter list or an array or slice type. If the bracket is
+// followed by an identifier, and the identifier is followed by a token
+// that may begin a constraint, then it is a type parameter list.
+// If the identifier is followed by * or (, see parseTypeParametersOrSize.
+//
+// BUG(eaburns): Generic aliases, type A[P any] = B[P], are not supported.
 func parseTypeSpec(p *Parser) *TypeSpec {
-	return &TypeSpec{
+	if p.tracing() {
+		defer p.enter("parseTypeSpec").leave()
+	}
+	ts := &TypeSpec{
 		comments:   p.comments(),
 		Identifier: *parseIdentifier(p),
-		Type:       parseType(p),
 	}
+	if p.tok == token.Equal {
+		p.next()
+		ts.Alias = true
+		ts.Type = parseType(p)
+		return ts
+	}
+	if p.tok != token.OpenBracket {
+		ts.Type = parseType(p)
+		return ts
+	}
+	openLoc := p.start()
+	p.next()
+	var size Expression
+	switch p.tok {
+	case token.CloseBracket:
+		p.next()
+		ts.Type = &SliceType{Element: parseType(p), openLoc: openLoc}
+		return ts
+	case token.Identifier:
+		id := parseIdentifier(p)
+		if constraintFollow[p.tok] {
+			ts.TypeParameters = parseTypeParameters(p, id)
+			ts.Type = parseType(p)
+			return ts
+		}
+		if p.tok == token.Star || p.tok == token.OpenParen {
+			ts.TypeParameters, size = parseTypeParametersOrSize(p, id)
+			if ts.TypeParameters != nil {
+				ts.Type = parseType(p)
+				return ts
+			}
+			break
+		}
+		size = parseExprFrom(p, id)
+	default:
+		size = parseExpr(p)
+	}
+	ts.Type = parseArrayTypeTail(p, openLoc, size)
+	return ts
+}
+
+// ConstraintFollow is the set of tokens that may follow the first
+// identifier of a type parameter list.
+var constraintFollow = map[token.Token]bool{
+	token.Identifier:  true,
+	token.Comma:       true,
+	token.Tilde:       true,
+	token.OpenBracket: true,
+	token.Struct:      true,
+	token.Func:        true,
+	token.Interface:   true,
+	token.Map:         true,
+	token.Chan:        true,
+	token.LessMinus:   true,
+}
+
+// ParseTypeParameters parses a type parameter list, beginning after
+// its first identifier, which has already been parsed.
+func parseTypeParameters(p *Parser, id *Identifier) []TypeParameter {
+	if p.tracing() {
+		defer p.enter("parseTypeParameters").leave()
+	}
+	return parseTypeParametersTail(p, parseTypeParameterGroup(p, id))
+}
+
+// ParseTypeParameterGroup parses a list of type parameter names and their
+// constraint, beginning after the first name, which has already been parsed.
+func parseTypeParameterGroup(p *Parser, id *Identifier) []TypeParameter {
+	if p.tracing() {
+		defer p.enter("parseTypeParameterGroup").leave()
+	}
+	ids := []*Identifier{id}
+	for p.tok == token.Comma {
+		p.next()
+		ids = append(ids, parseIdentifier(p))
+	}
+	c := parseConstraint(p)
+	tps := make([]TypeParameter, len(ids))
+	for i, id := range ids {
+		tps[i] = TypeParameter{Identifier: *id, Constraint: c}
+	}
+	return tps
+}
+
+// ParseTypeParametersTail parses the remainder of a type parameter list
+// for which the leading type parameters have already been parsed.
+func parseTypeParametersTail(p *Parser, tps []TypeParameter) []TypeParameter {
+	if p.tracing() {
+		defer p.enter("parseTypeParametersTail").leave()
+	}
+	for p.tok == token.Comma {
+		p.next()
+		// Allow trailing comma.
+		if p.tok == token.CloseBracket {
+			break
+		}
+		tps = append(tps, parseTypeParameterGroup(p, parseIdentifier(p))...)
+	}
+	p.expect(token.CloseBracket)
+	p.next()
+	return tps
+}
+
+// ParseTypeParametersOrSize parses the remainder of either a type
+// parameter list or an array length, beginning after an identifier that
+// is followed by * or (. Either P *C or P (C) may be the first type
+// parameter with the constraint *C or C, or it may be an expression.
+//
+// As with go/parser, it is a type parameter list if it is followed
+// by a comma, if the constraint or a term of a union that follows it
+// is a type literal or begins with ~, for example, type T[P *[]int],
+// and otherwise it is an array length: type T[P *C] is an array type.
+// Exactly one of the returned type parameters and size is non-nil.
+func parseTypeParametersOrSize(p *Parser, id *Identifier) ([]TypeParameter, Expression) {
+	if p.tracing() {
+		defer p.enter("parseTypeParametersOrSize").leave()
+	}
+	x := parseBinaryExprTail(p, precedence[token.Star], parsePrimaryExprTail(p, id, false))
+	c, lit := leadingConstraint(id, x)
+	var ors []*BinaryOp
+	tilde := false
+	for p.tok == token.Or {
+		or := &BinaryOp{Op: token.Or, opLoc: p.start()}
+		p.next()
+		if p.tok == token.Tilde {
+			tilde = true
+			break
+		}
+		or.Right = parseBinaryExpr(p, precedence[token.Or]+1, false)
+		lit = lit || isTypeLiteral(or.Right)
+		ors = append(ors, or)
+	}
+	if c != nil && (tilde || lit || p.tok == token.Comma) {
+		terms := []Term{{Type: c}}
+		for _, or := range ors {
+			t := typeExpr(or.Right)
+			if t == nil {
+				panic(p.err("type"))
+			}
+			terms = append(terms, Term{Type: t})
+		}
+		if tilde {
+			terms = append(terms, parseTerm(p))
+			for p.tok == token.Or {
+				p.next()
+				terms = append(terms, parseTerm(p))
+			}
+		}
+		if len(terms) > 1 {
+			c = &Union{Terms: terms}
+		}
+		tp := TypeParameter{Identifier: *id, Constraint: c}
+		return parseTypeParametersTail(p, []TypeParameter{tp}), nil
+	}
+	if tilde {
+		panic(p.err("operand"))
+	}
+	for _, or := range ors {
+		or.Left = x
+		x = or
+	}
+	return nil, parseBinaryExprTail(p, 1, x)
+}
+
+// LeadingConstraint returns the constraint of the type parameter id and
+// whether it is a type literal, if x is either id *C or id (C) for a type C.
+// Otherwise, it returns nil.
+func leadingConstraint(id *Identifier, x Expression) (Type, bool) {
+	switch x := x.(type) {
+	case *BinaryOp:
+		if x.Op != token.Star || x.Left != Expression(id) {
+			break
+		}
+		if t := typeExpr(x.Right); t != nil {
+			return &Star{Target: t, starLoc: x.opLoc}, isTypeLiteral(t)
+		}
+	case *Call:
+		if x.Function != Expression(id) || len(x.Arguments) != 1 || x.DotDotDot {
+			break
+		}
+		if t := typeExpr(x.Arguments[0]); t != nil {
+			return t, isTypeLiteral(t)
+		}
+	}
+	return nil, false
+}
+
+// ParseConstraint parses a type constraint: a type, or a union of terms.
+func parseConstraint(p *Parser) Type {
+	if p.tracing() {
+		defer p.enter("parseConstraint").leave()
+	}
+	if p.tok == token.Tilde {
+		return parseUnion(p, parseTerm(p))
+	}
+	return parseConstraintFrom(p, parseType(p))
+}
+
+// ParseConstraintFrom parses the remainder of a type constraint
+// for which the first type has already been parsed.
+func parseConstraintFrom(p *Parser, t Type) Type {
+	if p.tracing() {
+		defer p.enter("parseConstraintFrom").leave()
+	}
+	if p.tok != token.Or {
+		return t
+	}
+	return parseUnion(p, Term{Type: t})
+}
+
+// ParseUnion parses the remainder of a union for which the first term
+// has already been parsed.
+func parseUnion(p *Parser, first Term) *Union {
+	if p.tracing() {
+		defer p.enter("parseUnion").leave()
+	}
+	u := &Union{Terms: []Term{first}}
+	for p.tok == token.Or {
+		p.next()
+		u.Terms = append(u.Terms, parseTerm(p))
+	}
+	return u
+}
+
+func parseTerm(p *Parser) Term {
+	if p.tracing() {
+		defer p.enter("parseTerm").leave()
+	}
+	var t Term
+	if p.tok == token.Tilde {
+		t.Tilde = true
+		t.tildeLoc = p.start()
+		p.next()
+	}
+	t.Type = parseType(p)
+	return t
 }
 
 // TypeFirst is the set of tokens that can start a type.
@@ -992,6 +1397,9 @@ var typeFirst = map[token.Token]bool{
 }
 
 func parseType(p *Parser) Type {
+	if p.tracing() {
+		defer p.enter("parseType").leave()
+	}
 	switch p.tok {
 	case token.Identifier:
 		return parseTypeName(p)
@@ -1038,6 +1446,9 @@ func parseType(p *Parser) Type {
 }
 
 func parseStructType(p *Parser) *StructType {
+	if p.tracing() {
+		defer p.enter("parseStructType").leave()
+	}
 	p.expect(token.Struct)
 	st := &StructType{keywordLoc: p.start()}
 	p.next()
@@ -1061,6 +1472,9 @@ func parseStructType(p *Parser) *StructType {
 }
 
 func parseFieldDecl(p *Parser) []FieldDecl {
+	if p.tracing() {
+		defer p.enter("parseFieldDecl").leave()
+	}
 	var id *Identifier
 	var ids []*Identifier
 	var typ Type
@@ -1121,6 +1535,9 @@ func distributeField(ids []*Identifier, typ Type, tag *StringLiteral) []FieldDec
 }
 
 func parseInterfaceType(p *Parser) *InterfaceType {
+	if p.tracing() {
+		defer p.enter("parseInterfaceType").leave()
+	}
 	p.expect(token.Interface)
 	it := &InterfaceType{keywordLoc: p.start()}
 	p.next()
@@ -1128,23 +1545,16 @@ func parseInterfaceType(p *Parser) *InterfaceType {
 	p.next()
 
 	for p.tok != token.CloseBrace {
-		id := parseIdentifier(p)
-		switch p.tok {
-		case token.OpenParen:
+		if p.tok != token.Identifier {
+			it.Methods = append(it.Methods, parseConstraint(p))
+		} else if id := parseIdentifier(p); p.tok == token.OpenParen {
 			it.Methods = append(it.Methods, &Method{
 				Identifier: *id,
 				Signature:  parseSignature(p),
 			})
-
-		case token.Dot:
-			p.next()
-			it.Methods = append(it.Methods, &TypeName{
-				Package:    id,
-				Identifier: *parseIdentifier(p),
-			})
-
-		default:
-			it.Methods = append(it.Methods, &TypeName{Identifier: *id})
+		} else {
+			t := parseTypeNameFrom(p, id)
+			it.Methods = append(it.Methods, parseConstraintFrom(p, t))
 		}
 		if p.tok == token.CloseBrace {
 			break
@@ -1160,6 +1570,9 @@ func parseInterfaceType(p *Parser) *InterfaceType {
 }
 
 func parseSignature(p *Parser) Signature {
+	if p.tracing() {
+		defer p.enter("parseSignature").leave()
+	}
 	p.expect(token.OpenParen)
 	s := Signature{start: p.start()}
 	p.next()
@@ -1194,7 +1607,8 @@ func parseSignature(p *Parser) Signature {
 // ParameterListTail =
 // 	| “)”
 // 	| Identifier “,” ParameterListTail
-// 	| Identifier “.” Identifier TypeParameterList
+// 	| Identifier “.” Identifier [TypeArgs] TypeParameterList
+// 	| Identifier TypeArgs TypeParameterList
 // 	| Identifier Type DeclParameterList
 // 	| NonTypeNameType TypeParameterList
 // 	| “...” Type ")"
@@ -1212,10 +1626,16 @@ func parseSignature(p *Parser) Signature {
 // 	| Identifier “,” IdentifierList
 // 	| Identifier
 func parseParameterList(p *Parser) []ParameterDecl {
+	if p.tracing() {
+		defer p.enter("parseParameterList").leave()
+	}
 	return parseParameterListTail(p, nil)
 }
 
 func parseParameterListTail(p *Parser, ids []*Identifier) []ParameterDecl {
+	if p.tracing() {
+		defer p.enter("parseParameterListTail").leave()
+	}
 	switch {
 	case p.tok == token.CloseParen:
 		return typeNameDecls(ids)
@@ -1238,6 +1658,16 @@ func parseParameterListTail(p *Parser, ids []*Identifier) []ParameterDecl {
 			ps := append(typeNameDecls(ids), ParameterDecl{Type: t})
 			return parseTypeParameterList(p, ps)
 
+		case p.tok == token.OpenBracket:
+			// Either an array or slice type of a named parameter,
+			// or an instance of a generic type of an unnamed parameter.
+			t := parseArrayOrInstance(p, id)
+			if inst, ok := t.(*Instance); ok {
+				ps := append(typeNameDecls(ids), ParameterDecl{Type: inst})
+				return parseTypeParameterList(p, ps)
+			}
+			return parseDeclParameterList(p, distributeParm(append(ids, id), t, false))
+
 		default:
 			ids = append(ids, id)
 			if p.tok == token.DotDotDot {
@@ -1264,6 +1694,9 @@ func parseParameterListTail(p *Parser, ids []*Identifier) []ParameterDecl {
 }
 
 func parseTypeParameterList(p *Parser, ps []ParameterDecl) []ParameterDecl {
+	if p.tracing() {
+		defer p.enter("parseTypeParameterList").leave()
+	}
 	if p.tok == token.CloseParen {
 		return ps
 	}
@@ -1289,6 +1722,9 @@ func parseTypeParameterList(p *Parser, ps []ParameterDecl) []ParameterDecl {
 }
 
 func parseDeclParameterList(p *Parser, ps []ParameterDecl) []ParameterDecl {
+	if p.tracing() {
+		defer p.enter("parseDeclParameterList").leave()
+	}
 	if p.tok == token.CloseParen {
 		return ps
 	}
@@ -1338,6 +1774,9 @@ func typeNameDecls(ids []*Identifier) []ParameterDecl {
 }
 
 func parseChannelType(p *Parser) Type {
+	if p.tracing() {
+		defer p.enter("parseChannelType").leave()
+	}
 	ch := &ChannelType{Send: true, Receive: true, startLoc: p.start()}
 	if p.tok == token.LessMinus {
 		ch.Send = false
@@ -1354,6 +1793,9 @@ func parseChannelType(p *Parser) Type {
 }
 
 func parseMapType(p *Parser) Type {
+	if p.tracing() {
+		defer p.enter("parseMapType").leave()
+	}
 	p.expect(token.Map)
 	m := &MapType{mapLoc: p.start()}
 	p.next()
@@ -1369,6 +1811,9 @@ func parseMapType(p *Parser) Type {
 // Parses an array or slice type.  If dotDotDot is true then it will accept an
 // array with a size specified a "..." token, otherwise it will require a size.
 func parseArrayOrSliceType(p *Parser, dotDotDot bool) Type {
+	if p.tracing() {
+		defer p.enter("parseArrayOrSliceType").leave()
+	}
 	p.expect(token.OpenBracket)
 	openLoc := p.start()
 	p.next()
@@ -1378,28 +1823,124 @@ func parseArrayOrSliceType(p *Parser, dotDotDot bool) Type {
 		sl := &SliceType{Element: parseType(p), openLoc: openLoc}
 		return sl
 	}
-	ar := &ArrayType{openLoc: openLoc}
+	var size Expression
 	if dotDotDot && p.tok == token.DotDotDot {
 		p.next()
 	} else {
-		ar.Size = parseExpr(p)
+		size = parseExpr(p)
+	}
+	return parseArrayTypeTail(p, openLoc, size)
+}
+
+// Parses the remainder of an array type, beginning from the close
+// bracket following its size.  The size is nil for an array with a
+// size specified by a "..." token.
+func parseArrayTypeTail(p *Parser, openLoc token.Pos, size Expression) *ArrayType {
+	if p.tracing() {
+		defer p.enter("parseArrayTypeTail").leave()
 	}
 	p.expect(token.CloseBracket)
 	p.next()
-	ar.Element = parseType(p)
-	return ar
+	return &ArrayType{Size: size, Element: parseType(p), openLoc: openLoc}
+}
+
+// Parses the open bracket following an identifier that is either the
+// name of a parameter with an array or slice type, or a generic type
+// name with type arguments.  If the close bracket is followed by a type,
+// the array or slice type is returned, and the identifier is not used.
+// Otherwise, the instance of the generic type is returned.
+func parseArrayOrInstance(p *Parser, id *Identifier) Type {
+	if p.tracing() {
+		defer p.enter("parseArrayOrInstance").leave()
+	}
+	p.expect(token.OpenBracket)
+	openLoc := p.start()
+	p.next()
+	if p.tok == token.CloseBracket {
+		p.next()
+		return &SliceType{Element: parseType(p), openLoc: openLoc}
+	}
+	x := parseExpr(p)
+	if p.tok == token.CloseBracket {
+		p.next()
+		if typeFirst[p.tok] {
+			return &ArrayType{Size: x, Element: parseType(p), openLoc: openLoc}
+		}
+		inst := &Instance{Expression: &TypeName{Identifier: *id}, openLoc: openLoc}
+		inst.TypeArguments = []Type{typeArgument(p, x)}
+		inst.closeLoc = p.prevEnd
+		return inst
+	}
+	inst := &Instance{Expression: &TypeName{Identifier: *id}, openLoc: openLoc}
+	return parseTypeArguments(p, inst, x)
 }
 
 func parseTypeName(p *Parser) Type {
+	if p.tracing() {
+		defer p.enter("parseTypeName").leave()
+	}
 	p.expect(token.Identifier)
+	return parseTypeNameFrom(p, parseIdentifier(p))
+}
+
+// Parses the remainder of a type name, beginning after its first
+// identifier.  If the type name has type arguments, an Instance
+// is returned.
+func parseTypeNameFrom(p *Parser, name *Identifier) Type {
+	if p.tracing() {
+		defer p.enter("parseTypeNameFrom").leave()
+	}
 	var pkg *Identifier
-	name := parseIdentifier(p)
 	if p.tok == token.Dot {
 		p.next()
 		pkg = name
 		name = parseIdentifier(p)
 	}
-	return &TypeName{Package: pkg, Identifier: *name}
+	tn := &TypeName{Package: pkg, Identifier: *name}
+	if p.tok != token.OpenBracket {
+		return tn
+	}
+	inst := &Instance{Expression: tn, openLoc: p.start()}
+	p.next()
+	return parseTypeArguments(p, inst, parseType(p))
+}
+
+// Parses the remainder of the type arguments of an instance, beginning
+// after the first argument, which has already been parsed as either a
+// type or an expression.
+func parseTypeArguments(p *Parser, inst *Instance, first Expression) *Instance {
+	if p.tracing() {
+		defer p.enter("parseTypeArguments").leave()
+	}
+	inst.TypeArguments = []Type{typeArgument(p, first)}
+	for p.tok == token.Comma {
+		p.next()
+		// Allow trailing comma.
+		if p.tok == token.CloseBracket {
+			break
+		}
+		inst.TypeArguments = append(inst.TypeArguments, parseType(p))
+	}
+	p.expect(token.CloseBracket)
+	inst.closeLoc = p.end()
+	p.next()
+	return inst
+}
+
+// TypeArgument returns the type denoted by an expression parsed as a
+// type argument, or panics with a syntax error if it does not denote a type.
+func typeArgument(p *Parser, x Expression) Type {
+	t := typeExpr(x)
+	if t == nil {
+		panic(&SyntaxError{
+			Wanted: "type",
+			Got:    token.Error,
+			Text:   x.Source(),
+			Start:  x.Start(),
+			End:    x.End(),
+		})
+	}
+	return t
 }
 
 var (
@@ -1471,14 +2012,32 @@ var (
 )
 
 func parseExpr(p *Parser) Expression {
+	if p.tracing() {
+		defer p.enter("parseExpr").leave()
+	}
 	return parseExpression(p, false)
 }
 
 func parseExpression(p *Parser, typeSwitch bool) Expression {
+	if p.tracing() {
+		defer p.enter("parseExpression").leave()
+	}
 	return parseBinaryExpr(p, 1, typeSwitch)
 }
 
+// ParseExprFrom parses the remainder of an expression for which the
+// leading operand has already been parsed.
+func parseExprFrom(p *Parser, operand Expression) Expression {
+	if p.tracing() {
+		defer p.enter("parseExprFrom").leave()
+	}
+	return parseBinaryExprTail(p, 1, parsePrimaryExprTail(p, operand, false))
+}
+
 func parseBinaryExpr(p *Parser, prec int, typeSwitch bool) Expression {
+	if p.tracing() {
+		defer p.enter("parseBinaryExpr").leave()
+	}
 	left := parseUnaryExpr(p, typeSwitch)
 	if ta, ok := left.(*TypeAssertion); ok && ta.AssertedType == nil {
 		if !typeSwitch {
@@ -1488,6 +2047,15 @@ func parseBinaryExpr(p *Parser, prec int, typeSwitch bool) Expression {
 		// binary expression.
 		return left
 	}
+	return parseBinaryExprTail(p, prec, left)
+}
+
+// ParseBinaryExprTail parses the remainder of a binary expression
+// for which the left operand has already been parsed.
+func parseBinaryExprTail(p *Parser, prec int, left Expression) Expression {
+	if p.tracing() {
+		defer p.enter("parseBinaryExprTail").leave()
+	}
 	for {
 		pr, ok := precedence[p.tok]
 		if !ok || pr < prec {
@@ -1506,6 +2074,9 @@ func parseBinaryExpr(p *Parser, prec int, typeSwitch bool) Expression {
 }
 
 func parseUnaryExpr(p *Parser, typeSwitch bool) Expression {
+	if p.tracing() {
+		defer p.enter("parseUnaryExpr").leave()
+	}
 	if unary[p.tok] {
 		op, opLoc := p.tok, p.start()
 		p.next()
@@ -1523,6 +2094,9 @@ func parseUnaryExpr(p *Parser, typeSwitch bool) Expression {
 }
 
 func parsePrimaryExpr(p *Parser, typeSwitch bool) Expression {
+	if p.tracing() {
+		defer p.enter("parsePrimaryExpr").leave()
+	}
 	left := parseOperand(p, typeSwitch)
 	if p.exprLevel >= 0 && p.tok == token.OpenBrace {
 		if t := typeExpr(left); t != nil {
@@ -1531,8 +2105,26 @@ func parsePrimaryExpr(p *Parser, typeSwitch bool) Expression {
 			return lit
 		}
 	}
+	return parsePrimaryExprTail(p, left, typeSwitch)
+}
+
+// ParsePrimaryExprTail parses the remainder of a primary expression
+// for which the operand has already been parsed.
+func parsePrimaryExprTail(p *Parser, left Expression, typeSwitch bool) Expression {
+	if p.tracing() {
+		defer p.enter("parsePrimaryExprTail").leave()
+	}
 	for {
 		switch p.tok {
+		case token.OpenBrace:
+			// A composite literal with an instantiated generic type.
+			t := typeExpr(left)
+			if _, ok := t.(*Instance); !ok || p.exprLevel < 0 {
+				return left
+			}
+			lit := parseLiteralValue(p)
+			lit.LiteralType = t
+			return lit
 		case token.OpenBracket:
 			p.exprLevel++
 			left = parseSliceOrIndex(p, left)
@@ -1552,6 +2144,11 @@ func parsePrimaryExpr(p *Parser, typeSwitch bool) Expression {
 // Returns a Type if the Expression can be a Type, or nil if it is not.
 func typeExpr(e Expression) Type {
 	switch t := e.(type) {
+	case *Instance:
+		if x := typeExpr(t.Expression); x != nil {
+			t.Expression = x
+			return t
+		}
 	case Type:
 		return t
 	case *Identifier:
@@ -1560,11 +2157,35 @@ func typeExpr(e Expression) Type {
 		if pkg, ok := t.Parent.(*Identifier); ok {
 			return &TypeName{Package: pkg, Identifier: *t.Identifier}
 		}
+	case *Index:
+		x, i := typeExpr(t.Expression), typeExpr(t.Index)
+		if x != nil && i != nil {
+			return &Instance{
+				Expression:    x,
+				TypeArguments: []Type{i},
+				openLoc:       t.openLoc,
+				closeLoc:      t.closeLoc,
+			}
+		}
 	}
 	return nil
 }
 
+// IsTypeLiteral returns whether the expression is a type literal that
+// cannot also be a value, for example, a slice type.
+func isTypeLiteral(e Expression) bool {
+	switch e.(type) {
+	case *ArrayType, *SliceType, *MapType, *ChannelType, *StructType,
+		*InterfaceType, *FunctionType:
+		return true
+	}
+	return false
+}
+
 func parseSliceOrIndex(p *Parser, left Expression) Expression {
+	if p.tracing() {
+		defer p.enter("parseSliceOrIndex").leave()
+	}
 	p.expect(token.OpenBracket)
 	openLoc := p.start()
 	p.next()
@@ -1578,7 +2199,15 @@ func parseSliceOrIndex(p *Parser, left Expression) Expression {
 	e := parseExpr(p)
 
 	switch p.tok {
+	case token.Comma:
+		inst := &Instance{Expression: left, openLoc: openLoc}
+		return parseTypeArguments(p, inst, e)
+
 	case token.CloseBracket:
+		if isTypeLiteral(e) {
+			inst := &Instance{Expression: left, openLoc: openLoc}
+			return parseTypeArguments(p, inst, e)
+		}
 		index := &Index{Expression: left, Index: e, openLoc: openLoc}
 		p.expect(token.CloseBracket)
 		index.closeLoc = p.end()
@@ -1591,7 +2220,7 @@ func parseSliceOrIndex(p *Parser, left Expression) Expression {
 		return sl
 	}
 
-	panic(p.err(token.CloseBracket, token.Colon))
+	panic(p.err(token.CloseBracket, token.Colon, token.Comma))
 }
 
 // Parses the remainder of a slice expression, beginning from the
@@ -1599,6 +2228,9 @@ func parseSliceOrIndex(p *Parser, left Expression) Expression {
 // node does not have its openLoc field set; it must be set by the
 // caller.
 func parseSliceHighMax(p *Parser, left, low Expression) *Slice {
+	if p.tracing() {
+		defer p.enter("parseSliceHighMax").leave()
+	}
 	p.expect(token.Colon)
 	p.next()
 
@@ -1617,6 +2249,9 @@ func parseSliceHighMax(p *Parser, left, low Expression) *Slice {
 }
 
 func parseCall(p *Parser, left Expression) Expression {
+	if p.tracing() {
+		defer p.enter("parseCall").leave()
+	}
 	p.expect(token.OpenParen)
 	c := &Call{Function: left, openLoc: p.start()}
 	p.next()
@@ -1646,6 +2281,9 @@ func parseCall(p *Parser, left Expression) Expression {
 }
 
 func parseExpressionList(p *Parser) []Expression {
+	if p.tracing() {
+		defer p.enter("parseExpressionList").leave()
+	}
 	var exprs []Expression
 	for {
 		exprs = append(exprs, parseExpr(p))
@@ -1658,6 +2296,9 @@ func parseExpressionList(p *Parser) []Expression {
 }
 
 func parseExpressionListOrTypeGuard(p *Parser) []Expression {
+	if p.tracing() {
+		defer p.enter("parseExpressionListOrTypeGuard").leave()
+	}
 	var exprs []Expression
 	for {
 		expr := parseExpression(p, len(exprs) == 0)
@@ -1678,6 +2319,9 @@ func parseExpressionListOrTypeGuard(p *Parser) []Expression {
 }
 
 func parseOperand(p *Parser, typeSwitch bool) Expression {
+	if p.tracing() {
+		defer p.enter("parseOperand").leave()
+	}
 	switch p.tok {
 	case token.Identifier:
 		id := parseIdentifier(p)
@@ -1720,10 +2364,27 @@ func parseOperand(p *Parser, typeSwitch bool) Expression {
 	if typeFirst[p.tok] {
 		return parseType(p)
 	}
-	panic(p.err("operand"))
+	return parseBadExpr(p)
+}
+
+// ParseBadExpr records a syntax error for a missing operand and returns a
+// BadExpr spanning the current token. The token is not consumed, since it
+// may belong to an enclosing production.
+func parseBadExpr(p *Parser) *BadExpr {
+	if p.tracing() {
+		defer p.enter("parseBadExpr").leave()
+	}
+	p.syntaxError(p.err("operand"))
+	if p.tooManyErrors() {
+		panic(bailout{})
+	}
+	return &BadExpr{span: p.span()}
 }
 
 func parseFunctionLiteral(p *Parser) *FunctionLiteral {
+	if p.tracing() {
+		defer p.enter("parseFunctionLiteral").leave()
+	}
 	var f FunctionLiteral
 	p.expect(token.Func)
 	f.funcLoc = p.start()
@@ -1734,6 +2395,9 @@ func parseFunctionLiteral(p *Parser) *FunctionLiteral {
 }
 
 func parseLiteralValue(p *Parser) *CompositeLiteral {
+	if p.tracing() {
+		defer p.enter("parseLiteralValue").leave()
+	}
 	p.expect(token.OpenBrace)
 	v := &CompositeLiteral{openLoc: p.start()}
 	p.next()
@@ -1754,6 +2418,9 @@ func parseLiteralValue(p *Parser) *CompositeLiteral {
 }
 
 func parseElement(p *Parser) Element {
+	if p.tracing() {
+		defer p.enter("parseElement").leave()
+	}
 	if p.tok == token.OpenBrace {
 		return Element{Value: parseLiteralValue(p)}
 	}
@@ -1774,6 +2441,9 @@ func parseElement(p *Parser) Element {
 }
 
 func parseSelectorOrTypeAssertion(p *Parser, left Expression, typeSwitch bool) Expression {
+	if p.tracing() {
+		defer p.enter("parseSelectorOrTypeAssertion").leave()
+	}
 	p.expect(token.Dot)
 	dotLoc := p.start()
 	p.next()
@@ -1807,41 +2477,44 @@ func parseSelectorOrTypeAssertion(p *Parser, left Expression, typeSwitch bool) E
 	panic(p.err(token.OpenParen, token.Identifier))
 }
 
+// With base 0, SetString accepts the base prefixes and the underscore
+// separators of Go integer literals. A leading 0 without a prefix
+// denotes an octal literal.
 func parseIntegerLiteral(p *Parser) Expression {
+	if p.tracing() {
+		defer p.enter("parseIntegerLiteral").leave()
+	}
 	l := &IntegerLiteral{Value: new(big.Int), span: p.span()}
-	if _, ok := l.Value.SetString(p.lex.Text(), 0); ok {
-		p.next()
-		return l
+	if _, ok := l.Value.SetString(p.cur.Text, 0); !ok {
+		panic("bad integer literal: " + p.cur.Text)
 	}
-	// This check is needed to catch malformed octal literals that
-	// are currently allowed by the lexer.  For example, 08.
-	panic(&MalformedLiteral{
-		Type:  "integer literal",
-		Text:  p.text(),
-		Start: p.start(),
-		End:   p.end(),
-	})
+	p.next()
+	return l
 }
 
+// SetString accepts decimal and hexadecimal floating-point literals,
+// including underscore separators.
 func parseFloatLiteral(p *Parser) Expression {
+	if p.tracing() {
+		defer p.enter("parseFloatLiteral").leave()
+	}
 	l := &FloatLiteral{Value: new(big.Rat), span: p.span()}
-	if _, ok := l.Value.SetString(p.lex.Text()); ok {
-		p.next()
-		return l
+	if _, ok := l.Value.SetString(p.cur.Text); !ok {
+		panic("bad float literal: " + p.cur.Text)
 	}
-	// I seem to recall that there was some case where the lexer
-	// may return a malformed float, but I can't remember the
-	// specifics.
-	panic(&MalformedLiteral{
-		Type:  "float literal",
-		Text:  p.text(),
-		Start: p.start(),
-		End:   p.end(),
-	})
+	p.next()
+	return l
 }
 
+// The imaginary part may have any of the forms of an integer or
+// floating-point literal. Unlike an integer literal, decimal digits
+// with a leading 0 are decimal, not octal, which is also how SetString
+// parses them.
 func parseImaginaryLiteral(p *Parser) Expression {
-	text := p.lex.Text()
+	if p.tracing() {
+		defer p.enter("parseImaginaryLiteral").leave()
+	}
+	text := p.cur.Text
 	if len(text) < 1 || text[len(text)-1] != 'i' {
 		panic("bad imaginary literal: " + text)
 	}
@@ -1851,24 +2524,19 @@ func parseImaginaryLiteral(p *Parser) Expression {
 		Imaginary: new(big.Rat),
 		span:      p.span(),
 	}
-	if _, ok := l.Imaginary.SetString(text); ok {
-		p.next()
-		return l
+	if _, ok := l.Imaginary.SetString(text); !ok {
+		panic("bad imaginary literal: " + p.cur.Text)
 	}
-
-	// I seem to recall that there was some case where the lexer
-	// may return a malformed float, but I can't remember the
-	// specifics.
-	panic(&MalformedLiteral{
-		Type:  "imaginary literal",
-		Text:  p.text(),
-		Start: p.start(),
-		End:   p.end(),
-	})
+	p.next()
+	return l
 }
 
 func parseStringLiteral(p *Parser) *StringLiteral {
-	text := p.lex.Text()
+	if p.tracing() {
+		defer p.enter("parseStringLiteral").leave()
+	}
+	p.expect(token.StringLiteral)
+	text := p.cur.Text
 	if len(text) < 2 {
 		panic("bad string literal: " + text)
 	}
@@ -1878,7 +2546,7 @@ func parseStringLiteral(p *Parser) *StringLiteral {
 	} else {
 		var err error
 		if l.Value, err = strconv.Unquote(text); err != nil {
-			panic("bad string literal: " + p.lex.Text())
+			panic("bad string literal: " + p.cur.Text)
 		}
 	}
 	p.next()
@@ -1886,27 +2554,26 @@ func parseStringLiteral(p *Parser) *StringLiteral {
 }
 
 func parseIdentifier(p *Parser) *Identifier {
+	if p.tracing() {
+		defer p.enter("parseIdentifier").leave()
+	}
 	p.expect(token.Identifier)
-	id := &Identifier{Name: p.text(), span: p.span()}
+	id := &Identifier{Name: p.name(), span: p.span()}
 	p.next()
 	return id
 }
 
 func parseRuneLiteral(p *Parser) Expression {
-	text := p.lex.Text()
+	if p.tracing() {
+		defer p.enter("parseRuneLiteral").leave()
+	}
+	text := p.cur.Text
 	if len(text) < 3 {
 		panic("bad rune literal: " + text)
 	}
 	r, _, _, err := strconv.UnquoteChar(text[1:], '\'')
 	if err != nil {
-		// The lexer may allow bad rune literals (>0x0010FFFF and
-		// surrogate halves—whatever they are).
-		panic(&MalformedLiteral{
-			Type:  "rune literal",
-			Text:  p.text(),
-			Start: p.start(),
-			End:   p.end(),
-		})
+		panic("bad rune literal: " + text)
 	}
 	l := &IntegerLiteral{Value: big.NewInt(int64(r)), Rune: true, span: p.span()}
 	p.next()
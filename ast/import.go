@@ -0,0 +1,236 @@
+package ast
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/velour/stop/token"
+)
+
+// A Package is a checked package that can be imported.
+type Package struct {
+	// Name is the package name.
+	Name string
+	// Path is the import path of the package.
+	Path string
+
+	// Syms maps the exported identifiers of the package
+	// to their declarations.
+	syms *Scope
+}
+
+// Lookup returns the declaration of an exported identifier of the package,
+// or nil if the package has no such exported identifier.
+func (p *Package) Lookup(name string) Declaration {
+	return p.syms.Decls[name]
+}
+
+// An Importer returns the packages imported by the files being checked.
+type Importer interface {
+	// Import returns the package with the given import path.
+	// If the package cannot be read, the returned Package is nil.
+	// Otherwise, the Package is returned along with any errors
+	// encountered when checking it. Errors for a package are only
+	// returned by the first call to Import for its path.
+	Import(path string) (*Package, error)
+}
+
+// CheckPackage checks the files of the package with the given import path,
+// returning the Package of the files' exported declarations and any errors,
+// located in the FileSet of the files. The packages imported by the files
+// are returned by imp.
+func CheckPackage(path string, fset *token.FileSet, files []*File, imp Importer) (*Package, error) {
+	psyms, err := check(files, imp, nil)
+	p := &Package{Path: path, syms: makeScope(nil, nil)}
+	if len(files) > 0 {
+		p.Name = files[0].PackageName.Name
+	}
+	for n, d := range psyms.Decls {
+		if isExported(n) {
+			p.syms.Decls[n] = d
+		}
+	}
+	return p, locate(fset, err)
+}
+
+// A SourceImporter is an Importer that reads the Go source files of packages
+// from directories beneath a list of source roots. The directory of a package
+// is its import path, relative to the first source root containing it.
+type SourceImporter struct {
+	// Fset is the FileSet to which the source files are added.
+	fset  *token.FileSet
+	roots []string
+
+	// Pkgs maps the import path of each package that has been imported
+	// to the Package. The Package is nil if it could not be read.
+	pkgs map[string]*Package
+
+	// Stack contains the import paths of the packages that are currently
+	// being imported, in order. It is used to detect import cycles.
+	stack []string
+}
+
+// NewSourceImporter returns a new SourceImporter that searches the
+// source roots in order. The source files of the imported packages
+// are added to the FileSet.
+func NewSourceImporter(fset *token.FileSet, roots []string) *SourceImporter {
+	return &SourceImporter{fset: fset, roots: roots, pkgs: make(map[string]*Package)}
+}
+
+// Import implements the Importer interface. It returns an ImportCycle error
+// if the package is already being imported.
+func (imp *SourceImporter) Import(path string) (*Package, error) {
+	for i, p := range imp.stack {
+		if p == path {
+			cycle := append(append([]string{}, imp.stack[i:]...), path)
+			return nil, ImportCycle{Cycle: cycle}
+		}
+	}
+	if p, ok := imp.pkgs[path]; ok {
+		if p == nil {
+			return nil, errors{}
+		}
+		return p, nil
+	}
+
+	files, err := imp.parseDir(path)
+	if err != nil {
+		imp.pkgs[path] = nil
+		return nil, err
+	}
+	imp.stack = append(imp.stack, path)
+	p, err := CheckPackage(path, imp.fset, files, imp)
+	imp.stack = imp.stack[:len(imp.stack)-1]
+	imp.pkgs[path] = p
+	return p, err
+}
+
+// ParseDir returns the parsed source files of the package with the given
+// import path, from the first source root that has a directory for the path.
+//
+// BUG(eaburns): Build constraints are ignored. All .go files are read,
+// except for those ending in _test.go.
+func (imp *SourceImporter) parseDir(path string) ([]*File, error) {
+	for _, root := range imp.roots {
+		dir := filepath.Join(root, filepath.FromSlash(path))
+		fis, err := ioutil.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		var files []*File
+		for _, fi := range fis {
+			n := fi.Name()
+			if fi.IsDir() || filepath.Ext(n) != ".go" || strings.HasSuffix(n, "_test.go") {
+				continue
+			}
+			f, err := parseSourceFile(imp.fset, filepath.Join(dir, n))
+			if err != nil {
+				return nil, err
+			}
+			if len(files) > 0 && f.PackageName.Name != files[0].PackageName.Name {
+				return nil, fmt.Errorf("found packages %s and %s in %s",
+					files[0].PackageName.Name, f.PackageName.Name, dir)
+			}
+			files = append(files, f)
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no Go source files in %s", dir)
+		}
+		return files, nil
+	}
+	return nil, fmt.Errorf("package not found in source roots %v", imp.roots)
+}
+
+// ParseSourceFile returns the File parsed from the source file at the given
+// path, which is added to the FileSet.
+func parseSourceFile(fset *token.FileSet, path string) (*File, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(NewParser(token.NewLexer(fset, path, string(src))))
+}
+
+// ImportPkgs imports the packages of the ImportSpecs in all of the files.
+// The exported declarations of each dot-imported package are bound in the
+// scope of the importing file. If imp is nil, all imports fail.
+func importPkgs(files []*File, imp Importer) error {
+	var errs errors
+	for _, f := range files {
+		for i := range f.Imports {
+			for j := range f.Imports[i].Imports {
+				im := &f.Imports[i].Imports[j]
+				p, err := importSpec(imp, im)
+				if err != nil {
+					errs = append(errs, err)
+				}
+				if p == nil {
+					continue
+				}
+				im.pkg.Package = p
+				if im.Dot {
+					if err := bindDotImport(f.syms, im, p); err != nil {
+						errs = append(errs, err)
+					}
+				}
+			}
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// ImportSpec returns the package imported by an ImportSpec and any errors.
+// Errors in reading the package are reported at the ImportSpec.
+func importSpec(imp Importer, im *ImportSpec) (*Package, error) {
+	if imp == nil {
+		return nil, BadImport{im, fmt.Errorf("no importer")}
+	}
+	p, err := imp.Import(im.Path.Value)
+	switch e := err.(type) {
+	case nil:
+		return p, nil
+	case errors:
+		// Either errors checking the package, or errors
+		// in reading it that have already been reported.
+		if len(e) == 0 {
+			return p, nil
+		}
+		return p, e
+	case ImportCycle:
+		e.ImportSpec = im
+		return nil, e
+	}
+	if p == nil {
+		return nil, BadImport{im, err}
+	}
+	return p, err
+}
+
+// BindDotImport binds the exported declarations of a dot-imported package
+// in the file Scope. It is an error for a dot-imported identifier to also be
+// declared in the package scope.
+func bindDotImport(fsyms *Scope, im *ImportSpec, p *Package) error {
+	var names []string
+	for n := range p.syms.Decls {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	var errs errors
+	for _, n := range names {
+		if d, ok := fsyms.Parent.Decls[n]; ok {
+			errs = append(errs, &Redeclaration{Name: n, First: d, Second: im.pkg})
+			continue
+		}
+		if err := fsyms.Bind(n, p.syms.Decls[n]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs.ErrorOrNil()
+}
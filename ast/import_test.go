@@ -0,0 +1,297 @@
+package ast
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/velour/stop/token"
+)
+
+func TestImports(t *testing.T) {
+	tests := []struct {
+		// Roots maps the import path of each package in each
+		// source root to the source code of its files.
+		roots []map[string][]string
+		src   []string
+		errs  []reflect.Type
+	}{
+		{
+			roots: []map[string][]string{{"b": {`package b; type T int`}}},
+			src:   []string{`package a; import "b"; var x b.T`},
+		},
+		{
+			roots: []map[string][]string{{"b": {`package b; type T int`}}},
+			src:   []string{`package a; import "b"; var x b.T = 5; var y int = x`},
+			errs:  []reflect.Type{reflect.TypeOf(BadAssign{})},
+		},
+		{
+			roots: []map[string][]string{{"b": {`package b; const C = 1`, `package b; var V = C`}}},
+			src:   []string{`package a; import "b"; var x int8 = b.C; var y = b.V + 1`},
+		},
+		{
+			roots: []map[string][]string{{"b": {`package b; func F(x int) string { return "" }`}}},
+			src:   []string{`package a; import "b"; func f() string { return b.F(1) }`},
+		},
+		{
+			roots: []map[string][]string{{"x/y": {`package y; type T int`}}},
+			src:   []string{`package a; import "x/y"; var v y.T`},
+		},
+		{
+			roots: []map[string][]string{{"b": {`package b; type T int`}}},
+			src:   []string{`package a; import c "b"; var x c.T`},
+		},
+		{
+			roots: []map[string][]string{{"b": {`package b; type T int; const C = 1`}}},
+			src:   []string{`package a; import . "b"; var x T = C`},
+		},
+		{
+			roots: []map[string][]string{{"b": {`package b; type T int`}}},
+			src:   []string{`package a; import _ "b"`},
+		},
+		{
+			roots: []map[string][]string{{"b": {`package b; type T int`}}},
+			src: []string{
+				`package a; import "b"; var x b.T`,
+				`package a; import "b"; var y b.T = x`,
+			},
+		},
+		{
+			// The first root containing the package is used.
+			roots: []map[string][]string{
+				{"c": {`package c; type T int`}},
+				{"b": {`package b; type T int`}},
+				{"b": {`package b; type T string`}},
+			},
+			src: []string{`package a; import "b"; var x b.T = 1`},
+		},
+		{
+			roots: []map[string][]string{{
+				"b": {`package b; type I interface{ M() string }`},
+			}},
+			src: []string{`package a; import "b"; type T int; func (t T) M() string { return "" }; var i b.I = T(0)`},
+		},
+		{
+			roots: []map[string][]string{{
+				"b": {`package b; import "c"; type T c.T`},
+				"c": {`package c; type T struct{ x, y int }`},
+			}},
+			src: []string{`package a; import "b"; var x b.T`},
+		},
+		{
+			roots: []map[string][]string{{"b": {`package b; type Length int`}}},
+			src:   []string{`package a; import "b"; type Length b.Length`},
+		},
+		{
+			roots: []map[string][]string{{"b": {`package b; type t int`}}},
+			src:   []string{`package a; import "b"; var x b.t`},
+			errs:  []reflect.Type{reflect.TypeOf(Undeclared{})},
+		},
+		{
+			roots: []map[string][]string{{"b": {`package b; var v int`}}},
+			src:   []string{`package a; import "b"; var x = b.v`},
+			errs:  []reflect.Type{reflect.TypeOf(Undeclared{})},
+		},
+		{
+			roots: []map[string][]string{{"b": {`package b; type T int`}}},
+			src:   []string{`package a; import _ "b"; var x b.T`},
+			errs: []reflect.Type{
+				reflect.TypeOf(Undeclared{}),
+				reflect.TypeOf(Undeclared{}),
+			},
+		},
+		{
+			roots: []map[string][]string{{"b": {`package b; type T int`}}},
+			src:   []string{`package a; import . "b"; type T int`},
+			errs:  []reflect.Type{reflect.TypeOf(&Redeclaration{})},
+		},
+		{
+			roots: []map[string][]string{{"b": {`package b; type T int`}}},
+			src:   []string{`package a; var x int; type T x.T`},
+			errs:  []reflect.Type{reflect.TypeOf(NotPackage{})},
+		},
+		{
+			src:  []string{`package a; import "b"`},
+			errs: []reflect.Type{reflect.TypeOf(BadImport{})},
+		},
+		{
+			// Only reported once.
+			src: []string{
+				`package a; import "b"; var x b.T`,
+				`package a; import "b"; var y b.T`,
+			},
+			errs: []reflect.Type{reflect.TypeOf(BadImport{})},
+		},
+		{
+			roots: []map[string][]string{{"b": {`package b`, `package c`}}},
+			src:   []string{`package a; import "b"`},
+			errs:  []reflect.Type{reflect.TypeOf(BadImport{})},
+		},
+		{
+			roots: []map[string][]string{{"b": {`package b; const C = undeclared`}}},
+			src:   []string{`package a; import "b"; const D = b.C`},
+			errs:  []reflect.Type{reflect.TypeOf(Undeclared{})},
+		},
+		{
+			roots: []map[string][]string{{"b": {`package b; import "b"`}}},
+			src:   []string{`package a; import "b"`},
+			errs:  []reflect.Type{reflect.TypeOf(ImportCycle{})},
+		},
+		{
+			roots: []map[string][]string{{
+				"b": {`package b; import "c"`},
+				"c": {`package c; import "d"`},
+				"d": {`package d; import "b"`},
+			}},
+			src:  []string{`package a; import "b"`},
+			errs: []reflect.Type{reflect.TypeOf(ImportCycle{})},
+		},
+	}
+	for _, test := range tests {
+		roots, err := writeRoots(test.roots)
+		if err != nil {
+			t.Fatalf("failed to write source roots: %v", err)
+		}
+
+		want := make(map[reflect.Type]int)
+		for _, e := range test.errs {
+			want[e]++
+		}
+		fset := token.NewFileSet()
+		var files []*File
+		for _, src := range test.src {
+			files = append(files, parseFile(NewParser(token.NewLexer(fset, "", src))))
+		}
+		var got []reflect.Type
+		if err := Check(fset, files, NewSourceImporter(fset, roots), nil); err != nil {
+			for _, e := range err.(errors).All() {
+				t := reflect.TypeOf(e.(*LocatedError).Err)
+				want[t]--
+				if want[t] == 0 {
+					delete(want, t)
+				}
+				got = append(got, t)
+			}
+		}
+		if len(want) != 0 {
+			t.Errorf("Check(%v)=%v, want %v", test.src, got, test.errs)
+		}
+		for _, r := range roots {
+			os.RemoveAll(r)
+		}
+	}
+}
+
+// WriteRoots writes the source files of each source root to
+// a new temporary directory, returning the directories.
+func writeRoots(roots []map[string][]string) ([]string, error) {
+	var dirs []string
+	for _, pkgs := range roots {
+		root, err := ioutil.TempDir("", "stop-root-")
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, root)
+		for path, srcs := range pkgs {
+			dir := filepath.Join(root, filepath.FromSlash(path))
+			if err := os.MkdirAll(dir, 0700); err != nil {
+				return nil, err
+			}
+			for i, src := range srcs {
+				name := filepath.Join(dir, strconv.Itoa(i)+".go")
+				if err := ioutil.WriteFile(name, []byte(src), 0600); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return dirs, nil
+}
+
+// A fakeImporter imports packages from in-memory source.
+type fakeImporter map[string]string
+
+func (imp fakeImporter) Import(path string) (*Package, error) {
+	src, ok := imp[path]
+	if !ok {
+		return nil, fmt.Errorf("package %s not found", path)
+	}
+	fset := token.NewFileSet()
+	f := parseFile(NewParser(token.NewLexer(fset, path, src)))
+	return CheckPackage(path, fset, []*File{f}, imp)
+}
+
+func TestImporter(t *testing.T) {
+	imp := fakeImporter{
+		"fake/fmt": `package fmt; func Sprint(x int) string { return "" }; type Stringer interface { String() string }`,
+		"fake/os":  `package os; import "fake/fmt"; type Error int; func (e Error) String() string { return fmt.Sprint(int(e)) }`,
+	}
+	tests := []struct {
+		imp  Importer
+		src  string
+		errs []reflect.Type
+	}{
+		{imp, `package a; import "fake/fmt"; var s = fmt.Sprint(5)`, nil},
+		{imp, `package a; import ("fake/fmt"; "fake/os"); var s fmt.Stringer = os.Error(1)`, nil},
+		{imp, `package a; import f "fake/fmt"; var s string = f.Sprint(5)`, nil},
+		{
+			imp, `package a; import "fake/fmt"; var s int = fmt.Sprint(5)`,
+			[]reflect.Type{reflect.TypeOf(BadAssign{})},
+		},
+		{
+			imp, `package a; import "fake/net"`,
+			[]reflect.Type{reflect.TypeOf(BadImport{})},
+		},
+		{
+			nil, `package a; import "fake/fmt"`,
+			[]reflect.Type{reflect.TypeOf(BadImport{})},
+		},
+	}
+	for _, test := range tests {
+		want := make(map[reflect.Type]int)
+		for _, e := range test.errs {
+			want[e]++
+		}
+		fset := token.NewFileSet()
+		f := parseFile(NewParser(token.NewLexer(fset, "", test.src)))
+		var got []reflect.Type
+		if err := Check(fset, []*File{f}, test.imp, nil); err != nil {
+			for _, e := range err.(errors).All() {
+				t := reflect.TypeOf(e.(*LocatedError).Err)
+				want[t]--
+				if want[t] == 0 {
+					delete(want, t)
+				}
+				got = append(got, t)
+			}
+		}
+		if len(want) != 0 {
+			t.Errorf("Check(%v)=%v, want %v", test.src, got, test.errs)
+		}
+	}
+}
+
+func TestCheckPackage(t *testing.T) {
+	src := `package b; type T int; const C = 1; var v int; func F() {}`
+	fset := token.NewFileSet()
+	f := parseFile(NewParser(token.NewLexer(fset, "", src)))
+	p, err := CheckPackage("a/b", fset, []*File{f}, nil)
+	if err != nil {
+		t.Fatalf("CheckPackage(%s), unexpected error: %v", src, err)
+	}
+	if p.Name != "b" || p.Path != "a/b" {
+		t.Errorf("CheckPackage(%s)=%s %s, want b a/b", src, p.Name, p.Path)
+	}
+	for _, n := range []string{"T", "C", "F"} {
+		if p.Lookup(n) == nil {
+			t.Errorf("CheckPackage(%s).Lookup(%s)=nil", src, n)
+		}
+	}
+	if d := p.Lookup("v"); d != nil {
+		t.Errorf("CheckPackage(%s).Lookup(v)=%v, want nil", src, d)
+	}
+}
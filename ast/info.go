@@ -0,0 +1,149 @@
+package ast
+
+// An Info records the results of type checking. Only the non-nil maps
+// of an Info are filled in by Check.
+type Info struct {
+	// Defs maps each declaring identifier to the declaration that it
+	// defines. This includes package-level declarations, methods, imports
+	// with explicit names, parameters and results, method receivers,
+	// and local constants, types, and variables. The identifier of a type
+	// switch declaration is not included, because it declares a different
+	// variable in the scope of each case clause.
+	Defs map[*Identifier]Declaration
+
+	// Uses maps each identifier that refers to a declaration to the
+	// declaration that it denotes. The identifier may not remain in the
+	// tree after checking; for example, an identifier denoting a constant
+	// is replaced by the constant's value.
+	Uses map[*Identifier]Declaration
+
+	// Types maps each checked expression that denotes a value to its type.
+	// The keys include both the original expression and the expression that
+	// replaced it in the tree, if they differ. The identifiers in Defs that
+	// declare constants, variables, parameters, functions, and methods
+	// are also keys, mapped to the types given by DeclType.
+	//
+	// The types are recorded after untyped constants have been converted
+	// to their final types. An untyped constant whose type is not set by
+	// its context, like the operands of a comparison of constants, has
+	// its default type. The untyped nil has no type, and it is not recorded.
+	Types map[Expression]Type
+
+	// Values maps each constant expression that is checked to its folded
+	// value, one of the literal expression nodes. As with Types, the keys
+	// include both the original expression and its replacement.
+	Values map[Expression]Expression
+
+	// Scopes maps each node that introduces a scope to the Scope.
+	// The keys are *File, *FunctionDecl, *MethodDecl, *FunctionLiteral,
+	// *BlockStmt, *IfStmt, *ForStmt, *ExprSwitch, and *TypeSwitch nodes,
+	// and the case clauses of switch and select statements: *ExprCase,
+	// *TypeCase, and *CommCase.
+	Scopes map[Node]*Scope
+
+	// Exprs are the pairs of checked and replacement expressions.
+	// Their types are recorded in Types once checking is complete,
+	// after untyped constants have been converted to their final types.
+	exprs [][2]Expression
+
+	// Idents are the defining identifiers and their declarations.
+	// Their types are recorded in Types once checking is complete.
+	idents []identDecl
+}
+
+// An identDecl is a defining identifier and its declaration.
+type identDecl struct {
+	id *Identifier
+	d  Declaration
+}
+
+// Def records the declaration defined by an identifier.
+func (info *Info) def(id *Identifier, d Declaration) {
+	if info == nil || id == nil {
+		return
+	}
+	if info.Defs != nil {
+		info.Defs[id] = d
+	}
+	if info.Types != nil {
+		info.idents = append(info.idents, identDecl{id, d})
+	}
+}
+
+// Use records the declaration to which an identifier refers.
+func (info *Info) use(id *Identifier, d Declaration) {
+	if info != nil && info.Uses != nil && d != nil {
+		info.Uses[id] = d
+	}
+}
+
+// Expr records a checked expression and its replacement.
+func (info *Info) expr(x, y Expression) {
+	if info == nil || info.Types == nil && info.Values == nil {
+		return
+	}
+	info.exprs = append(info.exprs, [2]Expression{x, y})
+}
+
+// Scope records the Scope introduced by n.
+func (info *Info) scope(n Node, s *Scope) {
+	if info != nil && info.Scopes != nil {
+		info.Scopes[n] = s
+	}
+}
+
+// RecordTypes records the types and constant values of the checked
+// expressions in Types and Values.
+func (info *Info) recordTypes() {
+	if info == nil {
+		return
+	}
+	for _, e := range info.exprs {
+		x, y := e[0], e[1]
+		if info.Types != nil && !isType(y) {
+			if t := recordedType(y.Type()); t != nil {
+				info.Types[x] = t
+				info.Types[y] = t
+			}
+		}
+		if info.Values != nil && constOperand(y) {
+			info.Values[x] = y
+			info.Values[y] = y
+		}
+	}
+	for _, e := range info.idents {
+		if t := DeclType(e.d); t != nil {
+			info.Types[e.id] = t
+		}
+	}
+	info.exprs, info.idents = nil, nil
+}
+
+// RecordedType returns the type recorded in Types for an expression of
+// type t: the default type if t is untyped, or nil for the untyped nil.
+func recordedType(t Type) Type {
+	if t == nil {
+		return nil
+	}
+	t = defaultType(t)
+	if _, ok := t.(Untyped); ok {
+		return nil
+	}
+	return t
+}
+
+// CheckExpr checks an expression, recording it in the Info of the Scope.
+func checkExpr(syms *Scope, x Expression, iota int) (Expression, error) {
+	y, err := x.Check(syms, iota)
+	if err == nil && y != nil {
+		syms.info.expr(x, y)
+	}
+	return y, err
+}
+
+// Declare binds an identifier to its declaration in the Scope, recording
+// the definition in the Info of the Scope.
+func (s *Scope) declare(id *Identifier, d Declaration) error {
+	s.info.def(id, d)
+	return s.Bind(id.Name, d)
+}
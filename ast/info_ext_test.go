@@ -0,0 +1,84 @@
+package ast_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/velour/stop/ast"
+	"github.com/velour/stop/token"
+)
+
+// TestInfoExported walks an Info using only the exported API.
+func TestInfoExported(t *testing.T) {
+	const src = `package a
+		const c = 1
+		var v = c > 0
+		type T struct{ x int }
+		func f(p int, q ...string) (r int) {
+			i := 0
+			if i < 3 && c > 0 {
+				r = p
+			}
+			var t T
+			_ = t.x
+			return
+		}`
+	fset := token.NewFileSet()
+	f, err := ast.Parse(ast.NewParser(token.NewLexer(fset, "", src)))
+	if err != nil {
+		t.Fatalf("Parse(%s), unexpected error: %v", src, err)
+	}
+	info := &ast.Info{
+		Defs:  make(map[*ast.Identifier]ast.Declaration),
+		Uses:  make(map[*ast.Identifier]ast.Declaration),
+		Types: make(map[ast.Expression]ast.Type),
+	}
+	if err := ast.Check(fset, []*ast.File{f}, nil, info); err != nil {
+		t.Fatalf("Check(%s), unexpected error: %v", src, err)
+	}
+
+	for x, typ := range info.Types {
+		// Every recorded type must have a source representation
+		// and a location.
+		if typ.Source() == "" {
+			t.Errorf("Types[%s].Source() is empty", x.Source())
+		}
+		typ.Start()
+		typ.End()
+		typ.Loc()
+	}
+
+	defs := make(map[string]string)
+	for id, d := range info.Defs {
+		typ := ast.DeclType(d)
+		if typ == nil {
+			continue
+		}
+		if got := info.Types[id]; got == nil || !got.Identical(typ) {
+			t.Errorf("Types[%s]=%v, want %v", id.Name, got, typ)
+		}
+		defs[id.Name] = typ.Source()
+	}
+	wantDefs := map[string]string{
+		"c": "int",
+		"v": "bool",
+		"p": "int",
+		"q": "[]string",
+		"r": "int",
+		"i": "int",
+		"t": "T",
+	}
+	delete(defs, "f")
+	if !reflect.DeepEqual(defs, wantDefs) {
+		t.Errorf("DeclType of Defs=%v, want %v", defs, wantDefs)
+	}
+
+	for id, d := range info.Uses {
+		if id.Name != "i" {
+			continue
+		}
+		if typ := ast.DeclType(d); typ == nil || typ.Source() != "int" {
+			t.Errorf("DeclType(Uses[i])=%v, want int", typ)
+		}
+	}
+}
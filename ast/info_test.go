@@ -0,0 +1,193 @@
+package ast
+
+import (
+	"math/big"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/velour/stop/token"
+)
+
+func TestInfo(t *testing.T) {
+	const src = `package a
+		const c = 1 + 2
+		var x int8 = c
+		func f(p int) int {
+			y := p + c
+			if z := y; z > 0 {
+				return z
+			}
+			switch y {
+			case 1:
+			}
+			return y
+		}`
+	fset := token.NewFileSet()
+	f := parseFile(NewParser(token.NewLexer(fset, "", src)))
+	cSpec := f.Declarations[0].(*ConstSpec)
+	sum := cSpec.Values[0]
+	xSpec := f.Declarations[1].(*VarSpec)
+	fDecl := f.Declarations[2].(*FunctionDecl)
+
+	info := &Info{
+		Defs:   make(map[*Identifier]Declaration),
+		Uses:   make(map[*Identifier]Declaration),
+		Types:  make(map[Expression]Type),
+		Values: make(map[Expression]Expression),
+		Scopes: make(map[Node]*Scope),
+	}
+	if err := Check(fset, []*File{f}, nil, info); err != nil {
+		t.Fatalf("Check(%s), unexpected error: %v", src, err)
+	}
+
+	defs := make(map[string]Declaration)
+	for id, d := range info.Defs {
+		defs[id.Name] = d
+	}
+	var names []string
+	for n := range defs {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	if want := []string{"c", "f", "p", "x", "y", "z"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Defs names=%v, want %v", names, want)
+	}
+	if defs["f"] != fDecl {
+		t.Errorf("Defs[f]=%v, want the FunctionDecl", defs["f"])
+	}
+
+	uses := make(map[string]int)
+	for id, d := range info.Uses {
+		if d != defs[id.Name] && id.Name != "int" && id.Name != "int8" {
+			t.Errorf("Uses[%s]=%v, want %v", id.Name, d, defs[id.Name])
+		}
+		uses[id.Name]++
+	}
+	want := map[string]int{"c": 2, "int": 2, "int8": 1, "p": 1, "y": 3, "z": 2}
+	if !reflect.DeepEqual(uses, want) {
+		t.Errorf("Uses counts=%v, want %v", uses, want)
+	}
+
+	if v, ok := info.Values[sum].(*IntegerLiteral); !ok || v.Value.Cmp(big.NewInt(3)) != 0 {
+		t.Errorf("Values[1 + 2]=%v, want 3", info.Values[sum])
+	}
+	if tp := info.Types[xSpec.Values[0]]; tp == nil || !tp.Identical(int8Type) {
+		t.Errorf("Types[c]=%v, want int8", tp)
+	}
+	y := fDecl.Body.Statements[0].(*ShortVarDecl)
+	if tp := info.Types[y.Right[0]]; tp == nil || !tp.Identical(intType) {
+		t.Errorf("Types[p + c]=%v, want int", tp)
+	}
+
+	scopes := make(map[reflect.Type]int)
+	for n, s := range info.Scopes {
+		if s == nil {
+			t.Errorf("Scopes[%T]=nil", n)
+		}
+		scopes[reflect.TypeOf(n)]++
+	}
+	wantScopes := map[reflect.Type]int{
+		reflect.TypeOf(&File{}):         1,
+		reflect.TypeOf(&FunctionDecl{}): 1,
+		reflect.TypeOf(&IfStmt{}):       1,
+		reflect.TypeOf(&BlockStmt{}):    1,
+		reflect.TypeOf(&ExprSwitch{}):   1,
+		reflect.TypeOf(&ExprCase{}):     1,
+	}
+	if !reflect.DeepEqual(scopes, wantScopes) {
+		t.Errorf("Scopes=%v, want %v", scopes, wantScopes)
+	}
+	if s := info.Scopes[fDecl]; s == nil || s.Decls["p"] != defs["p"] {
+		t.Errorf("Scopes[f] does not bind p")
+	}
+}
+
+func TestInfoNil(t *testing.T) {
+	// Check records nothing in the nil maps of an Info.
+	src := `package a; const c = 1; func f() { if c > 0 {} }`
+	fset := token.NewFileSet()
+	f := parseFile(NewParser(token.NewLexer(fset, "", src)))
+	info := &Info{Uses: make(map[*Identifier]Declaration)}
+	if err := Check(fset, []*File{f}, nil, info); err != nil {
+		t.Fatalf("Check(%s), unexpected error: %v", src, err)
+	}
+	if len(info.Uses) != 1 || info.Defs != nil || info.Types != nil || info.Values != nil || info.Scopes != nil {
+		t.Errorf("Check(%s) info=%+v, want only a single Use", src, info)
+	}
+}
+
+func TestScopeInnermost(t *testing.T) {
+	const src = `package a
+		func f(p int) {
+			y := p
+			if z := y; z > 0 {
+				return
+			}
+			switch y {
+			case 1:
+				w := y
+				_ = w
+			}
+		}`
+	fset := token.NewFileSet()
+	f := parseFile(NewParser(token.NewLexer(fset, "", src)))
+	info := &Info{Scopes: make(map[Node]*Scope)}
+	if err := Check(fset, []*File{f}, nil, info); err != nil {
+		t.Fatalf("Check(%s), unexpected error: %v", src, err)
+	}
+	fDecl := f.Declarations[0].(*FunctionDecl)
+	ifStmt := fDecl.Body.Statements[1].(*IfStmt)
+	sw := fDecl.Body.Statements[2].(*ExprSwitch)
+	fsyms := info.Scopes[f]
+	psyms := fsyms.Parent
+
+	tests := []struct {
+		loc     token.Pos
+		scope   *Scope
+		visible []string
+		hidden  []string
+	}{
+		{fDecl.Body.Statements[0].Start(), info.Scopes[fDecl], []string{"f", "p", "y", "len"}, []string{"z", "w"}},
+		{ifStmt.Block.Statements[0].Start(), info.Scopes[&ifStmt.Block], []string{"p", "y", "z"}, []string{"w"}},
+		{sw.Cases[0].Statements[1].Start(), info.Scopes[&sw.Cases[0]], []string{"p", "y", "w"}, []string{"z"}},
+		{f.PackageName.Start(), fsyms, []string{"f"}, []string{"p"}},
+	}
+	for _, test := range tests {
+		if test.scope == nil {
+			t.Fatalf("Innermost(%s): scope is not recorded", fset.Location(test.loc))
+		}
+		s := psyms.Innermost(test.loc)
+		if s != test.scope {
+			t.Errorf("Innermost(%s)=%p, want %p", fset.Location(test.loc), s, test.scope)
+			continue
+		}
+		for _, n := range test.visible {
+			if s.Find(n) == nil {
+				t.Errorf("Innermost(%s).Find(%s)=nil", fset.Location(test.loc), n)
+			}
+		}
+		for _, n := range test.hidden {
+			if d := s.Find(n); d != nil {
+				t.Errorf("Innermost(%s).Find(%s)=%v, want nil", fset.Location(test.loc), n, d)
+			}
+		}
+	}
+
+	other := token.NewLexer(fset, "other.go", "package a").File().Pos(1)
+	if s := fsyms.Innermost(other); s != nil {
+		t.Errorf("Innermost(%s)=%p, want nil", fset.Location(other), s)
+	}
+	if ns := info.Scopes[fDecl].Names(); !reflect.DeepEqual(ns, []string{"p", "y"}) {
+		t.Errorf("Names()=%v, want [p y]", ns)
+	}
+	if d := info.Scopes[&ifStmt.Block].Lookup("z"); d != nil {
+		t.Errorf("Lookup(z)=%v in the if block, want nil", d)
+	}
+	if info.Scopes[ifStmt].Lookup("z") == nil {
+		t.Errorf("Lookup(z)=nil in the if statement")
+	}
+	if psyms.Parent != Universe || Universe.Lookup("len") == nil {
+		t.Errorf("package scope parent is not the Universe scope with len")
+	}
+}
@@ -2,7 +2,8 @@ package ast
 
 import (
 	"fmt"
-	"runtime"
+	"io"
+	"sort"
 	"strings"
 
 	"github.com/velour/stop/token"
@@ -14,47 +15,146 @@ type Parser struct {
 	lex *token.Lexer
 	tok token.Token
 
+	// Cur is the current token. Its trivia is only recorded
+	// if the parser reads from a token.Stream.
+	cur token.FullToken
+
+	// Stream, if non-nil, is the stream from which tokens are read,
+	// and tokens are all of the tokens read from it, in order.
+	stream *token.Stream
+	tokens []token.FullToken
+
+	// Trivia is the whitespace and comments preceding the current token.
+	trivia []token.Trivia
+
 	// ExprLevel is the nesting level of expressions.  When the level
 	// is greater or equal to zero, composite literals are allowed.
 	// It is negative when parsing the initialization statement of an
 	// if, switch, or for statement.
 	exprLevel int
 
+	// PrevEnd is the end position of the previous token.
+	prevEnd token.Pos
+
 	// Cmnts is a slice of all comments that are preceeding the
 	// current token without an intervening blank line.
 	cmnts []string
+
+	// Names interns the identifier names of the file, so that
+	// all identifiers with the same name share its text.
+	names map[string]string
+
+	// MaxErrors is the number of syntax errors after which Parse
+	// gives up. If MaxErrors is zero, DefaultMaxErrors is used.
+	// If it is negative, there is no limit.
+	MaxErrors int
+
+	// Errs are the syntax errors from which the parser has recovered.
+	errs errors
+
+	// TraceErrors is whether each SyntaxError records the chain of
+	// grammar productions that were being parsed when it occurred.
+	// Otherwise, the parser does not track the productions.
+	TraceErrors bool
+
+	// Trace, if non-nil, is written an indented trace of each grammar
+	// production entered and left by the parser, along with the
+	// current token and its location.
+	Trace io.Writer
+
+	// Productions is the chain of grammar productions being parsed,
+	// outermost first. It is only tracked when tracing.
+	productions []string
 }
 
+// DefaultMaxErrors is the default number of syntax errors after which
+// Parse gives up.
+const DefaultMaxErrors = 10
+
+// A bailout is panicked to abandon parsing once the limit
+// on the number of syntax errors is reached.
+type bailout struct{}
+
 // NewParser returns a new parser that parses from the given token.Lexer.
+// The lexer is put in recovering mode, so that the parser can continue
+// past malformed tokens.
 func NewParser(lex *token.Lexer) *Parser {
-	p := &Parser{lex: lex}
+	lex.Recover = true
+	return newParser(lex, nil)
+}
+
+// NewStreamParser returns a new parser that parses from a token.Stream
+// reading from the given token.Lexer. Unlike a parser returned by
+// NewParser, it keeps every token that it reads along with the token's
+// trivia, so that the source of the file and of each node can be
+// reproduced exactly; see Tokens and NodeTokens.
+func NewStreamParser(lex *token.Lexer) *Parser {
+	return newParser(lex, token.NewStream(lex))
+}
+
+func newParser(lex *token.Lexer, s *token.Stream) *Parser {
+	p := &Parser{
+		lex:    lex,
+		cur:    token.FullToken{Start: lex.Start, End: lex.End},
+		stream: s,
+		names:  make(map[string]string),
+	}
 	p.next()
 	return p
 }
 
-func (p *Parser) insertedSemicolon() bool {
-	return p.tok == token.Semicolon && p.lex.Text() != ";"
+// Tokens returns the tokens read by the parser, along with their trivia,
+// if the parser was returned by NewStreamParser. Once the parser has
+// read to the end of the file, the concatenation of the source of the
+// tokens is the source of the file.
+func (p *Parser) Tokens() []token.FullToken {
+	return p.tokens
+}
+
+// NodeTokens returns the tokens spanned by a node, along with their
+// trivia, if the parser that parsed the node was returned by
+// NewStreamParser. Otherwise, it returns nil.
+func (p *Parser) NodeTokens(n Node) []token.FullToken {
+	if len(p.tokens) == 0 {
+		return nil
+	}
+	f := p.lex.File()
+	start := f.Location(n.Start()).Rune
+	end := f.Location(n.End()).Rune
+	i := sort.Search(len(p.tokens), func(i int) bool { return tokenStart(p.tokens[i]).Rune >= start })
+	j := sort.Search(len(p.tokens), func(i int) bool { return tokenStart(p.tokens[i]).Rune >= end })
+	return p.tokens[i:j]
 }
 
-func (p *Parser) start() token.Location {
-	if p.insertedSemicolon() {
+// TokenStart returns the location of the start of a token.
+func tokenStart(t token.FullToken) token.Location {
+	if t.Inserted {
 		// Inserted semicolon starts at the end of the previous token.
-		return p.lex.End
+		return t.End
 	}
-	return p.lex.Start
+	return t.Start
 }
 
-func (p *Parser) end() token.Location { return p.lex.End }
+func (p *Parser) start() token.Pos {
+	return p.lex.File().Pos(p.startLocation().Rune)
+}
+
+// StartLocation returns the Location of the start of the current token.
+func (p *Parser) startLocation() token.Location {
+	return tokenStart(p.cur)
+}
+
+func (p *Parser) end() token.Pos { return p.lex.File().Pos(p.cur.End.Rune) }
 
 type span struct {
-	start, end token.Location
+	start, end token.Pos
 }
 
-func (s *span) Start() token.Location { return s.start }
-func (s *span) Loc() token.Location   { return s.start }
-func (s *span) End() token.Location   { return s.end }
+func (s *span) Start() token.Pos { return s.start }
+func (s *span) Loc() token.Pos   { return s.start }
+func (s *span) End() token.Pos   { return s.end }
 
-// Span returns a span for the start and end location of the current token.
+// Span returns a span for the start and end position of the current token.
 func (p *Parser) span() span {
 	return span{start: p.start(), end: p.end()}
 }
@@ -63,7 +163,20 @@ func (p *Parser) span() span {
 // a copy of the text so that it does not reference the lexer's source
 // string.
 func (p *Parser) text() string {
-	return string([]byte(p.lex.Text()))
+	return string([]byte(p.cur.Text))
+}
+
+// Name returns the text of the current token, interned in the names
+// of the file. Like the text returned by text, it does not reference
+// the lexer's source string.
+func (p *Parser) name() string {
+	text := p.cur.Text
+	if n, ok := p.names[text]; ok {
+		return n
+	}
+	n := string([]byte(text))
+	p.names[n] = n
+	return n
 }
 
 // Comments returns a new slice containing the comments
@@ -80,18 +193,33 @@ func (p *Parser) comments() []string {
 
 // Advances to the next non-whitespace, non-comment token.
 func (p *Parser) next() {
-	p.tok = p.lex.Next()
+	p.prevEnd = p.end()
+	// The trivia begins at the end of the current token.
+	line := p.cur.End.Line
+	if p.stream != nil {
+		p.trivia = append(p.trivia[:0], p.cur.Trailing...)
+		if p.cur.Token != token.EOF || len(p.tokens) == 0 {
+			p.cur = p.stream.Next()
+			p.tokens = append(p.tokens, p.cur)
+		}
+		p.trivia = append(p.trivia, p.cur.Leading...)
+	} else {
+		p.trivia = p.trivia[:0]
+		for p.cur = p.scan(); p.cur.Token == token.Whitespace || p.cur.Token == token.Comment; p.cur = p.scan() {
+			p.trivia = append(p.trivia, token.Trivia{Token: p.cur.Token, Text: p.cur.Text})
+		}
+	}
+	p.tok = p.cur.Token
+
 	cline := -1
-	for {
-		if cline < p.start().Line-1 {
+	for _, t := range p.trivia {
+		if cline < line-1 {
 			p.cmnts = p.cmnts[:0]
 		}
-		if p.tok != token.Whitespace && p.tok != token.Comment {
-			break
-		}
-		if p.tok == token.Comment {
-			text := p.text()
-			cline = p.end().Line
+		end := line + strings.Count(t.Text, "\n")
+		if t.Token == token.Comment {
+			text := string([]byte(t.Text))
+			cline = end
 			if strings.HasPrefix(text, "//") {
 				// Line comments end on the line following their
 				// text, so subtract one.
@@ -101,8 +229,31 @@ func (p *Parser) next() {
 			}
 			p.cmnts = append(p.cmnts, text)
 		}
-		p.tok = p.lex.Next()
+		line = end
+	}
+	if cline < line-1 {
+		p.cmnts = p.cmnts[:0]
+	}
+}
+
+// Scan returns the next token from the lexer, which may be trivia.
+// Like the tokens of a token.Stream, it has no trivia of its own.
+func (p *Parser) scan() token.FullToken {
+	tok := p.lex.Next()
+	t := token.FullToken{
+		Token:  tok,
+		Text:   p.lex.Text(),
+		Start:  p.lex.Start,
+		End:    p.lex.End,
+		Reason: p.lex.Reason,
+	}
+	if tok == token.Semicolon && t.Text != ";" {
+		// The text of an inserted semicolon
+		// is that of the preceding token.
+		t.Text = ""
+		t.Inserted = true
 	}
+	return t
 }
 
 // Expect panics with an unexpected token error if the current parser
@@ -113,19 +264,190 @@ func (p *Parser) expect(tok token.Token) {
 	}
 }
 
+// SyntaxError records a syntax error recovered from a panic. If the
+// recovered value is not a syntax error, syntaxError re-panics with it.
+// An error at the same location as the previous error is not recorded,
+// since it is likely caused by the previous error.
+func (p *Parser) syntaxError(r interface{}) {
+	err, ok := r.(*SyntaxError)
+	if !ok {
+		panic(r)
+	}
+	if n := len(p.errs); n > 0 && p.errs[n-1].(*SyntaxError).Start == err.Start {
+		return
+	}
+	p.errs = append(p.errs, err)
+}
+
+// TooManyErrors returns whether the parser has reached its limit
+// on the number of syntax errors.
+func (p *Parser) tooManyErrors() bool {
+	max := p.MaxErrors
+	if max == 0 {
+		max = DefaultMaxErrors
+	}
+	return max > 0 && len(p.errs) >= max
+}
+
+// Try calls f, returning true if it completes without a syntax error.
+// Otherwise, the syntax error is recorded, the parser is resynchronized
+// by calling sync with the location at which f was called, and false is
+// returned. If the limit on the number of syntax errors is reached,
+// try panics with a bailout instead of resynchronizing.
+func (p *Parser) try(f func(), sync func(*Parser, token.Pos)) (ok bool) {
+	start := p.start()
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		if _, ok := r.(bailout); ok {
+			panic(r)
+		}
+		p.syntaxError(r)
+		if p.tooManyErrors() {
+			panic(bailout{})
+		}
+		sync(p, start)
+	}()
+	f()
+	return true
+}
+
+// SyncStmt skips tokens until the parser is at the beginning of a
+// statement, or at the end of a statement list. Nested blocks are skipped
+// entirely. At least one token is skipped if the parser has not advanced
+// from the start location, so that parsing always makes progress,
+// even at a case or default outside of a switch or select. Only EOF
+// and a closing brace, which end every statement list, are never skipped.
+func syncStmt(p *Parser, start token.Pos) {
+	if p.start() == start && p.tok != token.EOF && p.tok != token.CloseBrace {
+		p.next()
+	}
+	depth := 0
+	for p.tok != token.EOF {
+		switch {
+		case p.tok == token.OpenBrace:
+			depth++
+		case p.tok == token.CloseBrace && depth > 0:
+			depth--
+		case depth > 0:
+			break
+		case endsStmtList(p.tok):
+			return
+		case p.tok == token.Semicolon:
+			p.next()
+			return
+		case startsStmt(p.tok):
+			return
+		}
+		p.next()
+	}
+}
+
+// EndsStmtList returns whether the token ends a list of statements.
+func endsStmtList(tok token.Token) bool {
+	switch tok {
+	case token.EOF, token.CloseBrace, token.Case, token.Default:
+		return true
+	}
+	return false
+}
+
+// SyncPackage skips tokens until the parser is at the beginning of a
+// top-level declaration or at the end of the file. Unlike syncDecl, it
+// does not skip a declaration keyword at the start location, because
+// parsing the package clause does not consume declaration keywords.
+func syncPackage(p *Parser, _ token.Pos) {
+	syncDecl(p, token.NoPos)
+}
+
+// EndsBlock returns whether the token ends the statements of a block.
+func endsBlock(tok token.Token) bool {
+	return tok == token.CloseBrace
+}
+
+// StartsStmt returns whether the token is a keyword that begins a statement.
+func startsStmt(tok token.Token) bool {
+	switch tok {
+	case token.Break, token.Const, token.Continue, token.Defer,
+		token.Fallthrough, token.For, token.Go, token.Goto, token.If,
+		token.Return, token.Select, token.Switch, token.Type, token.Var:
+		return true
+	}
+	return false
+}
+
+// SyncDecl skips tokens until the parser is at the beginning of a
+// top-level declaration or at the end of the file. At least one token
+// is skipped if the parser has not advanced from the start location,
+// so that parsing always makes progress.
+func syncDecl(p *Parser, start token.Pos) {
+	if p.start() == start && p.tok != token.EOF {
+		p.next()
+	}
+	for p.tok != token.EOF {
+		switch p.tok {
+		case token.Import, token.Const, token.Type, token.Var, token.Func:
+			return
+		}
+		p.next()
+	}
+}
+
+// Tracing returns whether the parser tracks the grammar productions
+// being parsed. Each production is entered with
+//
+//	if p.tracing() {
+//		defer p.enter("parseProduction").leave()
+//	}
+func (p *Parser) tracing() bool {
+	return p.TraceErrors || p.Trace != nil
+}
+
+// Enter records that parsing of a production has begun,
+// and it returns the parser so that leave may be deferred.
+func (p *Parser) enter(prod string) *Parser {
+	p.trace(prod)
+	p.productions = append(p.productions, prod)
+	return p
+}
+
+// Leave records that parsing of the most-recently entered production
+// has finished, either by returning or by panicking with an error.
+func (p *Parser) leave() {
+	n := len(p.productions) - 1
+	prod := p.productions[n]
+	p.productions = p.productions[:n]
+	p.trace("end " + prod)
+}
+
+// Trace writes a line of the trace, indented by the depth of the
+// current production, with the current token and its location.
+func (p *Parser) trace(s string) {
+	if p.Trace == nil {
+		return
+	}
+	indent := strings.Repeat(". ", len(p.productions))
+	fmt.Fprintf(p.Trace, "%s%s: %s %q at %s\n", indent, s, p.tok, p.cur.Text, p.startLocation())
+}
+
 // Error returns a syntax error.  The argument must be either a string
 // or a fmt.Stringer.  The syntax error states that the parse wanted
 // the string value of the argument, but got the current token instead.
+// If the parser is tracing errors, the error records the chain of
+// productions being parsed.
 func (p *Parser) err(want interface{}, orWant ...interface{}) error {
-	stack := make([]byte, 1024)
-	n := runtime.Stack(stack, false)
 	err := &SyntaxError{
 		Wanted: fmt.Sprintf("%s", want),
 		Got:    p.tok,
 		Text:   p.text(),
+		Reason: p.cur.Reason,
 		Start:  p.start(),
 		End:    p.end(),
-		Stack:  string(stack[:n]),
+	}
+	if p.TraceErrors {
+		err.Productions = append([]string(nil), p.productions...)
 	}
 	for _, w := range orWant {
 		err.Wanted += fmt.Sprintf(" or %s", w)
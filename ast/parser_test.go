@@ -1,12 +1,16 @@
 package ast
 
 import (
+	"bytes"
 	"go/parser"
 	stdtoken "go/token"
 	"math/big"
 	"reflect"
 	"regexp"
+	"strings"
 	"testing"
+	"time"
+	"unsafe"
 
 	"github.com/eaburns/eq"
 	"github.com/eaburns/pretty"
@@ -95,6 +99,9 @@ var specDeclarationTests = parserTests{
 	{`type T2 T1`, Declarations{&TypeSpec{Identifier: *id("T2"), Type: t1}}},
 	{`type T3 []T1`, Declarations{&TypeSpec{Identifier: *id("T3"), Type: &SliceType{Element: t1}}}},
 	{`type T4 T3`, Declarations{&TypeSpec{Identifier: *id("T4"), Type: t3}}},
+	{`type T5 = T1`, Declarations{&TypeSpec{Identifier: *id("T5"), Alias: true, Type: t1}}},
+	{`type T6 = []T1`, Declarations{&TypeSpec{Identifier: *id("T6"), Alias: true, Type: &SliceType{Element: t1}}}},
+	{`type T7 = List[T1]`, Declarations{&TypeSpec{Identifier: *id("T7"), Alias: true, Type: &Instance{Expression: typ("List"), TypeArguments: []Type{t1}}}}},
 	{
 		`type Lock interface {
 			Lock()
@@ -2170,6 +2177,218 @@ func TestParseTypeDecl(t *testing.T) {
 	}.run(t, func(p *Parser) Node { return parseDeclarations(p) })
 }
 
+func TestParseGenerics(t *testing.T) {
+	anyT := typ("any")
+	inst := func(x Expression, args ...Type) *Instance {
+		return &Instance{Expression: x, TypeArguments: args}
+	}
+	parserTests{
+		{
+			`type List[T any] struct{}`,
+			Declarations{&TypeSpec{
+				Identifier:     *id("List"),
+				TypeParameters: []TypeParameter{{Identifier: *id("T"), Constraint: anyT}},
+				Type:           &StructType{},
+			}},
+		},
+		{
+			`type Map[K comparable, V any,] []V`,
+			Declarations{&TypeSpec{
+				Identifier: *id("Map"),
+				TypeParameters: []TypeParameter{
+					{Identifier: *id("K"), Constraint: typ("comparable")},
+					{Identifier: *id("V"), Constraint: anyT},
+				},
+				Type: &SliceType{Element: typ("V")},
+			}},
+		},
+		{
+			`type Pair[K, V interface{ ~int | string }] int`,
+			Declarations{&TypeSpec{
+				Identifier: *id("Pair"),
+				TypeParameters: []TypeParameter{
+					{Identifier: *id("K"), Constraint: &InterfaceType{Methods: []Node{&Union{Terms: []Term{
+						{Tilde: true, Type: typ("int")},
+						{Type: typ("string")},
+					}}}}},
+					{Identifier: *id("V"), Constraint: &InterfaceType{Methods: []Node{&Union{Terms: []Term{
+						{Tilde: true, Type: typ("int")},
+						{Type: typ("string")},
+					}}}}},
+				},
+				Type: typ("int"),
+			}},
+		},
+		{
+			`type A [N]int`,
+			Declarations{&TypeSpec{Identifier: *id("A"), Type: &ArrayType{Size: id("N"), Element: typ("int")}}},
+		},
+		{
+			`type A [N+1]int`,
+			Declarations{&TypeSpec{
+				Identifier: *id("A"),
+				Type:       &ArrayType{Size: binOp(token.Plus, id("N"), intLit("1")), Element: typ("int")},
+			}},
+		},
+		{
+			`type A [pkg.N]int`,
+			Declarations{&TypeSpec{
+				Identifier: *id("A"),
+				Type:       &ArrayType{Size: sel(id("pkg"), id("N")), Element: typ("int")},
+			}},
+		},
+		{
+			`type A []int`,
+			Declarations{&TypeSpec{Identifier: *id("A"), Type: &SliceType{Element: typ("int")}}},
+		},
+		{`type A[T any, ] int`, Declarations{&TypeSpec{
+			Identifier:     *id("A"),
+			TypeParameters: []TypeParameter{{Identifier: *id("T"), Constraint: anyT}},
+			Type:           typ("int"),
+		}}},
+		{`type A[T any int`, parseError{"]"}},
+
+		// Type parameters with constraints beginning with * or (
+		// are distinguished from array lengths like go/parser.
+		{`type A[P *C] struct{}`, Declarations{&TypeSpec{
+			Identifier: *id("A"),
+			Type:       &ArrayType{Size: binOp(token.Star, id("P"), id("C")), Element: &StructType{}},
+		}}},
+		{`type A[P *C,] struct{}`, Declarations{&TypeSpec{
+			Identifier:     *id("A"),
+			TypeParameters: []TypeParameter{{Identifier: *id("P"), Constraint: &Star{Target: typ("C")}}},
+			Type:           &StructType{},
+		}}},
+		{`type A[P *int, Q any] struct{}`, Declarations{&TypeSpec{
+			Identifier: *id("A"),
+			TypeParameters: []TypeParameter{
+				{Identifier: *id("P"), Constraint: &Star{Target: typ("int")}},
+				{Identifier: *id("Q"), Constraint: anyT},
+			},
+			Type: &StructType{},
+		}}},
+		{`type A[P *[]int] struct{}`, Declarations{&TypeSpec{
+			Identifier:     *id("A"),
+			TypeParameters: []TypeParameter{{Identifier: *id("P"), Constraint: &Star{Target: &SliceType{Element: typ("int")}}}},
+			Type:           &StructType{},
+		}}},
+		{`type A[P *C | ~int | D] struct{}`, Declarations{&TypeSpec{
+			Identifier: *id("A"),
+			TypeParameters: []TypeParameter{{Identifier: *id("P"), Constraint: &Union{Terms: []Term{
+				{Type: &Star{Target: typ("C")}},
+				{Tilde: true, Type: typ("int")},
+				{Type: typ("D")},
+			}}}},
+			Type: &StructType{},
+		}}},
+		{`type A[P *C | []int] struct{}`, Declarations{&TypeSpec{
+			Identifier: *id("A"),
+			TypeParameters: []TypeParameter{{Identifier: *id("P"), Constraint: &Union{Terms: []Term{
+				{Type: &Star{Target: typ("C")}},
+				{Type: &SliceType{Element: typ("int")}},
+			}}}},
+			Type: &StructType{},
+		}}},
+		{`type A[P (C), Q any] struct{}`, Declarations{&TypeSpec{
+			Identifier: *id("A"),
+			TypeParameters: []TypeParameter{
+				{Identifier: *id("P"), Constraint: typ("C")},
+				{Identifier: *id("Q"), Constraint: anyT},
+			},
+			Type: &StructType{},
+		}}},
+		{`type A[P (C)] int`, Declarations{&TypeSpec{
+			Identifier: *id("A"),
+			Type:       &ArrayType{Size: call(id("P"), false, id("C")), Element: typ("int")},
+		}}},
+		{`type A[N * M | K + 1]int`, Declarations{&TypeSpec{
+			Identifier: *id("A"),
+			Type: &ArrayType{
+				Size:    binOp(token.Plus, binOp(token.Or, binOp(token.Star, id("N"), id("M")), id("K")), intLit("1")),
+				Element: typ("int"),
+			},
+		}}},
+		{`type A[N * 2 | ~int]int`, parseError{"operand"}},
+	}.run(t, func(p *Parser) Node { return parseDeclarations(p) })
+
+	parserTests{
+		{
+			`func F[T ~int | ~uint, U any](t T) U {}`,
+			Declarations{&FunctionDecl{
+				Identifier: *id("F"),
+				TypeParameters: []TypeParameter{
+					{Identifier: *id("T"), Constraint: &Union{Terms: []Term{
+						{Tilde: true, Type: typ("int")},
+						{Tilde: true, Type: typ("uint")},
+					}}},
+					{Identifier: *id("U"), Constraint: anyT},
+				},
+				Signature: Signature{
+					Parameters: []ParameterDecl{{Identifier: id("t"), Type: typ("T")}},
+					Results:    []ParameterDecl{{Type: typ("U")}},
+				},
+			}},
+		},
+		{
+			`func (l *List[T]) Len(x List[T], y []T, m Map[T, int]) {}`,
+			Declarations{&MethodDecl{
+				Receiver:               *id("l"),
+				Pointer:                true,
+				BaseTypeName:           *id("List"),
+				ReceiverTypeParameters: ids("T"),
+				Identifier:             *id("Len"),
+				Signature: Signature{Parameters: []ParameterDecl{
+					{Identifier: id("x"), Type: inst(typ("List"), typ("T"))},
+					{Identifier: id("y"), Type: &SliceType{Element: typ("T")}},
+					{Identifier: id("m"), Type: inst(typ("Map"), typ("T"), typ("int"))},
+				}},
+			}},
+		},
+		{
+			`func f(List[int], pkg.T[int]) {}`,
+			Declarations{&FunctionDecl{
+				Identifier: *id("f"),
+				Signature: Signature{Parameters: []ParameterDecl{
+					{Type: inst(typ("List"), typ("int"))},
+					{Type: inst(qtyp("pkg", "T"), typ("int"))},
+				}},
+			}},
+		},
+	}.run(t, func(p *Parser) Node { return parseTopLevelDecl(p) })
+
+	parserTests{
+		{`f[int]`, index(id("f"), id("int"))},
+		{`f[int, string]`, inst(id("f"), typ("int"), typ("string"))},
+		{`f[[]int]`, inst(id("f"), &SliceType{Element: typ("int")})},
+		{`f[int,](x)`, call(inst(id("f"), typ("int")), false, x)},
+		{`f[int, 5]`, parseError{"type"}},
+		{
+			`List[int]{1}`,
+			&CompositeLiteral{
+				LiteralType: inst(typ("List"), typ("int")),
+				Elements:    []Element{{Value: intLit("1")}},
+			},
+		},
+		{
+			`pkg.Map[string, int]{}`,
+			&CompositeLiteral{LiteralType: inst(qtyp("pkg", "Map"), typ("string"), typ("int"))},
+		},
+	}.run(t, func(p *Parser) Node { return parseExpr(p) })
+
+	parserTests{
+		{`interface{ ~int; String() string }`, &InterfaceType{Methods: []Node{
+			&Union{Terms: []Term{{Tilde: true, Type: typ("int")}}},
+			&Method{
+				Identifier: *id("String"),
+				Signature:  Signature{Results: []ParameterDecl{{Type: typ("string")}}},
+			},
+		}}},
+		{`interface{ fmt.Stringer | []byte }`, &InterfaceType{Methods: []Node{
+			&Union{Terms: []Term{{Type: qtyp("fmt", "Stringer")}, {Type: &SliceType{Element: typ("byte")}}}},
+		}}},
+	}.run(t, func(p *Parser) Node { return parseType(p) })
+}
+
 func TestParseType(t *testing.T) {
 	parserTests{
 		{`a`, typ("a")},
@@ -2969,7 +3188,15 @@ func TestParseIntegerLiteral(t *testing.T) {
 		{"1", intLit("1")},
 		{"010", intLit("8")},
 		{"0x10", intLit("16")},
-		{"08", parseError{"malformed.*integer"}},
+		{"0b101", intLit("5")},
+		{"0o17", intLit("15")},
+		{"0O17", intLit("15")},
+		{"0_17", intLit("15")},
+		{"1_000_000", intLit("1000000")},
+		{"0x_Ff", intLit("255")},
+		{"08", parseError{"invalid digit '8' in octal literal"}},
+		{"0b12", parseError{"invalid digit '2' in binary literal"}},
+		{"1__0", parseError{"'_' must separate successive digits"}},
 	}.run(t, func(p *Parser) Node { return parseExpr(p) })
 }
 
@@ -2981,6 +3208,13 @@ func TestParseFloatLiteral(t *testing.T) {
 		{"0.1000", floatLit("0.1")},
 		{"1e1", floatLit("10.0")},
 		{"1e-1", floatLit("0.1")},
+		{"09.5", floatLit("9.5")},
+		{"1_000.000_5", floatLit("1000.0005")},
+		{"0x1p-2", floatLit("0.25")},
+		{"0x1.8p1", floatLit("3.0")},
+		{"0X.8P0", floatLit("0.5")},
+		{"0x_1Fp+4", floatLit("496.0")},
+		{"0x1.8", parseError{"requires a 'p' exponent"}},
 	}.run(t, func(p *Parser) Node { return parseExpr(p) })
 }
 
@@ -2993,6 +3227,12 @@ func TestParseImaginaryLiteral(t *testing.T) {
 		{"0.1000i", imgLit("0.1")},
 		{"1e1i", imgLit("10.0")},
 		{"1e-1i", imgLit("0.1")},
+		{"011i", imgLit("11.0")},
+		{"0b11i", imgLit("3.0")},
+		{"0o11i", imgLit("9.0")},
+		{"0x11i", imgLit("17.0")},
+		{"0x1p-1i", imgLit("0.5")},
+		{"1_0i", imgLit("10.0")},
 	}.run(t, func(p *Parser) Node { return parseExpr(p) })
 }
 
@@ -3005,9 +3245,10 @@ func TestParseStringLiteral(t *testing.T) {
 		{`"\n\r\t\v\""`, strLit("\n\r\t\v\"")},
 
 		// Cannot have a newline in an interpreted string.
-		{"\x22\x0A\x22", parseError{"unexpected"}},
+		{"\x22\x0A\x22", parseError{"newline in string"}},
 		// Cannot escape a single quote in an interpreted string lit.
-		{`"\'""`, parseError{"unexpected.*'"}},
+		{`"\'""`, parseError{"invalid escape sequence.*'"}},
+		{`"\uD800"`, parseError{"invalid Unicode code point"}},
 
 		{"\x60\x60", strLit("")},
 		{"\x60\x5C\x60", strLit("\\")},
@@ -3031,19 +3272,224 @@ func TestParseRuneLiteral(t *testing.T) {
 		{`'\U000000FF'`, runeLit('\U000000FF')},
 		{`'\U0010FFFF'`, runeLit('\U0010FFFF')},
 
-		{`'\"'`, parseError{"unexpected.*\""}},
-		{`'\008'`, parseError{"unexpected"}},
-		{`'\U00110000'`, parseError{"malformed"}},
+		{`'\"'`, parseError{"invalid escape sequence.*\""}},
+		{`'\008'`, parseError{"invalid escape sequence.*8"}},
+		{`''`, parseError{"empty rune literal"}},
+		{`'\U00110000'`, parseError{"invalid Unicode code point"}},
 	}.run(t, func(p *Parser) Node { return parseExpr(p) })
 }
 
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		max int
+		// Errs are regexps matching each of the reported errors.
+		errs []string
+		// Decls are the names of the top-level declarations in
+		// the returned File. BadDecls are named "Bad".
+		decls []string
+	}{
+		{
+			src:   "package a; var x int",
+			decls: []string{"x"},
+		},
+		{
+			src:   "package a; var x = ; var y int",
+			errs:  []string{"1:19: expected operand"},
+			decls: []string{"x", "y"},
+		},
+		{
+			src:   "package a; var x = ); var y int",
+			errs:  []string{"1:19: expected operand"},
+			decls: []string{"Bad", "y"},
+		},
+		{
+			src: `package a
+				var x = )
+				func f() {
+					a := ]
+					b()
+					if { }
+					return
+				}
+				type T struct { x int ]
+				var y int`,
+			errs:  []string{"2:", "4:", "6:", "9:"},
+			decls: []string{"Bad", "f", "Bad", "y"},
+		},
+		{
+			src: `package a
+				func f() {
+					switch {
+					case 1:
+						x := )
+						y()
+					default:
+						z := ]
+					}
+				}`,
+			errs:  []string{"5:", "8:"},
+			decls: []string{"f"},
+		},
+		{
+			// The literal's unclosed { leaves the switch to end
+			// at its }, so the second case is outside of the switch.
+			src:   "package p\nfunc f() {\n\tswitch x {\n\tcase 1:\n\t\tc = v{v;}\n\tcase 2:\n\t}\n}\n",
+			errs:  []string{"5:9: expected }", "6:1: expected ;, got case", "8:0:"},
+			decls: []string{"f", "Bad"},
+		},
+		{
+			src:   "package a; func f() { case 1: x(); default: y() }; var z int",
+			errs:  []string{"1:22: expected ;, got case", "1:35: expected ;, got default"},
+			decls: []string{"f", "z"},
+		},
+		{
+			src:   "package a\nimport (\n\tx/y\"\n\t\"fmt\"\n)\nvar z int",
+			errs:  []string{"3:2: expected StringLiteral"},
+			decls: []string{"Bad", "z"},
+		},
+		{
+			src:   "package a; func f() { x := ) ",
+			errs:  []string{"expected operand", "expected }"},
+			decls: []string{"Bad"},
+		},
+		{
+			src:   "package a; var a = ); var b = ); var c = ); var d int",
+			max:   2,
+			errs:  []string{"1:19:", "1:30:"},
+			decls: []string{"Bad", "Bad"},
+		},
+		{
+			src:   "package a; var a = ); var b = ); var c = ); var d int",
+			max:   -1,
+			errs:  []string{"1:19:", "1:30:", "1:41:"},
+			decls: []string{"Bad", "Bad", "Bad", "d"},
+		},
+		{
+			src:   "var x int",
+			errs:  []string{"expected package"},
+			decls: []string{"Bad", "x"},
+		},
+		{
+			// The lexer recovers from malformed tokens.
+			src: `package a
+				var x = "a\zb"
+				var y = 09
+				var z = 'ab' + @
+				var w int`,
+			errs: []string{
+				`2:12: invalid escape sequence \[.*a.*zb.*\]`,
+				`3:12: invalid digit '9' in octal literal \[09\]`,
+				`4:12: more than one character in rune literal \['ab'\]`,
+			},
+			decls: []string{"Bad", "Bad", "Bad", "w"},
+		},
+	}
+	for _, test := range tests {
+		p := NewParser(token.NewLexer(token.NewFileSet(), "", test.src))
+		p.MaxErrors = test.max
+		f, err := Parse(p)
+		var errs []error
+		if err != nil {
+			errs = err.(errors).All()
+		}
+		if len(errs) != len(test.errs) {
+			t.Errorf("Parse(%q)=%v, want %d errors", test.src, err, len(test.errs))
+			continue
+		}
+		for i, re := range test.errs {
+			if !regexp.MustCompile(re).MatchString(errs[i].Error()) {
+				t.Errorf("Parse(%q) error %d=%v, want matching %s", test.src, i, errs[i], re)
+			}
+		}
+		var decls []string
+		for _, d := range f.Declarations {
+			switch d := d.(type) {
+			case *BadDecl:
+				decls = append(decls, "Bad")
+			case *FunctionDecl:
+				decls = append(decls, d.Name)
+			case *TypeSpec:
+				decls = append(decls, d.Name)
+			case *VarSpec:
+				decls = append(decls, d.Identifiers[0].Name)
+			}
+		}
+		if !reflect.DeepEqual(decls, test.decls) {
+			t.Errorf("Parse(%q) decls=%v, want %v", test.src, decls, test.decls)
+		}
+	}
+}
+
+func TestParseBadNodes(t *testing.T) {
+	src := "package a\nfunc f() {\n\tx := ]\n\tif x == {\n\t}\n}"
+	fset := token.NewFileSet()
+	f, err := Parse(NewParser(token.NewLexer(fset, "", src)))
+	if err == nil {
+		t.Fatalf("Parse(%q), expected an error", src)
+	}
+	stmts := f.Declarations[0].(*FunctionDecl).Body.Statements
+	if len(stmts) != 2 {
+		t.Fatalf("Parse(%q) got %d statements, want 2", src, len(stmts))
+	}
+	bad, ok := stmts[0].(*BadStmt)
+	if !ok {
+		t.Fatalf("Parse(%q) statement 0 is %T, want *BadStmt", src, stmts[0])
+	}
+	if s, e := fset.Location(bad.Start()).String(), fset.Location(bad.End()).String(); s != ":3:1" || e != ":3:7" {
+		t.Errorf("Parse(%q) BadStmt span=%s-%s, want :3:1-:3:7", src, s, e)
+	}
+	ifStmt, ok := stmts[1].(*IfStmt)
+	if !ok {
+		t.Fatalf("Parse(%q) statement 1 is %T, want *IfStmt", src, stmts[1])
+	}
+	cond, ok := ifStmt.Condition.(*BinaryOp)
+	if !ok {
+		t.Fatalf("Parse(%q) if condition is %T, want *BinaryOp", src, ifStmt.Condition)
+	}
+	x, ok := cond.Right.(*BadExpr)
+	if !ok {
+		t.Fatalf("Parse(%q) if condition operand is %T, want *BadExpr", src, cond.Right)
+	}
+	if s := fset.Location(x.Start()).String(); s != ":4:9" {
+		t.Errorf("Parse(%q) BadExpr start=%s, want :4:9", src, s)
+	}
+}
+
+// FuzzParse checks that Parse returns on arbitrary input, without
+// panicking and without getting stuck at a token that it cannot skip.
+func FuzzParse(f *testing.F) {
+	f.Add(test.Prog)
+	f.Add("package p\nfunc f() {\n\tswitch x {\n\tcase 1:\n\t\tc = v{v;}\n\tcase 2:\n\t}\n}\n")
+	f.Add("package p\nfunc f() {\n\tselect {\n\tcase <-c:\n\t\tx := T{\n\tdefault:\n\t}\n}\n")
+	f.Add("package p; func f() { case 1: default: }")
+	f.Add("package p; type T struct { x int ]; var x = )")
+	f.Fuzz(func(t *testing.T, src string) {
+		done := make(chan *File)
+		go func() {
+			p := NewParser(token.NewLexer(token.NewFileSet(), "", src))
+			p.MaxErrors = -1
+			f, _ := Parse(p)
+			done <- f
+		}()
+		select {
+		case f := <-done:
+			if f == nil {
+				t.Errorf("Parse(%q) returned a nil File", src)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("Parse(%q) made no progress", src)
+		}
+	})
+}
+
 type parserTest struct {
 	text string
 	node Node
 }
 
 func (test parserTest) run(t *testing.T, production func(*Parser) Node) {
-	n, err := parse(NewParser(token.NewLexer("", test.text)), production)
+	n, err := parse(NewParser(token.NewLexer(token.NewFileSet(), "", test.text)), production)
 	if pe, ok := test.node.(parseError); ok {
 		if err == nil {
 			t.Errorf("parse(%s): expected error matching %s, got\n%s", test.text, pe.re, pretty.String(n))
@@ -3069,22 +3515,21 @@ func parse(p *Parser, production func(*Parser) Node) (n Node, err error) {
 		switch e := r.(type) {
 		case *SyntaxError:
 			err = e
-		case *MalformedLiteral:
-			err = e
 		default:
 			panic(r)
 		}
 
 	}()
 	n = production(p)
-	return n, err
+	// Errors within statement lists are recovered by the parser.
+	return n, p.errs.ErrorOrNil()
 }
 
 // A special AST Node, denoting that a test expects to have a parse error.
 type parseError struct{ re string }
 
-func (p parseError) Start() token.Location { panic("unimplemented") }
-func (p parseError) End() token.Location   { panic("unimplemented") }
+func (p parseError) Start() token.Pos { panic("unimplemented") }
+func (p parseError) End() token.Pos   { panic("unimplemented") }
 
 type parserTests []parserTest
 
@@ -3103,7 +3548,7 @@ type commentTests []struct {
 
 func (tests commentTests) run(t *testing.T) {
 	for i, test := range tests {
-		p := NewParser(token.NewLexer("", test.src))
+		p := NewParser(token.NewLexer(token.NewFileSet(), "", test.src))
 		n := 0
 		for p.tok != token.EOF {
 			if p.tok != token.Identifier {
@@ -3130,6 +3575,65 @@ func (tests commentTests) run(t *testing.T) {
 	}
 }
 
+func TestSyntaxErrorProductions(t *testing.T) {
+	const src = "package a\nvar x = [}"
+	want := []string{
+		"parseFile", "parseTopLevelDecl", "parseDeclarations", "parseVarDecl",
+		"parseVarSpec", "parseExpressionList", "parseExpr", "parseExpression",
+		"parseBinaryExpr", "parseUnaryExpr", "parsePrimaryExpr", "parseOperand",
+		"parseArrayOrSliceType", "parseExpr", "parseExpression", "parseBinaryExpr",
+		"parseUnaryExpr", "parsePrimaryExpr", "parseOperand", "parseBadExpr",
+	}
+	for _, trace := range []bool{false, true} {
+		p := NewParser(token.NewLexer(token.NewFileSet(), "", src))
+		p.TraceErrors = trace
+		_, err := Parse(p)
+		es := err.(errors).All()
+		if len(es) != 1 {
+			t.Fatalf("Parse(%q) got errors %v, wanted one error", src, es)
+		}
+		got := es[0].(*LocatedError).Err.(*SyntaxError).Productions
+		if !trace && got != nil {
+			t.Errorf("without tracing, got productions %v, wanted nil", got)
+		}
+		if trace && !reflect.DeepEqual(got, want) {
+			t.Errorf("got productions %v, wanted %v", got, want)
+		}
+		if len(p.productions) != 0 {
+			t.Errorf("after parsing, got productions %v, wanted none", p.productions)
+		}
+	}
+}
+
+func TestParserTrace(t *testing.T) {
+	const src = "package a\nvar x = y"
+	const want = `parseFile: package "package" at a.go:1:0
+. parseIdentifier: Identifier "a" at a.go:1:8
+. end parseIdentifier: ; "" at a.go:1:9
+. parseTopLevelDecl: var "var" at a.go:2:0
+. . parseDeclarations: var "var" at a.go:2:0
+. . . parseVarDecl: var "var" at a.go:2:0
+. . . . parseVarSpec: Identifier "x" at a.go:2:4
+. . . . . parseIdentifierList: Identifier "x" at a.go:2:4
+. . . . . . parseIdentifier: Identifier "x" at a.go:2:4
+. . . . . . end parseIdentifier: = "=" at a.go:2:6
+. . . . . end parseIdentifierList: = "=" at a.go:2:6
+. . . . . parseExpressionList: Identifier "y" at a.go:2:8
+`
+	var b bytes.Buffer
+	p := NewParser(token.NewLexer(token.NewFileSet(), "a.go", src))
+	p.Trace = &b
+	if _, err := Parse(p); err != nil {
+		t.Fatalf("Parse(%q) unexpected error: %s", src, err)
+	}
+	if got := b.String(); !strings.HasPrefix(got, want) || !strings.HasSuffix(got, "end parseFile: EOF \"\" at a.go:2:9\n") {
+		t.Errorf("got trace:\n%s\nwanted a trace beginning with:\n%s", got, want)
+	}
+	if p.TraceErrors {
+		t.Errorf("TraceErrors is set by Trace")
+	}
+}
+
 func TestComments(t *testing.T) {
 	tests := commentTests{
 		{`a`, [][]string{{}}},
@@ -3147,9 +3651,77 @@ func TestComments(t *testing.T) {
 	tests.run(t)
 }
 
+func TestParseInternsNames(t *testing.T) {
+	src := "package x; var x = x + y.x"
+	f, err := Parse(NewParser(token.NewLexer(token.NewFileSet(), "", src)))
+	if err != nil {
+		t.Fatalf("Parse(%q) unexpected error: %s", src, err)
+	}
+	v := f.Declarations[0].(*VarSpec)
+	sum := v.Values[0].(*BinaryOp)
+	sel := sum.Right.(*Selector)
+	names := []string{f.PackageName.Name, v.Identifiers[0].Name, sum.Left.(*Identifier).Name, sel.Name}
+	for _, n := range names {
+		if n != "x" || unsafe.StringData(n) != unsafe.StringData(names[0]) {
+			t.Errorf("name %q does not share storage with %q", n, names[0])
+		}
+	}
+	if unsafe.StringData(names[0]) == unsafe.StringData(src[8:]) {
+		t.Errorf("name %q references the source", names[0])
+	}
+}
+
+func TestStreamParser(t *testing.T) {
+	for _, src := range []string{"", "package a", test.Prog} {
+		want, _ := Parse(NewParser(token.NewLexer(token.NewFileSet(), "", src)))
+		p := NewStreamParser(token.NewLexer(token.NewFileSet(), "", src))
+		got, _ := Parse(p)
+		if !eq.Deep(got, want) {
+			t.Errorf("Parse(%q) got %s, wanted %s", src, pretty.String(got), pretty.String(want))
+		}
+		var s []string
+		for _, tok := range p.Tokens() {
+			s = append(s, tok.Source())
+		}
+		if s := strings.Join(s, ""); s != src {
+			t.Errorf("Parse(%q) tokens have source %q", src, s)
+		}
+	}
+}
+
+func TestNodeTokens(t *testing.T) {
+	const src = "package a\n\n// Doc.\nvar x = f( /* one */ 1, // two\n\t2)\n"
+	p := NewStreamParser(token.NewLexer(token.NewFileSet(), "", src))
+	f, err := Parse(p)
+	if err != nil {
+		t.Fatalf("Parse(%q) unexpected error: %s", src, err)
+	}
+	tests := []struct {
+		node Node
+		want string
+	}{
+		{f.Declarations[0].(*VarSpec).Values[0], "f( /* one */ 1, // two\n\t2)"},
+		{f.Declarations[0].(*VarSpec).Values[0].(*Call).Arguments[1], "\t2"},
+		{&f.Declarations[0].(*VarSpec).Identifiers[0], "x "},
+		{f.Declarations[0], "x = f( /* one */ 1, // two\n\t2)"},
+	}
+	for _, test := range tests {
+		var s []string
+		for _, tok := range p.NodeTokens(test.node) {
+			s = append(s, tok.Source())
+		}
+		if got := strings.Join(s, ""); got != test.want {
+			t.Errorf("NodeTokens(%s) got %q, wanted %q", pretty.String(test.node), got, test.want)
+		}
+	}
+	if toks := NewParser(token.NewLexer(token.NewFileSet(), "", src)).NodeTokens(f); toks != nil {
+		t.Errorf("NodeTokens without a stream got %v, wanted nil", toks)
+	}
+}
+
 func BenchmarkParser(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		p := NewParser(token.NewLexer("", test.Prog))
+		p := NewParser(token.NewLexer(token.NewFileSet(), "", test.Prog))
 		_, err := Parse(p)
 		if err != nil {
 			b.Fatalf("parse error: %s", err)
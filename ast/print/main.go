@@ -3,10 +3,13 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"flag"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"strings"
 
 	"github.com/eaburns/pp"
 	"github.com/eaburns/pretty"
@@ -15,29 +18,31 @@ import (
 )
 
 var (
-	gv = flag.Bool("gv", false, "displays the tree using graphviz+postscript+gv")
-	v  = flag.Bool("v", false, "display verbose parse errors")
+	gv    = flag.Bool("gv", false, "displays the tree using graphviz+postscript+gv")
+	v     = flag.Bool("v", false, "display verbose parse errors")
+	trace = flag.Bool("trace", false, "writes a trace of the parsed grammar productions to standard error")
+	color = flag.Bool("color", false, "colors the parse errors using ANSI escape sequences")
 )
 
 func main() {
 	flag.Parse()
 
-	in, err := os.Open(flag.Arg(0))
+	path := flag.Arg(0)
+	src, err := ioutil.ReadFile(path)
 	if err != nil {
-		die(err)
+		die(err, nil)
 	}
-	defer in.Close()
+	sources := map[string]string{path: string(src)}
 
-	src, err := ioutil.ReadAll(in)
-	if err != nil {
-		die(err)
-	}
-
-	l := token.NewLexer(in.Name(), string(src))
+	l := token.NewBytesLexer(token.NewFileSet(), path, src)
 	p := ast.NewParser(l)
+	p.TraceErrors = *v
+	if *trace {
+		p.Trace = os.Stderr
+	}
 	root, err := ast.Parse(p)
 	if err != nil {
-		die(err)
+		die(err, sources)
 	}
 
 	if *gv {
@@ -45,7 +50,7 @@ func main() {
 	} else {
 		out := bufio.NewWriter(os.Stdout)
 		if err = pretty.Fprint(out, root); err != nil {
-			die(err)
+			die(err, nil)
 		}
 		out.Flush()
 	}
@@ -54,7 +59,7 @@ func main() {
 func dot(root ast.Node) {
 	tmp, err := ioutil.TempFile("", "stop-dot-")
 	if err != nil {
-		die(err)
+		die(err, nil)
 	}
 	ps := tmp.Name()
 	tmp.Close()
@@ -63,27 +68,41 @@ func dot(root ast.Node) {
 	dotCmd := exec.Command("dot", "-o"+ps, "-Tps")
 	out, err := dotCmd.StdinPipe()
 	if err != nil {
-		die(err)
+		die(err, nil)
 	}
 	go func() {
 		if err := pp.Dot(out, root); err != nil {
-			die(err)
+			die(err, nil)
 		}
 		out.Close()
 	}()
 	if err := dotCmd.Run(); err != nil {
-		die(err)
+		die(err, nil)
 	}
 	if err := exec.Command("gv", ps).Run(); err != nil {
-		die(err)
+		die(err, nil)
 	}
 }
 
-func die(err error) {
-	str := err.Error()
-	if se, ok := err.(*ast.SyntaxError); *v && ok {
-		str += "\n" + se.Stack
+// Die reports err and exits. Source lines are shown for the errors
+// that are located in the files of sources.
+func die(err error, sources map[string]string) {
+	errs := []error{err}
+	if es, ok := err.(interface {
+		All() []error
+	}); ok {
+		errs = es.All()
+	}
+	r := ast.Renderer{Sources: sources, Color: *color}
+	for _, e := range errs {
+		if rerr := r.Render(os.Stdout, e); rerr != nil {
+			fmt.Fprintf(os.Stderr, "%s\nfailed to render error: %s\n", e, rerr)
+			os.Exit(1)
+		}
+		var se *ast.SyntaxError
+		if *v && errors.As(e, &se) {
+			os.Stdout.WriteString("\t" + strings.Join(se.Productions, " > ") + "\n")
+		}
 	}
-	os.Stdout.WriteString(str + "\n")
 	os.Exit(1)
 }
@@ -117,6 +117,31 @@ func (e *Index) Source() string {
 	return e.Expression.Source() + "[" + e.Index.Source() + "]"
 }
 
+func (e *Instance) Source() string {
+	s := e.Expression.Source() + "["
+	for i, t := range e.TypeArguments {
+		if i != 0 {
+			s += ", "
+		}
+		s += t.Source()
+	}
+	return s + "]"
+}
+
+func (e *Union) Source() string {
+	s := ""
+	for i, t := range e.Terms {
+		if i != 0 {
+			s += " | "
+		}
+		if t.Tilde {
+			s += "~"
+		}
+		s += t.Type.Source()
+	}
+	return s
+}
+
 func (e *Slice) Source() string {
 	s := e.Expression.Source() + "["
 	if e.Low != nil {
@@ -224,3 +249,5 @@ func (e *BoolLiteral) Source() string {
 }
 
 func (e *NilLiteral) Source() string { return "nil" }
+
+func (e *BadExpr) Source() string { return "BadExpr" }
@@ -82,7 +82,7 @@ func TestExpressionSource(t *testing.T) {
 		{`interface{ x(); y(int)bool }{}`, `interface{…}{}`},
 	}
 	for _, test := range tests {
-		l := token.NewLexer("", test.expr)
+		l := token.NewLexer(token.NewFileSet(), "", test.expr)
 		p := NewParser(l)
 		e := parseExpr(p)
 		got := e.Source()
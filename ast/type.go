@@ -25,9 +25,9 @@ const (
 // An Untyped is a Type that representes an untyped constant.
 type Untyped ConstKind
 
-func (Untyped) Start() token.Location { panic("unimplemented") }
-func (Untyped) End() token.Location   { panic("unimplemented") }
-func (Untyped) Loc() token.Location   { panic("unimplemented") }
+func (Untyped) Start() token.Pos      { panic("unimplemented") }
+func (Untyped) End() token.Pos        { panic("unimplemented") }
+func (Untyped) Loc() token.Pos        { panic("unimplemented") }
 func (Untyped) Identical(t Type) bool { return false }
 func (n Untyped) Underlying() Type    { return n }
 func (n Untyped) Type() Type          { return n }
@@ -72,6 +72,38 @@ func IsBool(t Type) bool {
 
 }
 
+// IsString returns whether the type is a string type.
+func IsString(t Type) bool {
+	switch u := t.Underlying().(type) {
+	case Untyped:
+		return u == Untyped(StringConst)
+	case *TypeName:
+		return u.decl == String
+	}
+	return false
+}
+
+// DefaultType returns the default type of an untyped constant type: the type
+// to which an untyped constant is converted when assigned to a variable
+// declared without a type. All other types are returned unchanged.
+func defaultType(t Type) Type {
+	switch t {
+	case Untyped(RuneConst):
+		return runeType
+	case Untyped(IntegerConst):
+		return intType
+	case Untyped(FloatConst):
+		return float64Type
+	case Untyped(ComplexConst):
+		return complex128Type
+	case Untyped(StringConst):
+		return stringType
+	case Untyped(BoolConst):
+		return boolType
+	}
+	return t
+}
+
 // IsAssignable returns whether an expression is assignable to a variable of a given type.
 //	A value x is assignable to a variable of type T ("x is assignable to T") in any of these cases:
 //	x's type is identical to T.
@@ -82,38 +114,360 @@ func IsBool(t Type) bool {
 //	x is an untyped constant representable by a value of type T.
 func IsAssignable(x Expression, t Type) bool {
 	xt := x.Type()
-	_, xtIsNamed := xt.(*TypeName)
-	_, tIsNamed := t.(*TypeName)
 	_, xIsNil := x.(*NilLiteral)
 	_, xIsUntyped := xt.(Untyped)
+
+	switch {
+	case xIsNil:
+		return Nilable(t)
+
+	case xIsUntyped && constOperand(x) && typeParam(t) != nil:
+		// An untyped constant is assignable to a type parameter
+		// if it is representable by every type in its type set.
+		iface := typeParam(t).iface
+		if !iface.restricted || len(iface.terms) == 0 {
+			return false
+		}
+		for _, term := range iface.terms {
+			if !IsRepresentable(x, term.Type) {
+				return false
+			}
+		}
+		return true
+
+	case xIsUntyped && constOperand(x):
+		if _, ok := t.Underlying().(*InterfaceType); ok {
+			// An untyped constant assigned to an interface
+			// is first converted to its default type.
+			return assignableTypes(defaultType(xt), t)
+		}
+		return IsRepresentable(x, t)
+	}
+	return assignableTypes(xt, t)
+}
+
+// AssignableTypes returns whether a value of type xt is assignable to a variable
+// of type t. It implements the cases of IsAssignable that depend only on the
+// type of the value, not on the value itself.
+func assignableTypes(xt, t Type) bool {
+	_, xtIsNamed := xt.(*TypeName)
+	_, tIsNamed := t.(*TypeName)
 	xch, xtIsChan := xt.(*ChannelType)
 	tch, tIsChan := t.(*ChannelType)
+	iface, tIsIface := t.Underlying().(*InterfaceType)
+
+	if typeParam(xt) != nil || typeParam(t) != nil {
+		// The underlying type of a type parameter is the interface
+		// of its constraint, but a value of a type parameter is only
+		// assignable to an identical type or an interface that it
+		// implements.
+		return xt.Identical(t) || typeParam(t) == nil && tIsIface && Implements(xt, iface)
+	}
 
 	switch {
 	case xt.Identical(t):
 		return true
 
-	case xt.Underlying().Identical(t.Underlying()) && (!xtIsNamed || !tIsNamed):
+	case xt == Untyped(BoolConst) && IsBool(t):
+		// The untyped boolean result of a comparison or of a
+		// comma-ok expression.
 		return true
 
-	// BUG(eaburns): If t is an interface and x implements t: return true
-
-	case xtIsChan && xch.Send && xch.Receive && tIsChan && xch.Element.Identical(tch.Element) && (!xtIsNamed || !tIsNamed):
+	case xt.Underlying().Identical(t.Underlying()) && (!xtIsNamed || !tIsNamed):
 		return true
 
-	case xIsNil && Nilable(t):
+	case tIsIface && Implements(defaultType(xt), iface):
 		return true
 
-	case xIsUntyped && IsRepresentable(x, t):
+	case xtIsChan && xch.Send && xch.Receive && tIsChan && xch.Element.Identical(tch.Element) && (!xtIsNamed || !tIsNamed):
 		return true
 	}
 
 	return false
 }
 
+// ErrorInterface is the underlying type of the predeclared type error.
+var errorInterface = func() *InterfaceType {
+	m := &Method{
+		Identifier: Identifier{Name: "Error"},
+		Signature:  Signature{Results: []ParameterDecl{{Type: stringType}}},
+	}
+	return &InterfaceType{Methods: []Node{m}, methodSet: []*Method{m}}
+}()
+
+// AnyInterface is the type denoted by the predeclared alias any.
+var anyInterface = &InterfaceType{}
+
+// ComparableInterface is the underlying type of the predeclared type
+// comparable, the interface of all comparable types.
+var comparableInterface = &InterfaceType{comparable: true}
+
+// Implements returns whether the type t implements the interface iface: whether
+// the method set of t contains all of the methods of iface.
+//
+// This function expects the methodSet field of InterfaceTypes to be populated by
+// having called their Check methods.
+func Implements(t Type, iface *InterfaceType) bool {
+	missing, wrong := missingMethods(t, iface)
+	return len(missing) == 0 && len(wrong) == 0
+}
+
+// MissingMethods returns the methods of iface that are not in the method set
+// of t, and the methods of iface for which t has a method of the same name,
+// but with a different signature.
+func missingMethods(t Type, iface *InterfaceType) (missing, wrong []*Method) {
+	ms := methodSet(t)
+	for _, m := range iface.methodSet {
+		switch sig, ok := ms[m.Name]; {
+		case !ok:
+			missing = append(missing, m)
+		case sig != nil && !sig.identical(&m.Signature):
+			wrong = append(wrong, m)
+		}
+	}
+	return missing, wrong
+}
+
+// MethodSet returns the method set of a type, mapping the name of each method
+// to its signature. The signature is nil if the method's declaration has
+// errors, which are reported when the method itself is checked.
+//
+// The method set of an interface type is its interface. The method set of a
+// named type T consists of all methods declared with receiver type T, and the
+// method set of the pointer type *T consists of all methods declared with
+// receiver type *T or T. Methods of embedded fields are promoted following the
+// rules for selectors: a name at a shallower depth of embedding hides the same
+// name at deeper depths, and a name that is declared more than once at the
+// shallowest depth is ambiguous and is not in the method set. Methods of an
+// embedded field T are promoted to both S and *S, and methods with receiver
+// *T are only promoted to *S, unless the field is itself a pointer *T.
+func methodSet(t Type) map[string]*Signature {
+	ms := make(map[string]*Signature)
+	ptr := false
+	if s, ok := t.(*Star); ok {
+		t, ptr = s.Target.(Type), true
+	}
+	if iface, ok := t.Underlying().(*InterfaceType); ok {
+		if !ptr {
+			for _, m := range iface.methodSet {
+				ms[m.Name] = &m.Signature
+			}
+		}
+		return ms
+	}
+
+	type embedded struct {
+		Type
+		// Ptr is true if the methods with pointer receivers are
+		// promoted from the embedded type.
+		ptr bool
+	}
+	seen := make(map[string]bool)
+	visited := make(map[*TypeSpec]bool)
+	for depth := []embedded{{t, ptr}}; len(depth) > 0; {
+		var next []embedded
+		count := make(map[string]int)
+		sigs := make(map[string]*Signature)
+		for _, e := range depth {
+			// The signatures of the methods of an instance of a generic
+			// type have its type arguments substituted for the type
+			// parameters of the type.
+			var d *TypeSpec
+			var tm map[*TypeParameter]Type
+			switch n := e.Type.(type) {
+			case *TypeName:
+				if n.decl == nil {
+					// The name is undeclared; it has already been reported.
+					continue
+				}
+				d, _ = n.decl.(*TypeSpec)
+			case *Instance:
+				d, tm = n.decl, n.bindings()
+			}
+			if d != nil {
+				if visited[d] {
+					continue
+				}
+				visited[d] = true
+				for _, m := range d.methods {
+					count[m.Name]++
+					if m.Pointer && !e.ptr {
+						continue
+					}
+					sigs[m.Name] = nil
+					if m.checkSignature() != nil {
+						continue
+					}
+					sigs[m.Name] = &m.Signature
+					if tm != nil {
+						sig := substSignature(&m.Signature, tm)
+						sigs[m.Name] = &sig
+					}
+				}
+			}
+			switch u := e.Underlying().(type) {
+			case *StructType:
+				for i := range u.Fields {
+					f := &u.Fields[i]
+					count[f.name()]++
+					if f.Identifier != nil {
+						continue
+					}
+					if s, ok := f.Type.(*Star); ok {
+						next = append(next, embedded{s.Target.(Type), true})
+					} else {
+						next = append(next, embedded{f.Type, e.ptr})
+					}
+				}
+			case *InterfaceType:
+				for _, m := range u.methodSet {
+					count[m.Name]++
+					sigs[m.Name] = &m.Signature
+				}
+			}
+		}
+		for name, c := range count {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			if sig, ok := sigs[name]; ok && c == 1 {
+				ms[name] = sig
+			}
+		}
+		depth = next
+	}
+	return ms
+}
+
+// A selection is a field or method found by looking up a name in a type.
+type selection struct {
+	// Field is the selected field, or nil if a method is selected.
+	field *FieldDecl
+
+	// Method is the declaration of the selected method, or nil if
+	// a field or the method of an interface is selected.
+	method *MethodDecl
+
+	// Sig is the signature of the selected method, with the type
+	// arguments of an instance substituted for its type parameters.
+	// It is nil for a field, or if the method's declaration has errors.
+	sig *Signature
+
+	// Indirect is whether a pointer is dereferenced to reach the
+	// field or method: either the type itself or an embedded field
+	// along the way is a pointer.
+	indirect bool
+}
+
+// Lookup returns the field or method with the given name in the type t.
+// As with methodSet, a name at a shallower depth of embedding hides the
+// same name at deeper depths. If the name is declared more than once at
+// the shallowest depth, it is ambiguous, and nil is returned. The result
+// is also nil if there is no field or method with the name.
+//
+// If t is a pointer to a named type, the field or method is looked up in
+// the named type. If t is itself a named pointer type, only fields are
+// selected, and the methods of an interface are not selected through a
+// pointer to the interface.
+func lookup(t Type, name string) (sel *selection, ambiguous bool) {
+	indirect, onlyFields := false, false
+	if s, ok := t.Underlying().(*Star); ok {
+		_, onlyFields = t.(*TypeName)
+		t, indirect = s.Target.(Type), true
+		if _, ok := t.Underlying().(*InterfaceType); ok {
+			return nil, false
+		}
+	}
+
+	type embedded struct {
+		Type
+		indirect bool
+	}
+	visited := make(map[*TypeSpec]bool)
+	for depth := []embedded{{t, indirect}}; len(depth) > 0; {
+		var next []embedded
+		n := 0
+		for _, e := range depth {
+			var d *TypeSpec
+			var tm map[*TypeParameter]Type
+			switch t := e.Type.(type) {
+			case *TypeName:
+				if t.decl == nil {
+					// The name is undeclared; it has already been reported.
+					continue
+				}
+				d, _ = t.decl.(*TypeSpec)
+			case *Instance:
+				d, tm = t.decl, t.bindings()
+			}
+			if d != nil {
+				if visited[d] {
+					continue
+				}
+				visited[d] = true
+				for _, m := range d.methods {
+					if m.Name != name || onlyFields {
+						continue
+					}
+					n++
+					sel = &selection{method: m, indirect: e.indirect}
+					if m.checkSignature() != nil {
+						continue
+					}
+					sel.sig = &m.Signature
+					if tm != nil {
+						sig := substSignature(&m.Signature, tm)
+						sel.sig = &sig
+					}
+				}
+			}
+			switch u := e.Underlying().(type) {
+			case *StructType:
+				for i := range u.Fields {
+					f := &u.Fields[i]
+					if f.name() == name {
+						n++
+						sel = &selection{field: f, indirect: e.indirect}
+					}
+					if f.Identifier != nil {
+						continue
+					}
+					if s, ok := f.Type.(*Star); ok {
+						next = append(next, embedded{s.Target.(Type), true})
+					} else {
+						next = append(next, embedded{f.Type, e.indirect})
+					}
+				}
+			case *InterfaceType:
+				if onlyFields {
+					break
+				}
+				for _, m := range u.methodSet {
+					if m.Name == name {
+						n++
+						sel = &selection{sig: &m.Signature, indirect: e.indirect}
+					}
+				}
+			}
+		}
+		switch {
+		case n == 1:
+			return sel, false
+		case n > 1:
+			return nil, true
+		}
+		depth = next
+	}
+	return nil, false
+}
+
 // Nilable returns whether the type can be nil.
 func Nilable(t Type) bool {
-	switch t.(type) {
+	if typeParam(t) != nil {
+		return false
+	}
+	switch t.Underlying().(type) {
 	case *Star:
 		return true
 	case *SliceType:
@@ -124,6 +478,8 @@ func Nilable(t Type) bool {
 		return true
 	case *InterfaceType:
 		return true
+	case *FunctionType:
+		return true
 	default:
 		return false
 	}
@@ -264,9 +620,16 @@ func (t *StructType) Identical(other Type) bool {
 // having called their Check methods.
 func (t *InterfaceType) Identical(other Type) bool {
 	s, ok := other.(*InterfaceType)
-	if !ok || len(t.methodSet) != len(s.methodSet) {
+	if !ok || len(t.methodSet) != len(s.methodSet) || t.comparable != s.comparable ||
+		t.restricted != s.restricted || len(t.terms) != len(s.terms) {
 		return false
 	}
+	for i := range t.terms {
+		a, b := t.terms[i], s.terms[i]
+		if a.Tilde != b.Tilde || !a.Type.Identical(b.Type) {
+			return false
+		}
+	}
 	for i, m := range t.methodSet {
 		switch n := s.methodSet[i]; {
 		case m.Name != n.Name:
@@ -356,9 +719,60 @@ func (t *Star) Identical(other Type) bool {
 
 // Identical returns whether the two types are identical.
 // Two named types are identical if their type names originate in the same TypeSpec.
+// Two type parameters are identical if they originate in the same TypeParameter.
+// An alias is identical to the type that it denotes.
 func (t *TypeName) Identical(other Type) bool {
+	if a := aliasTarget(t); a != nil {
+		return a.Identical(other)
+	}
 	s, ok := other.(*TypeName)
-	return ok && t.Identifier.decl == s.Identifier.decl
+	if !ok {
+		return false
+	}
+	if a := aliasTarget(s); a != nil {
+		return t.Identical(a)
+	}
+	return t.Identifier.decl == s.Identifier.decl
+}
+
+// AliasTarget returns the type denoted by the type name
+// if it names an alias, and otherwise nil.
+func aliasTarget(t *TypeName) Type {
+	if d, ok := t.Identifier.decl.(*TypeSpec); ok && d.Alias {
+		return d.Type
+	}
+	return nil
+}
+
+// Identical returns whether the two types are identical.
+// Two unions are identical if they have identical terms in the same order.
+func (t *Union) Identical(other Type) bool {
+	s, ok := other.(*Union)
+	if !ok || len(t.Terms) != len(s.Terms) {
+		return false
+	}
+	for i := range t.Terms {
+		if t.Terms[i].Tilde != s.Terms[i].Tilde || !t.Terms[i].Type.Identical(s.Terms[i].Type) {
+			return false
+		}
+	}
+	return true
+}
+
+// Identical returns whether the two types are identical.
+// Two instances of generic types are identical if they instantiate the same
+// generic type with identical type arguments.
+func (t *Instance) Identical(other Type) bool {
+	s, ok := other.(*Instance)
+	if !ok || t.decl == nil || t.decl != s.decl || len(t.TypeArguments) != len(s.TypeArguments) {
+		return false
+	}
+	for i := range t.TypeArguments {
+		if !t.TypeArguments[i].Identical(s.TypeArguments[i]) {
+			return false
+		}
+	}
+	return true
 }
 
 func (t *StructType) Underlying() Type    { return t }
@@ -369,11 +783,32 @@ func (t *MapType) Underlying() Type       { return t }
 func (t *ArrayType) Underlying() Type     { return t }
 func (t *SliceType) Underlying() Type     { return t }
 func (t *Star) Underlying() Type          { return t }
+func (t *Union) Underlying() Type         { return t }
 
+// Underlying returns the underlying type of the instantiated generic type,
+// with the type arguments substituted for its type parameters.
+func (t *Instance) Underlying() Type {
+	if t.underlying == nil {
+		t.underlying = subst(t.decl.Type, t.bindings()).Underlying()
+	}
+	return t.underlying
+}
+
+// Underlying returns the underlying type. The underlying type of
+// a type parameter is the interface of its constraint, and the underlying
+// type of an alias is that of the type that it denotes.
 func (t *TypeName) Underlying() Type {
 	switch d := t.Identifier.decl.(type) {
 	case predeclaredType:
+		switch d {
+		case Error:
+			return errorInterface
+		case Comparable:
+			return comparableInterface
+		}
 		return t
+	case *TypeParameter:
+		return d.iface
 	case *TypeSpec:
 		return d.Type.Underlying()
 	default:
@@ -389,47 +824,68 @@ func (n *MapType) Type() Type       { return n }
 func (n *ArrayType) Type() Type     { return n }
 func (n *SliceType) Type() Type     { return n }
 
-func (n *Star) Type() Type {
-	panic("unimplemented")
-}
+func (n *Star) Type() Type { return n }
 
 func (n *TypeName) Type() Type { return n }
 
-func (n *CompositeLiteral) Type() Type { return n.LiteralType }
+func (n *CompositeLiteral) Type() Type { return n.typ }
 
-func (n *Index) Type() Type {
-	panic("unimplemented")
-}
+func (n *Index) Type() Type { return n.typ }
 
-func (n *Slice) Type() Type {
-	panic("unimplemented")
+// Type returns the instance itself for an instance of a generic type,
+// and the instantiated function type for an instance of a generic function.
+func (n *Instance) Type() Type {
+	if n.decl != nil {
+		return n
+	}
+	return n.typ
 }
 
-func (n *TypeAssertion) Type() Type {
-	panic("unimplemented")
-}
+func (n *Union) Type() Type { return n }
 
-func (n *Selector) Type() Type {
-	panic("unimplemented")
-}
+func (n *Slice) Type() Type { return n.typ }
 
+func (n *TypeAssertion) Type() Type { return n.AssertedType }
+
+func (n *Selector) Type() Type { return n.typ }
+
+// Type returns the type of the result of the call, or nil if the call
+// does not have exactly one result.
 func (n *Call) Type() Type {
-	panic("unimplemented")
+	if len(n.results) != 1 {
+		return nil
+	}
+	return n.results[0]
 }
 
-func (n *BinaryOp) Type() Type {
-	panic("unimplemented")
-}
+func (n *BinaryOp) Type() Type     { return n.typ }
+func (n *BinaryOp) SetType(t Type) { n.typ = t }
 
-func (n *UnaryOp) Type() Type {
-	panic("unimplemented")
-}
+func (n *UnaryOp) Type() Type { return n.typ }
 
 func (n *Identifier) Type() Type {
 	switch d := n.decl.(type) {
 	case *VarSpec:
 		return d.Type
 
+	case *varSpecView:
+		return d.Type
+
+	case *shortVarDeclView:
+		return d.Type
+
+	case *recvStmtView:
+		return d.Type
+
+	case *typeSwitchView:
+		return d.Type
+
+	case *ParameterDecl:
+		if d.DotDotDot {
+			return &SliceType{Element: d.Type}
+		}
+		return d.Type
+
 	case *MethodDecl:
 		return &FunctionType{Signature: d.Signature}
 
@@ -460,3 +916,6 @@ func (n *BoolLiteral) Type() Type        { return n.typ }
 func (n *BoolLiteral) SetType(t Type)    { n.typ = t }
 func (n *NilLiteral) Type() Type         { return n.typ }
 func (n *NilLiteral) SetType(t Type)     { n.typ = t }
+
+// Type returns nil, because a BadExpr has no type.
+func (n *BadExpr) Type() Type { return nil }
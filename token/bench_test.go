@@ -10,7 +10,7 @@ import (
 
 func BenchmarkLexer(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		lex := NewLexer("", test.Prog)
+		lex := NewLexer(NewFileSet(), "", test.Prog)
 		tok := Semicolon
 		for tok != EOF && tok != Error {
 			tok = lex.Next()
@@ -0,0 +1,115 @@
+package token
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A lineDirective records a //line comment, which remaps the locations
+// that follow it to another file and line. Line directives are used by
+// generated code to refer to the source from which it was generated.
+//
+// A line directive has one of the forms
+//
+//	//line path:line
+//	//line path:line:col
+//
+// and must begin at the start of a line. The location of the rune
+// immediately following the directive, at the start of the next line,
+// is remapped to the given line and column of the given path.
+// Each subsequent line is remapped to the following line of the path.
+// If the column is given, then columns on the first line are remapped
+// relative to it. Otherwise, columns are not remapped.
+// If the path is empty, the path is that of the previous directive,
+// or the path of the file if there is none.
+type lineDirective struct {
+	// Offset is the rune offset immediately following the directive,
+	// and rawLine is its line number.
+	offset, rawLine int
+	// Path, line, and col are the remapped location of offset.
+	// Col is 0 if the directive does not give a column.
+	path      string
+	line, col int
+}
+
+// ParseLineDirective returns the path, line, and column of a //line
+// comment. The column is 0 if it is not given. If the text is not a
+// well-formed line directive, ok is false.
+func parseLineDirective(text string) (path string, line, col int, ok bool) {
+	const prefix = "//line "
+	if !strings.HasPrefix(text, prefix) {
+		return "", 0, 0, false
+	}
+	s := strings.TrimRight(text[len(prefix):], "\r\n")
+	s, n, ok := splitNumber(s)
+	if !ok {
+		return "", 0, 0, false
+	}
+	if p, m, ok := splitNumber(s); ok {
+		return p, m, n, true
+	}
+	return s, n, 0, true
+}
+
+// SplitNumber splits a string of the form s:n, where n is a positive
+// decimal integer, returning s and n. If the string is not of this
+// form, ok is false.
+func splitNumber(s string) (string, int, bool) {
+	i := strings.LastIndexByte(s, ':')
+	if i < 0 {
+		return s, 0, false
+	}
+	n, err := strconv.Atoi(s[i+1:])
+	if err != nil || n <= 0 {
+		return s, 0, false
+	}
+	return s[:i], n, true
+}
+
+// AddDirective records the line directive of a comment ending at the
+// given location, if the comment is a line directive. Directives that
+// are not beyond the last recorded directive are ignored.
+func (f *File) addDirective(text string, end Location) {
+	path, line, col, ok := parseLineDirective(text)
+	if !ok {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	prevPath := f.path
+	if n := len(f.directives); n > 0 {
+		if f.directives[n-1].offset >= end.Rune {
+			return
+		}
+		prevPath = f.directives[n-1].path
+	}
+	if path == "" {
+		path = prevPath
+	}
+	f.directives = append(f.directives, lineDirective{
+		offset:  end.Rune,
+		rawLine: end.Line,
+		path:    path,
+		line:    line,
+		col:     col,
+	})
+}
+
+// Remap returns the path, line, and column of a location as remapped
+// by the line directive in effect at the location.
+func (f *File) remap(l Location) (path string, line, col int) {
+	path, line, col = l.Path, l.Line, l.Column()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i := sort.Search(len(f.directives), func(i int) bool { return f.directives[i].offset > l.Rune }) - 1
+	if i < 0 {
+		return path, line, col
+	}
+	d := f.directives[i]
+	path, line = d.path, d.line+l.Line-d.rawLine
+	if d.col > 0 && l.Line == d.rawLine {
+		col += d.col - 1
+	}
+	return path, line, col
+}
@@ -0,0 +1,55 @@
+package token
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLineDirective(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{"a", []string{"x.go:1:0"}},
+		{"a\n//line b.tmpl:10\nb c\nd", []string{"x.go:1:0", "b.tmpl:10:0", "b.tmpl:10:2", "b.tmpl:11:0"}},
+		{"//line b.tmpl:10:5\nb c\nd", []string{"b.tmpl:10:4", "b.tmpl:10:6", "b.tmpl:11:0"}},
+		{"//line c:\\b.tmpl:10\nb", []string{"c:\\b.tmpl:10:0"}},
+		{"//line b:c:10\nb", []string{"b:c:10:0"}},
+		{"//line b.tmpl:10\n//line :20\nb", []string{"b.tmpl:20:0"}},
+		{"//line b.tmpl:10\n//line c.tmpl:20\nb\n//line b.tmpl:30\nc", []string{"c.tmpl:20:0", "b.tmpl:30:0"}},
+		// Not directives.
+		{"a //line b.tmpl:10\nb", []string{"x.go:1:0", "x.go:2:0"}},
+		{"//line b.tmpl\nb", []string{"x.go:2:0"}},
+		{"//line b.tmpl:0\nb", []string{"x.go:2:0"}},
+		{"//line b.tmpl:x\nb", []string{"x.go:2:0"}},
+		{"/*line b.tmpl:10*/b", []string{"x.go:1:18"}},
+		{"//lineb.tmpl:10\nb", []string{"x.go:2:0"}},
+	}
+	for _, test := range tests {
+		lex := NewLexer(NewFileSet(), "x.go", test.src)
+		var got []string
+		for tok := lex.Next(); tok != EOF; tok = lex.Next() {
+			if tok == Identifier {
+				got = append(got, lex.Start.String())
+			}
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q got %v, wanted %v", test.src, got, test.want)
+		}
+	}
+}
+
+func TestLineDirectivePos(t *testing.T) {
+	const src = "a\n//line b.tmpl:10:3\nb"
+	fset := NewFileSet()
+	lex := NewLexer(fset, "x.go", src)
+	for lex.Next() != EOF {
+	}
+	l := fset.Location(lex.File().Pos(len(src)))
+	if s := l.String(); s != "b.tmpl:10:2" {
+		t.Errorf("String()=%s, want b.tmpl:10:2", s)
+	}
+	if l.Path != "x.go" || l.Line != 3 || l.Column() != 0 {
+		t.Errorf("Location()=%s:%d:%d, want x.go:3:0", l.Path, l.Line, l.Column())
+	}
+}
@@ -1,15 +1,36 @@
 package token
 
 import (
+	"errors"
+	"io"
+	"strings"
 	"unicode"
 	"unicode/utf8"
+	"unsafe"
 )
 
 // A Lexer scans and returns Go tokens from an input stream.
 type Lexer struct {
-	// Src is the source text being scanned.
+	// Src is the source text being scanned. If the lexer reads
+	// from an io.Reader, src is only the portion of the input
+	// beginning with the current token that has been read so far.
 	src string
 
+	// In is the reader from which more of src is read, or nil
+	// if there is no more input to read. Buf is the buffer
+	// into which it is read; src is usually a view of its end.
+	in  io.Reader
+	buf []byte
+
+	// Err is the first error returned by in other than io.EOF.
+	err error
+
+	// Fset is the FileSet to which file was added.
+	fset *FileSet
+	// File is the File of the source, in which
+	// the starts of the scanned lines are recorded.
+	file *File
+
 	// N is the next un-scanned byte in src and w is the width of the
 	// most-recently returned rune.  If n >= len(src) && w==0
 	// then an end of file, -1, rune has been returned by rune()
@@ -28,21 +49,104 @@ type Lexer struct {
 	// last token scanned by Next.  They are left unmodified
 	// when Next returns an inserted semicolon.
 	Start, End Location
+
+	// Reason describes why the last token scanned by Next is
+	// an Error, for example, "newline in string" or "invalid escape
+	// sequence". It is the empty string for any other token.
+	Reason string
+
+	// Recover is whether the lexer is in recovering mode. In recovering
+	// mode, scanning continues after an Error token, and the text and
+	// span of the Error token cover the entire malformed token, such as
+	// the rest of a literal with an invalid escape sequence. Otherwise,
+	// the text of an Error token is the unexpected rune, and all
+	// subsequent tokens are EOF.
+	Recover bool
+}
+
+// NewLexer returns a new Lexer that reads tokens from the source text
+// of a file. All Locations produced by the lexer use the path as their
+// path. The file is added to the FileSet, and the start of each line
+// is recorded in the File as it is scanned, as are any //line directives,
+// which remap the string representation of the Locations that follow them.
+func NewLexer(fset *FileSet, path string, src string) *Lexer {
+	return newLexer(fset, path, src, len(src))
+}
+
+// NewBytesLexer is like NewLexer, but it reads tokens from a byte slice
+// without copying it. The strings returned by Text share memory with
+// the slice, so the slice must not be modified after it is passed to
+// NewBytesLexer.
+func NewBytesLexer(fset *FileSet, path string, src []byte) *Lexer {
+	return newLexer(fset, path, unsafe.String(unsafe.SliceData(src), len(src)), len(src))
+}
+
+// ErrFileNotLast is returned by the Err method of a Lexer reading from
+// an io.Reader if its File cannot grow to cover more of the input,
+// because another file was added to its FileSet.
+var ErrFileNotLast = errors.New("token: another file was added to the FileSet before the end of the input")
+
+// ReaderBufferSize is the number of bytes read at a time by a Lexer
+// that reads from an io.Reader.
+const readerBufferSize = 4096
+
+// ReaderChunkSize is the minimum size of the buffers allocated by
+// a Lexer that reads from an io.Reader.
+const readerChunkSize = 16 * readerBufferSize
+
+// NewReaderLexer is like NewLexer, but it reads tokens from an io.Reader.
+// The input is read as it is needed, so the lexer holds only the text of
+// the current token and the input following it that is read but not yet
+// scanned. An error from the reader, other than
+// io.EOF, ends the input and is returned by Err.
+//
+// The size of the input is not known, so the range of Pos values of the
+// lexer's File grows as the input is read. It can only grow while the File
+// is the last File of its FileSet: if another file is added to the FileSet
+// before the end of the input is read, the input ends, and Err returns
+// ErrFileNotLast.
+func NewReaderLexer(fset *FileSet, path string, r io.Reader) *Lexer {
+	l := newLexer(fset, path, "", 0)
+	l.in = r
+	return l
 }
 
-// NewLexer returns a new Lexer that reads tokens from an input file.
-// If the underlying type of the reader is an os.File, then all Locations
-// produced by the lexer use the file name as their path.
-func NewLexer(path string, src string) *Lexer {
+func newLexer(fset *FileSet, path string, src string, size int) *Lexer {
+	// The line table is allocated once, with room for every line of src.
+	f := fset.addFile(path, size, strings.Count(src, "\n")+1)
+	start := Location{Path: path, Line: 1, Rune: 1, Byte: 1, LineStart: 1, file: f}
 	l := &Lexer{
 		src:           src,
+		fset:          fset,
+		file:          f,
 		prevLineStart: -1,
-		Start:         Location{Path: path, Line: 1, Rune: 1, LineStart: 1},
-		End:           Location{Path: path, Line: 1, Rune: 1, LineStart: 1},
+		// A semicolon is never inserted before the first token.
+		prev:  Semicolon,
+		Start: start,
+		End:   start,
 	}
 	return l
 }
 
+// Err returns the first error, other than io.EOF, returned by the
+// io.Reader of a lexer created with NewReaderLexer, or nil if there
+// is none. Once an error is returned by the reader, the lexer scans
+// as though the input had ended.
+func (l *Lexer) Err() error {
+	return l.err
+}
+
+// FileSet returns the FileSet to which the lexer's source was added.
+func (l *Lexer) FileSet() *FileSet {
+	return l.fset
+}
+
+// File returns the File of the lexer's source in its FileSet, which maps
+// the Pos of each rune offset scanned by the lexer back to its Location.
+func (l *Lexer) File() *File {
+	return l.file
+}
+
 // Text returns the text of the last token scanned by Next.  Inserted
 // semicolons do not update the string retuned by Text.  If Next
 // returns an inserted semicolon, then Text will return the text of the
@@ -52,15 +156,25 @@ func (l *Lexer) Text() string {
 }
 
 // Next returns the next token, inserting semicolons where required.
-// Once an Error or EOF token is returned, all subsequent tokens will
-// be of type EOF.
+// Once an EOF token is returned, all subsequent tokens will be of type
+// EOF. The same is true after an Error token, unless the lexer is in
+// recovering mode.
 func (l *Lexer) Next() Token {
+	if l.prev == Error && !l.Recover {
+		l.src, l.n, l.w = "", 0, 0
+		l.in = nil
+		l.Start = l.End
+		l.Reason = ""
+		return EOF
+	}
+
 	// Saved, to be reset if a semicolon is inserted.
 	src, n, start := l.src, l.n, l.Start
 
 	l.src = l.src[l.n:]
 	l.n, l.w = 0, 0
 	l.Start = l.End
+	l.Reason = ""
 	t := l.scan()
 
 	nl := (t == Comment || t == Whitespace) && l.End.Line > l.Start.Line
@@ -79,21 +193,75 @@ func (l *Lexer) Next() Token {
 			l.prev == MinusMinus ||
 			l.prev == CloseParen ||
 			l.prev == CloseBracket ||
-			l.prev == CloseBrace) {
+			l.prev == CloseBrace ||
+			// A malformed token is most likely a malformed literal.
+			l.prev == Error) {
+		if len(l.src) > len(src)-n {
+			// More input was read while scanning; keep it.
+			src = src[:n] + l.src
+		}
 		l.src, l.n, l.Start, l.End = src, n, start, l.Start
 		t = Semicolon
 	}
 	if t != Comment && t != Whitespace {
 		l.prev = t
 	}
-	if t == Error {
+	if t == Comment && l.Start.Column() == 0 {
+		l.file.addDirective(l.Text(), l.End)
+	}
+	switch {
+	case t == Error && l.Recover:
+		l.skipError()
+	case t == Error && l.w > 0:
 		// Advance to the most-recent rune: the unexpected one.
-		l.src = l.src[l.n-1:]
-		l.n = 1
+		l.src = l.src[l.n-l.w:]
+		l.n = l.w
 	}
 	return t
 }
 
+// SkipError extends an Error token to the end of the malformed token:
+// to the end of a malformed number or to the closing quote of a malformed
+// rune or string literal. The unexpected rune is replaced if it does not
+// belong to the malformed token, so that it is scanned as the next token.
+func (l *Lexer) skipError() {
+	r := rune(-1)
+	if l.w > 0 {
+		r, _ = utf8.DecodeRuneInString(l.src[l.n-l.w:])
+	}
+	switch c := l.src[0]; {
+	case c == '"' || c == '\'':
+		skipQuoted(l, r, rune(c))
+	case c == '.' && l.src[1] == '.':
+		l.replace()
+	case isDecimalDigit(rune(c)) || c == '.':
+		for isIdent(r) || r == '.' {
+			r = l.rune()
+		}
+		l.replace()
+	}
+}
+
+// SkipQuoted reads the remainder of a literal beginning with the rune r,
+// up to and including the closing quote q. The literal ends before a newline.
+func skipQuoted(l *Lexer, r, q rune) {
+	for {
+		switch {
+		case r == q || r < 0:
+			return
+		case r == '\n':
+			l.replace()
+			return
+		case r == '\\':
+			if r = l.rune(); r == '\n' {
+				l.replace()
+				return
+			}
+		}
+		r = l.rune()
+	}
+}
+
 // Scan scans the next token and returns it.
 func (l *Lexer) scan() Token {
 	r := l.rune()
@@ -144,7 +312,7 @@ func (l *Lexer) scan() Token {
 			l.replace()
 		}
 		return operator(l)
-	case r == ',' || r == ';' || r == '(' || r == ')' || r == '[' || r == ']' || r == '{' || r == '}':
+	case r == ',' || r == ';' || r == '(' || r == ')' || r == '[' || r == ']' || r == '{' || r == '}' || r == '~':
 		return operator(l)
 	case r == '/':
 		switch r := l.rune(); {
@@ -159,10 +327,11 @@ func (l *Lexer) scan() Token {
 	case r == '.':
 		if r = l.rune(); r == '.' {
 			if r = l.rune(); r != '.' {
-				return Error
+				return l.error("unexpected rune after ..")
 			}
 		} else if isDecimalDigit(r) {
-			return fraction(l)
+			l.replace()
+			return number('.', l)
 		} else {
 			l.replace()
 		}
@@ -188,28 +357,86 @@ func (l *Lexer) scan() Token {
 	case r == '`':
 		return rawStringLiteral(l)
 	}
-	return Error
+	return l.error("invalid character")
 }
 
 // Rune reads and returns the next rune from the input stream and
 // updates the locations and text of the current token.  If the end of
 // the input is reached -1 is returned.
 func (l *Lexer) rune() (r rune) {
+	for len(l.src)-l.n < utf8.UTFMax && l.in != nil && !utf8.FullRuneInString(l.src[l.n:]) {
+		l.read()
+	}
 	if l.n >= len(l.src) {
 		l.w = 0
 		return -1
 	}
-	r, l.w = utf8.DecodeRuneInString(l.src[l.n:])
+	if c := l.src[l.n]; c < utf8.RuneSelf {
+		r, l.w = rune(c), 1
+	} else {
+		r, l.w = utf8.DecodeRuneInString(l.src[l.n:])
+		if l.w > 1 {
+			l.file.addWide(l.End.Rune, r, l.w)
+		}
+	}
 	l.n += l.w
 	l.End.Rune++
+	l.End.Byte += l.w
 	if r == '\n' {
 		l.End.Line++
 		l.prevLineStart = l.End.LineStart
 		l.End.LineStart = l.End.Rune
+		l.file.AddLine(l.End.LineStart)
 	}
 	return r
 }
 
+// Read appends more of the input from the reader to src. At the end of
+// the input, or on an error, the reader is set to nil.
+//
+// The input is read into the unused capacity of buf, after src,
+// and src is extended to cover it without copying. The bytes before the
+// end of buf are never modified, since strings returned by Text may
+// refer to them. When buf is full, only src, the text of the current
+// token and the unscanned input, is copied to a new buf with room for
+// at least twice as much, so a long token is read in linear time.
+func (l *Lexer) read() {
+	if !l.srcAtEnd() || cap(l.buf)-len(l.buf) < readerBufferSize {
+		b := make([]byte, len(l.src), 2*len(l.src)+readerChunkSize)
+		copy(b, l.src)
+		l.buf = b
+	}
+	end := len(l.buf)
+	n, err := l.in.Read(l.buf[end : end+readerBufferSize])
+	if n > 0 && !l.fset.grow(l.file, n) {
+		// There are no more Pos values for the input.
+		n, err = 0, ErrFileNotLast
+	}
+	l.buf = l.buf[:end+n]
+	if m := len(l.src) + n; m > 0 {
+		l.src = unsafe.String(&l.buf[len(l.buf)-m], m)
+	}
+	if err != nil {
+		if err != io.EOF {
+			l.err = err
+		}
+		l.in, l.buf = nil, nil
+	}
+}
+
+// SrcAtEnd returns whether src is a view of the end of buf.
+// It is not after a semicolon is inserted following input
+// that was read into a previous buf.
+func (l *Lexer) srcAtEnd() bool {
+	if len(l.src) == 0 {
+		return true
+	}
+	if len(l.src) > len(l.buf) {
+		return false
+	}
+	return unsafe.StringData(l.src) == &l.buf[len(l.buf)-len(l.src)]
+}
+
 // Replace replaces the most-recently-read rune into the input
 // stream.  If no runes were read since the last call to Next then
 // it panics; nothing can be replaced.
@@ -221,6 +448,7 @@ func (l *Lexer) replace() {
 		return
 	}
 	l.n -= l.w
+	l.End.Byte -= l.w
 	l.w = 0
 	l.End.Rune--
 	if l.End.Rune < l.End.LineStart {
@@ -230,13 +458,28 @@ func (l *Lexer) replace() {
 	}
 }
 
+// Error sets the Reason for an Error token and returns Error.
+func (l *Lexer) error(reason string) Token {
+	l.Reason = reason
+	return Error
+}
+
+// Rewind moves the end of the current token back to the byte offset n,
+// making the rune that ends at n the most-recently read rune. All runes
+// read after offset n-1 must be single-byte, non-newline runes.
+func (l *Lexer) rewind(n int) {
+	l.End.Rune -= l.n - n
+	l.End.Byte -= l.n - n
+	l.n, l.w = n, 1
+}
+
 func operator(l *Lexer) Token {
 	text := l.src[:l.n]
-	oper, ok := operators[text]
-	if !ok {
-		panic("bad operator: \"" + string(text) + "\"")
+	e := operatorTable[operatorHash(text)]
+	if e.text != text {
+		panic("bad operator: \"" + text + "\"")
 	}
-	return oper
+	return e.tok
 }
 
 func identifier(l *Lexer) Token {
@@ -245,11 +488,7 @@ func identifier(l *Lexer) Token {
 		r = l.rune()
 	}
 	l.replace()
-	text := l.src[:l.n]
-	if keyword, ok := keywords[text]; ok {
-		return keyword
-	}
-	return Identifier
+	return keyword(l.src[:l.n])
 }
 
 func whitespace(l *Lexer) Token {
@@ -270,7 +509,7 @@ func comment(l *Lexer, closing []rune) Token {
 			r = '\n'
 			i++
 		case r < 0:
-			panic("unexpected EOF")
+			return l.error("comment not terminated")
 		case r != closing[i]:
 			i = 0
 		default:
@@ -280,79 +519,203 @@ func comment(l *Lexer, closing []rune) Token {
 	return Comment
 }
 
-// BUG(eaburns): Malformed octal literals that begin with 0 followed by
-// any number of decmial digits are returned as valid integer literals.
+// Number scans a number literal that begins with the rune r0, which is
+// either a decimal digit or the radix point of a literal such as .25.
+// Digits may be separated by underscores, and an integer literal may
+// have a 0b, 0o, or 0x prefix for binary, octal, or hexadecimal.
+// A hexadecimal floating-point literal must have a p exponent.
+//
+// If the literal is malformed, Error is returned, and the most-recently
+// read rune is the unexpected one: an invalid digit, a misplaced underscore,
+// or the rune following an incomplete prefix or exponent.
 func number(r0 rune, l *Lexer) Token {
-	r := l.rune()
-	if r0 == '0' && (r == 'x' || r == 'X') {
-		return hex(l)
+	tok, base, prefix := IntegerLiteral, 10, rune(0)
+	// Digsep has bit 0 set if a digit was read
+	// and bit 1 set if an underscore was read.
+	digsep, invalid := 0, -1
+	r := r0
+	if r0 != '.' {
+		if r0 == '0' {
+			r = l.rune()
+			switch lower(r) {
+			case 'x':
+				base, prefix = 16, 'x'
+				r = l.rune()
+			case 'o':
+				base, prefix = 8, 'o'
+				r = l.rune()
+			case 'b':
+				base, prefix = 2, 'b'
+				r = l.rune()
+			default:
+				// A leading 0 begins an octal literal,
+				// unless the literal is a float or imaginary.
+				base, prefix, digsep = 8, '0', 1
+			}
+		}
+		var ds int
+		r, ds = digits(l, r, base, &invalid)
+		digsep |= ds
+		if r != '.' && digsep&1 == 0 {
+			return l.error(litName(prefix) + " has no digits")
+		}
 	}
-	for {
+	if r == '.' {
+		tok = FloatLiteral
+		if prefix == 'o' || prefix == 'b' {
+			return l.error("invalid radix point in " + litName(prefix))
+		}
+		var ds int
+		r, ds = digits(l, l.rune(), base, &invalid)
+		digsep |= ds
+		if digsep&1 == 0 {
+			return l.error(litName(prefix) + " has no digits")
+		}
+	}
+	switch e := lower(r); {
+	case e == 'e' || e == 'p':
 		switch {
-		case r == 'e' || r == 'E':
-			return mantissa(l)
-		case r == '.':
-			return fraction(l)
-		case r == 'i':
-			return ImaginaryLiteral
-		case !isDecimalDigit(r):
-			l.replace()
-			return IntegerLiteral
+		case e == 'e' && prefix != 0 && prefix != '0':
+			return l.error("'e' exponent requires decimal mantissa")
+		case e == 'p' && prefix != 'x':
+			return l.error("'p' exponent requires hexadecimal mantissa")
 		}
-		r = l.rune()
+		tok = FloatLiteral
+		if r = l.rune(); r == '+' || r == '-' {
+			r = l.rune()
+		}
+		var ds int
+		r, ds = digits(l, r, 10, nil)
+		if ds&1 == 0 {
+			return l.error("exponent has no digits")
+		}
+		digsep |= ds
+	case prefix == 'x' && tok == FloatLiteral:
+		return l.error("hexadecimal mantissa requires a 'p' exponent")
+	}
+	if r == 'i' {
+		tok = ImaginaryLiteral
+	} else {
+		l.replace()
 	}
-}
 
-func mantissa(l *Lexer) Token {
-	r := l.rune()
-	if r == '+' || r == '-' {
-		r = l.rune()
+	// The digits 8 and 9 are only allowed after a leading 0
+	// if the literal is a float or imaginary.
+	if tok == IntegerLiteral && invalid >= 0 {
+		l.rewind(invalid + 1)
+		return l.error("invalid digit '" + l.src[invalid:invalid+1] + "' in " + litName(prefix))
 	}
-	for isDecimalDigit(r) {
-		r = l.rune()
+	if digsep&2 != 0 {
+		if i := invalidSeparator(l.src[:l.n]); i >= 0 {
+			l.rewind(i + 1)
+			return l.error("'_' must separate successive digits")
+		}
 	}
-	if r == 'i' {
-		return ImaginaryLiteral
+	return tok
+}
+
+// LitName returns the name of a number literal with the given prefix,
+// as used in the Reason for a malformed literal.
+func litName(prefix rune) string {
+	switch prefix {
+	case 'x':
+		return "hexadecimal literal"
+	case 'o', '0':
+		return "octal literal"
+	case 'b':
+		return "binary literal"
 	}
-	l.replace()
-	return FloatLiteral
+	return "decimal literal"
 }
 
-func fraction(l *Lexer) Token {
-	r := l.rune()
-	for isDecimalDigit(r) {
+// Digits reads a sequence of digits in the given base and underscores,
+// beginning with the most-recently read rune r. It returns the rune
+// following the sequence and a digsep value as described in number.
+// If invalid is non-nil and less than zero, it is set to the offset
+// of the first decimal digit that is not valid in the base.
+func digits(l *Lexer, r rune, base int, invalid *int) (rune, int) {
+	digsep := 0
+	for {
+		switch {
+		case r == '_':
+			digsep |= 2
+		case base == 16 && isHexDigit(r):
+			digsep |= 1
+		case base <= 10 && isDecimalDigit(r):
+			digsep |= 1
+			if r >= rune('0'+base) && invalid != nil && *invalid < 0 {
+				*invalid = l.n - l.w
+			}
+		default:
+			return r, digsep
+		}
 		r = l.rune()
 	}
-	switch {
-	case r == 'i':
-		return ImaginaryLiteral
-	case r == 'e' || r == 'E':
-		return mantissa(l)
-	}
-	l.replace()
-	return FloatLiteral
 }
 
-func hex(l *Lexer) Token {
-	r := l.rune()
-	for isHexDigit(r) {
-		r = l.rune()
+// InvalidSeparator returns the index of the first underscore in the
+// number literal text that does not separate successive digits, or -1 if
+// there is none. The base prefix of a literal counts as a digit.
+func invalidSeparator(text string) int {
+	hex := false
+	// Prev is '0' for a digit, '_' for an underscore,
+	// and '.' for anything else.
+	prev, i := '.', 0
+	if len(text) >= 2 && text[0] == '0' {
+		switch lower(rune(text[1])) {
+		case 'x':
+			hex = true
+			fallthrough
+		case 'o', 'b':
+			prev, i = '0', 2
+		}
 	}
-	l.replace()
-	return IntegerLiteral
+	for ; i < len(text); i++ {
+		r := rune(text[i])
+		switch {
+		case r == '_':
+			if prev != '0' {
+				return i
+			}
+			prev = '_'
+		case isDecimalDigit(r) || hex && isHexDigit(r):
+			prev = '0'
+		default:
+			if prev == '_' {
+				return i - 1
+			}
+			prev = '.'
+		}
+	}
+	if prev == '_' {
+		return len(text) - 1
+	}
+	return -1
+}
+
+// Lower returns the lower-case form of an ASCII letter.
+func lower(r rune) rune {
+	return ('a' - 'A') | r
 }
 
 func runeLiteral(l *Lexer) Token {
-	switch r := l.rune(); r {
-	case '\\':
+	switch r := l.rune(); {
+	case r == '\\':
 		if !unicodeValue(l, '\'') {
 			return Error
 		}
-	case '\'':
-		return Error
+	case r == '\'':
+		return l.error("empty rune literal")
+	case r == '\n':
+		return l.error("newline in rune literal")
+	case r < 0:
+		return l.error("rune literal not terminated")
 	}
-	if r := l.rune(); r != '\'' {
-		return Error
+	switch r := l.rune(); {
+	case r == '\n' || r < 0:
+		return l.error("rune literal not terminated")
+	case r != '\'':
+		return l.error("more than one character in rune literal")
 	}
 	return RuneLiteral
 }
@@ -364,13 +727,13 @@ func interpertedStringLiteral(l *Lexer) Token {
 		case r == '"':
 			return StringLiteral
 		case r == '\n':
-			return Error
+			return l.error("newline in string")
 		case r == '\\':
 			if !unicodeValue(l, '"') {
 				return Error
 			}
 		case r < 0:
-			return Error
+			return l.error("string literal not terminated")
 		}
 	}
 }
@@ -382,68 +745,67 @@ func rawStringLiteral(l *Lexer) Token {
 		case r == '`':
 			return StringLiteral
 		case r < 0:
-			return Error
+			return l.error("raw string literal not terminated")
 		}
 	}
 }
 
 // Parses a unicode value (assuming that the leading '\' has
 // already been consumed), and returns true on success or
-// false if the last rune read was unexpected.
-//
-// BUG(eaburns): Unicode code points are not validated, so characters
-// with invalid code points (such as '\U00110000' and '\uDFFF') are
-// returned as valid character literals.  Ideally they would be checked
-// here so that the parser would not have to error-check them.
+// false if the last rune read was unexpected. The value of
+// a Unicode escape must be a valid code point, and the value
+// of a hexadecimal or octal byte escape must be at most 255.
+// On failure, the Reason of the lexer is set.
 func unicodeValue(l *Lexer, quote rune) bool {
-	r := l.rune()
-	switch {
+	var n, base int
+	var x, max rune
+	switch r := l.rune(); {
 	case r == 'U':
-		if s := l.rune(); !isHexDigit(s) {
-			return false
-		}
-		if s := l.rune(); !isHexDigit(s) {
-			return false
-		}
-		if s := l.rune(); !isHexDigit(s) {
-			return false
-		}
-		if s := l.rune(); !isHexDigit(s) {
-			return false
-		}
-		fallthrough
-
+		n, base, max = 8, 16, unicode.MaxRune
 	case r == 'u':
-		if s := l.rune(); !isHexDigit(s) {
-			return false
-		}
-		if s := l.rune(); !isHexDigit(s) {
-			return false
-		}
-		fallthrough
-
+		n, base, max = 4, 16, unicode.MaxRune
 	case r == 'x':
-		if s := l.rune(); !isHexDigit(s) {
-			return false
-		}
-		if s := l.rune(); !isHexDigit(s) {
-			return false
-		}
-
+		n, base, max = 2, 16, 255
 	case isOctalDigit(r):
-		if s := l.rune(); !isOctalDigit(s) {
-			return false
-		}
-		if s := l.rune(); !isOctalDigit(s) {
+		n, base, max = 2, 8, 255
+		x = r - '0'
+	case r != 'a' && r != 'b' && r != 'f' && r != 'n' && r != 'r' && r != 't' && r != 'v' && r != '\\' && r != quote:
+		l.Reason = "invalid escape sequence"
+		return false
+	}
+	for i := 0; i < n; i++ {
+		d := digitValue(l.rune())
+		if d >= base {
+			l.Reason = "invalid escape sequence"
 			return false
 		}
-
-	case r != 'a' && r != 'b' && r != 'f' && r != 'n' && r != 'r' && r != 't' && r != 'v' && r != '\\' && r != quote:
+		x = x*rune(base) + rune(d)
+	}
+	if x > max && base == 8 {
+		l.Reason = "octal escape value > 255"
+		return false
+	}
+	if x > max || max == unicode.MaxRune && x >= 0xD800 && x < 0xE000 {
+		l.Reason = "escape sequence is invalid Unicode code point"
 		return false
 	}
 	return true
 }
 
+// DigitValue returns the value of a hexadecimal digit,
+// or 16 if the rune is not a hexadecimal digit.
+func digitValue(r rune) int {
+	switch {
+	case isDecimalDigit(r):
+		return int(r - '0')
+	case r >= 'a' && r <= 'f':
+		return int(r - 'a' + 10)
+	case r >= 'A' && r <= 'F':
+		return int(r - 'A' + 10)
+	}
+	return 16
+}
+
 func isDecimalDigit(r rune) bool {
 	return r >= '0' && r <= '9'
 }
@@ -1,8 +1,14 @@
 package token
 
 import (
+	"errors"
+	"io"
 	"reflect"
+	"strings"
 	"testing"
+	"testing/iotest"
+
+	"github.com/velour/stop/test"
 )
 
 type singleTokenTests []struct {
@@ -12,7 +18,7 @@ type singleTokenTests []struct {
 
 func (tests singleTokenTests) run(t *testing.T) {
 	for i, test := range tests {
-		lex := NewLexer("", test.text)
+		lex := NewLexer(NewFileSet(), "", test.text)
 		got := lex.Next()
 		if got != test.want {
 			t.Errorf("test %d: %s got %s, wanted %v", i, test.text, got, test.want)
@@ -27,7 +33,7 @@ type multiTokenTests []struct {
 
 func (tests multiTokenTests) run(t *testing.T) {
 	for i, test := range tests {
-		lex := NewLexer("", test.text)
+		lex := NewLexer(NewFileSet(), "", test.text)
 		got := make([]Token, 0, len(test.want))
 		for len(got) == 0 || got[len(got)-1] != EOF {
 			got = append(got, lex.Next())
@@ -45,7 +51,7 @@ type locTests []struct {
 
 func (tests locTests) run(t *testing.T, loc func(s, e Location) [2]int) {
 	for i, test := range tests {
-		lex := NewLexer("", test.text)
+		lex := NewLexer(NewFileSet(), "", test.text)
 		got := make([][2]int, 0, len(test.want))
 		for {
 			tok := lex.Next()
@@ -61,7 +67,7 @@ func (tests locTests) run(t *testing.T, loc func(s, e Location) [2]int) {
 }
 
 func TestReplace(t *testing.T) {
-	l := NewLexer("", "αβ")
+	l := NewLexer(NewFileSet(), "", "αβ")
 	if l.rune() != 'α' {
 		t.Fatalf("first rune was not α")
 	}
@@ -84,9 +90,18 @@ func TestError(t *testing.T) {
 		{`*\`, '\\'},
 		{`"\'"`, '\''},
 		{`'\"'`, '"'},
+		{`08`, '8'},
+		{`0b12`, '2'},
+		{`1__0`, '_'},
+		{`1_.0`, '_'},
+		{`0x1_ `, '_'},
+		{`0x;`, ';'},
+		{`0b1.0`, '.'},
+		{`1e+x`, 'x'},
+		{`0x1.0 `, ' '},
 	}
 	for i, test := range tests {
-		lex := NewLexer("", test.text)
+		lex := NewLexer(NewFileSet(), "", test.text)
 		got := Semicolon
 		for got != EOF && got != Error {
 			got = lex.Next()
@@ -100,6 +115,106 @@ func TestError(t *testing.T) {
 	}
 }
 
+func TestErrorReason(t *testing.T) {
+	tests := []struct {
+		text, reason string
+	}{
+		{`"abc`, "string literal not terminated"},
+		{"\x60abc", "raw string literal not terminated"},
+		{"\"abc\n\"", "newline in string"},
+		{`"\z"`, "invalid escape sequence"},
+		{`"\x0z"`, "invalid escape sequence"},
+		{`'\'`, "rune literal not terminated"},
+		{`"\uD800"`, "escape sequence is invalid Unicode code point"},
+		{`'\U00110000'`, "escape sequence is invalid Unicode code point"},
+		{`'\400'`, "octal escape value > 255"},
+		{`"\777"`, "octal escape value > 255"},
+		{`''`, "empty rune literal"},
+		{`'ab'`, "more than one character in rune literal"},
+		{"'\n'", "newline in rune literal"},
+		{`'a`, "rune literal not terminated"},
+		{`/* abc`, "comment not terminated"},
+		{`09`, "invalid digit '9' in octal literal"},
+		{`0b`, "binary literal has no digits"},
+		{`0x`, "hexadecimal literal has no digits"},
+		{`0o1.0`, "invalid radix point in octal literal"},
+		{`0b1e1`, "'e' exponent requires decimal mantissa"},
+		{`1p1`, "'p' exponent requires hexadecimal mantissa"},
+		{`1e+`, "exponent has no digits"},
+		{`0x1.0`, "hexadecimal mantissa requires a 'p' exponent"},
+		{`1__0`, "'_' must separate successive digits"},
+		{`@`, "invalid character"},
+	}
+	for _, test := range tests {
+		lex := NewLexer(NewFileSet(), "", test.text)
+		got := lex.Next()
+		if got != Error || lex.Reason != test.reason {
+			t.Errorf("%s got %s with reason %q, wanted Error with reason %q", test.text, got, lex.Reason, test.reason)
+		}
+	}
+
+	lex := NewLexer(NewFileSet(), "", `"\z" a`)
+	if lex.Next(); lex.Reason == "" {
+		t.Fatalf("no reason for the Error")
+	}
+	for tok := lex.Next(); tok != EOF; tok = lex.Next() {
+		if tok != Error && lex.Reason != "" {
+			t.Errorf("got %s with reason %q, wanted no reason", tok, lex.Reason)
+		}
+	}
+}
+
+func TestErrorEOF(t *testing.T) {
+	lex := NewLexer(NewFileSet(), "", "a @ b\nc")
+	want := []Token{Identifier, Whitespace, Error, EOF, EOF}
+	for i, w := range want {
+		if got := lex.Next(); got != w {
+			t.Errorf("token %d got %s, wanted %s", i, got, w)
+		}
+	}
+}
+
+func TestRecover(t *testing.T) {
+	type tok struct {
+		tok  Token
+		text string
+	}
+	tests := []struct {
+		text string
+		want []tok
+	}{
+		{"a @ b", []tok{{Identifier, "a"}, {Whitespace, " "}, {Error, "@"}, {Whitespace, " "}, {Identifier, "b"}, {Semicolon, "b"}, {EOF, ""}}},
+		{"α\x00β", []tok{{Identifier, "α"}, {Error, "\x00"}, {Identifier, "β"}, {Semicolon, "β"}, {EOF, ""}}},
+		{`"a\zb" c`, []tok{{Error, `"a\zb"`}, {Whitespace, " "}, {Identifier, "c"}, {Semicolon, "c"}, {EOF, ""}}},
+		{`"a\zb\"c" d`, []tok{{Error, `"a\zb\"c"`}, {Whitespace, " "}, {Identifier, "d"}, {Semicolon, "d"}, {EOF, ""}}},
+		{`"\x0"+`, []tok{{Error, `"\x0"`}, {Plus, "+"}, {EOF, ""}}},
+		{`'ab'+`, []tok{{Error, `'ab'`}, {Plus, "+"}, {EOF, ""}}},
+		{`''+`, []tok{{Error, `''`}, {Plus, "+"}, {EOF, ""}}},
+		{`'\U00110000'+`, []tok{{Error, `'\U00110000'`}, {Plus, "+"}, {EOF, ""}}},
+		{"\"abc\nx", []tok{{Error, `"abc`}, {Semicolon, `"abc`}, {Whitespace, "\n"}, {Identifier, "x"}, {Semicolon, "x"}, {EOF, ""}}},
+		{"x = \"abc", []tok{{Identifier, "x"}, {Whitespace, " "}, {Equal, "="}, {Whitespace, " "}, {Error, `"abc`}, {Semicolon, `"abc`}, {EOF, ""}}},
+		{"09+1", []tok{{Error, "09"}, {Plus, "+"}, {IntegerLiteral, "1"}, {Semicolon, "1"}, {EOF, ""}}},
+		{"0x1.8q+1", []tok{{Error, "0x1.8q"}, {Plus, "+"}, {IntegerLiteral, "1"}, {Semicolon, "1"}, {EOF, ""}}},
+		{"1__0;", []tok{{Error, "1__0"}, {Semicolon, ";"}, {EOF, ""}}},
+		{"0x;", []tok{{Error, "0x"}, {Semicolon, ";"}, {EOF, ""}}},
+		{"1e+)", []tok{{Error, "1e+"}, {CloseParen, ")"}, {Semicolon, ")"}, {EOF, ""}}},
+		{"..+", []tok{{Error, ".."}, {Plus, "+"}, {EOF, ""}}},
+		{"/* abc", []tok{{Error, "/* abc"}, {Semicolon, "/* abc"}, {EOF, ""}}},
+	}
+	for i, test := range tests {
+		lex := NewLexer(NewFileSet(), "", test.text)
+		lex.Recover = true
+		var got []tok
+		for len(got) == 0 || got[len(got)-1].tok != EOF {
+			t := lex.Next()
+			got = append(got, tok{t, lex.Text()})
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("test %d: %s got %v, wanted %v", i, test.text, got, test.want)
+		}
+	}
+}
+
 func TestEOF(t *testing.T) {
 	tests := multiTokenTests{
 		{"", []Token{EOF}},
@@ -226,6 +341,7 @@ func TestOperators(t *testing.T) {
 		{":", Colon},
 		{"&^", AndCarrot},
 		{"&^=", AndCarrotEqual},
+		{"~", Tilde},
 
 		{"..*", Error},
 	}
@@ -295,6 +411,26 @@ func TestIntegerLiteral(t *testing.T) {
 		{"0xF", IntegerLiteral},
 		{"0XF", IntegerLiteral},
 		{"9832", IntegerLiteral},
+		{"0b1011", IntegerLiteral},
+		{"0B1", IntegerLiteral},
+		{"0o660", IntegerLiteral},
+		{"0O7", IntegerLiteral},
+		{"1_000_000", IntegerLiteral},
+		{"0_600", IntegerLiteral},
+		{"0x_BadFace", IntegerLiteral},
+		{"0b_1_0", IntegerLiteral},
+
+		{"08", Error},
+		{"0779", Error},
+		{"0b102", Error},
+		{"0o8", Error},
+		{"0x", Error},
+		{"0b", Error},
+		{"0o_", Error},
+		{"1__0", Error},
+		{"1_", Error},
+		{"0_x1", Error},
+		{"0x1_", Error},
 	}
 	tests.run(t)
 }
@@ -310,6 +446,26 @@ func TestFloatLiteral(t *testing.T) {
 		{"1E6", FloatLiteral},
 		{".25", FloatLiteral},
 		{".12345E+5", FloatLiteral},
+		{"089.5", FloatLiteral},
+		{"09e1", FloatLiteral},
+		{"1_000.000_1", FloatLiteral},
+		{"1e1_0", FloatLiteral},
+		{"0x1p-2", FloatLiteral},
+		{"0X1.8P+1", FloatLiteral},
+		{"0x.8p1", FloatLiteral},
+		{"0x1.p0", FloatLiteral},
+		{"0x_1p0", FloatLiteral},
+
+		{"1e", Error},
+		{"1e+", Error},
+		{"1_.5", Error},
+		{"1._5", Error},
+		{"1e_1", Error},
+		{"1p1", Error},
+		{"0x1.8", Error},
+		{"0x.p1", Error},
+		{"0b1.0", Error},
+		{"0o1e1", Error},
 	}
 	tests.run(t)
 }
@@ -325,6 +481,12 @@ func TestImaginaryLiteral(t *testing.T) {
 		{"1E6i", ImaginaryLiteral},
 		{".25i", ImaginaryLiteral},
 		{".12345E+5i", ImaginaryLiteral},
+		{"09i", ImaginaryLiteral},
+		{"0b101i", ImaginaryLiteral},
+		{"0o17i", ImaginaryLiteral},
+		{"0x1Fi", ImaginaryLiteral},
+		{"0x1p-2i", ImaginaryLiteral},
+		{"1_0i", ImaginaryLiteral},
 	}
 	tests.run(t)
 }
@@ -369,10 +531,9 @@ func TestRuneLiteral(t *testing.T) {
 		{`'\Z'`, Error},
 		{`'\0Z'`, Error},
 		{`'\00Z'`, Error},
-
-		// The following two should be errors, but we don't validate the Unicode code points.
-		{"'\\uDFFF'", RuneLiteral},
-		{"'\\U00110000'", RuneLiteral},
+		{"'\\uDFFF'", Error},
+		{"'\\U00110000'", Error},
+		{"'\\400'", Error},
 	}
 	tests.run(t)
 }
@@ -395,10 +556,9 @@ func TestInterpretedStringLiteral(t *testing.T) {
 		{`"\z"`, Error},
 		{`"\'"`, Error},
 		{`"not terminated`, Error},
-
-		// The following two should be errors, but we don`t validate the Unicode code points.
-		{`"\uD800"`, StringLiteral},
-		{`"\U00110000"`, StringLiteral},
+		{`"\uD800"`, Error},
+		{`"\U00110000"`, Error},
+		{`"\777"`, Error},
 	}
 	tests.run(t)
 }
@@ -449,3 +609,171 @@ func TestRuneNumbers(t *testing.T) {
 		return [2]int{start.Rune, end.Rune}
 	})
 }
+
+func TestByteNumbers(t *testing.T) {
+	tests := locTests{
+		{"\n", [][2]int{{1, 2}}},
+		{"hello", [][2]int{{1, 6}, {1, 6}}},
+		{"α", [][2]int{{1, 3}, {1, 3}}},
+		{"αβ", [][2]int{{1, 5}, {1, 5}}},
+		{"α β", [][2]int{{1, 3}, {3, 4}, {4, 6}, {4, 6}}},
+		{"α\nβ", [][2]int{{1, 3}, {1, 3}, {3, 4}, {4, 6}, {4, 6}}},
+		{"\"𝛂\" 1", [][2]int{{1, 7}, {7, 8}, {8, 9}, {8, 9}}},
+	}
+	tests.run(t, func(start, end Location) [2]int {
+		return [2]int{start.Byte, end.Byte}
+	})
+}
+
+// A lexed is a token scanned by a Lexer along with its text and locations.
+type lexed struct {
+	tok          Token
+	text, reason string
+	start, end   [4]int
+}
+
+func lexAll(lex *Lexer) []lexed {
+	loc := func(l Location) [4]int { return [4]int{l.Rune, l.Byte, l.Line, l.LineStart} }
+	var toks []lexed
+	for {
+		tok := lex.Next()
+		toks = append(toks, lexed{tok, lex.Text(), lex.Reason, loc(lex.Start), loc(lex.End)})
+		if tok == EOF {
+			return toks
+		}
+	}
+}
+
+func TestReaderAndBytesLexers(t *testing.T) {
+	tests := []string{
+		"",
+		"a\nb\n",
+		"α := \"𝛂β\"\n\t/* γ */ 'δ' // ε\n",
+		"x := `raw\n\nstring`\n0x1p-2 1_000 0o17 .5e3\n",
+		"x := \"abc\\z\" + 'ab'\n09 @ y..z\n",
+		"/* unterminated",
+		test.Prog,
+		strings.Repeat(test.Prog, 8),
+		"/*" + strings.Repeat("long comment\n", 20000) + "*/",
+	}
+	for _, src := range tests {
+		for _, recover := range []bool{false, true} {
+			str := NewLexer(NewFileSet(), "", src)
+			str.Recover = recover
+			want := lexAll(str)
+
+			bytes := NewBytesLexer(NewFileSet(), "", []byte(src))
+			bytes.Recover = recover
+			if got := lexAll(bytes); !reflect.DeepEqual(got, want) {
+				t.Errorf("%.20q: bytes lexer got %v, wanted %v", src, got, want)
+			}
+
+			for _, r := range []io.Reader{
+				strings.NewReader(src),
+				iotest.OneByteReader(strings.NewReader(src)),
+				iotest.HalfReader(strings.NewReader(src)),
+			} {
+				rdr := NewReaderLexer(NewFileSet(), "", r)
+				rdr.Recover = recover
+				if got := lexAll(rdr); !reflect.DeepEqual(got, want) {
+					t.Errorf("%.20q: reader lexer got %v, wanted %v", src, got, want)
+				}
+				if err := rdr.Err(); err != nil {
+					t.Errorf("%.20q: reader lexer error: %s", src, err)
+				}
+			}
+		}
+	}
+}
+
+func TestReaderLexerError(t *testing.T) {
+	bad := errors.New("bad read")
+	r := io.MultiReader(strings.NewReader("a + b"), iotest.ErrReader(bad))
+	lex := NewReaderLexer(NewFileSet(), "", iotest.OneByteReader(r))
+	var got []Token
+	for tok := lex.Next(); tok != EOF; tok = lex.Next() {
+		got = append(got, tok)
+	}
+	want := []Token{Identifier, Whitespace, Plus, Whitespace, Identifier, Semicolon}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, wanted %v", got, want)
+	}
+	if err := lex.Err(); err != bad {
+		t.Errorf("Err()=%v, wanted %v", err, bad)
+	}
+}
+
+// Only the current token is copied when a reader lexer's buffer fills,
+// and the buffer at least doubles, so a long token takes few allocations.
+func TestReaderLexerLongToken(t *testing.T) {
+	src := "/*" + strings.Repeat("x", 1<<22) + "*/"
+	allocs := testing.AllocsPerRun(1, func() {
+		lex := NewReaderLexer(NewFileSet(), "", strings.NewReader(src))
+		if tok := lex.Next(); tok != Comment || len(lex.Text()) != len(src) {
+			t.Fatalf("got %s of length %d, wanted a Comment of length %d", tok, len(lex.Text()), len(src))
+		}
+	})
+	if allocs > 32 {
+		t.Errorf("got %.0f allocations, wanted at most 32", allocs)
+	}
+}
+
+// The Files of reader lexers grow as the input is read, so many of them
+// fit in a FileSet, even with the 32-bit Pos values of GOARCH=386.
+func TestReaderLexerFileSet(t *testing.T) {
+	fset := NewFileSet()
+	var prev Pos
+	for i := 0; i < 4; i++ {
+		lex := NewReaderLexer(fset, "", strings.NewReader(test.Prog))
+		for tok := lex.Next(); tok != EOF; tok = lex.Next() {
+			p := lex.File().Pos(lex.Start.Rune)
+			if p < prev {
+				t.Fatalf("file %d: Pos(%d)=%d, before %d", i, lex.Start.Rune, p, prev)
+			}
+			if l := fset.Location(p); l != lex.Start {
+				t.Fatalf("file %d: Location(%d)=%#v, want %#v", i, p, l, lex.Start)
+			}
+			prev = p
+		}
+		if err := lex.Err(); err != nil {
+			t.Fatalf("file %d: reader lexer error: %s", i, err)
+		}
+	}
+}
+
+func TestReaderLexerFileNotLast(t *testing.T) {
+	fset := NewFileSet()
+	lex := NewReaderLexer(fset, "", iotest.OneByteReader(strings.NewReader("a + b")))
+	if tok := lex.Next(); tok != Identifier {
+		t.Fatalf("got %s, wanted Identifier", tok)
+	}
+	NewLexer(fset, "", "package b")
+	var got []Token
+	for tok := lex.Next(); tok != EOF; tok = lex.Next() {
+		got = append(got, tok)
+	}
+	if want := []Token{Whitespace, Semicolon}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, wanted %v", got, want)
+	}
+	if err := lex.Err(); err != ErrFileNotLast {
+		t.Errorf("Err()=%v, wanted %v", err, ErrFileNotLast)
+	}
+}
+
+func TestKeywordsAndOperators(t *testing.T) {
+	for text, tok := range keywords {
+		if got := keyword(text); got != tok {
+			t.Errorf("keyword(%q)=%s, want %s", text, got, tok)
+		}
+	}
+	for _, text := range []string{"a", "_", "x1", "Break", "breaks", "brea", "fallthroughs", "gox", "i", "iff", "ma", "α"} {
+		if got := keyword(text); got != Identifier {
+			t.Errorf("keyword(%q)=%s, want Identifier", text, got)
+		}
+	}
+	for text, tok := range operators {
+		if got := NewLexer(NewFileSet(), "", text).Next(); got != tok {
+			t.Errorf("lexing %q got %s, want %s", text, got, tok)
+		}
+	}
+}
@@ -8,10 +8,15 @@ type Location struct {
 	Path string
 	// Rune is the rune offset.
 	Rune int
+	// Byte is the byte offset of the rune. Like Rune, it begins at 1.
+	Byte int
 	// Line is the line number.
 	Line int
 	// LineStart is the rune offset of the first rune on this line.
 	LineStart int
+
+	// File is the File of the location, or nil.
+	file *File
 }
 
 // Column returns the rune offset into the line of a location.
@@ -19,8 +24,20 @@ func (l Location) Column() int {
 	return l.Rune - l.LineStart
 }
 
+// Mapped returns the path, line, and column of a location as remapped
+// by any //line directive in effect at the location. If there is no
+// such directive, they are the Path, Line, and Column of the location.
+func (l Location) Mapped() (path string, line, col int) {
+	if l.file == nil {
+		return l.Path, l.Line, l.Column()
+	}
+	return l.file.remap(l)
+}
+
 // String returns the string representation of a location as an
-// Acme address.
+// Acme address. The address is remapped by any //line directive
+// in effect at the location.
 func (l Location) String() string {
-	return l.Path + ":" + strconv.Itoa(l.Line) + ":" + strconv.Itoa(l.Column())
+	path, line, col := l.Mapped()
+	return path + ":" + strconv.Itoa(line) + ":" + strconv.Itoa(col)
 }
@@ -0,0 +1,308 @@
+package token
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"unicode/utf16"
+)
+
+// A Pos is a compact encoding of a Location. Each file added to a
+// FileSet is assigned a distinct range of Pos values, one for each
+// rune offset in the file, and the Location of a Pos is found by looking
+// up the start of its line in the file of the FileSet to which it belongs.
+//
+// Pos values from different files of a FileSet are ordered by the order
+// in which the files were added to the set, and Pos values within a file
+// are ordered by their rune offset.
+type Pos int
+
+// NoPos is the zero Pos. Its Location is the zero Location.
+const NoPos Pos = 0
+
+// IsValid returns whether the Pos is not NoPos.
+func (p Pos) IsValid() bool { return p != NoPos }
+
+// A FileSet is a set of Files with disjoint ranges of Pos values.
+// The Pos values of the AST nodes and errors of a file can only be
+// located by the FileSet of the lexer that scanned the file.
+//
+// A FileSet may be used concurrently by multiple lexers. Looking up
+// the File of a Pos does not block.
+type FileSet struct {
+	// Mu serializes the addition of files.
+	mu sync.Mutex
+	// Base is the base of the next File.
+	base Pos
+	// Files are the Files in increasing order of base.
+	// A new slice is stored each time a File is added,
+	// so a loaded slice is never modified.
+	files atomic.Pointer[[]*File]
+}
+
+// NewFileSet returns a new, empty FileSet.
+func NewFileSet() *FileSet {
+	return &FileSet{base: 1}
+}
+
+// A File is an entry in a FileSet. It records the starts of the
+// lines of a file as they are scanned, mapping the Pos values of the
+// file back to Locations.
+type File struct {
+	path string
+	// Base is the Pos of the first rune of the file.
+	base Pos
+	// Size is the number of Pos values in the file's range.
+	// It only grows, and only while the file is the last File
+	// of its FileSet; see FileSet.grow.
+	size atomic.Int64
+
+	// Lines are the increasing rune offsets of the line starts,
+	// in the first nlines elements of the loaded slice. They are
+	// appended without locking by the single writer of the file,
+	// so readers load nlines before lines; see AddLine.
+	lines  atomic.Pointer[[]int]
+	nlines atomic.Int64
+	// Lines0 is the initial slice of line starts.
+	lines0 []int
+
+	// Mu guards wide and directives.
+	mu sync.Mutex
+	// Wide are the runes that are encoded in more than one byte,
+	// in increasing order of rune offset.
+	wide []wideRune
+	// Directives are the //line directives of the file,
+	// in increasing order of rune offset.
+	directives []lineDirective
+}
+
+// A wideRune records a rune that is encoded in more than one byte
+// of UTF-8, for converting between rune, byte, and UTF-16 offsets.
+type wideRune struct {
+	// Offset is the rune offset of the rune.
+	offset int
+	// Bytes and units are the number of bytes and UTF-16 code units
+	// beyond one per rune, up to and including this rune.
+	bytes, units int
+}
+
+// AddFile adds a file with the given path to the set and returns it.
+// The size is an upper bound on the number of runes in the file.
+func (s *FileSet) AddFile(path string, size int) *File {
+	return s.addFile(path, size, 1)
+}
+
+// AddFile is like AddFile, but it allocates room in the line table of
+// the File for the given number of lines.
+func (s *FileSet) addFile(path string, size, lines int) *File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f := &File{path: path, base: s.base, lines0: make([]int, max(lines, 1))}
+	f.lines0[0] = 1
+	f.lines.Store(&f.lines0)
+	f.nlines.Store(1)
+	// One more than the size, for the location of the end of the file.
+	f.size.Store(int64(size) + 1)
+	s.base += Pos(size + 1)
+	var files []*File
+	if fs := s.files.Load(); fs != nil {
+		files = *fs
+	}
+	files = append(files[:len(files):len(files)], f)
+	s.files.Store(&files)
+	return f
+}
+
+// File returns the File of the set containing the Pos,
+// or nil if there is none.
+func (s *FileSet) File(p Pos) *File {
+	fs := s.files.Load()
+	if fs == nil {
+		return nil
+	}
+	files := *fs
+	i := sort.Search(len(files), func(i int) bool { return files[i].base > p }) - 1
+	if i < 0 || int64(p-files[i].base) >= files[i].size.Load() {
+		return nil
+	}
+	return files[i]
+}
+
+// Grow extends the range of Pos values of a File of the set by n,
+// returning whether it was extended. A File can only grow while it is
+// the last File of the set, and while the Pos values do not overflow.
+func (s *FileSet) grow(f *File, n int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files := *s.files.Load()
+	if files[len(files)-1] != f || n > math.MaxInt-int(s.base) {
+		return false
+	}
+	f.size.Add(int64(n))
+	s.base += Pos(n)
+	return true
+}
+
+// Location returns the Location of the Pos. If the Pos is not
+// in a File of the set, the zero Location is returned.
+func (s *FileSet) Location(p Pos) Location {
+	if f := s.File(p); f != nil {
+		return f.Location(p)
+	}
+	return Location{}
+}
+
+// Path returns the path of the file.
+func (f *File) Path() string { return f.path }
+
+// Pos returns the Pos of a rune offset in the file. Rune offsets begin at 1,
+// as in the Rune field of a Location.
+func (f *File) Pos(offset int) Pos {
+	if offset < 1 || int64(offset) > f.size.Load() {
+		panic("rune offset out of range")
+	}
+	return f.base + Pos(offset-1)
+}
+
+// AddLine records that a line starts at the given rune offset.
+// Offsets that are not beyond the last recorded line start are ignored.
+//
+// AddLine is called by the Lexer of the file for each line that it scans.
+// It must not be called concurrently with itself, but the Locations of the
+// file may be looked up concurrently with it.
+func (f *File) AddLine(offset int) {
+	ls := *f.lines.Load()
+	n := int(f.nlines.Load())
+	if offset <= ls[n-1] {
+		return
+	}
+	if n == len(ls) {
+		// The new slice is stored before nlines is increased,
+		// so a reader that loads nlines then lines sees all of them.
+		grown := make([]int, 2*n)
+		copy(grown, ls)
+		f.lines.Store(&grown)
+		ls = grown
+	}
+	ls[n] = offset
+	f.nlines.Store(int64(n + 1))
+}
+
+// LineStarts returns the recorded line starts of the file.
+func (f *File) lineStarts() []int {
+	n := f.nlines.Load()
+	return (*f.lines.Load())[:n]
+}
+
+// AddWide records that the rune at the given rune offset is encoded
+// in w > 1 bytes. Offsets that are not beyond the last recorded wide
+// rune are ignored.
+func (f *File) addWide(offset int, r rune, w int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var last wideRune
+	if n := len(f.wide); n > 0 {
+		last = f.wide[n-1]
+	}
+	if offset <= last.offset {
+		return
+	}
+	f.wide = append(f.wide, wideRune{
+		offset: offset,
+		bytes:  last.bytes + w - 1,
+		units:  last.units + utf16.RuneLen(r) - 1,
+	})
+}
+
+// Location returns the Location of a Pos in the file.
+func (f *File) Location(p Pos) Location {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r := int(p-f.base) + 1
+	lines := f.lineStarts()
+	i := sort.Search(len(lines), func(i int) bool { return lines[i] > r }) - 1
+	return Location{
+		Path:      f.path,
+		Rune:      r,
+		Byte:      r + f.extra(r).bytes,
+		Line:      i + 1,
+		LineStart: lines[i],
+		file:      f,
+	}
+}
+
+// Extra returns the number of extra bytes and UTF-16 code units
+// of the wide runes preceding the rune offset.
+func (f *File) extra(offset int) wideRune {
+	i := sort.Search(len(f.wide), func(i int) bool { return f.wide[i].offset >= offset })
+	if i == 0 {
+		return wideRune{}
+	}
+	return f.wide[i-1]
+}
+
+// RuneToByte returns the byte offset of a rune offset in the file.
+// Like rune offsets, byte offsets begin at 1.
+func (f *File) RuneToByte(offset int) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return offset + f.extra(offset).bytes
+}
+
+// RuneToUTF16 returns the UTF-16 code unit offset of a rune offset
+// in the file. Like rune offsets, UTF-16 offsets begin at 1.
+func (f *File) RuneToUTF16(offset int) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return offset + f.extra(offset).units
+}
+
+// ByteToRune returns the rune offset of a byte offset in the file.
+// A byte offset within the encoding of a rune maps to that rune.
+func (f *File) ByteToRune(offset int) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.toRune(offset, func(w wideRune) int { return w.bytes })
+}
+
+// UTF16ToRune returns the rune offset of a UTF-16 code unit offset
+// in the file. An offset within a surrogate pair maps to its rune.
+func (f *File) UTF16ToRune(offset int) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.toRune(offset, func(w wideRune) int { return w.units })
+}
+
+// ToRune returns the rune offset of an offset in units for which
+// extra gives the cumulative count of units beyond one per rune.
+func (f *File) toRune(o int, extra func(wideRune) int) int {
+	// The offset of the ith wide rune in units.
+	start := func(i int) int {
+		if i == 0 {
+			return f.wide[0].offset
+		}
+		return f.wide[i].offset + extra(f.wide[i-1])
+	}
+	i := sort.Search(len(f.wide), func(i int) bool { return start(i) > o })
+	if i == 0 {
+		return o
+	}
+	w := f.wide[i-1]
+	if end := w.offset + extra(w); o <= end {
+		// The offset is within the ith wide rune.
+		return w.offset
+	}
+	return o - extra(w)
+}
+
+// ByteColumn returns the byte offset into the line of a Location in the file.
+func (f *File) ByteColumn(l Location) int {
+	return f.RuneToByte(l.Rune) - f.RuneToByte(l.LineStart)
+}
+
+// UTF16Column returns the UTF-16 code unit offset into the line of
+// a Location in the file.
+func (f *File) UTF16Column(l Location) int {
+	return f.RuneToUTF16(l.Rune) - f.RuneToUTF16(l.LineStart)
+}
@@ -0,0 +1,161 @@
+package token
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/velour/stop/test"
+)
+
+func TestPosLocation(t *testing.T) {
+	tests := []string{
+		"",
+		"\n",
+		"hello",
+		"α\nβ",
+		"a\n\n\tb /* c\nd */ e\n",
+		"`raw\n\nstring`\n+",
+		"\"unterminated\nx",
+		"x .. \n y",
+		"α := \"𝛂β\"\n\t/* γ */ 'δ'",
+		test.Prog,
+	}
+	for _, src := range tests {
+		fset := NewFileSet()
+		lex := NewLexer(fset, "a.go", src)
+		lex.Recover = true
+		for {
+			tok := lex.Next()
+			f := lex.File()
+			for _, l := range []Location{lex.Start, lex.End} {
+				if got := fset.Location(f.Pos(l.Rune)); got != l {
+					t.Errorf("%q: %s Pos(%d).Location()=%#v, want %#v", src, tok, l.Rune, got, l)
+				}
+			}
+			if tok == EOF {
+				break
+			}
+		}
+	}
+}
+
+func TestPosFiles(t *testing.T) {
+	fset := NewFileSet()
+	a := NewLexer(fset, "a.go", "package a\n").File()
+	b := NewLexer(fset, "b.go", "").File()
+	tests := []struct {
+		pos  Pos
+		want Location
+	}{
+		{NoPos, Location{}},
+		{a.Pos(1), Location{Path: "a.go", Rune: 1, Byte: 1, Line: 1, LineStart: 1, file: a}},
+		{a.Pos(10), Location{Path: "a.go", Rune: 10, Byte: 10, Line: 1, LineStart: 1, file: a}},
+		{b.Pos(1), Location{Path: "b.go", Rune: 1, Byte: 1, Line: 1, LineStart: 1, file: b}},
+		{b.Pos(1) + 1, Location{}},
+	}
+	for _, test := range tests {
+		if got := fset.Location(test.pos); got != test.want {
+			t.Errorf("Location(%d)=%#v, want %#v", int(test.pos), got, test.want)
+		}
+	}
+	if a.Pos(11) >= b.Pos(1) {
+		t.Errorf("a.Pos(11)=%d >= b.Pos(1)=%d", a.Pos(11), b.Pos(1))
+	}
+	if got := NewFileSet().Location(a.Pos(1)); got != (Location{}) {
+		t.Errorf("Location(%d) in another FileSet=%#v, want the zero Location", int(a.Pos(1)), got)
+	}
+}
+
+func TestFileSetConcurrent(t *testing.T) {
+	fset := NewFileSet()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lex := NewLexer(fset, "a.go", test.Prog)
+			for tok := lex.Next(); tok != EOF; tok = lex.Next() {
+				if got := fset.Location(lex.File().Pos(lex.Start.Rune)); got != lex.Start {
+					t.Errorf("Location()=%#v, want %#v", got, lex.Start)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Locations are looked up in one goroutine while the lexer of the file
+// adds lines in another. The reader lexer's line table grows as it goes.
+func TestFileLocationWhileLexing(t *testing.T) {
+	fset := NewFileSet()
+	lex := NewReaderLexer(fset, "a.go", strings.NewReader(test.Prog))
+	locs := make(chan Location, 16)
+	go func() {
+		defer close(locs)
+		for tok := lex.Next(); tok != EOF; tok = lex.Next() {
+			locs <- lex.Start
+		}
+	}()
+	for l := range locs {
+		if got := fset.Location(l.file.Pos(l.Rune)); got != l {
+			t.Fatalf("Location()=%#v, want %#v", got, l)
+		}
+	}
+}
+
+func TestPosConversions(t *testing.T) {
+	const src = "aα𝛂\nb"
+	fset := NewFileSet()
+	lex := NewLexer(fset, "", src)
+	for lex.Next() != EOF {
+	}
+	f := lex.File()
+	tests := []struct {
+		rune, byte, utf16 int
+	}{
+		{1, 1, 1},
+		{2, 2, 2},
+		{3, 4, 3},
+		{4, 8, 5},
+		{5, 9, 6},
+		{6, 10, 7},
+	}
+	for _, test := range tests {
+		if b := f.RuneToByte(test.rune); b != test.byte {
+			t.Errorf("RuneToByte(%d)=%d, want %d", test.rune, b, test.byte)
+		}
+		if u := f.RuneToUTF16(test.rune); u != test.utf16 {
+			t.Errorf("RuneToUTF16(%d)=%d, want %d", test.rune, u, test.utf16)
+		}
+		if r := f.ByteToRune(test.byte); r != test.rune {
+			t.Errorf("ByteToRune(%d)=%d, want %d", test.byte, r, test.rune)
+		}
+		if r := f.UTF16ToRune(test.utf16); r != test.rune {
+			t.Errorf("UTF16ToRune(%d)=%d, want %d", test.utf16, r, test.rune)
+		}
+	}
+	// Offsets within the encoding of a rune map to the rune.
+	if r := f.ByteToRune(7); r != 3 {
+		t.Errorf("ByteToRune(7)=%d, want 3", r)
+	}
+	if r := f.UTF16ToRune(4); r != 3 {
+		t.Errorf("UTF16ToRune(4)=%d, want 3", r)
+	}
+
+	p := f.Pos(4)
+	if fset.File(p) != f {
+		t.Errorf("File()=%p, want %p", fset.File(p), f)
+	}
+	l := fset.Location(p)
+	if c := l.Column(); c != 3 {
+		t.Errorf("Column()=%d, want 3", c)
+	}
+	if c := f.ByteColumn(l); c != 7 {
+		t.Errorf("ByteColumn()=%d, want 7", c)
+	}
+	if c := f.UTF16Column(l); c != 4 {
+		t.Errorf("UTF16Column()=%d, want 4", c)
+	}
+}
@@ -0,0 +1,136 @@
+package token
+
+import "strings"
+
+// A Trivia is a run of whitespace or a comment between two tokens.
+type Trivia struct {
+	// Token is either Whitespace or Comment.
+	Token Token
+	// Text is the text of the whitespace or comment.
+	Text string
+}
+
+// A FullToken is a token along with the trivia surrounding it.
+// The source text is the concatenation, in order, of the leading trivia,
+// the text, and the trailing trivia of each token returned by a Stream.
+type FullToken struct {
+	Token Token
+	// Text is the text of the token. It is empty for an inserted
+	// semicolon and for EOF.
+	Text string
+	// Start and End are the locations of the start and end
+	// of the token.
+	Start, End Location
+	// Inserted is true if the token is a semicolon that was
+	// inserted by the lexer.
+	Inserted bool
+	// Reason describes why the token is malformed
+	// if it is an Error token.
+	Reason string
+
+	// Leading is the trivia preceding the token that is not
+	// trailing trivia of the previous token.
+	Leading []Trivia
+	// Trailing is the trivia following the token on the same line,
+	// up to and including the first newline.
+	Trailing []Trivia
+}
+
+// Source returns the text of the token with its leading and trailing trivia.
+func (t FullToken) Source() string {
+	var s []string
+	for _, tr := range t.Leading {
+		s = append(s, tr.Text)
+	}
+	s = append(s, t.Text)
+	for _, tr := range t.Trailing {
+		s = append(s, tr.Text)
+	}
+	return strings.Join(s, "")
+}
+
+// A Stream returns the tokens scanned by a Lexer along with their trivia,
+// so that the source can be reproduced exactly from the tokens.
+// The parser returned by ast.NewStreamParser reads from a Stream and
+// keeps its tokens, so that the trivia of each node can be recovered.
+type Stream struct {
+	lex *Lexer
+
+	// Peek is a token that was scanned while reading the trailing
+	// trivia of the previous token, or nil.
+	peek *FullToken
+
+	// Leading is the leading trivia of the next token that was
+	// scanned while reading the trailing trivia of the previous token.
+	leading []Trivia
+}
+
+// NewStream returns a new Stream that reads tokens from the Lexer.
+// The Lexer is put in recovering mode, so that the text of malformed
+// tokens is kept as the text of Error tokens.
+func NewStream(lex *Lexer) *Stream {
+	lex.Recover = true
+	return &Stream{lex: lex}
+}
+
+// Next returns the next non-trivia token, including inserted semicolons.
+// Once an EOF token is returned, all subsequent tokens will be of type EOF.
+// The leading trivia of the EOF token is the trivia at the end of the file.
+func (s *Stream) Next() FullToken {
+	var t FullToken
+	if s.peek != nil {
+		t, s.peek = *s.peek, nil
+	} else {
+		for t = s.scan(); t.Token == Whitespace || t.Token == Comment; t = s.scan() {
+			s.leading = append(s.leading, Trivia{Token: t.Token, Text: t.Text})
+		}
+	}
+	t.Leading, s.leading = s.leading, nil
+	if t.Token == EOF {
+		return t
+	}
+
+	for {
+		u := s.scan()
+		if u.Token != Whitespace && u.Token != Comment {
+			s.peek = &u
+			return t
+		}
+		i := strings.IndexByte(u.Text, '\n')
+		switch {
+		case i < 0:
+			t.Trailing = append(t.Trailing, Trivia{Token: u.Token, Text: u.Text})
+			continue
+		case u.Token == Whitespace && i < len(u.Text)-1:
+			// The whitespace following the newline
+			// leads the next token.
+			t.Trailing = append(t.Trailing, Trivia{Token: Whitespace, Text: u.Text[:i+1]})
+			s.leading = append(s.leading, Trivia{Token: Whitespace, Text: u.Text[i+1:]})
+		default:
+			t.Trailing = append(t.Trailing, Trivia{Token: u.Token, Text: u.Text})
+		}
+		return t
+	}
+}
+
+// Scan returns the next token from the lexer, which may be trivia.
+func (s *Stream) scan() FullToken {
+	tok := s.lex.Next()
+	t := FullToken{
+		Token:  tok,
+		Text:   s.lex.Text(),
+		Start:  s.lex.Start,
+		End:    s.lex.End,
+		Reason: s.lex.Reason,
+	}
+	switch {
+	case tok == EOF:
+		t.Text = ""
+	case tok == Semicolon && t.Text != ";":
+		// The text of an inserted semicolon
+		// is that of the preceding token.
+		t.Text = ""
+		t.Inserted = true
+	}
+	return t
+}
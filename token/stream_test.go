@@ -0,0 +1,69 @@
+package token
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/velour/stop/test"
+)
+
+func TestStreamRoundTrip(t *testing.T) {
+	tests := []string{
+		"",
+		"\n",
+		"package a",
+		"package a\n",
+		"package a // comment\n\n// Doc.\nfunc f() {\n\treturn\n}\n",
+		"a /* one */ /* two\n */ b\n\t\n",
+		"x := \"abc\\z\" + 'ab'\n09 @ y\n",
+		"x := \"unterminated\ny := `raw\n\nstring`\n",
+		"/* unterminated",
+		test.Prog,
+	}
+	for _, src := range tests {
+		s := NewStream(NewLexer(NewFileSet(), "", src))
+		var got []string
+		for {
+			t := s.Next()
+			got = append(got, t.Source())
+			if t.Token == EOF {
+				break
+			}
+		}
+		if g := strings.Join(got, ""); g != src {
+			t.Errorf("round trip of %q got %q", src, g)
+		}
+	}
+}
+
+func TestStreamTrivia(t *testing.T) {
+	const src = "// Doc.\nx = 1 // c\n\t/* d */ y  \n"
+	want := []FullToken{
+		{
+			Token:    Identifier,
+			Text:     "x",
+			Leading:  []Trivia{{Comment, "// Doc.\n"}},
+			Trailing: []Trivia{{Whitespace, " "}},
+		},
+		{Token: Equal, Text: "=", Trailing: []Trivia{{Whitespace, " "}}},
+		{Token: IntegerLiteral, Text: "1", Trailing: []Trivia{{Whitespace, " "}}},
+		{Token: Semicolon, Inserted: true, Trailing: []Trivia{{Comment, "// c\n"}}},
+		{
+			Token:    Identifier,
+			Text:     "y",
+			Leading:  []Trivia{{Whitespace, "\t"}, {Comment, "/* d */"}, {Whitespace, " "}},
+			Trailing: nil,
+		},
+		{Token: Semicolon, Inserted: true, Trailing: []Trivia{{Whitespace, "  \n"}}},
+		{Token: EOF},
+	}
+	s := NewStream(NewLexer(NewFileSet(), "", src))
+	for i, w := range want {
+		got := s.Next()
+		got.Start, got.End = Location{}, Location{}
+		if !reflect.DeepEqual(got, w) {
+			t.Errorf("token %d got %+v, wanted %+v", i, got, w)
+		}
+	}
+}
@@ -93,6 +93,7 @@ const (
 	Colon
 	AndCarrot
 	AndCarrotEqual
+	Tilde
 
 	EOF Token = -1
 )
@@ -180,6 +181,7 @@ var tokenNames = map[Token]string{
 	Colon:               ":",
 	AndCarrot:           "&^",
 	AndCarrotEqual:      "&^=",
+	Tilde:               "~",
 }
 
 // String returns the string represenation of a token.
@@ -264,4 +266,63 @@ var operators = map[string]Token{
 	":":   Colon,
 	"&^":  AndCarrot,
 	"&^=": AndCarrotEqual,
+	"~":   Tilde,
+}
+
+// A tableEntry is an entry in a perfect hash table of token text.
+type tableEntry struct {
+	text string
+	tok  Token
+}
+
+// KeywordTable and operatorTable are perfect hash tables of the keywords
+// and operators, indexed by keywordHash and operatorHash respectively.
+// Each entry is either empty or holds the text of its token, which must
+// be compared to determine whether a string is in the table.
+var (
+	keywordTable  [64]tableEntry
+	operatorTable [128]tableEntry
+)
+
+func init() {
+	for text, tok := range keywords {
+		addEntry(keywordTable[:], keywordHash(text), text, tok)
+	}
+	for text, tok := range operators {
+		addEntry(operatorTable[:], operatorHash(text), text, tok)
+	}
+}
+
+// AddEntry adds an entry to a perfect hash table,
+// panicking if the hash is not perfect.
+func addEntry(table []tableEntry, h int, text string, tok Token) {
+	if table[h].text != "" {
+		panic("hash collision: " + text + " and " + table[h].text)
+	}
+	table[h] = tableEntry{text: text, tok: tok}
+}
+
+// KeywordHash returns the hash of a string of at least two bytes that
+// is perfect over the keywords.
+func keywordHash(s string) int {
+	return int((uint(s[0])<<4 ^ uint(s[1]) + uint(len(s))) & 63)
+}
+
+// OperatorHash returns the hash of a non-empty string that is perfect
+// over the operators.
+func operatorHash(s string) int {
+	return int((uint(s[0]) + 6*uint(s[len(s)-1]) + 45*uint(len(s))) & 127)
+}
+
+// Keyword returns the keyword token of a string, or Identifier if
+// the string is not a keyword.
+func keyword(s string) Token {
+	// All keywords are lowercase and between 2 and 11 bytes long.
+	if len(s) < 2 || len(s) > 11 || s[0] < 'a' || s[0] > 'z' {
+		return Identifier
+	}
+	if e := keywordTable[keywordHash(s)]; e.text == s {
+		return e.tok
+	}
+	return Identifier
 }